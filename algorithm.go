@@ -65,9 +65,16 @@ const (
 
 // Hash algorithms by RFC 9054.
 const (
+	// SHA-256 truncated to 64 bits by RFC 9054.
+	AlgorithmSHA256_64 Algorithm = -15
+
 	// SHA-256 by RFC 9054.
 	AlgorithmSHA256 Algorithm = -16
 
+	// SHA-512 truncated to 256 bits by RFC 9054.
+	// Requires an available crypto.SHA512_256.
+	AlgorithmSHA512_256 Algorithm = -17
+
 	// SHA-384 by RFC 9054.
 	AlgorithmSHA384 Algorithm = -43
 
@@ -114,8 +121,12 @@ func (a Algorithm) String() string {
 		return "EdDSA"
 	case AlgorithmReserved:
 		return "Reserved"
+	case AlgorithmSHA256_64:
+		return "SHA-256/64"
 	case AlgorithmSHA256:
 		return "SHA-256"
+	case AlgorithmSHA512_256:
+		return "SHA-512/256"
 	case AlgorithmSHA384:
 		return "SHA-384"
 	case AlgorithmSHA512:
@@ -135,6 +146,8 @@ func (a Algorithm) hashFunc() crypto.Hash {
 		return crypto.SHA384
 	case AlgorithmPS512, AlgorithmES512, AlgorithmSHA512:
 		return crypto.SHA512
+	case AlgorithmSHA512_256:
+		return crypto.SHA512_256
 	default:
 		return 0
 	}
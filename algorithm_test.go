@@ -26,7 +26,9 @@ func TestAlgorithm_String(t *testing.T) {
 		{AlgorithmES512, "ES512"},
 		{AlgorithmEdDSA, "EdDSA"},
 		{AlgorithmReserved, "Reserved"},
+		{AlgorithmSHA256_64, "SHA-256/64"},
 		{AlgorithmSHA256, "SHA-256"},
+		{AlgorithmSHA512_256, "SHA-512/256"},
 		{AlgorithmSHA384, "SHA-384"},
 		{AlgorithmSHA512, "SHA-512"},
 		{7, "Algorithm(7)"},
@@ -56,9 +58,11 @@ func TestAlgorithm_hashFunc(t *testing.T) {
 		{AlgorithmES512, crypto.SHA512},
 		{AlgorithmEdDSA, 0},
 		{AlgorithmReserved, 0},
+		{AlgorithmSHA256_64, 0}, // truncated crypto.SHA256
 		{AlgorithmSHA256, crypto.SHA256},
 		{AlgorithmSHA384, crypto.SHA384},
 		{AlgorithmSHA512, crypto.SHA512},
+		{AlgorithmSHA512_256, crypto.SHA512_256},
 		{7, 0},
 	}
 	for _, tt := range tests {
@@ -0,0 +1,1191 @@
+package cose
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// C509 certificate types.
+//
+// Reference: https://datatracker.ietf.org/doc/html/draft-ietf-cose-cbor-encoded-cert-12#section-3.1
+const (
+	// C509TypeNative is the type of natively signed C509 certificates, whose
+	// signature is computed over the CBOR encoded TBSCertificate.
+	C509TypeNative int64 = 2
+
+	// C509TypeReencoded is the type of CBOR re-encoded X.509 certificates,
+	// whose signature is computed over the DER encoded X.509
+	// TBSCertificate.
+	C509TypeReencoded int64 = 3
+)
+
+// C509Certificate is a CBOR encoded certificate.
+//
+//	C509Certificate = [
+//	    TBSCertificate,
+//	    issuerSignatureValue : any,
+//	]
+//
+//	TBSCertificate = (
+//	    c509CertificateType: int,
+//	    certificateSerialNumber: CertificateSerialNumber,
+//	    issuerSignatureAlgorithm: AlgorithmIdentifier,
+//	    issuer: Name / null,
+//	    validityNotBefore: ~time,
+//	    validityNotAfter: ~time / null,
+//	    subject: Name,
+//	    subjectPublicKeyAlgorithm: AlgorithmIdentifier,
+//	    subjectPublicKey: any,
+//	    extensions: Extensions,
+//	)
+//
+// This implementation supports the following subset of C509:
+//   - ECDSA and Ed25519 issuer signature algorithms;
+//   - P-256, P-384, P-521 and Ed25519 subject public keys;
+//   - names made of the common name, serial number, country, locality, state
+//     or province, organization, and organizational unit attributes, one per
+//     relative distinguished name;
+//   - extensions encoded with their object identifier.
+//
+// Reference: https://datatracker.ietf.org/doc/html/draft-ietf-cose-cbor-encoded-cert-12
+//
+// # Experimental
+//
+// Notice: The C509 API is EXPERIMENTAL and may be changed or removed in a
+// later release.
+type C509Certificate struct {
+	// Raw is the CBOR encoded C509Certificate.
+	Raw []byte
+
+	// Type is the C509 certificate type, C509TypeNative or
+	// C509TypeReencoded.
+	Type int64
+
+	// Certificate is the X.509 certificate with the same content.
+	//
+	// For CBOR re-encoded X.509 certificates, Certificate.Raw is the
+	// original DER encoded certificate, and its signature is valid.
+	// For natively signed C509 certificates, Certificate is only a view of
+	// the content of the certificate, as its signature is computed over
+	// the CBOR encoded TBSCertificate.
+	Certificate *x509.Certificate
+
+	tbs       []byte    // CBOR encoded TBSCertificate, as a CBOR sequence
+	signature []byte    // issuerSignatureValue
+	algorithm Algorithm // issuerSignatureAlgorithm
+}
+
+// c509Certificate is the CBOR representation of a C509Certificate.
+type c509Certificate struct {
+	_                         struct{} `cbor:",toarray"`
+	Type                      int64
+	SerialNumber              []byte
+	IssuerSignatureAlgorithm  int64
+	Issuer                    cbor.RawMessage
+	NotBefore                 int64
+	NotAfter                  *int64
+	Subject                   cbor.RawMessage
+	SubjectPublicKeyAlgorithm int64
+	SubjectPublicKey          []byte
+	Extensions                cbor.RawMessage
+	SignatureValue            []byte
+}
+
+// c509SignatureAlgorithm is an entry of the C509 signature algorithms
+// registry.
+type c509SignatureAlgorithm struct {
+	id        int64
+	x509      x509.SignatureAlgorithm
+	oid       asn1.ObjectIdentifier
+	algorithm Algorithm
+	size      int // minimal size of r and s for ECDSA signatures
+}
+
+// c509SignatureAlgorithms are the supported C509 signature algorithms.
+var c509SignatureAlgorithms = []c509SignatureAlgorithm{
+	{0, x509.ECDSAWithSHA256, asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}, AlgorithmES256, 32},
+	{1, x509.ECDSAWithSHA384, asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 3}, AlgorithmES384, 48},
+	{2, x509.ECDSAWithSHA512, asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 4}, AlgorithmES512, 66},
+	{12, x509.PureEd25519, asn1.ObjectIdentifier{1, 3, 101, 112}, AlgorithmEdDSA, 0},
+}
+
+// c509PublicKeyAlgorithm is an entry of the C509 public key algorithms
+// registry.
+type c509PublicKeyAlgorithm struct {
+	id    int64
+	curve elliptic.Curve // nil for Ed25519
+	oid   asn1.ObjectIdentifier
+}
+
+var (
+	oidPublicKeyECDSA   = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+	oidPublicKeyEd25519 = asn1.ObjectIdentifier{1, 3, 101, 112}
+)
+
+// c509PublicKeyAlgorithms are the supported C509 public key algorithms.
+// The object identifiers are the named curves of EC public keys.
+var c509PublicKeyAlgorithms = []c509PublicKeyAlgorithm{
+	{1, elliptic.P256(), asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7}},
+	{2, elliptic.P384(), asn1.ObjectIdentifier{1, 3, 132, 0, 34}},
+	{3, elliptic.P521(), asn1.ObjectIdentifier{1, 3, 132, 0, 35}},
+	{10, nil, oidPublicKeyEd25519},
+}
+
+// c509Attributes are the supported C509 name attributes.
+var c509Attributes = []struct {
+	id  int64
+	oid asn1.ObjectIdentifier
+}{
+	{1, asn1.ObjectIdentifier{2, 5, 4, 3}},  // commonName
+	{3, asn1.ObjectIdentifier{2, 5, 4, 5}},  // serialNumber
+	{4, asn1.ObjectIdentifier{2, 5, 4, 6}},  // countryName
+	{5, asn1.ObjectIdentifier{2, 5, 4, 7}},  // localityName
+	{6, asn1.ObjectIdentifier{2, 5, 4, 8}},  // stateOrProvinceName
+	{8, asn1.ObjectIdentifier{2, 5, 4, 10}}, // organizationName
+	{9, asn1.ObjectIdentifier{2, 5, 4, 11}}, // organizationalUnitName
+}
+
+// c509NoExpiration is the validityNotAfter of certificates without
+// well-defined expiration date, encoded as null.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc5280.html#section-4.1.2.5
+var c509NoExpiration = time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)
+
+// ParseC509Certificate parses a CBOR encoded C509Certificate.
+//
+// # Experimental
+//
+// Notice: The C509 API is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func ParseC509Certificate(data []byte) (*C509Certificate, error) {
+	var items []cbor.RawMessage
+	if err := decMode.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("c509: %w", err)
+	}
+	if len(items) != 11 {
+		return nil, errors.New("c509: require array of 11 elements")
+	}
+	var raw c509Certificate
+	if err := decMode.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("c509: %w", err)
+	}
+	if raw.Type != C509TypeNative && raw.Type != C509TypeReencoded {
+		return nil, fmt.Errorf("c509: unsupported certificate type: %d", raw.Type)
+	}
+	sigAlg, err := c509SignatureAlgorithmByID(raw.IssuerSignatureAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	der, err := raw.toDER(sigAlg)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("c509: %w", err)
+	}
+	return &C509Certificate{
+		Raw:         data,
+		Type:        raw.Type,
+		Certificate: cert,
+		tbs:         bytes.Join(byteSlices(items[:10]), nil),
+		signature:   raw.SignatureValue,
+		algorithm:   sigAlg.algorithm,
+	}, nil
+}
+
+// byteSlices converts raw messages into byte slices.
+func byteSlices(items []cbor.RawMessage) [][]byte {
+	s := make([][]byte, len(items))
+	for i, item := range items {
+		s[i] = item
+	}
+	return s
+}
+
+// NewC509Certificate re-encodes a X.509 certificate as a C509 certificate of
+// type C509TypeReencoded.
+// It fails if the certificate cannot be re-encoded without loss.
+//
+// # Experimental
+//
+// Notice: The C509 API is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func NewC509Certificate(cert *x509.Certificate) (*C509Certificate, error) {
+	raw, _, err := c509FromX509(cert)
+	if err != nil {
+		return nil, err
+	}
+	raw.Type = C509TypeReencoded
+	data, err := encMode.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	c, err := ParseC509Certificate(data)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(c.Certificate.Raw, cert.Raw) {
+		return nil, errors.New("c509: certificate cannot be re-encoded without loss")
+	}
+	return c, nil
+}
+
+// CreateC509Certificate creates a natively signed C509 certificate based on a
+// template, as x509.CreateCertificate does for X.509 certificates.
+// The certificate is issued by parent, or self-signed if parent is equal to
+// template.
+//
+// # Experimental
+//
+// Notice: The C509 API is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func CreateC509Certificate(rand io.Reader, template, parent *x509.Certificate, pub crypto.PublicKey, priv crypto.Signer) (*C509Certificate, error) {
+	der, err := x509.CreateCertificate(rand, template, parent, pub, priv)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	raw, sigAlg, err := c509FromX509(cert)
+	if err != nil {
+		return nil, err
+	}
+	raw.Type = C509TypeNative
+
+	// sign the CBOR encoded TBSCertificate
+	data, err := encMode.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var items []cbor.RawMessage
+	if err := decMode.Unmarshal(data, &items); err != nil {
+		return nil, err
+	}
+	signer, err := NewSigner(sigAlg.algorithm, priv)
+	if err != nil {
+		return nil, fmt.Errorf("c509: %w", err)
+	}
+	raw.SignatureValue, err = signer.Sign(rand, bytes.Join(byteSlices(items[:10]), nil))
+	if err != nil {
+		return nil, err
+	}
+	data, err = encMode.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	return ParseC509Certificate(data)
+}
+
+// CheckSignatureFrom verifies that the signature of the certificate is a
+// valid signature from parent.
+//
+// # Experimental
+//
+// Notice: The C509 API is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func (c *C509Certificate) CheckSignatureFrom(parent *C509Certificate) error {
+	if c.Type == C509TypeReencoded {
+		return c.Certificate.CheckSignatureFrom(parent.Certificate)
+	}
+
+	// apply the same constraints as x509.Certificate.CheckSignatureFrom
+	p := parent.Certificate
+	if !p.BasicConstraintsValid || !p.IsCA {
+		return x509.ConstraintViolationError{}
+	}
+	if p.KeyUsage != 0 && p.KeyUsage&x509.KeyUsageCertSign == 0 {
+		return x509.ConstraintViolationError{}
+	}
+	verifier, err := NewVerifier(c.algorithm, p.PublicKey)
+	if err != nil {
+		return fmt.Errorf("c509: %w", err)
+	}
+	return verifier.Verify(c.tbs, c.signature)
+}
+
+// C5B returns the bag of C509 certificates in the protected header, or nil if
+// the c5b header parameter is not present.
+//
+// Reference: https://datatracker.ietf.org/doc/html/draft-ietf-cose-cbor-encoded-cert-12#section-9
+//
+// # Experimental
+//
+// Notice: The C509 API is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func (h ProtectedHeader) C5B() ([]*C509Certificate, error) {
+	return headerC509Certificates(h, true, HeaderLabelC5B)
+}
+
+// SetC5B sets the bag of C509 certificates in the protected header.
+//
+// Reference: https://datatracker.ietf.org/doc/html/draft-ietf-cose-cbor-encoded-cert-12#section-9
+//
+// # Experimental
+//
+// Notice: The C509 API is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func (h ProtectedHeader) SetC5B(certs []*C509Certificate) error {
+	return setHeaderC509Certificates(h, true, HeaderLabelC5B, certs)
+}
+
+// C5C returns the chain of C509 certificates in the protected header, or nil
+// if the c5c header parameter is not present.
+// The first certificate is the end-entity certificate.
+//
+// Reference: https://datatracker.ietf.org/doc/html/draft-ietf-cose-cbor-encoded-cert-12#section-9
+//
+// # Experimental
+//
+// Notice: The C509 API is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func (h ProtectedHeader) C5C() ([]*C509Certificate, error) {
+	return headerC509Certificates(h, true, HeaderLabelC5C)
+}
+
+// SetC5C sets the chain of C509 certificates in the protected header.
+// The first certificate must be the end-entity certificate.
+//
+// Reference: https://datatracker.ietf.org/doc/html/draft-ietf-cose-cbor-encoded-cert-12#section-9
+//
+// # Experimental
+//
+// Notice: The C509 API is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func (h ProtectedHeader) SetC5C(certs []*C509Certificate) error {
+	return setHeaderC509Certificates(h, true, HeaderLabelC5C, certs)
+}
+
+// C5B returns the bag of C509 certificates in the unprotected header, or nil
+// if the c5b header parameter is not present.
+//
+// Reference: https://datatracker.ietf.org/doc/html/draft-ietf-cose-cbor-encoded-cert-12#section-9
+//
+// # Experimental
+//
+// Notice: The C509 API is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func (h UnprotectedHeader) C5B() ([]*C509Certificate, error) {
+	return headerC509Certificates(h, false, HeaderLabelC5B)
+}
+
+// SetC5B sets the bag of C509 certificates in the unprotected header.
+//
+// Reference: https://datatracker.ietf.org/doc/html/draft-ietf-cose-cbor-encoded-cert-12#section-9
+//
+// # Experimental
+//
+// Notice: The C509 API is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func (h UnprotectedHeader) SetC5B(certs []*C509Certificate) error {
+	return setHeaderC509Certificates(h, false, HeaderLabelC5B, certs)
+}
+
+// C5C returns the chain of C509 certificates in the unprotected header, or
+// nil if the c5c header parameter is not present.
+// The first certificate is the end-entity certificate.
+//
+// Reference: https://datatracker.ietf.org/doc/html/draft-ietf-cose-cbor-encoded-cert-12#section-9
+//
+// # Experimental
+//
+// Notice: The C509 API is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func (h UnprotectedHeader) C5C() ([]*C509Certificate, error) {
+	return headerC509Certificates(h, false, HeaderLabelC5C)
+}
+
+// SetC5C sets the chain of C509 certificates in the unprotected header.
+// The first certificate must be the end-entity certificate.
+//
+// Reference: https://datatracker.ietf.org/doc/html/draft-ietf-cose-cbor-encoded-cert-12#section-9
+//
+// # Experimental
+//
+// Notice: The C509 API is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func (h UnprotectedHeader) SetC5C(certs []*C509Certificate) error {
+	return setHeaderC509Certificates(h, false, HeaderLabelC5C, certs)
+}
+
+// C5B returns the bag of C509 certificates from the protected or the
+// unprotected header, or nil if the c5b header parameter is not present.
+// It fails with ErrDuplicateHeaderParameter if both headers contain a bag of
+// certificates.
+//
+// # Experimental
+//
+// Notice: The C509 API is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func (h *Headers) C5B() ([]*C509Certificate, error) {
+	if err := h.ensureSingleBucket(HeaderLabelC5B); err != nil {
+		return nil, err
+	}
+	if hasLabel(h.Protected, HeaderLabelC5B) {
+		return h.Protected.C5B()
+	}
+	return h.Unprotected.C5B()
+}
+
+// SetC5B sets the bag of C509 certificates in the unprotected header, as the
+// certificates are only hints to build a chain.
+// It fails with ErrDuplicateHeaderParameter if the protected header contains
+// a bag of certificates.
+//
+// # Experimental
+//
+// Notice: The C509 API is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func (h *Headers) SetC5B(certs []*C509Certificate) error {
+	if hasLabel(h.Protected, HeaderLabelC5B) {
+		return fmt.Errorf("header parameter: c5b: %w", ErrDuplicateHeaderParameter)
+	}
+	if h.Unprotected == nil {
+		h.Unprotected = make(UnprotectedHeader)
+	}
+	return h.Unprotected.SetC5B(certs)
+}
+
+// C5C returns the chain of C509 certificates from the protected or the
+// unprotected header, or nil if the c5c header parameter is not present.
+// It fails with ErrDuplicateHeaderParameter if both headers contain a chain
+// of certificates.
+//
+// # Experimental
+//
+// Notice: The C509 API is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func (h *Headers) C5C() ([]*C509Certificate, error) {
+	if err := h.ensureSingleBucket(HeaderLabelC5C); err != nil {
+		return nil, err
+	}
+	if hasLabel(h.Protected, HeaderLabelC5C) {
+		return h.Protected.C5C()
+	}
+	return h.Unprotected.C5C()
+}
+
+// SetC5C sets the chain of C509 certificates in the protected header, so that
+// the signature binds the signer to its certificate.
+// It fails with ErrDuplicateHeaderParameter if the unprotected header contains
+// a chain of certificates.
+//
+// # Experimental
+//
+// Notice: The C509 API is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func (h *Headers) SetC5C(certs []*C509Certificate) error {
+	if hasLabel(h.Unprotected, HeaderLabelC5C) {
+		return fmt.Errorf("header parameter: c5c: %w", ErrDuplicateHeaderParameter)
+	}
+	if h.Protected == nil {
+		h.Protected = make(ProtectedHeader)
+	}
+	return h.Protected.SetC5C(certs)
+}
+
+// C5T returns the thumbprint of the C509 certificate of the signer from the
+// protected or the unprotected header, or nil if the c5t header parameter is
+// not present.
+// It fails with ErrDuplicateHeaderParameter if both headers contain a
+// thumbprint.
+//
+// # Experimental
+//
+// Notice: The C509 API is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func (h *Headers) C5T() (*CertHash, error) {
+	if err := h.ensureSingleBucket(HeaderLabelC5T); err != nil {
+		return nil, err
+	}
+	if hasLabel(h.Protected, HeaderLabelC5T) {
+		return headerCertHash(h.Protected, true, HeaderLabelC5T)
+	}
+	return headerCertHash(h.Unprotected, false, HeaderLabelC5T)
+}
+
+// SetC5T sets the thumbprint of the C509 certificate of the signer in the
+// protected header.
+// It fails with ErrDuplicateHeaderParameter if the unprotected header contains
+// a thumbprint.
+//
+// # Experimental
+//
+// Notice: The C509 API is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func (h *Headers) SetC5T(hash *CertHash) error {
+	if hasLabel(h.Unprotected, HeaderLabelC5T) {
+		return fmt.Errorf("header parameter: c5t: %w", ErrDuplicateHeaderParameter)
+	}
+	if h.Protected == nil {
+		h.Protected = make(ProtectedHeader)
+	}
+	return setHeaderCertHash(h.Protected, true, HeaderLabelC5T, hash)
+}
+
+// C5U returns the URI of the C509 certificates of the signer from the
+// protected or the unprotected header, or an empty string if the c5u header
+// parameter is not present.
+// It fails with ErrDuplicateHeaderParameter if both headers contain a URI.
+//
+// # Experimental
+//
+// Notice: The C509 API is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func (h *Headers) C5U() (string, error) {
+	if err := h.ensureSingleBucket(HeaderLabelC5U); err != nil {
+		return "", err
+	}
+	if hasLabel(h.Protected, HeaderLabelC5U) {
+		return headerTstr(h.Protected, true, HeaderLabelC5U)
+	}
+	return headerTstr(h.Unprotected, false, HeaderLabelC5U)
+}
+
+// SetC5U sets the URI of the C509 certificates of the signer in the protected
+// header.
+// It fails with ErrDuplicateHeaderParameter if the unprotected header contains
+// a URI.
+//
+// # Experimental
+//
+// Notice: The C509 API is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func (h *Headers) SetC5U(uri string) error {
+	if hasLabel(h.Unprotected, HeaderLabelC5U) {
+		return fmt.Errorf("header parameter: c5u: %w", ErrDuplicateHeaderParameter)
+	}
+	if h.Protected == nil {
+		h.Protected = make(ProtectedHeader)
+	}
+	return setHeaderParameter(h.Protected, true, HeaderLabelC5U, uri)
+}
+
+// headerC509Certificates returns the parsed certificates of a c5b or a c5c
+// header parameter.
+func headerC509Certificates(h map[any]any, protected bool, label int64) ([]*C509Certificate, error) {
+	value, ok, err := headerParameter(h, protected, label)
+	if !ok {
+		return nil, err
+	}
+	raw, _ := coseX509(value)
+	certs := make([]*C509Certificate, 0, len(raw))
+	for _, data := range raw {
+		cert, err := ParseC509Certificate(data)
+		if err != nil {
+			return nil, fmt.Errorf("header parameter: %s: %w", headerLabelName(label), err)
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
+
+// setHeaderC509Certificates sets a c5b or a c5c header parameter.
+// A single certificate is encoded as a bstr, multiple certificates as an array
+// of bstr.
+func setHeaderC509Certificates(h map[any]any, protected bool, label int64, certs []*C509Certificate) error {
+	var value any
+	switch len(certs) {
+	case 0:
+		return fmt.Errorf("header parameter: %s: require at least one certificate", headerLabelName(label))
+	case 1:
+		value = certs[0].Raw
+	default:
+		raw := make([]any, 0, len(certs))
+		for _, cert := range certs {
+			raw = append(raw, cert.Raw)
+		}
+		value = raw
+	}
+	return setHeaderParameter(h, protected, label, value)
+}
+
+// NewC509CertHash computes the thumbprint of a C509 certificate with the hash
+// algorithm, for the c5t header parameter.
+//
+// # Experimental
+//
+// Notice: The C509 API is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func NewC509CertHash(alg Algorithm, cert *C509Certificate) (*CertHash, error) {
+	value, err := certHashValue(alg, cert.Raw)
+	if err != nil {
+		return nil, err
+	}
+	return &CertHash{
+		HashAlgorithm: alg,
+		HashValue:     value,
+	}, nil
+}
+
+// MatchC509 reports whether the thumbprint identifies the C509 certificate.
+//
+// # Experimental
+//
+// Notice: The C509 API is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func (h *CertHash) MatchC509(cert *C509Certificate) (bool, error) {
+	return h.match(cert.Raw)
+}
+
+// verifyC509Chain verifies the C509 certificate chain of the headers, and
+// returns the verified chain with a verifier for the public key of the
+// end-entity certificate.
+//
+// Chains of CBOR re-encoded X.509 certificates are verified as X.509
+// certificate chains. Chains including natively signed certificates are
+// verified against C509Roots, checking the validity periods, the basic
+// constraints and key usages of the issuers, and the signatures; extended key
+// usages and name constraints are not enforced.
+func (v *X509Verifier) verifyC509Chain(h *Headers, certs []*C509Certificate) ([]*x509.Certificate, Verifier, error) {
+	hash, err := h.C5T()
+	if err != nil {
+		return nil, nil, err
+	}
+	if hash != nil {
+		ok, err := hash.MatchC509(certs[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ok {
+			return nil, nil, errors.New("header parameter: c5t: certificate mismatch")
+		}
+	}
+	bag, err := h.C5B()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var chain []*x509.Certificate
+	if c509Reencoded(certs) && c509Reencoded(bag) {
+		chain, err = v.verifyX509Chain(c509X509Certificates(certs), c509X509Certificates(bag), c509X509Certificates(v.C509Roots))
+	} else {
+		chain, err = v.buildC509Chain(certs, bag)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("c5c: %w", err)
+	}
+	verifier, err := newLeafVerifier(h, HeaderLabelC5C, chain[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	return chain, verifier, nil
+}
+
+// buildC509Chain builds a chain from the end-entity certificate up to one of
+// the C509Roots, using the tail of the chain and the bag as intermediates.
+func (v *X509Verifier) buildC509Chain(certs, bag []*C509Certificate) ([]*x509.Certificate, error) {
+	now := v.Options.CurrentTime
+	if now.IsZero() {
+		now = time.Now()
+	}
+	candidates := append(certs[1:len(certs):len(certs)], bag...)
+	chain := []*C509Certificate{certs[0]}
+	for cert := certs[0]; cert != nil; {
+		if err := c509CheckValidity(cert, now); err != nil {
+			return nil, err
+		}
+		for _, root := range v.C509Roots {
+			if bytes.Equal(root.Raw, cert.Raw) {
+				return c509X509Certificates(chain), nil
+			}
+			if cert.CheckSignatureFrom(root) == nil {
+				if err := c509CheckValidity(root, now); err != nil {
+					return nil, err
+				}
+				return c509X509Certificates(append(chain, root)), nil
+			}
+		}
+		var parent *C509Certificate
+		for _, candidate := range candidates {
+			if !c509Contains(chain, candidate) && cert.CheckSignatureFrom(candidate) == nil {
+				parent = candidate
+				break
+			}
+		}
+		if parent != nil {
+			chain = append(chain, parent)
+		}
+		cert = parent
+	}
+	return nil, x509.UnknownAuthorityError{Cert: certs[0].Certificate}
+}
+
+// c509CheckValidity checks the validity period of the certificate.
+func c509CheckValidity(cert *C509Certificate, now time.Time) error {
+	if now.Before(cert.Certificate.NotBefore) || now.After(cert.Certificate.NotAfter) {
+		return x509.CertificateInvalidError{
+			Cert:   cert.Certificate,
+			Reason: x509.Expired,
+		}
+	}
+	return nil
+}
+
+// c509Contains reports whether the certificates contain the certificate.
+func c509Contains(certs []*C509Certificate, cert *C509Certificate) bool {
+	for _, c := range certs {
+		if bytes.Equal(c.Raw, cert.Raw) {
+			return true
+		}
+	}
+	return false
+}
+
+// c509Reencoded reports whether all the certificates are CBOR re-encoded X.509
+// certificates.
+func c509Reencoded(certs []*C509Certificate) bool {
+	for _, cert := range certs {
+		if cert.Type != C509TypeReencoded {
+			return false
+		}
+	}
+	return true
+}
+
+// c509X509Certificates returns the X.509 view of the certificates.
+func c509X509Certificates(certs []*C509Certificate) []*x509.Certificate {
+	views := make([]*x509.Certificate, 0, len(certs))
+	for _, cert := range certs {
+		views = append(views, cert.Certificate)
+	}
+	return views
+}
+
+// c509SignatureAlgorithmByID returns the signature algorithm of a C509
+// identifier.
+func c509SignatureAlgorithmByID(id int64) (c509SignatureAlgorithm, error) {
+	for _, alg := range c509SignatureAlgorithms {
+		if alg.id == id {
+			return alg, nil
+		}
+	}
+	return c509SignatureAlgorithm{}, fmt.Errorf("c509: unsupported signature algorithm: %d", id)
+}
+
+// c509FromX509 converts the content of a X.509 certificate into a C509
+// certificate, without setting its type.
+func c509FromX509(cert *x509.Certificate) (*c509Certificate, c509SignatureAlgorithm, error) {
+	var sigAlg c509SignatureAlgorithm
+	if cert.Version != 3 {
+		return nil, sigAlg, fmt.Errorf("c509: unsupported certificate version: %d", cert.Version)
+	}
+	found := false
+	for _, alg := range c509SignatureAlgorithms {
+		if alg.x509 == cert.SignatureAlgorithm {
+			sigAlg, found = alg, true
+			break
+		}
+	}
+	if !found {
+		return nil, sigAlg, fmt.Errorf("c509: unsupported signature algorithm: %v", cert.SignatureAlgorithm)
+	}
+	if cert.SerialNumber.Sign() < 0 {
+		return nil, sigAlg, errors.New("c509: negative serial number")
+	}
+
+	raw := &c509Certificate{
+		SerialNumber:             cert.SerialNumber.Bytes(),
+		IssuerSignatureAlgorithm: sigAlg.id,
+		NotBefore:                cert.NotBefore.Unix(),
+	}
+	var err error
+	if bytes.Equal(cert.RawIssuer, cert.RawSubject) {
+		raw.Issuer = cbor.RawMessage{0xf6} // null
+	} else if raw.Issuer, err = c509EncodeName(cert.RawIssuer); err != nil {
+		return nil, sigAlg, err
+	}
+	if !cert.NotAfter.Equal(c509NoExpiration) {
+		notAfter := cert.NotAfter.Unix()
+		raw.NotAfter = &notAfter
+	}
+	if raw.Subject, err = c509EncodeName(cert.RawSubject); err != nil {
+		return nil, sigAlg, err
+	}
+	if raw.SubjectPublicKeyAlgorithm, raw.SubjectPublicKey, err = c509EncodePublicKey(cert); err != nil {
+		return nil, sigAlg, err
+	}
+	if raw.Extensions, err = c509EncodeExtensions(cert.Extensions); err != nil {
+		return nil, sigAlg, err
+	}
+	if raw.SignatureValue, err = c509EncodeSignature(sigAlg, cert.Signature); err != nil {
+		return nil, sigAlg, err
+	}
+	return raw, sigAlg, nil
+}
+
+// c509TBSCertificate is the DER representation of a X.509 TBSCertificate.
+type c509TBSCertificate struct {
+	Version            int `asn1:"optional,explicit,default:0,tag:0"`
+	SerialNumber       *big.Int
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Issuer             asn1.RawValue
+	Validity           c509Validity
+	Subject            asn1.RawValue
+	PublicKey          asn1.RawValue
+	Extensions         []pkix.Extension `asn1:"omitempty,optional,explicit,tag:3"`
+}
+
+// c509Validity is the DER representation of a X.509 validity period.
+type c509Validity struct {
+	NotBefore, NotAfter time.Time
+}
+
+// c509X509Certificate is the DER representation of a X.509 certificate.
+type c509X509Certificate struct {
+	TBSCertificate     asn1.RawValue
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	SignatureValue     asn1.BitString
+}
+
+// c509PublicKeyInfo is the DER representation of a X.509
+// SubjectPublicKeyInfo.
+type c509PublicKeyInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	PublicKey asn1.BitString
+}
+
+// c509AttributeTypeAndValue is the DER representation of a X.509 name
+// attribute, preserving the string type of the value.
+type c509AttributeTypeAndValue struct {
+	Type  asn1.ObjectIdentifier
+	Value asn1.RawValue
+}
+
+// c509ECDSASignature is the DER representation of an ECDSA signature.
+type c509ECDSASignature struct {
+	R, S *big.Int
+}
+
+// toDER converts the C509 certificate into a DER encoded X.509 certificate.
+func (c *c509Certificate) toDER(sigAlg c509SignatureAlgorithm) ([]byte, error) {
+	subject, err := c509DecodeName(c.Subject)
+	if err != nil {
+		return nil, err
+	}
+	issuer := subject
+	if !bytes.Equal(c.Issuer, []byte{0xf6}) { // not null
+		if issuer, err = c509DecodeName(c.Issuer); err != nil {
+			return nil, err
+		}
+	}
+	notAfter := c509NoExpiration
+	if c.NotAfter != nil {
+		notAfter = time.Unix(*c.NotAfter, 0).UTC()
+	}
+	publicKey, err := c509DecodePublicKey(c.SubjectPublicKeyAlgorithm, c.SubjectPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	extensions, err := c509DecodeExtensions(c.Extensions)
+	if err != nil {
+		return nil, err
+	}
+	tbs, err := asn1.Marshal(c509TBSCertificate{
+		Version:            2, // v3
+		SerialNumber:       new(big.Int).SetBytes(c.SerialNumber),
+		SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: sigAlg.oid},
+		Issuer:             asn1.RawValue{FullBytes: issuer},
+		Validity: c509Validity{
+			NotBefore: time.Unix(c.NotBefore, 0).UTC(),
+			NotAfter:  notAfter,
+		},
+		Subject:    asn1.RawValue{FullBytes: subject},
+		PublicKey:  asn1.RawValue{FullBytes: publicKey},
+		Extensions: extensions,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("c509: %w", err)
+	}
+	signature, err := c509DecodeSignature(sigAlg, c.SignatureValue)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(c509X509Certificate{
+		TBSCertificate:     asn1.RawValue{FullBytes: tbs},
+		SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: sigAlg.oid},
+		SignatureValue: asn1.BitString{
+			Bytes:     signature,
+			BitLength: len(signature) * 8,
+		},
+	})
+}
+
+// c509EncodeName encodes a DER encoded X.509 name.
+//
+//	Name = [ * Attribute ] / SpecialText
+//	Attribute = ( attributeType: int, attributeValue: text )
+//
+// Positive attribute types denote UTF8String values, negative ones
+// PrintableString values. A name made of a single UTF8String common name is
+// encoded as a text string.
+func c509EncodeName(der []byte) (cbor.RawMessage, error) {
+	var rdns []asn1.RawValue
+	if rest, err := asn1.Unmarshal(der, &rdns); err != nil || len(rest) > 0 {
+		return nil, errors.New("c509: invalid name")
+	}
+	name := make([]any, 0, len(rdns)*2)
+	for _, rdn := range rdns {
+		var atvs []c509AttributeTypeAndValue
+		if rest, err := asn1.UnmarshalWithParams(rdn.FullBytes, &atvs, "set"); err != nil || len(rest) > 0 {
+			return nil, errors.New("c509: invalid name")
+		}
+		if len(atvs) != 1 {
+			return nil, errors.New("c509: unsupported multi-valued relative distinguished name")
+		}
+		atv := atvs[0]
+		id, found := int64(0), false
+		for _, attr := range c509Attributes {
+			if attr.oid.Equal(atv.Type) {
+				id, found = attr.id, true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("c509: unsupported name attribute: %v", atv.Type)
+		}
+		switch {
+		case atv.Value.Class == asn1.ClassUniversal && atv.Value.Tag == asn1.TagUTF8String:
+		case atv.Value.Class == asn1.ClassUniversal && atv.Value.Tag == asn1.TagPrintableString:
+			id = -id
+		default:
+			return nil, fmt.Errorf("c509: unsupported name attribute value type: %d", atv.Value.Tag)
+		}
+		name = append(name, id, string(atv.Value.Bytes))
+	}
+	if len(name) == 2 && name[0] == int64(1) { // UTF8String common name
+		return encMode.Marshal(name[1])
+	}
+	return encMode.Marshal(name)
+}
+
+// c509DecodeName decodes a C509 name into a DER encoded X.509 name.
+func c509DecodeName(data cbor.RawMessage) ([]byte, error) {
+	var value any
+	if err := decMode.Unmarshal(data, &value); err != nil {
+		return nil, fmt.Errorf("c509: name: %w", err)
+	}
+	var name []any
+	switch v := value.(type) {
+	case string:
+		name = []any{int64(1), v}
+	case []any:
+		name = v
+	default:
+		return nil, errors.New("c509: unsupported name")
+	}
+	if len(name)%2 != 0 {
+		return nil, errors.New("c509: invalid name")
+	}
+	rdns := make([]asn1.RawValue, 0, len(name)/2)
+	for i := 0; i < len(name); i += 2 {
+		id, ok := name[i].(int64)
+		text, ok2 := name[i+1].(string)
+		if !ok || !ok2 {
+			return nil, errors.New("c509: unsupported name attribute")
+		}
+		tag := asn1.TagUTF8String
+		if id < 0 {
+			id, tag = -id, asn1.TagPrintableString
+		}
+		var oid asn1.ObjectIdentifier
+		for _, attr := range c509Attributes {
+			if attr.id == id {
+				oid = attr.oid
+				break
+			}
+		}
+		if oid == nil {
+			return nil, fmt.Errorf("c509: unsupported name attribute: %d", id)
+		}
+		set, err := asn1.MarshalWithParams([]c509AttributeTypeAndValue{{
+			Type:  oid,
+			Value: asn1.RawValue{Class: asn1.ClassUniversal, Tag: tag, Bytes: []byte(text)},
+		}}, "set")
+		if err != nil {
+			return nil, fmt.Errorf("c509: %w", err)
+		}
+		rdns = append(rdns, asn1.RawValue{FullBytes: set})
+	}
+	return asn1.Marshal(rdns)
+}
+
+// c509EncodePublicKey encodes the subject public key of a certificate.
+// EC public keys are encoded as compressed points.
+func c509EncodePublicKey(cert *x509.Certificate) (int64, []byte, error) {
+	var spki c509PublicKeyInfo
+	if rest, err := asn1.Unmarshal(cert.RawSubjectPublicKeyInfo, &spki); err != nil || len(rest) > 0 {
+		return 0, nil, errors.New("c509: invalid subject public key info")
+	}
+	key := spki.PublicKey.RightAlign()
+	switch pub := cert.PublicKey.(type) {
+	case *ecdsa.PublicKey:
+		for _, alg := range c509PublicKeyAlgorithms {
+			if alg.curve != pub.Curve {
+				continue
+			}
+			n := (alg.curve.Params().BitSize + 7) / 8
+			if len(key) != 1+2*n || key[0] != 0x04 {
+				return 0, nil, errors.New("c509: unsupported EC point encoding")
+			}
+			compressed := make([]byte, 1+n)
+			compressed[0] = 0x02 | key[len(key)-1]&1
+			copy(compressed[1:], key[1:1+n])
+			return alg.id, compressed, nil
+		}
+		return 0, nil, fmt.Errorf("c509: unsupported curve: %s", pub.Curve.Params().Name)
+	case ed25519.PublicKey:
+		return 10, key, nil
+	}
+	return 0, nil, fmt.Errorf("c509: unsupported public key algorithm: %v", cert.PublicKeyAlgorithm)
+}
+
+// c509DecodePublicKey decodes a C509 subject public key into a DER encoded
+// X.509 SubjectPublicKeyInfo.
+func c509DecodePublicKey(id int64, key []byte) ([]byte, error) {
+	for _, alg := range c509PublicKeyAlgorithms {
+		if alg.id != id {
+			continue
+		}
+		var spki c509PublicKeyInfo
+		if alg.curve == nil {
+			spki.Algorithm = pkix.AlgorithmIdentifier{Algorithm: oidPublicKeyEd25519}
+		} else {
+			x, y := elliptic.UnmarshalCompressed(alg.curve, key)
+			if x == nil {
+				return nil, errors.New("c509: invalid EC public key")
+			}
+			n := (alg.curve.Params().BitSize + 7) / 8
+			point := make([]byte, 1+2*n)
+			point[0] = 0x04
+			x.FillBytes(point[1 : 1+n])
+			y.FillBytes(point[1+n:])
+			key = point
+			curve, err := asn1.Marshal(alg.oid)
+			if err != nil {
+				return nil, err
+			}
+			spki.Algorithm = pkix.AlgorithmIdentifier{
+				Algorithm:  oidPublicKeyECDSA,
+				Parameters: asn1.RawValue{FullBytes: curve},
+			}
+		}
+		spki.PublicKey = asn1.BitString{Bytes: key, BitLength: len(key) * 8}
+		return asn1.Marshal(spki)
+	}
+	return nil, fmt.Errorf("c509: unsupported public key algorithm: %d", id)
+}
+
+// c509EncodeExtensions encodes X.509 extensions with their object
+// identifiers.
+//
+//	Extensions = [ * Extension ] / int
+//	Extension = ( extensionID: ~oid, ? critical: true, extensionValue: bytes )
+func c509EncodeExtensions(extensions []pkix.Extension) (cbor.RawMessage, error) {
+	encoded := make([]any, 0, len(extensions)*2)
+	for _, ext := range extensions {
+		oid, err := asn1.Marshal(ext.Id)
+		if err != nil {
+			return nil, fmt.Errorf("c509: %w", err)
+		}
+		var raw asn1.RawValue
+		if _, err := asn1.Unmarshal(oid, &raw); err != nil {
+			return nil, fmt.Errorf("c509: %w", err)
+		}
+		encoded = append(encoded, raw.Bytes)
+		if ext.Critical {
+			encoded = append(encoded, true)
+		}
+		encoded = append(encoded, ext.Value)
+	}
+	return encMode.Marshal(encoded)
+}
+
+// c509DecodeExtensions decodes C509 extensions encoded with their object
+// identifiers.
+func c509DecodeExtensions(data cbor.RawMessage) ([]pkix.Extension, error) {
+	var encoded []any
+	if err := decMode.Unmarshal(data, &encoded); err != nil {
+		return nil, errors.New("c509: unsupported extensions")
+	}
+	var extensions []pkix.Extension
+	for i := 0; i < len(encoded); {
+		oid, ok := encoded[i].([]byte)
+		if !ok {
+			return nil, errors.New("c509: unsupported extension")
+		}
+		var ext pkix.Extension
+		raw, err := asn1.Marshal(asn1.RawValue{Tag: asn1.TagOID, Bytes: oid})
+		if err != nil {
+			return nil, fmt.Errorf("c509: %w", err)
+		}
+		if _, err := asn1.Unmarshal(raw, &ext.Id); err != nil {
+			return nil, fmt.Errorf("c509: extension: %w", err)
+		}
+		i++
+		if i < len(encoded) && encoded[i] == true {
+			ext.Critical = true
+			i++
+		}
+		if i >= len(encoded) {
+			return nil, errors.New("c509: missing extension value")
+		}
+		if ext.Value, ok = encoded[i].([]byte); !ok {
+			return nil, errors.New("c509: invalid extension value")
+		}
+		i++
+		extensions = append(extensions, ext)
+	}
+	return extensions, nil
+}
+
+// c509EncodeSignature encodes a X.509 signature value.
+// ECDSA signatures are encoded as r || s.
+func c509EncodeSignature(sigAlg c509SignatureAlgorithm, signature []byte) ([]byte, error) {
+	if sigAlg.size == 0 {
+		return signature, nil
+	}
+	var sig c509ECDSASignature
+	if rest, err := asn1.Unmarshal(signature, &sig); err != nil || len(rest) > 0 {
+		return nil, errors.New("c509: invalid ECDSA signature")
+	}
+	if sig.R.Sign() <= 0 || sig.S.Sign() <= 0 {
+		return nil, errors.New("c509: invalid ECDSA signature")
+	}
+	n := max(sigAlg.size, (sig.R.BitLen()+7)/8, (sig.S.BitLen()+7)/8)
+	encoded := make([]byte, 2*n)
+	sig.R.FillBytes(encoded[:n])
+	sig.S.FillBytes(encoded[n:])
+	return encoded, nil
+}
+
+// c509DecodeSignature decodes a C509 signature value into a X.509 signature
+// value.
+func c509DecodeSignature(sigAlg c509SignatureAlgorithm, signature []byte) ([]byte, error) {
+	if sigAlg.size == 0 {
+		return signature, nil
+	}
+	n := len(signature) / 2
+	if n == 0 || len(signature)%2 != 0 {
+		return nil, errors.New("c509: invalid ECDSA signature")
+	}
+	return asn1.Marshal(c509ECDSASignature{
+		R: new(big.Int).SetBytes(signature[:n]),
+		S: new(big.Int).SetBytes(signature[n:]),
+	})
+}
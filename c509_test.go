@@ -0,0 +1,488 @@
+package cose
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// generateTestC509CertificateChain generates a chain of natively signed C509
+// certificates, starting with the end-entity certificate, and returns it with
+// the private key of the end-entity certificate.
+func generateTestC509CertificateChain(t *testing.T) ([]*C509Certificate, ed25519.PrivateKey) {
+	t.Helper()
+	now := time.Now().Truncate(time.Second)
+	rootTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test C509 Root CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	rootKey := generateTestECDSAKey(t)
+	root, err := CreateC509Certificate(rand.Reader, rootTemplate, rootTemplate, rootKey.Public(), rootKey)
+	if err != nil {
+		t.Fatalf("CreateC509Certificate() error = %v", err)
+	}
+
+	leafPub, leafKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("ed25519.GenerateKey() error = %v", err)
+	}
+	leaf, err := CreateC509Certificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject: pkix.Name{
+			CommonName:   "Test C509 Leaf",
+			Organization: []string{"Example"},
+			Country:      []string{"SE"},
+		},
+		NotBefore: now.Add(-time.Hour),
+		NotAfter:  now.Add(time.Hour),
+		KeyUsage:  x509.KeyUsageDigitalSignature,
+	}, root.Certificate, leafPub, rootKey)
+	if err != nil {
+		t.Fatalf("CreateC509Certificate() error = %v", err)
+	}
+	return []*C509Certificate{leaf, root}, leafKey
+}
+
+func TestNewC509Certificate(t *testing.T) {
+	chain, _ := generateTestCertificateChain(t)
+	for _, cert := range chain {
+		t.Run(cert.Subject.CommonName, func(t *testing.T) {
+			c, err := NewC509Certificate(cert)
+			if err != nil {
+				t.Fatalf("NewC509Certificate() error = %v", err)
+			}
+			if c.Type != C509TypeReencoded {
+				t.Errorf("C509Certificate.Type = %v, want %v", c.Type, C509TypeReencoded)
+			}
+			if !c.Certificate.Equal(cert) {
+				t.Errorf("C509Certificate.Certificate = %v, want %v", c.Certificate, cert)
+			}
+			if len(c.Raw) >= len(cert.Raw) {
+				t.Errorf("len(C509Certificate.Raw) = %d, want less than %d", len(c.Raw), len(cert.Raw))
+			}
+
+			got, err := ParseC509Certificate(c.Raw)
+			if err != nil {
+				t.Fatalf("ParseC509Certificate() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, c) {
+				t.Errorf("ParseC509Certificate() = %v, want %v", got, c)
+			}
+		})
+	}
+
+	// unsupported content
+	rsaCert := &x509.Certificate{Version: 3, SignatureAlgorithm: x509.SHA256WithRSA}
+	wantErr := "c509: unsupported signature algorithm: SHA256-RSA"
+	if _, err := NewC509Certificate(rsaCert); err == nil || err.Error() != wantErr {
+		t.Errorf("NewC509Certificate() error = %v, wantErr %v", err, wantErr)
+	}
+}
+
+func TestCreateC509Certificate(t *testing.T) {
+	chain, _ := generateTestC509CertificateChain(t)
+	leaf, root := chain[0], chain[1]
+
+	for _, cert := range chain {
+		if cert.Type != C509TypeNative {
+			t.Errorf("C509Certificate.Type = %v, want %v", cert.Type, C509TypeNative)
+		}
+		got, err := ParseC509Certificate(cert.Raw)
+		if err != nil {
+			t.Fatalf("ParseC509Certificate() error = %v", err)
+		}
+		if !reflect.DeepEqual(got, cert) {
+			t.Errorf("ParseC509Certificate() = %v, want %v", got, cert)
+		}
+	}
+	if got, want := leaf.Certificate.Subject.String(), "CN=Test C509 Leaf,O=Example,C=SE"; got != want {
+		t.Errorf("C509Certificate.Certificate.Subject = %v, want %v", got, want)
+	}
+	if _, ok := leaf.Certificate.PublicKey.(ed25519.PublicKey); !ok {
+		t.Errorf("C509Certificate.Certificate.PublicKey = %T, want ed25519.PublicKey", leaf.Certificate.PublicKey)
+	}
+
+	if err := leaf.CheckSignatureFrom(root); err != nil {
+		t.Errorf("C509Certificate.CheckSignatureFrom() error = %v", err)
+	}
+	if err := root.CheckSignatureFrom(root); err != nil {
+		t.Errorf("C509Certificate.CheckSignatureFrom() error = %v", err)
+	}
+	if err := root.CheckSignatureFrom(leaf); !errors.As(err, &x509.ConstraintViolationError{}) {
+		t.Errorf("C509Certificate.CheckSignatureFrom() error = %v, want %v", err, x509.ConstraintViolationError{})
+	}
+
+	// the natively signed signature is not a valid X.509 signature
+	if err := leaf.Certificate.CheckSignatureFrom(root.Certificate); err == nil {
+		t.Error("x509.Certificate.CheckSignatureFrom() error = nil, want error")
+	}
+
+	// tampered certificate
+	tampered := *leaf
+	tampered.tbs = append([]byte{}, leaf.tbs...)
+	tampered.tbs[len(tampered.tbs)-1] ^= 0xff
+	if err := tampered.CheckSignatureFrom(root); err != ErrVerification {
+		t.Errorf("C509Certificate.CheckSignatureFrom() error = %v, want %v", err, ErrVerification)
+	}
+}
+
+func TestParseC509Certificate(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr string
+	}{
+		{
+			name:    "not an array",
+			data:    []byte{0x40},
+			wantErr: "c509: cbor: cannot unmarshal byte string into Go value of type []cbor.RawMessage",
+		},
+		{
+			name:    "wrong size",
+			data:    []byte{0x81, 0x03},
+			wantErr: "c509: require array of 11 elements",
+		},
+		{
+			name: "unsupported type",
+			data: []byte{
+				0x8b, 0x01, 0x41, 0x01, 0x00, 0xf6, 0x00, 0xf6,
+				0x61, 0x41, 0x01, 0x40, 0x80, 0x40,
+			},
+			wantErr: "c509: unsupported certificate type: 1",
+		},
+		{
+			name: "unsupported signature algorithm",
+			data: []byte{
+				0x8b, 0x03, 0x41, 0x01, 0x18, 0x17, 0xf6, 0x00, 0xf6,
+				0x61, 0x41, 0x01, 0x40, 0x80, 0x40,
+			},
+			wantErr: "c509: unsupported signature algorithm: 23",
+		},
+		{
+			name: "unsupported public key algorithm",
+			data: []byte{
+				0x8b, 0x03, 0x41, 0x01, 0x0c, 0xf6, 0x00, 0xf6,
+				0x61, 0x41, 0x00, 0x40, 0x80, 0x40,
+			},
+			wantErr: "c509: unsupported public key algorithm: 0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseC509Certificate(tt.data)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("ParseC509Certificate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHeaders_C5C(t *testing.T) {
+	chain, _ := generateTestC509CertificateChain(t)
+	leaf, root := chain[0], chain[1]
+
+	tests := []struct {
+		name    string
+		h       Headers
+		want    []*C509Certificate
+		wantErr string
+	}{
+		{
+			name: "absent",
+		},
+		{
+			name: "single certificate",
+			h: Headers{
+				Protected: ProtectedHeader{HeaderLabelC5C: leaf.Raw},
+			},
+			want: []*C509Certificate{leaf},
+		},
+		{
+			name: "array of certificates",
+			h: Headers{
+				Unprotected: UnprotectedHeader{HeaderLabelC5C: []any{leaf.Raw, root.Raw}},
+			},
+			want: []*C509Certificate{leaf, root},
+		},
+		{
+			name: "duplicate",
+			h: Headers{
+				Protected:   ProtectedHeader{HeaderLabelC5C: leaf.Raw},
+				Unprotected: UnprotectedHeader{HeaderLabelC5C: leaf.Raw},
+			},
+			wantErr: "header parameter: c5c: header parameter present in both protected and unprotected headers",
+		},
+		{
+			name: "invalid type",
+			h: Headers{
+				Protected: ProtectedHeader{HeaderLabelC5C: "foo"},
+			},
+			wantErr: "header parameter: c5c: require bstr / array of bstr type",
+		},
+		{
+			name: "invalid certificate",
+			h: Headers{
+				Protected: ProtectedHeader{HeaderLabelC5C: []byte{0x80}},
+			},
+			wantErr: "header parameter: c5c: c509: require array of 11 elements",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.h.C5C()
+			if err != nil && (err.Error() != tt.wantErr) {
+				t.Errorf("Headers.C5C() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			} else if err == nil && tt.wantErr != "" {
+				t.Errorf("Headers.C5C() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Headers.C5C() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeaders_SetC5(t *testing.T) {
+	chain, _ := generateTestC509CertificateChain(t)
+	leaf, root := chain[0], chain[1]
+	hash, err := NewC509CertHash(AlgorithmSHA256_64, leaf)
+	if err != nil {
+		t.Fatalf("NewC509CertHash() error = %v", err)
+	}
+
+	var h Headers
+	if err := h.SetC5C(chain); err != nil {
+		t.Fatalf("Headers.SetC5C() error = %v", err)
+	}
+	if err := h.SetC5B([]*C509Certificate{root}); err != nil {
+		t.Fatalf("Headers.SetC5B() error = %v", err)
+	}
+	if err := h.SetC5T(hash); err != nil {
+		t.Fatalf("Headers.SetC5T() error = %v", err)
+	}
+	if err := h.SetC5U("https://example.com/leaf.c509"); err != nil {
+		t.Fatalf("Headers.SetC5U() error = %v", err)
+	}
+	protected, unprotected, err := h.marshal(nil)
+	if err != nil {
+		t.Fatalf("Headers.marshal() error = %v", err)
+	}
+	got := Headers{
+		RawProtected:   protected,
+		RawUnprotected: unprotected,
+	}
+	if err := got.UnmarshalFromRaw(); err != nil {
+		t.Fatalf("Headers.UnmarshalFromRaw() error = %v", err)
+	}
+
+	if !hasLabel(got.Protected, HeaderLabelC5C) || !hasLabel(got.Unprotected, HeaderLabelC5B) {
+		t.Errorf("Headers = %v, want c5c protected and c5b unprotected", got)
+	}
+	if certs, err := got.C5C(); err != nil || !reflect.DeepEqual(certs, chain) {
+		t.Errorf("Headers.C5C() = %v, %v, want %v", certs, err, chain)
+	}
+	if certs, err := got.C5B(); err != nil || !reflect.DeepEqual(certs, []*C509Certificate{root}) {
+		t.Errorf("Headers.C5B() = %v, %v, want %v", certs, err, root)
+	}
+	if gotHash, err := got.C5T(); err != nil || !reflect.DeepEqual(gotHash, hash) {
+		t.Errorf("Headers.C5T() = %v, %v, want %v", gotHash, err, hash)
+	}
+	if ok, err := hash.MatchC509(leaf); err != nil || !ok {
+		t.Errorf("CertHash.MatchC509() = %v, %v, want true", ok, err)
+	}
+	if uri, err := got.C5U(); err != nil || uri != "https://example.com/leaf.c509" {
+		t.Errorf("Headers.C5U() = %v, %v, want %v", uri, err, "https://example.com/leaf.c509")
+	}
+
+	// duplicates
+	h = Headers{
+		Protected:   ProtectedHeader{HeaderLabelC5B: root.Raw},
+		Unprotected: UnprotectedHeader{HeaderLabelC5C: leaf.Raw, HeaderLabelC5U: "foo"},
+	}
+	wantErr := "header parameter: c5c: header parameter present in both protected and unprotected headers"
+	if err := h.SetC5C(chain); err == nil || err.Error() != wantErr {
+		t.Errorf("Headers.SetC5C() error = %v, wantErr %v", err, wantErr)
+	}
+	wantErr = "header parameter: c5b: header parameter present in both protected and unprotected headers"
+	if err := h.SetC5B(chain); err == nil || err.Error() != wantErr {
+		t.Errorf("Headers.SetC5B() error = %v, wantErr %v", err, wantErr)
+	}
+	wantErr = "header parameter: c5u: header parameter present in both protected and unprotected headers"
+	if err := h.SetC5U("bar"); err == nil || err.Error() != wantErr {
+		t.Errorf("Headers.SetC5U() error = %v, wantErr %v", err, wantErr)
+	}
+	wantErr = "header parameter: c5c: require at least one certificate"
+	if err := (ProtectedHeader{}).SetC5C(nil); err == nil || err.Error() != wantErr {
+		t.Errorf("ProtectedHeader.SetC5C() error = %v, wantErr %v", err, wantErr)
+	}
+}
+
+func TestX509Verifier_c5c(t *testing.T) {
+	nativeChain, nativeKey := generateTestC509CertificateChain(t)
+	nativeSigner, err := NewSigner(AlgorithmEdDSA, nativeKey)
+	if err != nil {
+		t.Fatalf("NewSigner() error = %v", err)
+	}
+
+	x509Chain, x509Key := generateTestCertificateChain(t)
+	reencodedChain := make([]*C509Certificate, 0, len(x509Chain))
+	for _, cert := range x509Chain {
+		c, err := NewC509Certificate(cert)
+		if err != nil {
+			t.Fatalf("NewC509Certificate() error = %v", err)
+		}
+		reencodedChain = append(reencodedChain, c)
+	}
+	reencodedSigner, err := NewSigner(AlgorithmES256, x509Key)
+	if err != nil {
+		t.Fatalf("NewSigner() error = %v", err)
+	}
+	x509Roots := x509.NewCertPool()
+	x509Roots.AddCert(x509Chain[1])
+
+	otherChain, _ := generateTestC509CertificateChain(t)
+	otherHash, err := NewC509CertHash(AlgorithmSHA256, otherChain[0])
+	if err != nil {
+		t.Fatalf("NewC509CertHash() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		verifier *X509Verifier
+		signer   Signer
+		headers  func(h *Headers) error
+		want     []*x509.Certificate
+		wantErr  string
+	}{
+		{
+			name:     "native chain",
+			verifier: &X509Verifier{C509Roots: nativeChain[1:]},
+			signer:   nativeSigner,
+			headers: func(h *Headers) error {
+				return h.SetC5C(nativeChain)
+			},
+			want: c509X509Certificates(nativeChain),
+		},
+		{
+			name:     "native leaf with bag",
+			verifier: &X509Verifier{C509Roots: nativeChain[1:]},
+			signer:   nativeSigner,
+			headers: func(h *Headers) error {
+				if err := h.SetC5B(nativeChain[1:]); err != nil {
+					return err
+				}
+				return h.SetC5C(nativeChain[:1])
+			},
+			want: c509X509Certificates(nativeChain),
+		},
+		{
+			name:     "native chain with untrusted root",
+			verifier: &X509Verifier{C509Roots: otherChain[1:]},
+			signer:   nativeSigner,
+			headers: func(h *Headers) error {
+				return h.SetC5C(nativeChain)
+			},
+			wantErr: "c5c: x509: certificate signed by unknown authority",
+		},
+		{
+			name:     "native chain with mismatching c5t",
+			verifier: &X509Verifier{C509Roots: nativeChain[1:]},
+			signer:   nativeSigner,
+			headers: func(h *Headers) error {
+				if err := h.SetC5T(otherHash); err != nil {
+					return err
+				}
+				return h.SetC5C(nativeChain)
+			},
+			wantErr: "header parameter: c5t: certificate mismatch",
+		},
+		{
+			name: "expired native chain",
+			verifier: &X509Verifier{
+				C509Roots: nativeChain[1:],
+				Options:   x509.VerifyOptions{CurrentTime: time.Now().Add(2 * time.Hour)},
+			},
+			signer: nativeSigner,
+			headers: func(h *Headers) error {
+				return h.SetC5C(nativeChain)
+			},
+			wantErr: "c5c: x509: certificate has expired or is not yet valid: ",
+		},
+		{
+			name:     "re-encoded chain with X.509 roots",
+			verifier: NewX509Verifier(x509Roots, x509.VerifyOptions{}),
+			signer:   reencodedSigner,
+			headers: func(h *Headers) error {
+				return h.SetC5C(reencodedChain)
+			},
+			want: x509Chain,
+		},
+		{
+			name: "re-encoded chain with C509 roots",
+			verifier: &X509Verifier{
+				Roots:     x509.NewCertPool(),
+				C509Roots: reencodedChain[1:],
+			},
+			signer: reencodedSigner,
+			headers: func(h *Headers) error {
+				return h.SetC5C(reencodedChain[:1])
+			},
+			want: x509Chain,
+		},
+		{
+			name:     "re-encoded chain with untrusted root",
+			verifier: &X509Verifier{Roots: x509.NewCertPool()},
+			signer:   reencodedSigner,
+			headers: func(h *Headers) error {
+				return h.SetC5C(reencodedChain)
+			},
+			wantErr: "c5c: x509: certificate signed by unknown authority",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := NewSign1Message()
+			msg.Payload = []byte("hello world")
+			msg.Headers.Protected.SetAlgorithm(tt.signer.Algorithm())
+			if err := tt.headers(&msg.Headers); err != nil {
+				t.Fatalf("headers() error = %v", err)
+			}
+			if err := msg.Sign(rand.Reader, nil, tt.signer); err != nil {
+				t.Fatalf("Sign1Message.Sign() error = %v", err)
+			}
+
+			got, err := tt.verifier.VerifySign1(msg, nil)
+			if err != nil && (err.Error() != tt.wantErr) {
+				t.Errorf("X509Verifier.VerifySign1() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			} else if err == nil && tt.wantErr != "" {
+				t.Errorf("X509Verifier.VerifySign1() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr != "" {
+				return
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("X509Verifier.VerifySign1() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) {
+					t.Errorf("X509Verifier.VerifySign1()[%d] = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
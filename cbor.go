@@ -14,6 +14,7 @@ import (
 const (
 	CBORTagSignMessage  = 98
 	CBORTagSign1Message = 18
+	CBORTagCWT          = 61
 )
 
 // Pre-configured modes for CBOR encoding and decoding.
@@ -21,6 +22,7 @@ var (
 	encMode                  cbor.EncMode
 	decMode                  cbor.DecMode
 	decModeWithTagsForbidden cbor.DecMode
+	decModeWithIndefLength   cbor.DecMode
 )
 
 func init() {
@@ -51,6 +53,172 @@ func init() {
 	if err != nil {
 		panic(err)
 	}
+	decOpts.TagsMd = cbor.TagsAllowed
+	decOpts.IndefLength = cbor.IndefLengthAllowed
+	decModeWithIndefLength, err = decOpts.DecMode()
+	if err != nil {
+		panic(err)
+	}
+}
+
+// CBOROptions specifies caller-supplied CBOR encoding and decoding modes for
+// header values, and additional encoding requirements on decoded messages.
+//
+// Applications embedding values such as tagged dates or UUIDs in COSE headers
+// can register their own tags using [cbor.EncOptions.EncModeWithTags] and
+// [cbor.DecOptions.DecModeWithTags], and pass the resulting modes to the
+// WithOptions family of methods.
+//
+// The supplied modes are only used to encode and decode header values.
+// The security-critical rules of this library are enforced regardless of the
+// supplied modes:
+//   - the outer COSE structures never contain tags other than the COSE message
+//     tags;
+//   - header maps are checked for duplicated labels, indefinite lengths and
+//     label types, and their labels are decoded, using the built-in decoding
+//     mode;
+//   - the protected header is always encoded with core deterministic sorting;
+//   - Sig_structure and Countersign_structure are always encoded with the
+//     built-in encoding mode.
+//
+// Note that signing uses the default encoding mode for the protected header.
+// To sign a message with a protected header requiring custom encoding, set
+// [Headers.RawProtected] using [Headers.MarshalProtectedWithOptions] prior to
+// signing.
+type CBOROptions struct {
+	// EncMode is used to encode header values.
+	// The built-in encoding mode is used if EncMode is nil.
+	EncMode cbor.EncMode
+
+	// DecMode is used to decode header values.
+	// The built-in decoding mode is used if DecMode is nil.
+	DecMode cbor.DecMode
+
+	// Deterministic requires decoded COSE messages to be encoded with the
+	// core deterministic encoding requirements of RFC 8949, including the
+	// header maps embedded in protected headers and countersignatures.
+	// Payloads are not checked since they are opaque to COSE; use
+	// [CheckDeterministicEncoding] to check CBOR payloads.
+	//
+	// Decoding fails with a [*DeterministicEncodingError] locating the
+	// offending data item if the requirements are not met.
+	//
+	// Reference: https://www.rfc-editor.org/rfc/rfc8949.html#section-4.2.1
+	Deterministic bool
+
+	// AllowIndefiniteLength accepts indefinite-length arrays, maps, byte
+	// strings and text strings in decoded COSE messages, for interoperability
+	// with encoders which stream their output. Such items are converted into
+	// definite-length items before decoding, so that the decoded message,
+	// including Headers.RawUnprotected, only contains definite-length items.
+	//
+	// Encoded protected headers must be definite-length byte strings, and
+	// their content must not contain indefinite-length items, as they are
+	// integrity protected and used byte by byte.
+	//
+	// Security considerations: enabling this option trades strictness for
+	// interoperability.
+	//   - Decoded messages re-encode to different bytes than the received
+	//     ones, and several encodings decode to the same message. Signatures
+	//     are not affected since they only cover the protected headers and the
+	//     payload, but applications identifying messages by their encoding,
+	//     e.g. by hashing them for replay detection or logging, must hash the
+	//     re-encoded message instead of the received one.
+	//   - Payloads and signatures sent as chunked byte strings are
+	//     concatenated, i.e. the chunk boundaries are lost.
+	//   - Streaming encoders are commonly not hardened the way deterministic
+	//     ones are; accept them only from peers that require it.
+	//
+	// AllowIndefiniteLength must not be combined with Deterministic, which
+	// forbids indefinite-length items.
+	AllowIndefiniteLength bool
+}
+
+// encMode returns the encoding mode for header values.
+func (o *CBOROptions) encMode() cbor.EncMode {
+	if o == nil || o.EncMode == nil {
+		return encMode
+	}
+	return o.EncMode
+}
+
+// decMode returns the decoding mode for header values.
+func (o *CBOROptions) decMode() cbor.DecMode {
+	if o == nil || o.DecMode == nil {
+		return decMode
+	}
+	return o.DecMode
+}
+
+// deterministic reports whether decoded COSE messages must be encoded
+// deterministically.
+func (o *CBOROptions) deterministic() bool {
+	return o != nil && o.Deterministic
+}
+
+// toDefiniteLength converts an encoded COSE_Sign1 or, if signatures is true,
+// COSE_Sign object into definite-length encoding if indefinite-length items
+// are allowed.
+func (o *CBOROptions) toDefiniteLength(data []byte, signatures bool) ([]byte, error) {
+	if o == nil || !o.AllowIndefiniteLength || len(data) == 0 {
+		return data, nil
+	}
+	if o.Deterministic {
+		return nil, errors.New("cbor: indefinite lengths not allowed with deterministic encoding")
+	}
+	return toDefiniteLength(data, signatures)
+}
+
+// messageDecMode returns the decoding mode for the outer structure of COSE
+// messages.
+// Tags are allowed in the outer structure only if a custom decoding mode is
+// supplied since the values of the unprotected header may be tagged.
+// Other fields of the outer structure are type checked separately.
+func (o *CBOROptions) messageDecMode() cbor.DecMode {
+	if o == nil || o.DecMode == nil {
+		return decModeWithTagsForbidden
+	}
+	return decMode
+}
+
+// protectedEncMode returns the encoding mode for the protected header, which
+// must produce deterministically sorted maps.
+func (o *CBOROptions) protectedEncMode() (cbor.EncMode, error) {
+	em := o.encMode()
+	if em.EncOptions().Sort != cbor.SortCoreDeterministic {
+		return nil, errors.New("cbor: protected header: require core deterministic sort mode")
+	}
+	return em, nil
+}
+
+// optionsUnmarshaler is implemented by types that can be decoded with
+// caller-supplied CBOR options.
+type optionsUnmarshaler interface {
+	unmarshal(data []byte, opts *CBOROptions) error
+}
+
+// decodeWithOptions decodes a single well-formed CBOR data item into v using
+// opts.
+func decodeWithOptions(data []byte, v optionsUnmarshaler, opts *CBOROptions) error {
+	if err := decMode.Wellformed(data); err != nil {
+		return err
+	}
+	return v.unmarshal(data, opts)
+}
+
+// ensureUntaggedItems ensures that none of the items of a CBOR array is
+// tagged.
+func ensureUntaggedItems(data []byte) error {
+	var items []cbor.RawMessage
+	if err := decMode.Unmarshal(data, &items); err != nil {
+		return err
+	}
+	for _, item := range items {
+		if len(item) > 0 && item[0]>>5 == 6 { // major type 6: tag
+			return errors.New("cbor: CBOR tag isn't allowed")
+		}
+	}
+	return nil
 }
 
 // byteString represents a "bstr / nil" type.
@@ -208,3 +208,171 @@ func Test_deterministicBinaryString(t *testing.T) {
 		})
 	}
 }
+
+// testUUID is a test type registered with CBOR tag 37.
+type testUUID [16]byte
+
+func testCBOROptions(t *testing.T) *CBOROptions {
+	tags := cbor.NewTagSet()
+	err := tags.Add(
+		cbor.TagOptions{EncTag: cbor.EncTagRequired, DecTag: cbor.DecTagRequired},
+		reflect.TypeOf(testUUID{}),
+		37,
+	)
+	if err != nil {
+		t.Fatalf("TagSet.Add() error = %v", err)
+	}
+	em, err := cbor.EncOptions{Sort: cbor.SortCoreDeterministic}.EncModeWithTags(tags)
+	if err != nil {
+		t.Fatalf("EncModeWithTags() error = %v", err)
+	}
+	dm, err := cbor.DecOptions{IntDec: cbor.IntDecConvertSigned}.DecModeWithTags(tags)
+	if err != nil {
+		t.Fatalf("DecModeWithTags() error = %v", err)
+	}
+	return &CBOROptions{EncMode: em, DecMode: dm}
+}
+
+func TestCBOROptions_Sign1Message(t *testing.T) {
+	opts := testCBOROptions(t)
+	uuid := testUUID{0x01, 0x02, 0x03, 0x04}
+	msg := &Sign1Message{
+		Headers: Headers{
+			Protected: ProtectedHeader{
+				HeaderLabelAlgorithm: AlgorithmES256,
+				"uuid":               uuid,
+			},
+			Unprotected: UnprotectedHeader{
+				"uuid": uuid,
+			},
+		},
+		Payload:   []byte("foo"),
+		Signature: []byte("bar"),
+	}
+	data, err := msg.MarshalCBORWithOptions(opts)
+	if err != nil {
+		t.Fatalf("Sign1Message.MarshalCBORWithOptions() error = %v", err)
+	}
+
+	// default options forbid tags in the unprotected header
+	var got Sign1Message
+	if err := got.UnmarshalCBOR(data); err == nil {
+		t.Error("Sign1Message.UnmarshalCBOR() error = nil, want tag error")
+	}
+
+	// custom options decode the tag as the registered type
+	if err := got.UnmarshalCBORWithOptions(data, opts); err != nil {
+		t.Fatalf("Sign1Message.UnmarshalCBORWithOptions() error = %v", err)
+	}
+	if v := got.Headers.Protected["uuid"]; v != uuid {
+		t.Errorf("Sign1Message.UnmarshalCBORWithOptions() protected uuid = %v, want %v", v, uuid)
+	}
+	if v := got.Headers.Unprotected["uuid"]; v != uuid {
+		t.Errorf("Sign1Message.UnmarshalCBORWithOptions() unprotected uuid = %v, want %v", v, uuid)
+	}
+	if alg, err := got.Headers.Protected.Algorithm(); err != nil || alg != AlgorithmES256 {
+		t.Errorf("Sign1Message.UnmarshalCBORWithOptions() alg = %v, %v", alg, err)
+	}
+}
+
+func TestCBOROptions_SignMessage(t *testing.T) {
+	opts := testCBOROptions(t)
+	uuid := testUUID{0x05}
+	msg := &SignMessage{
+		Headers: Headers{
+			Protected:   ProtectedHeader{"uuid": uuid},
+			Unprotected: UnprotectedHeader{},
+		},
+		Payload: []byte("foo"),
+		Signatures: []*Signature{
+			{
+				Headers: Headers{
+					Protected: ProtectedHeader{
+						HeaderLabelAlgorithm: AlgorithmES256,
+					},
+					Unprotected: UnprotectedHeader{"uuid": uuid},
+				},
+				Signature: []byte("bar"),
+			},
+		},
+	}
+	data, err := msg.MarshalCBORWithOptions(opts)
+	if err != nil {
+		t.Fatalf("SignMessage.MarshalCBORWithOptions() error = %v", err)
+	}
+	var got SignMessage
+	if err := got.UnmarshalCBORWithOptions(data, opts); err != nil {
+		t.Fatalf("SignMessage.UnmarshalCBORWithOptions() error = %v", err)
+	}
+	if v := got.Headers.Protected["uuid"]; v != uuid {
+		t.Errorf("SignMessage.UnmarshalCBORWithOptions() protected uuid = %v, want %v", v, uuid)
+	}
+	if v := got.Signatures[0].Headers.Unprotected["uuid"]; v != uuid {
+		t.Errorf("SignMessage.UnmarshalCBORWithOptions() signature uuid = %v, want %v", v, uuid)
+	}
+}
+
+func TestCBOROptions_enforced(t *testing.T) {
+	t.Run("non-deterministic protected header", func(t *testing.T) {
+		em, err := cbor.EncOptions{Sort: cbor.SortNone}.EncMode()
+		if err != nil {
+			t.Fatalf("EncMode() error = %v", err)
+		}
+		h := Headers{
+			Protected: ProtectedHeader{HeaderLabelAlgorithm: AlgorithmES256},
+		}
+		_, err = h.MarshalProtectedWithOptions(&CBOROptions{EncMode: em})
+		want := "cbor: protected header: require core deterministic sort mode"
+		if err == nil || err.Error() != want {
+			t.Errorf("Headers.MarshalProtectedWithOptions() error = %v, want %v", err, want)
+		}
+	})
+
+	t.Run("duplicated labels with lenient decoder", func(t *testing.T) {
+		dm, err := cbor.DecOptions{DupMapKey: cbor.DupMapKeyQuiet}.DecMode()
+		if err != nil {
+			t.Fatalf("DecMode() error = %v", err)
+		}
+		h := Headers{
+			RawProtected:   []byte{0x40},
+			RawUnprotected: []byte{0xa2, 0x04, 0x41, 0x01, 0x04, 0x41, 0x02},
+		}
+		if err := h.UnmarshalFromRawWithOptions(&CBOROptions{DecMode: dm}); err == nil {
+			t.Error("Headers.UnmarshalFromRawWithOptions() error = nil, want duplicated key error")
+		}
+	})
+
+	t.Run("labels with plain decoder", func(t *testing.T) {
+		dm, err := cbor.DecOptions{}.DecMode()
+		if err != nil {
+			t.Fatalf("DecMode() error = %v", err)
+		}
+		h := Headers{
+			RawProtected:   []byte{0x43, 0xa1, 0x01, 0x26},
+			RawUnprotected: []byte{0xa1, 0x04, 0x41, 0x01},
+		}
+		if err := h.UnmarshalFromRawWithOptions(&CBOROptions{DecMode: dm}); err != nil {
+			t.Fatalf("Headers.UnmarshalFromRawWithOptions() error = %v", err)
+		}
+		alg, err := h.Protected.Algorithm()
+		if err != nil || alg != AlgorithmES256 {
+			t.Errorf("ProtectedHeader.Algorithm() = %v, %v, want %v", alg, err, AlgorithmES256)
+		}
+		if _, ok := h.Unprotected[HeaderLabelKeyID]; !ok {
+			t.Errorf("UnprotectedHeader = %v, want kid", h.Unprotected)
+		}
+	})
+
+	t.Run("tags in outer structure", func(t *testing.T) {
+		data := []byte{
+			0xd2, 0x84,
+			0x40, 0xa0,
+			0xc2, 0x40, // tagged payload
+			0x41, 0x00,
+		}
+		var msg Sign1Message
+		if err := msg.UnmarshalCBORWithOptions(data, testCBOROptions(t)); err == nil {
+			t.Error("Sign1Message.UnmarshalCBORWithOptions() error = nil, want tag error")
+		}
+	})
+}
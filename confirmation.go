@@ -0,0 +1,209 @@
+package cose
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// Confirmation methods of the cnf claim, registered in the IANA "CWT
+// Confirmation Methods" registry.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc8747.html#section-7.2
+const (
+	ConfirmationMethodCOSEKey          int64 = 1
+	ConfirmationMethodEncryptedCOSEKey int64 = 2
+	ConfirmationMethodKeyID            int64 = 3
+)
+
+// Confirmation is the typed form of the cnf claim, identifying the
+// proof-of-possession key of the presenter of a CWT.
+// Exactly one confirmation method is set.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc8747.html#section-3
+type Confirmation struct {
+	// Key is the COSE_Key of the presenter.
+	Key *Key
+
+	// EncryptedKey is the CBOR encoded COSE_Encrypt or COSE_Encrypt0 object
+	// of the Encrypted_COSE_Key of the presenter.
+	EncryptedKey cbor.RawMessage
+
+	// KeyID is the key identifier of the presenter.
+	KeyID []byte
+}
+
+// ConfirmationKeyResolver resolves the confirmation keys which are not
+// embedded in the cnf claim.
+type ConfirmationKeyResolver interface {
+	// ResolveKeyID returns the key identified by the kid confirmation method.
+	ResolveKeyID(kid []byte) (*Key, error)
+
+	// DecryptKey decrypts the Encrypted_COSE_Key confirmation method, a CBOR
+	// encoded COSE_Encrypt or COSE_Encrypt0 object, into a COSE_Key.
+	DecryptKey(encrypted []byte) (*Key, error)
+}
+
+// ConfirmationClaim returns the typed form of the cnf claim, or nil if the
+// claim is not present.
+func (s *CWTClaimsSet) ConfirmationClaim() (*Confirmation, error) {
+	if s.Confirmation == nil {
+		return nil, nil
+	}
+	var c Confirmation
+	methods := 0
+	for label, value := range s.Confirmation {
+		label, ok := normalizeLabel(label)
+		if !ok {
+			return nil, errors.New("cwt claim: cnf: require int / tstr label")
+		}
+		switch label {
+		case ConfirmationMethodCOSEKey:
+			key, err := confirmationKey(value)
+			if err != nil {
+				return nil, fmt.Errorf("cwt claim: cnf: COSE_Key: %w", err)
+			}
+			c.Key = key
+		case ConfirmationMethodEncryptedCOSEKey:
+			switch v := value.(type) {
+			case cbor.RawMessage:
+				c.EncryptedKey = v
+			case []any, cbor.Tag:
+				data, err := encMode.Marshal(v)
+				if err != nil {
+					return nil, fmt.Errorf("cwt claim: cnf: Encrypted_COSE_Key: %w", err)
+				}
+				c.EncryptedKey = data
+			default:
+				return nil, errors.New("cwt claim: cnf: Encrypted_COSE_Key: require COSE_Encrypt / COSE_Encrypt0")
+			}
+		case ConfirmationMethodKeyID:
+			if !canBstr(value) {
+				return nil, errors.New("cwt claim: cnf: kid: require bstr")
+			}
+			c.KeyID = value.([]byte)
+		default:
+			continue
+		}
+		methods++
+	}
+	if methods != 1 {
+		return nil, errors.New("cwt claim: cnf: require exactly one supported confirmation method")
+	}
+	return &c, nil
+}
+
+// SetConfirmationClaim sets the cnf claim from its typed form.
+func (s *CWTClaimsSet) SetConfirmationClaim(c *Confirmation) error {
+	if c == nil {
+		return errors.New("cwt claim: cnf: require confirmation")
+	}
+	cnf := make(map[any]any, 1)
+	if c.Key != nil {
+		cnf[ConfirmationMethodCOSEKey] = c.Key
+	}
+	if c.EncryptedKey != nil {
+		cnf[ConfirmationMethodEncryptedCOSEKey] = c.EncryptedKey
+	}
+	if c.KeyID != nil {
+		cnf[ConfirmationMethodKeyID] = c.KeyID
+	}
+	if len(cnf) != 1 {
+		return errors.New("cwt claim: cnf: require exactly one supported confirmation method")
+	}
+	s.Confirmation = cnf
+	return nil
+}
+
+// ResolveKey returns the proof-of-possession key of the confirmation.
+// The resolver is only used for the kid and the Encrypted_COSE_Key
+// confirmation methods, and may be nil otherwise.
+func (c *Confirmation) ResolveKey(resolver ConfirmationKeyResolver) (*Key, error) {
+	switch {
+	case c.Key != nil:
+		return c.Key, nil
+	case resolver == nil:
+		return nil, errors.New("cnf: require key resolver")
+	case c.EncryptedKey != nil:
+		return resolver.DecryptKey(c.EncryptedKey)
+	case c.KeyID != nil:
+		return resolver.ResolveKeyID(c.KeyID)
+	}
+	return nil, errors.New("cnf: missing confirmation method")
+}
+
+// Verifier returns a Verifier for the proof-of-possession key of the
+// confirmation, created by Key.Verifier.
+func (c *Confirmation) Verifier(resolver ConfirmationKeyResolver) (Verifier, error) {
+	key, err := c.ResolveKey(resolver)
+	if err != nil {
+		return nil, err
+	}
+	return key.Verifier()
+}
+
+// VerifyProofOfPossession verifies a message signed by the presenter of a CWT
+// with the proof-of-possession key of the cnf claim of its claims set.
+// If the cnf claim identifies the key by kid, the kid header parameter of the
+// message, if present, must be equal.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc8747.html#section-3
+func VerifyProofOfPossession(msg *Sign1Message, external []byte, claims *CWTClaimsSet, resolver ConfirmationKeyResolver) error {
+	return VerifyProofOfPossessionWithOptions(msg, external, claims, resolver, nil)
+}
+
+// VerifyProofOfPossessionWithOptions verifies a message signed by the
+// presenter of a CWT as [VerifyProofOfPossession] does, with the options
+// specified by opts.
+func VerifyProofOfPossessionWithOptions(msg *Sign1Message, external []byte, claims *CWTClaimsSet, resolver ConfirmationKeyResolver, opts *VerifyOptions) error {
+	if msg == nil {
+		return errors.New("verifying nil Sign1Message")
+	}
+	if claims == nil {
+		return errors.New("verifying with nil CWTClaimsSet")
+	}
+	c, err := claims.ConfirmationClaim()
+	if err != nil {
+		return err
+	}
+	if c == nil {
+		return fmt.Errorf("cwt claim: cnf: %w", ErrCWTMissingClaim)
+	}
+	if c.KeyID != nil {
+		kid, err := msg.Headers.KeyID()
+		if err != nil {
+			return err
+		}
+		if kid != nil && !bytes.Equal(kid, c.KeyID) {
+			return errors.New("header parameter: kid: confirmation key mismatch")
+		}
+	}
+	verifier, err := c.Verifier(resolver)
+	if err != nil {
+		return err
+	}
+	return msg.VerifyWithOptions(external, verifier, opts)
+}
+
+// confirmationKey returns the COSE_Key of a cnf claim value.
+func confirmationKey(value any) (*Key, error) {
+	switch v := value.(type) {
+	case *Key:
+		return v, nil
+	case Key:
+		return &v, nil
+	case map[any]any:
+		data, err := encMode.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		var key Key
+		if err := key.UnmarshalCBOR(data); err != nil {
+			return nil, err
+		}
+		return &key, nil
+	}
+	return nil, errors.New("require map")
+}
@@ -0,0 +1,293 @@
+package cose
+
+import (
+	"crypto/rand"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testConfirmationKeyResolver resolves keys by kid, and "decrypts" keys by
+// decoding them.
+type testConfirmationKeyResolver map[string]*Key
+
+func (r testConfirmationKeyResolver) ResolveKeyID(kid []byte) (*Key, error) {
+	key, ok := r[string(kid)]
+	if !ok {
+		return nil, errors.New("unknown kid")
+	}
+	return key, nil
+}
+
+func (r testConfirmationKeyResolver) DecryptKey(encrypted []byte) (*Key, error) {
+	var key Key
+	if err := key.UnmarshalCBOR(encrypted); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func TestCWTClaimsSet_ConfirmationClaim(t *testing.T) {
+	privateKey := generateTestECDSAKey(t)
+	key, err := NewKeyFromPublic(privateKey.Public())
+	if err != nil {
+		t.Fatalf("NewKeyFromPublic() error = %v", err)
+	}
+	encodedKey, err := key.MarshalCBOR()
+	if err != nil {
+		t.Fatalf("Key.MarshalCBOR() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		cnf     *Confirmation
+		want    *Confirmation
+		wantErr string
+	}{
+		{
+			name: "COSE_Key",
+			cnf:  &Confirmation{Key: key},
+			want: &Confirmation{Key: key},
+		},
+		{
+			name: "Encrypted_COSE_Key",
+			cnf:  &Confirmation{EncryptedKey: []byte{0x83, 0x40, 0xa0, 0xf6}},
+			want: &Confirmation{EncryptedKey: []byte{0x83, 0x40, 0xa0, 0xf6}},
+		},
+		{
+			name: "kid",
+			cnf:  &Confirmation{KeyID: []byte("holder")},
+			want: &Confirmation{KeyID: []byte("holder")},
+		},
+		{
+			name:    "no method",
+			cnf:     &Confirmation{},
+			wantErr: "cwt claim: cnf: require exactly one supported confirmation method",
+		},
+		{
+			name:    "multiple methods",
+			cnf:     &Confirmation{Key: key, KeyID: []byte("holder")},
+			wantErr: "cwt claim: cnf: require exactly one supported confirmation method",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s CWTClaimsSet
+			err := s.SetConfirmationClaim(tt.cnf)
+			if err != nil && (err.Error() != tt.wantErr) {
+				t.Errorf("CWTClaimsSet.SetConfirmationClaim() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			} else if err == nil && tt.wantErr != "" {
+				t.Errorf("CWTClaimsSet.SetConfirmationClaim() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr != "" {
+				return
+			}
+
+			// round trip through CBOR
+			data, err := s.MarshalCBOR()
+			if err != nil {
+				t.Fatalf("CWTClaimsSet.MarshalCBOR() error = %v", err)
+			}
+			var decoded CWTClaimsSet
+			if err := decoded.UnmarshalCBOR(data); err != nil {
+				t.Fatalf("CWTClaimsSet.UnmarshalCBOR() error = %v", err)
+			}
+			got, err := decoded.ConfirmationClaim()
+			if err != nil {
+				t.Fatalf("CWTClaimsSet.ConfirmationClaim() error = %v", err)
+			}
+			if tt.want.Key != nil {
+				if gotKey, _ := got.Key.MarshalCBOR(); !reflect.DeepEqual(gotKey, encodedKey) {
+					t.Errorf("CWTClaimsSet.ConfirmationClaim().Key = %v, want %v", got.Key, tt.want.Key)
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CWTClaimsSet.ConfirmationClaim() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// invalid claims
+	for _, tt := range []struct {
+		cnf     map[any]any
+		wantErr string
+	}{
+		{
+			cnf:     map[any]any{ConfirmationMethodCOSEKey: "key"},
+			wantErr: "cwt claim: cnf: COSE_Key: require map",
+		},
+		{
+			cnf:     map[any]any{ConfirmationMethodCOSEKey: map[any]any{int64(1): int64(0)}},
+			wantErr: "cwt claim: cnf: COSE_Key: kty: invalid value 0",
+		},
+		{
+			cnf:     map[any]any{ConfirmationMethodEncryptedCOSEKey: []byte{0x00}},
+			wantErr: "cwt claim: cnf: Encrypted_COSE_Key: require COSE_Encrypt / COSE_Encrypt0",
+		},
+		{
+			cnf:     map[any]any{ConfirmationMethodKeyID: "holder"},
+			wantErr: "cwt claim: cnf: kid: require bstr",
+		},
+		{
+			cnf:     map[any]any{int64(42): []byte{}},
+			wantErr: "cwt claim: cnf: require exactly one supported confirmation method",
+		},
+	} {
+		s := CWTClaimsSet{Confirmation: tt.cnf}
+		if _, err := s.ConfirmationClaim(); err == nil || err.Error() != tt.wantErr {
+			t.Errorf("CWTClaimsSet.ConfirmationClaim() error = %v, wantErr %v", err, tt.wantErr)
+		}
+	}
+}
+
+func TestVerifyProofOfPossession(t *testing.T) {
+	holderKey := generateTestECDSAKey(t)
+	key, err := NewKeyFromPublic(holderKey.Public())
+	if err != nil {
+		t.Fatalf("NewKeyFromPublic() error = %v", err)
+	}
+	encodedKey, err := key.MarshalCBOR()
+	if err != nil {
+		t.Fatalf("Key.MarshalCBOR() error = %v", err)
+	}
+	signer, err := NewSigner(AlgorithmES256, holderKey)
+	if err != nil {
+		t.Fatalf("NewSigner() error = %v", err)
+	}
+	otherKey, err := NewKeyFromPublic(generateTestECDSAKey(t).Public())
+	if err != nil {
+		t.Fatalf("NewKeyFromPublic() error = %v", err)
+	}
+	resolver := testConfirmationKeyResolver{
+		"holder": key,
+		"other":  otherKey,
+	}
+
+	tests := []struct {
+		name     string
+		cnf      *Confirmation
+		kid      []byte
+		resolver ConfirmationKeyResolver
+		wantErr  error
+	}{
+		{
+			name: "COSE_Key",
+			cnf:  &Confirmation{Key: key},
+		},
+		{
+			name:     "Encrypted_COSE_Key",
+			cnf:      &Confirmation{EncryptedKey: encodedKey},
+			resolver: resolver,
+		},
+		{
+			name:     "kid",
+			cnf:      &Confirmation{KeyID: []byte("holder")},
+			kid:      []byte("holder"),
+			resolver: resolver,
+		},
+		{
+			name:    "wrong COSE_Key",
+			cnf:     &Confirmation{Key: otherKey},
+			wantErr: ErrVerification,
+		},
+		{
+			name:     "wrong kid",
+			cnf:      &Confirmation{KeyID: []byte("other")},
+			resolver: resolver,
+			wantErr:  ErrVerification,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := &CWTClaimsSet{}
+			if err := claims.SetConfirmationClaim(tt.cnf); err != nil {
+				t.Fatalf("CWTClaimsSet.SetConfirmationClaim() error = %v", err)
+			}
+			msg := NewSign1Message()
+			msg.Payload = []byte("nonce")
+			msg.Headers.Protected.SetAlgorithm(AlgorithmES256)
+			if tt.kid != nil {
+				msg.Headers.Protected[HeaderLabelKeyID] = tt.kid
+			}
+			if err := msg.Sign(rand.Reader, nil, signer); err != nil {
+				t.Fatalf("Sign1Message.Sign() error = %v", err)
+			}
+			if err := VerifyProofOfPossession(msg, nil, claims, tt.resolver); err != tt.wantErr {
+				t.Errorf("VerifyProofOfPossession() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// error cases
+	msg := NewSign1Message()
+	msg.Payload = []byte("nonce")
+	msg.Headers.Protected.SetAlgorithm(AlgorithmES256)
+	msg.Headers.Protected[HeaderLabelKeyID] = []byte("other")
+	if err := msg.Sign(rand.Reader, nil, signer); err != nil {
+		t.Fatalf("Sign1Message.Sign() error = %v", err)
+	}
+	for _, tt := range []struct {
+		name     string
+		claims   *CWTClaimsSet
+		resolver ConfirmationKeyResolver
+		wantErr  string
+	}{
+		{
+			name:    "missing cnf",
+			claims:  &CWTClaimsSet{},
+			wantErr: "cwt claim: cnf: missing claim",
+		},
+		{
+			name:     "kid mismatch",
+			claims:   &CWTClaimsSet{Confirmation: map[any]any{ConfirmationMethodKeyID: []byte("holder")}},
+			resolver: resolver,
+			wantErr:  "header parameter: kid: confirmation key mismatch",
+		},
+		{
+			name:    "missing resolver",
+			claims:  &CWTClaimsSet{Confirmation: map[any]any{ConfirmationMethodEncryptedCOSEKey: []any{[]byte{}, map[any]any{}, nil}}},
+			wantErr: "cnf: require key resolver",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyProofOfPossession(msg, nil, tt.claims, tt.resolver)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("VerifyProofOfPossession() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// kid in both headers
+	msg.Headers.Unprotected[HeaderLabelKeyID] = []byte("holder")
+	kidClaims := &CWTClaimsSet{Confirmation: map[any]any{ConfirmationMethodKeyID: []byte("holder")}}
+	if err := VerifyProofOfPossession(msg, nil, kidClaims, resolver); !errors.Is(err, ErrDuplicateHeaderParameter) {
+		t.Errorf("VerifyProofOfPossession() error = %v, want %v", err, ErrDuplicateHeaderParameter)
+	}
+
+	// proof of possession of a signed CWT
+	exp := NewNumericDate(time.Now().Add(time.Hour))
+	claims := &CWTClaimsSet{ExpirationTime: &exp}
+	if err := claims.SetConfirmationClaim(&Confirmation{Key: key}); err != nil {
+		t.Fatalf("CWTClaimsSet.SetConfirmationClaim() error = %v", err)
+	}
+	token, err := SignCWT(rand.Reader, signer, Headers{}, claims, true)
+	if err != nil {
+		t.Fatalf("SignCWT() error = %v", err)
+	}
+	_, parsed, err := ParseCWT(token)
+	if err != nil {
+		t.Fatalf("ParseCWT() error = %v", err)
+	}
+	msg.Headers.Protected = ProtectedHeader{HeaderLabelAlgorithm: AlgorithmES256}
+	msg.Signature = nil
+	if err := msg.Sign(rand.Reader, nil, signer); err != nil {
+		t.Fatalf("Sign1Message.Sign() error = %v", err)
+	}
+	if err := VerifyProofOfPossession(msg, nil, parsed, nil); err != nil {
+		t.Errorf("VerifyProofOfPossession() error = %v", err)
+	}
+}
@@ -110,6 +110,41 @@ func TestConformance(t *testing.T) {
 	}
 }
 
+func TestConformance_cborDiag(t *testing.T) {
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", tt.name+".json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var tc TestCase
+			err = json.Unmarshal(data, &tc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var samples []*CBOR
+			if tc.Sign1 != nil {
+				samples = append(samples, tc.Sign1.ProtectedHeaders, tc.Sign1.UnprotectedHeaders, &tc.Sign1.TBS, &tc.Sign1.Output)
+			}
+			if tc.Verify1 != nil {
+				samples = append(samples, &tc.Verify1.TaggedCOSESign1)
+			}
+			for _, sample := range samples {
+				if sample == nil || sample.CBORDiag == "" {
+					continue
+				}
+				got, err := cose.ParseDiagnostic(sample.CBORDiag)
+				if err != nil {
+					t.Fatalf("ParseDiagnostic(%q) error = %v", sample.CBORDiag, err)
+				}
+				if want := mustHexToBytes(sample.CBORHex); !bytes.Equal(got, want) {
+					t.Errorf("ParseDiagnostic(%q) = %x, want %x", sample.CBORDiag, got, want)
+				}
+			}
+		})
+	}
+}
+
 func testVerify1(t *testing.T, tc *TestCase, wantErr string) {
 	var err error
 	defer func() {
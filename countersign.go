@@ -99,6 +99,11 @@ func (s *Countersignature) Sign(rand io.Reader, signer Signer, parent any, exter
 // error if verification fails.
 // Verifying a COSE_Countersignature requires the parent message.
 //
+// Countersignatures with critical header parameters not defined by this
+// library are rejected. Use [Countersignature.VerifyWithOptions] to verify
+// countersignatures with critical header parameters understood by the
+// application.
+//
 // Reference: https://datatracker.ietf.org/doc/html/rfc8152#section-4.4
 //
 // # Experimental
@@ -106,6 +111,17 @@ func (s *Countersignature) Sign(rand io.Reader, signer Signer, parent any, exter
 // Notice: The COSE Sign API is EXPERIMENTAL and may be changed or removed in a
 // later release.
 func (s *Countersignature) Verify(verifier Verifier, parent any, external []byte) error {
+	return s.VerifyWithOptions(verifier, parent, external, nil)
+}
+
+// VerifyWithOptions verifies the countersignature as
+// [Countersignature.Verify] does, with the options specified by opts.
+//
+// # Experimental
+//
+// Notice: The COSE Sign API is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func (s *Countersignature) VerifyWithOptions(verifier Verifier, parent any, external []byte, opts *VerifyOptions) error {
 	if s == nil {
 		return errors.New("verifying nil Countersignature")
 	}
@@ -120,6 +136,9 @@ func (s *Countersignature) Verify(verifier Verifier, parent any, external []byte
 	if err != nil {
 		return err
 	}
+	if err := ensureCriticalUnderstood(s.Headers.Protected, opts, false); err != nil {
+		return err
+	}
 
 	// verify the message
 	toBeSigned, err := s.toBeSigned(parent, external)
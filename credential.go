@@ -0,0 +1,190 @@
+package cose
+
+import (
+	"errors"
+	"fmt"
+)
+
+// KCWT returns the CBOR Web Token carried in the kcwt header parameter of the
+// protected header, with its claims set, or nil if the header parameter is not
+// present.
+// The kcwt header parameter is not allowed in the unprotected header, which
+// can not hold the tagged COSE_Sign1 object of a CWT.
+//
+// The signature and the claims of the CWT are not verified, see
+// [VerifySign1WithCredential].
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9528.html#section-10.6
+func (h *Headers) KCWT() (*Sign1Message, *CWTClaimsSet, error) {
+	if hasLabel(h.Unprotected, HeaderLabelKCWT) {
+		return nil, nil, errors.New("header parameter: kcwt: not allowed")
+	}
+	value, ok, err := headerParameter(h.Protected, true, HeaderLabelKCWT)
+	if !ok {
+		return nil, nil, err
+	}
+	data, err := encMode.Marshal(value)
+	if err != nil {
+		return nil, nil, fmt.Errorf("header parameter: kcwt: %w", err)
+	}
+	msg, claims, err := ParseCWT(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("header parameter: kcwt: %w", err)
+	}
+	return msg, claims, nil
+}
+
+// SetKCWT sets the encoded CBOR Web Token in the kcwt header parameter of the
+// protected header.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9528.html#section-10.6
+func (h *Headers) SetKCWT(token []byte) error {
+	if _, _, err := ParseCWT(token); err != nil {
+		return fmt.Errorf("header parameter: kcwt: %w", err)
+	}
+	var value any
+	if err := decMode.Unmarshal(token, &value); err != nil {
+		return fmt.Errorf("header parameter: kcwt: %w", err)
+	}
+	if h.Protected == nil {
+		h.Protected = make(ProtectedHeader)
+	}
+	return setHeaderParameter(h.Protected, true, HeaderLabelKCWT, value)
+}
+
+// KCCS returns the CWT Claims Set carried in the kccs header parameter of the
+// protected or the unprotected header, or nil if the header parameter is not
+// present.
+// It fails with ErrDuplicateHeaderParameter if both headers contain a CWT
+// Claims Set.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9528.html#section-10.6
+func (h *Headers) KCCS() (*CWTClaimsSet, error) {
+	if err := h.ensureSingleBucket(HeaderLabelKCCS); err != nil {
+		return nil, err
+	}
+	bucket, protected := map[any]any(h.Protected), true
+	if !hasLabel(bucket, HeaderLabelKCCS) {
+		bucket, protected = h.Unprotected, false
+	}
+	value, ok, err := headerParameter(bucket, protected, HeaderLabelKCCS)
+	if !ok {
+		return nil, err
+	}
+	claims, _ := value.(map[any]any)
+	if c, isClaims := value.(CWTClaims); isClaims {
+		claims = c
+	}
+	s, err := CWTClaims(claims).ClaimsSet()
+	if err != nil {
+		return nil, fmt.Errorf("header parameter: kccs: %w", err)
+	}
+	return s, nil
+}
+
+// SetKCCS sets the CWT Claims Set in the kccs header parameter of the
+// protected header.
+// It fails with ErrDuplicateHeaderParameter if the unprotected header contains
+// a CWT Claims Set.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9528.html#section-10.6
+func (h *Headers) SetKCCS(claims *CWTClaimsSet) error {
+	if hasLabel(h.Unprotected, HeaderLabelKCCS) {
+		return fmt.Errorf("header parameter: kccs: %w", ErrDuplicateHeaderParameter)
+	}
+	if claims == nil {
+		return errors.New("header parameter: kccs: require claims set")
+	}
+	c, err := claims.Claims()
+	if err != nil {
+		return fmt.Errorf("header parameter: kccs: %w", err)
+	}
+	if h.Protected == nil {
+		h.Protected = make(ProtectedHeader)
+	}
+	return setHeaderParameter(h.Protected, true, HeaderLabelKCCS, c)
+}
+
+// VerifySign1WithCredential verifies a Sign1Message with the COSE_Key of the
+// cnf claim of the credential carried in its kcwt or kccs header parameter,
+// and returns the claims set of the credential.
+//
+// A kcwt credential is a CWT whose signature is verified with issuer, and
+// whose claims are validated with validator, if not nil.
+// A kccs credential is not authenticated: its claims are validated with
+// validator, if not nil, and the application must trust the returned claims
+// set by other means, e.g. by comparing it to a stored credential. issuer is
+// not used for kccs credentials.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9528.html#section-3.5.2
+func VerifySign1WithCredential(msg *Sign1Message, external []byte, issuer Verifier, validator *CWTValidator) (*CWTClaimsSet, error) {
+	if msg == nil {
+		return nil, errors.New("verifying nil Sign1Message")
+	}
+	h := &msg.Headers
+	hasKCWT := hasLabel(h.Protected, HeaderLabelKCWT) || hasLabel(h.Unprotected, HeaderLabelKCWT)
+	hasKCCS := hasLabel(h.Protected, HeaderLabelKCCS) || hasLabel(h.Unprotected, HeaderLabelKCCS)
+
+	var claims *CWTClaimsSet
+	var name string
+	switch {
+	case hasKCWT && hasKCCS:
+		return nil, errors.New("header parameter: kcwt, kccs: require a single credential")
+	case hasKCWT:
+		name = "kcwt"
+		token, c, err := h.KCWT()
+		if err != nil {
+			return nil, err
+		}
+		if issuer == nil {
+			return nil, errors.New("kcwt: require issuer verifier")
+		}
+		if err := token.Verify(nil, issuer); err != nil {
+			return nil, fmt.Errorf("kcwt: %w", err)
+		}
+		claims = c
+	case hasKCCS:
+		name = "kccs"
+		c, err := h.KCCS()
+		if err != nil {
+			return nil, err
+		}
+		claims = c
+	default:
+		return nil, errors.New("header parameter: kcwt, kccs: missing credential")
+	}
+	if validator != nil {
+		if err := validator.Validate(claims); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	verifier, err := credentialVerifier(name, claims)
+	if err != nil {
+		return nil, err
+	}
+	if err := msg.Verify(external, verifier); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// credentialVerifier returns a Verifier for the COSE_Key of the cnf claim of
+// a credential. name prefixes the errors.
+func credentialVerifier(name string, claims *CWTClaimsSet) (Verifier, error) {
+	cnf, err := claims.ConfirmationClaim()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if cnf == nil {
+		return nil, fmt.Errorf("%s: cwt claim: cnf: %w", name, ErrCWTMissingClaim)
+	}
+	if cnf.Key == nil {
+		return nil, fmt.Errorf("%s: cwt claim: cnf: require COSE_Key", name)
+	}
+	verifier, err := cnf.Key.Verifier()
+	if err != nil {
+		return nil, fmt.Errorf("%s: cwt claim: cnf: %w", name, err)
+	}
+	return verifier, nil
+}
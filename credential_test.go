@@ -0,0 +1,339 @@
+package cose
+
+import (
+	"crypto/rand"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+func TestHeaders_KCCS(t *testing.T) {
+	key, err := NewKeyFromPublic(generateTestECDSAKey(t).Public())
+	if err != nil {
+		t.Fatalf("NewKeyFromPublic() error = %v", err)
+	}
+	claims := &CWTClaimsSet{Subject: "42-50-31-FF-EF-37-32-39"}
+	if err := claims.SetConfirmationClaim(&Confirmation{Key: key}); err != nil {
+		t.Fatalf("CWTClaimsSet.SetConfirmationClaim() error = %v", err)
+	}
+
+	var h Headers
+	if err := h.SetKCCS(claims); err != nil {
+		t.Fatalf("Headers.SetKCCS() error = %v", err)
+	}
+	protected, unprotected, err := h.marshal(nil)
+	if err != nil {
+		t.Fatalf("Headers.marshal() error = %v", err)
+	}
+	got := Headers{
+		RawProtected:   protected,
+		RawUnprotected: unprotected,
+	}
+	if err := got.UnmarshalFromRaw(); err != nil {
+		t.Fatalf("Headers.UnmarshalFromRaw() error = %v", err)
+	}
+	gotClaims, err := got.KCCS()
+	if err != nil {
+		t.Fatalf("Headers.KCCS() error = %v", err)
+	}
+	if gotClaims.Subject != claims.Subject {
+		t.Errorf("Headers.KCCS().Subject = %v, want %v", gotClaims.Subject, claims.Subject)
+	}
+	if cnf, err := gotClaims.ConfirmationClaim(); err != nil || cnf.Key == nil {
+		t.Errorf("Headers.KCCS().ConfirmationClaim() = %v, %v, want COSE_Key", cnf, err)
+	}
+
+	tests := []struct {
+		name    string
+		h       Headers
+		wantErr string
+	}{
+		{
+			name: "duplicate",
+			h: Headers{
+				Protected:   ProtectedHeader{HeaderLabelKCCS: map[any]any{}},
+				Unprotected: UnprotectedHeader{HeaderLabelKCCS: map[any]any{}},
+			},
+			wantErr: "header parameter: kccs: header parameter present in both protected and unprotected headers",
+		},
+		{
+			name: "invalid type",
+			h: Headers{
+				Protected: ProtectedHeader{HeaderLabelKCCS: []byte{}},
+			},
+			wantErr: "header parameter: kccs: require map type",
+		},
+		{
+			name: "invalid claims",
+			h: Headers{
+				Unprotected: UnprotectedHeader{HeaderLabelKCCS: map[any]any{CWTClaimSubject: 42}},
+			},
+			wantErr: "header parameter: kccs: cwt claim: sub: require tstr",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.h.KCCS(); err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Headers.KCCS() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHeaders_KCWT(t *testing.T) {
+	issuerSigner, err := NewSigner(AlgorithmES256, generateTestECDSAKey(t))
+	if err != nil {
+		t.Fatalf("NewSigner() error = %v", err)
+	}
+	claims := &CWTClaimsSet{Issuer: "issuer.example"}
+	token, err := SignCWT(rand.Reader, issuerSigner, Headers{}, claims, true)
+	if err != nil {
+		t.Fatalf("SignCWT() error = %v", err)
+	}
+
+	var h Headers
+	if err := h.SetKCWT(token); err != nil {
+		t.Fatalf("Headers.SetKCWT() error = %v", err)
+	}
+	protected, unprotected, err := h.marshal(nil)
+	if err != nil {
+		t.Fatalf("Headers.marshal() error = %v", err)
+	}
+	got := Headers{
+		RawProtected:   protected,
+		RawUnprotected: unprotected,
+	}
+	if err := got.UnmarshalFromRaw(); err != nil {
+		t.Fatalf("Headers.UnmarshalFromRaw() error = %v", err)
+	}
+	msg, gotClaims, err := got.KCWT()
+	if err != nil {
+		t.Fatalf("Headers.KCWT() error = %v", err)
+	}
+	if !reflect.DeepEqual(gotClaims, claims) {
+		t.Errorf("Headers.KCWT() claims = %v, want %v", gotClaims, claims)
+	}
+	if msg == nil || len(msg.Signature) == 0 {
+		t.Errorf("Headers.KCWT() message = %v, want signed message", msg)
+	}
+
+	wantErr := "header parameter: kcwt: cbor: invalid COSE_Sign1 object"
+	if err := h.SetKCWT([]byte{0xd2, 0x80}); err == nil || err.Error() != wantErr {
+		t.Errorf("Headers.SetKCWT() error = %v, wantErr %v", err, wantErr)
+	}
+	h = Headers{Protected: ProtectedHeader{HeaderLabelKCWT: "token"}}
+	wantErr = "header parameter: kcwt: require COSE_Messages type"
+	if _, _, err := h.KCWT(); err == nil || err.Error() != wantErr {
+		t.Errorf("Headers.KCWT() error = %v, wantErr %v", err, wantErr)
+	}
+
+	// kcwt is not allowed in the unprotected header, whether tagged or not
+	var value any
+	if err := decMode.Unmarshal(token, &value); err != nil {
+		t.Fatalf("cbor.Unmarshal() error = %v", err)
+	}
+	h = Headers{Unprotected: UnprotectedHeader{HeaderLabelKCWT: value}}
+	wantErr = "header parameter: kcwt: not allowed"
+	if _, _, err := h.KCWT(); err == nil || err.Error() != wantErr {
+		t.Errorf("Headers.KCWT() error = %v, wantErr %v", err, wantErr)
+	}
+	msg = &Sign1Message{
+		Headers: Headers{
+			Protected:   ProtectedHeader{HeaderLabelAlgorithm: AlgorithmES256},
+			Unprotected: UnprotectedHeader{HeaderLabelKCWT: value},
+		},
+		Payload:   []byte("hello world"),
+		Signature: []byte{0x01},
+	}
+	wantErr = "unprotected header: header parameter: kcwt: not allowed"
+	if _, err := msg.MarshalCBOR(); err == nil || err.Error() != wantErr {
+		t.Errorf("Sign1Message.MarshalCBOR() error = %v, wantErr %v", err, wantErr)
+	}
+	msg.Headers.Unprotected[HeaderLabelKCWT] = value.(cbor.Tag).Content
+	if _, err := msg.MarshalCBOR(); err == nil || err.Error() != wantErr {
+		t.Errorf("Sign1Message.MarshalCBOR() error = %v, wantErr %v", err, wantErr)
+	}
+
+	// 18([<<{1: -7}>>, {13: [h'a10126', {}, h'', h'00']}, 'hello world', h'01'])
+	data := []byte{
+		0xd2, 0x84, 0x43, 0xa1, 0x01, 0x26,
+		0xa1, 0x0d, 0x84, 0x43, 0xa1, 0x01, 0x26, 0xa0, 0x40, 0x41, 0x00,
+		0x4b, 'h', 'e', 'l', 'l', 'o', ' ', 'w', 'o', 'r', 'l', 'd',
+		0x41, 0x01,
+	}
+	wantErr = "cbor: invalid unprotected header: unprotected header: header parameter: kcwt: not allowed"
+	var decoded Sign1Message
+	if err := decoded.UnmarshalCBOR(data); err == nil || err.Error() != wantErr {
+		t.Errorf("Sign1Message.UnmarshalCBOR() error = %v, wantErr %v", err, wantErr)
+	}
+}
+
+func TestVerifySign1WithCredential(t *testing.T) {
+	issuerKey := generateTestECDSAKey(t)
+	issuerSigner, err := NewSigner(AlgorithmES256, issuerKey)
+	if err != nil {
+		t.Fatalf("NewSigner() error = %v", err)
+	}
+	issuerVerifier, err := NewVerifier(AlgorithmES256, issuerKey.Public())
+	if err != nil {
+		t.Fatalf("NewVerifier() error = %v", err)
+	}
+	otherVerifier, err := NewVerifier(AlgorithmES256, generateTestECDSAKey(t).Public())
+	if err != nil {
+		t.Fatalf("NewVerifier() error = %v", err)
+	}
+
+	holderKey := generateTestECDSAKey(t)
+	holderSigner, err := NewSigner(AlgorithmES256, holderKey)
+	if err != nil {
+		t.Fatalf("NewSigner() error = %v", err)
+	}
+	key, err := NewKeyFromPublic(holderKey.Public())
+	if err != nil {
+		t.Fatalf("NewKeyFromPublic() error = %v", err)
+	}
+	now := time.Now()
+	exp := NewNumericDate(now.Add(time.Hour))
+	claims := &CWTClaimsSet{
+		Issuer:         "issuer.example",
+		ExpirationTime: &exp,
+	}
+	if err := claims.SetConfirmationClaim(&Confirmation{Key: key}); err != nil {
+		t.Fatalf("CWTClaimsSet.SetConfirmationClaim() error = %v", err)
+	}
+	token, err := SignCWT(rand.Reader, issuerSigner, Headers{}, claims, false)
+	if err != nil {
+		t.Fatalf("SignCWT() error = %v", err)
+	}
+	kidClaims := &CWTClaimsSet{}
+	if err := kidClaims.SetConfirmationClaim(&Confirmation{KeyID: []byte("holder")}); err != nil {
+		t.Fatalf("CWTClaimsSet.SetConfirmationClaim() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		headers   func(h *Headers) error
+		signer    Signer
+		issuer    Verifier
+		validator *CWTValidator
+		wantErr   string
+	}{
+		{
+			name: "kcwt",
+			headers: func(h *Headers) error {
+				return h.SetKCWT(token)
+			},
+			signer:    holderSigner,
+			issuer:    issuerVerifier,
+			validator: &CWTValidator{Issuer: "issuer.example"},
+		},
+		{
+			name: "kccs",
+			headers: func(h *Headers) error {
+				return h.SetKCCS(claims)
+			},
+			signer: holderSigner,
+		},
+		{
+			name: "kcwt from another issuer",
+			headers: func(h *Headers) error {
+				return h.SetKCWT(token)
+			},
+			signer:  holderSigner,
+			issuer:  otherVerifier,
+			wantErr: "kcwt: verification error",
+		},
+		{
+			name: "kcwt without issuer verifier",
+			headers: func(h *Headers) error {
+				return h.SetKCWT(token)
+			},
+			signer:  holderSigner,
+			wantErr: "kcwt: require issuer verifier",
+		},
+		{
+			name: "kccs with rejected claims",
+			headers: func(h *Headers) error {
+				return h.SetKCCS(claims)
+			},
+			signer:    holderSigner,
+			validator: &CWTValidator{Issuer: "other.example"},
+			wantErr:   "kccs: cwt claim: iss: issuer mismatch",
+		},
+		{
+			name: "kccs with kid confirmation",
+			headers: func(h *Headers) error {
+				return h.SetKCCS(kidClaims)
+			},
+			signer:  holderSigner,
+			wantErr: "kccs: cwt claim: cnf: require COSE_Key",
+		},
+		{
+			name: "kccs without cnf",
+			headers: func(h *Headers) error {
+				return h.SetKCCS(&CWTClaimsSet{Issuer: "issuer.example"})
+			},
+			signer:  holderSigner,
+			wantErr: "kccs: cwt claim: cnf: missing claim",
+		},
+		{
+			name: "signed by another key",
+			headers: func(h *Headers) error {
+				return h.SetKCCS(claims)
+			},
+			signer:  issuerSigner,
+			wantErr: "verification error",
+		},
+		{
+			name: "both credentials",
+			headers: func(h *Headers) error {
+				if err := h.SetKCCS(claims); err != nil {
+					return err
+				}
+				return h.SetKCWT(token)
+			},
+			signer:  holderSigner,
+			wantErr: "header parameter: kcwt, kccs: require a single credential",
+		},
+		{
+			name: "no credential",
+			headers: func(h *Headers) error {
+				return nil
+			},
+			signer:  holderSigner,
+			wantErr: "header parameter: kcwt, kccs: missing credential",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := NewSign1Message()
+			msg.Payload = []byte("hello world")
+			msg.Headers.Protected.SetAlgorithm(AlgorithmES256)
+			if err := tt.headers(&msg.Headers); err != nil {
+				t.Fatalf("headers() error = %v", err)
+			}
+			if err := msg.Sign(rand.Reader, nil, tt.signer); err != nil {
+				t.Fatalf("Sign1Message.Sign() error = %v", err)
+			}
+
+			got, err := VerifySign1WithCredential(msg, nil, tt.issuer, tt.validator)
+			if err != nil && (err.Error() != tt.wantErr) {
+				t.Errorf("VerifySign1WithCredential() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			} else if err == nil && tt.wantErr != "" {
+				t.Errorf("VerifySign1WithCredential() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr == "" && got.Issuer != claims.Issuer {
+				t.Errorf("VerifySign1WithCredential().Issuer = %v, want %v", got.Issuer, claims.Issuer)
+			}
+			if wantVerification := strings.HasSuffix(tt.wantErr, "verification error"); errors.Is(err, ErrVerification) != wantVerification {
+				t.Errorf("VerifySign1WithCredential() error = %v, want ErrVerification %v", err, wantVerification)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package cose
+
+import "fmt"
+
+// VerifyOptions specifies options for verifying messages.
+type VerifyOptions struct {
+	// UnderstoodLabels lists the labels of the header parameters understood by
+	// the application, in addition to the ones registered with
+	// [RegisterHeaderParameter] and the header parameters processed when
+	// verifying a message: labels 1 to 7 defined by RFC 9052, and the CWT
+	// Claims header parameter if CWTClaims is set.
+	//
+	// Messages listing any other label in their crit header parameter are
+	// rejected with a [CriticalHeaderError].
+	//
+	// Reference: https://datatracker.ietf.org/doc/html/rfc9052#section-3.1
+	UnderstoodLabels []any
+
+	// CWTClaims, if not nil, validates the CWT Claims header parameter of the
+	// protected header once the signature is verified, so that expired or
+	// foreign claims are rejected with a [CWTClaimsError].
+	// Messages without CWT Claims are validated as an empty claims set.
+	// Signatures of COSE_Sign messages are validated only if their protected
+	// header contains CWT Claims. Countersignatures are not validated.
+	//
+	// Reference: https://www.rfc-editor.org/rfc/rfc9597.html
+	CWTClaims *CWTValidator
+}
+
+// understoodLabels are the labels of the header parameters understood by any
+// COSE implementation, which RFC 9052 recommends not to list as critical.
+// Other header parameters defined by this library, such as x5chain or kcwt,
+// are not processed when verifying a message, and are not understood unless
+// listed in VerifyOptions.UnderstoodLabels.
+//
+// Reference: https://datatracker.ietf.org/doc/html/rfc9052#section-3.1
+var understoodLabels = map[any]bool{
+	HeaderLabelAlgorithm:        true,
+	HeaderLabelCritical:         true,
+	HeaderLabelContentType:      true,
+	HeaderLabelKeyID:            true,
+	HeaderLabelIV:               true,
+	HeaderLabelPartialIV:        true,
+	HeaderLabelCounterSignature: true,
+}
+
+// understands reports whether the header label is understood.
+// The CWT Claims header parameter is understood if cwtClaims is true and the
+// options validate the claims.
+func (o *VerifyOptions) understands(label any, cwtClaims bool) bool {
+	label, ok := normalizeLabel(label)
+	if !ok {
+		return false
+	}
+	if understoodLabels[label] || lookupHeaderParameter(label) != nil {
+		return true
+	}
+	if o == nil {
+		return false
+	}
+	if label == HeaderLabelCWTClaims && cwtClaims && o.CWTClaims != nil {
+		return true
+	}
+	for _, understood := range o.UnderstoodLabels {
+		if understood, ok := normalizeLabel(understood); ok && understood == label {
+			return true
+		}
+	}
+	return false
+}
+
+// CriticalHeaderError is returned when verifying a message whose crit header
+// parameter lists a label not understood by the application.
+type CriticalHeaderError struct {
+	// Label is the label of the critical header parameter.
+	Label any
+}
+
+// Error implements the error interface.
+func (e *CriticalHeaderError) Error() string {
+	return fmt.Sprintf("header parameter: crit: label not understood: %v", e.Label)
+}
+
+// ensureCriticalUnderstood ensures all labels listed in the crit header
+// parameter of the protected header are understood.
+// If cwtClaims is false, the CWT Claims of the header are not validated and
+// thus not understood.
+func ensureCriticalUnderstood(h ProtectedHeader, opts *VerifyOptions, cwtClaims bool) error {
+	labels, err := h.Critical()
+	if err != nil {
+		return fmt.Errorf("header parameter: crit: %w", err)
+	}
+	for _, label := range labels {
+		if !opts.understands(label, cwtClaims) {
+			return &CriticalHeaderError{Label: label}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,330 @@
+package cose
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"errors"
+	"testing"
+)
+
+func TestSign1Message_VerifyWithOptions_critical(t *testing.T) {
+	registerTestHeaderParameter(t, HeaderParameter{Label: -65537})
+
+	alg := AlgorithmES256
+	key := generateTestECDSAKey(t)
+	signer, err := NewSigner(alg, key)
+	if err != nil {
+		t.Fatalf("NewSigner() error = %v", err)
+	}
+	verifier, err := NewVerifier(alg, key.Public())
+	if err != nil {
+		t.Fatalf("NewVerifier() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		protected ProtectedHeader
+		opts      *VerifyOptions
+		wantLabel any
+	}{
+		{
+			name: "library label",
+			protected: ProtectedHeader{
+				HeaderLabelCritical: []any{HeaderLabelAlgorithm},
+			},
+		},
+		{
+			name: "unprocessed library label",
+			protected: ProtectedHeader{
+				HeaderLabelCritical: []any{HeaderLabelX5Chain},
+				HeaderLabelX5Chain:  []byte{0x30},
+			},
+			wantLabel: HeaderLabelX5Chain,
+		},
+		{
+			name: "cwt claims without validator",
+			protected: ProtectedHeader{
+				HeaderLabelCritical:  []any{HeaderLabelCWTClaims},
+				HeaderLabelCWTClaims: map[any]any{CWTClaimIssuer: "foo"},
+			},
+			wantLabel: HeaderLabelCWTClaims,
+		},
+		{
+			name: "cwt claims with validator",
+			protected: ProtectedHeader{
+				HeaderLabelCritical:  []any{HeaderLabelCWTClaims},
+				HeaderLabelCWTClaims: map[any]any{CWTClaimIssuer: "foo"},
+			},
+			opts: &VerifyOptions{
+				CWTClaims: &CWTValidator{},
+			},
+		},
+		{
+			name: "registered label",
+			protected: ProtectedHeader{
+				HeaderLabelCritical: []any{int64(-65537)},
+				int64(-65537):       int64(1),
+			},
+		},
+		{
+			name: "unknown label",
+			protected: ProtectedHeader{
+				HeaderLabelCritical: []any{int64(-65538)},
+				int64(-65538):       int64(1),
+			},
+			wantLabel: int64(-65538),
+		},
+		{
+			name: "unknown text label",
+			protected: ProtectedHeader{
+				HeaderLabelCritical: []any{HeaderLabelAlgorithm, "foo"},
+				"foo":               "bar",
+			},
+			wantLabel: "foo",
+		},
+		{
+			name: "understood labels",
+			protected: ProtectedHeader{
+				HeaderLabelCritical: []any{int64(-65538), "foo"},
+				int64(-65538):       int64(1),
+				"foo":               "bar",
+			},
+			opts: &VerifyOptions{
+				UnderstoodLabels: []any{-65538, "foo"},
+			},
+		},
+		{
+			name: "understood labels without label",
+			protected: ProtectedHeader{
+				HeaderLabelCritical: []any{int64(-65538), "foo"},
+				int64(-65538):       int64(1),
+				"foo":               "bar",
+			},
+			opts: &VerifyOptions{
+				UnderstoodLabels: []any{"foo"},
+			},
+			wantLabel: int64(-65538),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := NewSign1Message()
+			msg.Headers.Protected = tt.protected
+			msg.Headers.Protected.SetAlgorithm(alg)
+			msg.Payload = []byte("hello world")
+			if err := msg.Sign(rand.Reader, nil, signer); err != nil {
+				t.Fatalf("Sign1Message.Sign() error = %v", err)
+			}
+
+			err := msg.VerifyWithOptions(nil, verifier, tt.opts)
+			if tt.wantLabel == nil {
+				if err != nil {
+					t.Errorf("Sign1Message.VerifyWithOptions() error = %v", err)
+				}
+				return
+			}
+			var critErr *CriticalHeaderError
+			if !errors.As(err, &critErr) {
+				t.Fatalf("Sign1Message.VerifyWithOptions() error = %v, want CriticalHeaderError", err)
+			}
+			if critErr.Label != tt.wantLabel {
+				t.Errorf("CriticalHeaderError.Label = %v, want %v", critErr.Label, tt.wantLabel)
+			}
+			if tt.opts == nil {
+				if err := msg.Verify(nil, verifier); err == nil || err.Error() != critErr.Error() {
+					t.Errorf("Sign1Message.Verify() error = %v, want %v", err, critErr)
+				}
+			}
+		})
+	}
+}
+
+func TestSignMessage_VerifyWithOptions_critical(t *testing.T) {
+	alg := AlgorithmES256
+	key := generateTestECDSAKey(t)
+	signer, err := NewSigner(alg, key)
+	if err != nil {
+		t.Fatalf("NewSigner() error = %v", err)
+	}
+	verifier, err := NewVerifier(alg, key.Public())
+	if err != nil {
+		t.Fatalf("NewVerifier() error = %v", err)
+	}
+	opts := &VerifyOptions{UnderstoodLabels: []any{"foo"}}
+
+	// critical header parameter in the body
+	msg := NewSignMessage()
+	msg.Headers.Protected = ProtectedHeader{
+		HeaderLabelCritical: []any{"foo"},
+		"foo":               "bar",
+	}
+	msg.Payload = []byte("hello world")
+	sig := NewSignature()
+	sig.Headers.Protected.SetAlgorithm(alg)
+	msg.Signatures = append(msg.Signatures, sig)
+	if err := msg.Sign(rand.Reader, nil, signer); err != nil {
+		t.Fatalf("SignMessage.Sign() error = %v", err)
+	}
+	wantErr := "header parameter: crit: label not understood: foo"
+	if err := msg.Verify(nil, verifier); err == nil || err.Error() != wantErr {
+		t.Errorf("SignMessage.Verify() error = %v, wantErr %v", err, wantErr)
+	}
+	if err := msg.VerifyWithOptions(nil, opts, verifier); err != nil {
+		t.Errorf("SignMessage.VerifyWithOptions() error = %v", err)
+	}
+
+	// critical header parameter in the signature
+	msg = NewSignMessage()
+	msg.Payload = []byte("hello world")
+	sig = NewSignature()
+	sig.Headers.Protected = ProtectedHeader{
+		HeaderLabelCritical: []any{"foo"},
+		"foo":               "bar",
+	}
+	sig.Headers.Protected.SetAlgorithm(alg)
+	msg.Signatures = append(msg.Signatures, sig)
+	if err := msg.Sign(rand.Reader, nil, signer); err != nil {
+		t.Fatalf("SignMessage.Sign() error = %v", err)
+	}
+	if err := msg.Verify(nil, verifier); err == nil || err.Error() != wantErr {
+		t.Errorf("SignMessage.Verify() error = %v, wantErr %v", err, wantErr)
+	}
+	if err := msg.VerifyWithOptions(nil, opts, verifier); err != nil {
+		t.Errorf("SignMessage.VerifyWithOptions() error = %v", err)
+	}
+}
+
+func TestCountersignature_VerifyWithOptions_critical(t *testing.T) {
+	alg := AlgorithmES256
+	key := generateTestECDSAKey(t)
+	signer, err := NewSigner(alg, key)
+	if err != nil {
+		t.Fatalf("NewSigner() error = %v", err)
+	}
+	verifier, err := NewVerifier(alg, key.Public())
+	if err != nil {
+		t.Fatalf("NewVerifier() error = %v", err)
+	}
+
+	msg := NewSign1Message()
+	msg.Headers.Protected.SetAlgorithm(alg)
+	msg.Payload = []byte("hello world")
+	if err := msg.Sign(rand.Reader, nil, signer); err != nil {
+		t.Fatalf("Sign1Message.Sign() error = %v", err)
+	}
+
+	cs := NewCountersignature()
+	cs.Headers.Protected = ProtectedHeader{
+		HeaderLabelCritical: []any{int64(-65537)},
+		int64(-65537):       []byte{0x01},
+	}
+	cs.Headers.Protected.SetAlgorithm(alg)
+	if err := cs.Sign(rand.Reader, signer, msg, nil); err != nil {
+		t.Fatalf("Countersignature.Sign() error = %v", err)
+	}
+
+	wantErr := "header parameter: crit: label not understood: -65537"
+	if err := cs.Verify(verifier, msg, nil); err == nil || err.Error() != wantErr {
+		t.Errorf("Countersignature.Verify() error = %v, wantErr %v", err, wantErr)
+	}
+	opts := &VerifyOptions{UnderstoodLabels: []any{int64(-65537)}}
+	if err := cs.VerifyWithOptions(verifier, msg, nil, opts); err != nil {
+		t.Errorf("Countersignature.VerifyWithOptions() error = %v", err)
+	}
+}
+
+func TestVerifyWithOptions_critical(t *testing.T) {
+	chain, key := generateTestCertificateChain(t)
+	roots := x509.NewCertPool()
+	roots.AddCert(chain[1])
+	alg := AlgorithmES256
+	signer, err := NewSigner(alg, key)
+	if err != nil {
+		t.Fatalf("NewSigner() error = %v", err)
+	}
+	verifier, err := NewVerifier(alg, key.Public())
+	if err != nil {
+		t.Fatalf("NewVerifier() error = %v", err)
+	}
+	coseKey, err := NewKeyFromPublic(key.Public())
+	if err != nil {
+		t.Fatalf("NewKeyFromPublic() error = %v", err)
+	}
+	claims := &CWTClaimsSet{Issuer: "issuer.example"}
+	if err := claims.SetConfirmationClaim(&Confirmation{Key: coseKey}); err != nil {
+		t.Fatalf("CWTClaimsSet.SetConfirmationClaim() error = %v", err)
+	}
+	payload, err := claims.MarshalCBOR()
+	if err != nil {
+		t.Fatalf("CWTClaimsSet.MarshalCBOR() error = %v", err)
+	}
+	headers := func() Headers {
+		h := Headers{
+			Protected: ProtectedHeader{
+				HeaderLabelAlgorithm: alg,
+				HeaderLabelCritical:  []any{"foo"},
+				"foo":                "bar",
+			},
+		}
+		if err := h.SetX5Chain(chain[:1]); err != nil {
+			t.Fatalf("Headers.SetX5Chain() error = %v", err)
+		}
+		return h
+	}
+	msg := &Sign1Message{
+		Headers: headers(),
+		Payload: payload,
+	}
+	if err := msg.Sign(rand.Reader, nil, signer); err != nil {
+		t.Fatalf("Sign1Message.Sign() error = %v", err)
+	}
+	token, err := SignCWT(rand.Reader, signer, headers(), claims, false)
+	if err != nil {
+		t.Fatalf("SignCWT() error = %v", err)
+	}
+	x509Verifier := NewX509Verifier(roots, x509.VerifyOptions{})
+	opts := &VerifyOptions{UnderstoodLabels: []any{"foo"}}
+
+	tests := []struct {
+		name   string
+		verify func(opts *VerifyOptions) error
+	}{
+		{
+			name: "UntaggedSign1Message",
+			verify: func(opts *VerifyOptions) error {
+				return (*UntaggedSign1Message)(msg).VerifyWithOptions(nil, verifier, opts)
+			},
+		},
+		{
+			name: "VerifyCWT",
+			verify: func(opts *VerifyOptions) error {
+				_, err := VerifyCWTWithOptions(token, verifier, nil, opts)
+				return err
+			},
+		},
+		{
+			name: "VerifyProofOfPossession",
+			verify: func(opts *VerifyOptions) error {
+				return VerifyProofOfPossessionWithOptions(msg, nil, claims, nil, opts)
+			},
+		},
+		{
+			name: "X509Verifier",
+			verify: func(opts *VerifyOptions) error {
+				_, err := x509Verifier.VerifySign1WithOptions(msg, nil, opts)
+				return err
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wantErr := "header parameter: crit: label not understood: foo"
+			if err := tt.verify(nil); err == nil || err.Error() != wantErr {
+				t.Errorf("verify() error = %v, wantErr %v", err, wantErr)
+			}
+			if err := tt.verify(opts); err != nil {
+				t.Errorf("verify() error = %v", err)
+			}
+		})
+	}
+}
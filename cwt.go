@@ -1,5 +1,18 @@
 package cose
 
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
 // https://www.iana.org/assignments/cwt/cwt.xhtml#claims-registry
 const (
 	CWTClaimIssuer         int64 = 1
@@ -18,3 +31,529 @@ const (
 // CWTClaims contains parameters that are to be cryptographically
 // protected.
 type CWTClaims map[any]any
+
+// cwtClaimName returns the name of a claim registered in the IANA "CBOR Web
+// Token (CWT) Claims" registry, or an empty string if the claim is not known.
+func cwtClaimName(label any) string {
+	switch label {
+	case CWTClaimIssuer:
+		return "iss"
+	case CWTClaimSubject:
+		return "sub"
+	case CWTClaimAudience:
+		return "aud"
+	case CWTClaimExpirationTime:
+		return "exp"
+	case CWTClaimNotBefore:
+		return "nbf"
+	case CWTClaimIssuedAt:
+		return "iat"
+	case CWTClaimCWTID:
+		return "cti"
+	case CWTClaimConfirmation:
+		return "cnf"
+	case CWTClaimScope:
+		return "scope"
+	}
+	return ""
+}
+
+// NumericDate is a CWT NumericDate, the number of seconds since
+// 1970-01-01T00:00:00Z UTC, encoded as an integer or, with fractional
+// seconds, as a floating-point number.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc8392.html#section-2
+type NumericDate struct {
+	value any // int64 or float64
+}
+
+// NewNumericDate returns the NumericDate of t, encoded as an integer if t
+// has no fractional seconds, or as a floating-point number otherwise.
+func NewNumericDate(t time.Time) NumericDate {
+	if t.Nanosecond() == 0 {
+		return NumericDate{value: t.Unix()}
+	}
+	return NumericDate{value: float64(t.Unix()) + float64(t.Nanosecond())/1e9}
+}
+
+// Time returns the date as a UTC time.
+func (d NumericDate) Time() time.Time {
+	switch v := d.value.(type) {
+	case int64:
+		return time.Unix(v, 0).UTC()
+	case float64:
+		sec, frac := math.Modf(v)
+		return time.Unix(int64(sec), int64(math.Round(frac*1e9))).UTC()
+	}
+	return time.Unix(0, 0).UTC()
+}
+
+// claim returns the claim value of the date.
+func (d NumericDate) claim() any {
+	if d.value == nil {
+		return int64(0)
+	}
+	return d.value
+}
+
+// numericDate returns the NumericDate of a claim value.
+func numericDate(value any) (NumericDate, bool) {
+	switch v := value.(type) {
+	case float32:
+		return floatNumericDate(float64(v))
+	case float64:
+		return floatNumericDate(v)
+	case uint:
+		return NumericDate{value: int64(v)}, v <= math.MaxInt64
+	case uint64:
+		return NumericDate{value: int64(v)}, v <= math.MaxInt64
+	}
+	if !canInt(value) {
+		return NumericDate{}, false
+	}
+	return NumericDate{value: reflect.ValueOf(value).Convert(reflect.TypeOf(int64(0))).Int()}, true
+}
+
+// floatNumericDate returns the NumericDate of a floating-point value, which
+// must be finite and its integral part must fit in an int64.
+func floatNumericDate(v float64) (NumericDate, bool) {
+	// -2^63 is exact, 2^63 is the smallest float64 above math.MaxInt64.
+	if math.IsNaN(v) || v < math.MinInt64 || v >= -math.MinInt64 {
+		return NumericDate{}, false
+	}
+	return NumericDate{value: v}, true
+}
+
+// CWTClaimsSet is the typed form of the claims registered in RFC 8392.
+//
+// CWTClaims.ClaimsSet and CWTClaimsSet.Claims convert between both forms
+// without loss: claims without a field are kept in Other, and the encoding
+// of the audience and of the dates is preserved. Empty Issuer and Subject are
+// omitted.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc8392.html#section-3
+type CWTClaimsSet struct {
+	// Issuer is the iss claim.
+	Issuer string
+
+	// Subject is the sub claim.
+	Subject string
+
+	// Audience is the aud claim.
+	// A single audience is encoded as a tstr, unless decoded from an array.
+	Audience []string
+
+	// ExpirationTime is the exp claim.
+	ExpirationTime *NumericDate
+
+	// NotBefore is the nbf claim.
+	NotBefore *NumericDate
+
+	// IssuedAt is the iat claim.
+	IssuedAt *NumericDate
+
+	// CWTID is the cti claim.
+	CWTID []byte
+
+	// Confirmation is the cnf claim.
+	//
+	// Reference: https://www.rfc-editor.org/rfc/rfc8747.html#section-3.1
+	Confirmation map[any]any
+
+	// Scope is the scope claim, either a string or a []byte.
+	//
+	// Reference: https://www.rfc-editor.org/rfc/rfc9200.html#section-5.8.1
+	Scope any
+
+	// Other contains the claims without a field, e.g. unregistered claims,
+	// and the redacted claim keys of an SD-CWT.
+	Other CWTClaims
+
+	audienceArray bool // aud decoded from an array
+}
+
+// ClaimsSet returns the typed form of the claims.
+// It fails if a registered claim has an invalid type.
+func (c CWTClaims) ClaimsSet() (*CWTClaimsSet, error) {
+	s := &CWTClaimsSet{}
+	for label, value := range c {
+		if label == SDRedactedClaimKeys {
+			if s.Other == nil {
+				s.Other = make(CWTClaims)
+			}
+			s.Other[label] = value
+			continue
+		}
+		label, ok := normalizeLabel(label)
+		if !ok {
+			return nil, errors.New("cwt claim: require int / tstr label")
+		}
+		var err error
+		switch label {
+		case CWTClaimIssuer:
+			s.Issuer, err = cwtClaimTstr(label, value)
+		case CWTClaimSubject:
+			s.Subject, err = cwtClaimTstr(label, value)
+		case CWTClaimAudience:
+			switch v := value.(type) {
+			case string:
+				s.Audience = []string{v}
+			case []string:
+				s.Audience, s.audienceArray = v, true
+			case []any:
+				s.Audience, s.audienceArray = make([]string, 0, len(v)), true
+				for _, aud := range v {
+					str, ok := aud.(string)
+					if !ok {
+						return nil, errors.New("cwt claim: aud: require tstr / array of tstr")
+					}
+					s.Audience = append(s.Audience, str)
+				}
+			default:
+				return nil, errors.New("cwt claim: aud: require tstr / array of tstr")
+			}
+		case CWTClaimExpirationTime:
+			s.ExpirationTime, err = cwtClaimNumericDate(label, value)
+		case CWTClaimNotBefore:
+			s.NotBefore, err = cwtClaimNumericDate(label, value)
+		case CWTClaimIssuedAt:
+			s.IssuedAt, err = cwtClaimNumericDate(label, value)
+		case CWTClaimCWTID:
+			if !canBstr(value) {
+				return nil, errors.New("cwt claim: cti: require bstr")
+			}
+			s.CWTID = value.([]byte)
+		case CWTClaimConfirmation:
+			switch v := value.(type) {
+			case map[any]any:
+				s.Confirmation = v
+			case CWTClaims:
+				s.Confirmation = v
+			default:
+				return nil, errors.New("cwt claim: cnf: require map")
+			}
+		case CWTClaimScope:
+			if !canTstr(value) && !canBstr(value) {
+				return nil, errors.New("cwt claim: scope: require tstr / bstr")
+			}
+			s.Scope = value
+		default:
+			if s.Other == nil {
+				s.Other = make(CWTClaims)
+			}
+			s.Other[label] = value
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Claims returns the claims of the typed form.
+// It fails if Other contains a claim with a field.
+func (s *CWTClaimsSet) Claims() (CWTClaims, error) {
+	c := make(CWTClaims, len(s.Other)+9)
+	for label, value := range s.Other {
+		if label == SDRedactedClaimKeys {
+			c[label] = value
+			continue
+		}
+		label, ok := normalizeLabel(label)
+		if !ok {
+			return nil, errors.New("cwt claim: require int / tstr label")
+		}
+		if name := cwtClaimName(label); name != "" {
+			return nil, fmt.Errorf("cwt claim: %s: must not be in Other", name)
+		}
+		c[label] = value
+	}
+	if s.Issuer != "" {
+		c[CWTClaimIssuer] = s.Issuer
+	}
+	if s.Subject != "" {
+		c[CWTClaimSubject] = s.Subject
+	}
+	switch {
+	case len(s.Audience) == 1 && !s.audienceArray:
+		c[CWTClaimAudience] = s.Audience[0]
+	case len(s.Audience) > 0 || s.audienceArray:
+		aud := make([]any, 0, len(s.Audience))
+		for _, str := range s.Audience {
+			aud = append(aud, str)
+		}
+		c[CWTClaimAudience] = aud
+	}
+	if s.ExpirationTime != nil {
+		c[CWTClaimExpirationTime] = s.ExpirationTime.claim()
+	}
+	if s.NotBefore != nil {
+		c[CWTClaimNotBefore] = s.NotBefore.claim()
+	}
+	if s.IssuedAt != nil {
+		c[CWTClaimIssuedAt] = s.IssuedAt.claim()
+	}
+	if s.CWTID != nil {
+		c[CWTClaimCWTID] = s.CWTID
+	}
+	if s.Confirmation != nil {
+		c[CWTClaimConfirmation] = s.Confirmation
+	}
+	if s.Scope != nil {
+		if !canTstr(s.Scope) && !canBstr(s.Scope) {
+			return nil, errors.New("cwt claim: scope: require tstr / bstr")
+		}
+		c[CWTClaimScope] = s.Scope
+	}
+	return c, nil
+}
+
+// MarshalCBOR encodes the claims set as a CBOR map.
+func (s *CWTClaimsSet) MarshalCBOR() ([]byte, error) {
+	c, err := s.Claims()
+	if err != nil {
+		return nil, err
+	}
+	return encMode.Marshal(c)
+}
+
+// UnmarshalCBOR decodes a CBOR map into the claims set.
+func (s *CWTClaimsSet) UnmarshalCBOR(data []byte) error {
+	if s == nil {
+		return errors.New("cbor: UnmarshalCBOR on nil CWTClaimsSet pointer")
+	}
+	var c CWTClaims
+	if err := decMode.Unmarshal(data, &c); err != nil {
+		return err
+	}
+	if c == nil {
+		return errors.New("cwt claim: require map")
+	}
+	decoded, err := c.ClaimsSet()
+	if err != nil {
+		return err
+	}
+	*s = *decoded
+	return nil
+}
+
+// cwtClaimTstr returns the value of a tstr claim.
+func cwtClaimTstr(label, value any) (string, error) {
+	str, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("cwt claim: %s: require tstr", cwtClaimName(label))
+	}
+	return str, nil
+}
+
+// cwtClaimNumericDate returns the value of a NumericDate claim.
+func cwtClaimNumericDate(label, value any) (*NumericDate, error) {
+	d, ok := numericDate(value)
+	if !ok {
+		switch value.(type) {
+		case float32, float64:
+			return nil, fmt.Errorf("cwt claim: %s: date out of range", cwtClaimName(label))
+		}
+		return nil, fmt.Errorf("cwt claim: %s: require int / float", cwtClaimName(label))
+	}
+	return &d, nil
+}
+
+// cwtPrefix represents the fixed prefix of a CWT tag.
+var cwtPrefix = []byte{
+	0xd8, 0x3d, // #6.61
+}
+
+// SignCWT signs the claims set as a CBOR Web Token, carried in the payload of
+// a COSE_Sign1_Tagged object. If tagged is true, the object is wrapped in the
+// CWT tag.
+//
+// CWTs protected by COSE_Mac0 are not supported, as this library does not
+// implement MAC messages.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc8392.html#section-7.1
+func SignCWT(rand io.Reader, signer Signer, headers Headers, claims *CWTClaimsSet, tagged bool) ([]byte, error) {
+	if claims == nil {
+		return nil, errors.New("signing nil CWTClaimsSet")
+	}
+	payload, err := claims.MarshalCBOR()
+	if err != nil {
+		return nil, err
+	}
+	msg := Sign1Message{
+		Headers: headers,
+		Payload: payload,
+	}
+	if err := msg.Sign(rand, nil, signer); err != nil {
+		return nil, err
+	}
+	data, err := msg.MarshalCBOR()
+	if err != nil {
+		return nil, err
+	}
+	if !tagged {
+		return data, nil
+	}
+	return encMode.Marshal(cbor.Tag{
+		Number:  CBORTagCWT,
+		Content: cbor.RawMessage(data),
+	})
+}
+
+// ParseCWT decodes a CBOR Web Token carried in a COSE_Sign1 object, optionally
+// wrapped in the CWT tag, and returns the message with its claims set.
+//
+// The signature and the claims are not verified, see [VerifyCWT].
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc8392.html#section-7.2
+func ParseCWT(data []byte) (*Sign1Message, *CWTClaimsSet, error) {
+	data = bytes.TrimPrefix(data, cwtPrefix)
+	var msg Sign1Message
+	if bytes.HasPrefix(data, sign1MessagePrefix) {
+		if err := msg.UnmarshalCBOR(data); err != nil {
+			return nil, nil, err
+		}
+	} else if err := (*UntaggedSign1Message)(&msg).UnmarshalCBOR(data); err != nil {
+		return nil, nil, err
+	}
+	if msg.Payload == nil {
+		return nil, nil, ErrMissingPayload
+	}
+	var claims CWTClaimsSet
+	if err := claims.UnmarshalCBOR(msg.Payload); err != nil {
+		return nil, nil, err
+	}
+	return &msg, &claims, nil
+}
+
+// VerifyCWT decodes a CBOR Web Token as ParseCWT does, verifies its signature
+// and validates its claims, and returns the verified claims set.
+// The claims are not validated if validator is nil.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc8392.html#section-7.2
+func VerifyCWT(data []byte, verifier Verifier, validator *CWTValidator) (*CWTClaimsSet, error) {
+	return VerifyCWTWithOptions(data, verifier, validator, nil)
+}
+
+// VerifyCWTWithOptions verifies a CBOR Web Token as [VerifyCWT] does, with the
+// options specified by opts for verifying its COSE_Sign1 object.
+func VerifyCWTWithOptions(data []byte, verifier Verifier, validator *CWTValidator, opts *VerifyOptions) (*CWTClaimsSet, error) {
+	msg, claims, err := ParseCWT(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := msg.VerifyWithOptions(nil, verifier, opts); err != nil {
+		return nil, err
+	}
+	if validator != nil {
+		if err := validator.Validate(claims); err != nil {
+			return nil, err
+		}
+	}
+	return claims, nil
+}
+
+// CWTValidator validates the claims of CBOR Web Tokens.
+//
+// Validation failures wrap ErrCWTExpired, ErrCWTNotYetValid,
+// ErrCWTIssuedInFuture, ErrCWTIssuerMismatch, ErrCWTSubjectMismatch,
+// ErrCWTAudienceMismatch or ErrCWTMissingClaim, which can be tested with
+// errors.Is.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc8392.html#section-7.2
+type CWTValidator struct {
+	// Now returns the current time. If nil, time.Now is used.
+	Now func() time.Time
+
+	// Leeway is the allowed clock skew for the exp, nbf and iat claims.
+	Leeway time.Duration
+
+	// Issuer, if not empty, must be equal to the iss claim.
+	Issuer string
+
+	// Subject, if not empty, must be equal to the sub claim.
+	Subject string
+
+	// Audience, if not empty, must be one of the values of the aud claim.
+	Audience string
+
+	// RequireExpirationTime requires the presence of the exp claim.
+	RequireExpirationTime bool
+}
+
+// Validate validates the claims set.
+func (v *CWTValidator) Validate(claims *CWTClaimsSet) error {
+	if claims == nil {
+		return errors.New("validating nil CWTClaimsSet")
+	}
+	now := time.Now()
+	if v.Now != nil {
+		now = v.Now()
+	}
+
+	switch {
+	case claims.ExpirationTime != nil:
+		if !now.Before(claims.ExpirationTime.Time().Add(v.Leeway)) {
+			return fmt.Errorf("cwt claim: exp: %w", ErrCWTExpired)
+		}
+	case v.RequireExpirationTime:
+		return fmt.Errorf("cwt claim: exp: %w", ErrCWTMissingClaim)
+	}
+	if claims.NotBefore != nil && now.Add(v.Leeway).Before(claims.NotBefore.Time()) {
+		return fmt.Errorf("cwt claim: nbf: %w", ErrCWTNotYetValid)
+	}
+	if claims.IssuedAt != nil && now.Add(v.Leeway).Before(claims.IssuedAt.Time()) {
+		return fmt.Errorf("cwt claim: iat: %w", ErrCWTIssuedInFuture)
+	}
+	if v.Issuer != "" && claims.Issuer != v.Issuer {
+		return fmt.Errorf("cwt claim: iss: %w", ErrCWTIssuerMismatch)
+	}
+	if v.Subject != "" && claims.Subject != v.Subject {
+		return fmt.Errorf("cwt claim: sub: %w", ErrCWTSubjectMismatch)
+	}
+	if v.Audience != "" && !slices.Contains(claims.Audience, v.Audience) {
+		return fmt.Errorf("cwt claim: aud: %w", ErrCWTAudienceMismatch)
+	}
+	return nil
+}
+
+// CWTClaimsError is returned when verifying a message whose CWT Claims header
+// parameter is invalid or rejected by [VerifyOptions.CWTClaims], once its
+// signature is verified.
+type CWTClaimsError struct {
+	// Err is the validation failure, e.g. wrapping ErrCWTExpired.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *CWTClaimsError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the validation failure.
+func (e *CWTClaimsError) Unwrap() error {
+	return e.Err
+}
+
+// validateCWTClaims validates the CWT Claims header parameter of the protected
+// header with the validator of the options, if any.
+// If required is false, headers without CWT Claims are not validated.
+func validateCWTClaims(h ProtectedHeader, opts *VerifyOptions, required bool) error {
+	if opts == nil || opts.CWTClaims == nil {
+		return nil
+	}
+	if !required && !hasLabel(h, HeaderLabelCWTClaims) {
+		return nil
+	}
+	claims, err := h.CWTClaimsSet()
+	if err != nil {
+		return &CWTClaimsError{Err: err}
+	}
+	if claims == nil {
+		claims = &CWTClaimsSet{}
+	}
+	if err := opts.CWTClaims.Validate(claims); err != nil {
+		return &CWTClaimsError{Err: err}
+	}
+	return nil
+}
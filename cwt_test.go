@@ -1,11 +1,18 @@
 package cose_test
 
 import (
+	"bytes"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
+	"errors"
 	"fmt"
+	"math"
+	"reflect"
+	"testing"
+	"time"
 
+	"github.com/fxamacker/cbor/v2"
 	"github.com/veraison/go-cose"
 )
 
@@ -80,3 +87,605 @@ func ExampleCWTClaims() {
 	// message verified
 	// verification error as expected
 }
+
+func TestCWTClaims_ClaimsSet(t *testing.T) {
+	exp := cose.NewNumericDate(time.Unix(1444064944, 0))
+	iat := cose.NewNumericDate(time.Unix(1443944944, 500000000))
+	tests := []struct {
+		name    string
+		claims  cose.CWTClaims
+		want    *cose.CWTClaimsSet
+		wantErr string
+	}{
+		{
+			name: "registered claims",
+			claims: cose.CWTClaims{
+				cose.CWTClaimIssuer:         "coap://as.example.com",
+				cose.CWTClaimSubject:        "erikw",
+				cose.CWTClaimAudience:       "coap://light.example.com",
+				cose.CWTClaimExpirationTime: int64(1444064944),
+				cose.CWTClaimIssuedAt:       1443944944.5,
+				cose.CWTClaimCWTID:          []byte{0x0b, 0x71},
+				cose.CWTClaimConfirmation:   map[any]any{int64(3): []byte("kid")},
+				cose.CWTClaimScope:          "read write",
+			},
+			want: &cose.CWTClaimsSet{
+				Issuer:         "coap://as.example.com",
+				Subject:        "erikw",
+				Audience:       []string{"coap://light.example.com"},
+				ExpirationTime: &exp,
+				IssuedAt:       &iat,
+				CWTID:          []byte{0x0b, 0x71},
+				Confirmation:   map[any]any{int64(3): []byte("kid")},
+				Scope:          "read write",
+			},
+		},
+		{
+			name: "unregistered claims",
+			claims: cose.CWTClaims{
+				1:       "issuer",
+				-65537:  "private",
+				"claim": true,
+			},
+			want: &cose.CWTClaimsSet{
+				Issuer: "issuer",
+				Other: cose.CWTClaims{
+					int64(-65537): "private",
+					"claim":       true,
+				},
+			},
+		},
+		{
+			name:    "invalid iss",
+			claims:  cose.CWTClaims{cose.CWTClaimIssuer: 42},
+			wantErr: "cwt claim: iss: require tstr",
+		},
+		{
+			name:    "invalid sub",
+			claims:  cose.CWTClaims{2: []byte("subject")},
+			wantErr: "cwt claim: sub: require tstr",
+		},
+		{
+			name:    "invalid aud",
+			claims:  cose.CWTClaims{cose.CWTClaimAudience: []any{"a", 1}},
+			wantErr: "cwt claim: aud: require tstr / array of tstr",
+		},
+		{
+			name:    "invalid exp",
+			claims:  cose.CWTClaims{cose.CWTClaimExpirationTime: "tomorrow"},
+			wantErr: "cwt claim: exp: require int / float",
+		},
+		{
+			name:    "invalid cti",
+			claims:  cose.CWTClaims{cose.CWTClaimCWTID: "id"},
+			wantErr: "cwt claim: cti: require bstr",
+		},
+		{
+			name:    "invalid cnf",
+			claims:  cose.CWTClaims{cose.CWTClaimConfirmation: "key"},
+			wantErr: "cwt claim: cnf: require map",
+		},
+		{
+			name:    "invalid scope",
+			claims:  cose.CWTClaims{cose.CWTClaimScope: 1},
+			wantErr: "cwt claim: scope: require tstr / bstr",
+		},
+		{
+			name:    "invalid label",
+			claims:  cose.CWTClaims{1.5: "foo"},
+			wantErr: "cwt claim: require int / tstr label",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.claims.ClaimsSet()
+			if err != nil && (err.Error() != tt.wantErr) {
+				t.Errorf("CWTClaims.ClaimsSet() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			} else if err == nil && tt.wantErr != "" {
+				t.Errorf("CWTClaims.ClaimsSet() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CWTClaims.ClaimsSet() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCWTClaimsSet_roundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{
+			// Reference: https://www.rfc-editor.org/rfc/rfc8392.html#appendix-A.1
+			name: "RFC 8392 example",
+			data: []byte{
+				0xa7, 0x01, 0x75, 0x63, 0x6f, 0x61, 0x70, 0x3a, 0x2f, 0x2f, 0x61, 0x73,
+				0x2e, 0x65, 0x78, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x2e, 0x63, 0x6f, 0x6d,
+				0x02, 0x65, 0x65, 0x72, 0x69, 0x6b, 0x77, 0x03, 0x78, 0x18, 0x63, 0x6f,
+				0x61, 0x70, 0x3a, 0x2f, 0x2f, 0x6c, 0x69, 0x67, 0x68, 0x74, 0x2e, 0x65,
+				0x78, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x2e, 0x63, 0x6f, 0x6d, 0x04, 0x1a,
+				0x56, 0x12, 0xae, 0xb0, 0x05, 0x1a, 0x56, 0x10, 0xd9, 0xf0, 0x06, 0x1a,
+				0x56, 0x10, 0xd9, 0xf0, 0x07, 0x42, 0x0b, 0x71,
+			},
+		},
+		{
+			name: "audience array and float date",
+			data: []byte{
+				0xa3, 0x03, 0x81, 0x61, 0x61, 0x06, 0xfb, 0x41, 0xd5, 0x81, 0x0e,
+				0x7c, 0x20, 0x00, 0x00, 0x3a, 0x00, 0x01, 0x00, 0x00, 0xf5,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s cose.CWTClaimsSet
+			if err := s.UnmarshalCBOR(tt.data); err != nil {
+				t.Fatalf("CWTClaimsSet.UnmarshalCBOR() error = %v", err)
+			}
+			got, err := s.MarshalCBOR()
+			if err != nil {
+				t.Fatalf("CWTClaimsSet.MarshalCBOR() error = %v", err)
+			}
+			if !bytes.Equal(got, tt.data) {
+				t.Errorf("CWTClaimsSet.MarshalCBOR() = %x, want %x", got, tt.data)
+			}
+		})
+	}
+}
+
+func TestNumericDate_Time(t *testing.T) {
+	now := time.Date(2025, 1, 2, 3, 4, 5, 250000000, time.UTC)
+	if got := cose.NewNumericDate(now).Time(); !got.Equal(now) {
+		t.Errorf("NumericDate.Time() = %v, want %v", got, now)
+	}
+	now = now.Truncate(time.Second)
+	if got := cose.NewNumericDate(now).Time(); !got.Equal(now) {
+		t.Errorf("NumericDate.Time() = %v, want %v", got, now)
+	}
+}
+
+func TestProtectedHeader_CWTClaimsSet(t *testing.T) {
+	exp := cose.NewNumericDate(time.Unix(1444064944, 0))
+	want := &cose.CWTClaimsSet{
+		Issuer:         "issuer.example",
+		Subject:        "subject.example",
+		ExpirationTime: &exp,
+	}
+
+	h := cose.ProtectedHeader{}
+	if got, err := h.CWTClaimsSet(); err != nil || got != nil {
+		t.Errorf("ProtectedHeader.CWTClaimsSet() = %v, %v, want nil", got, err)
+	}
+	if err := h.SetCWTClaimsSet(want); err != nil {
+		t.Fatalf("ProtectedHeader.SetCWTClaimsSet() error = %v", err)
+	}
+	data, err := h.MarshalCBOR()
+	if err != nil {
+		t.Fatalf("ProtectedHeader.MarshalCBOR() error = %v", err)
+	}
+	var decoded cose.ProtectedHeader
+	if err := decoded.UnmarshalCBOR(data); err != nil {
+		t.Fatalf("ProtectedHeader.UnmarshalCBOR() error = %v", err)
+	}
+	got, err := decoded.CWTClaimsSet()
+	if err != nil {
+		t.Fatalf("ProtectedHeader.CWTClaimsSet() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ProtectedHeader.CWTClaimsSet() = %v, want %v", got, want)
+	}
+
+	// claims set with an integer label of another type
+	if _, err := h.SetCWTClaims(cose.CWTClaims{1: 42}); err == nil || err.Error() != "cwt claim: iss: require tstr" {
+		t.Errorf("ProtectedHeader.SetCWTClaims() error = %v, wantErr %v", err, "cwt claim: iss: require tstr")
+	}
+	h = cose.ProtectedHeader{cose.HeaderLabelCWTClaims: "claims"}
+	if _, err := h.CWTClaimsSet(); err == nil || err.Error() != "header parameter: CWT Claims: require map type" {
+		t.Errorf("ProtectedHeader.CWTClaimsSet() error = %v, wantErr %v", err, "header parameter: CWT Claims: require map type")
+	}
+}
+
+func TestSignCWT(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey() error = %v", err)
+	}
+	signer, err := cose.NewSigner(cose.AlgorithmES256, privateKey)
+	if err != nil {
+		t.Fatalf("NewSigner() error = %v", err)
+	}
+	verifier, err := cose.NewVerifier(cose.AlgorithmES256, privateKey.Public())
+	if err != nil {
+		t.Fatalf("NewVerifier() error = %v", err)
+	}
+	exp := cose.NewNumericDate(time.Unix(1444064944, 0))
+	claims := &cose.CWTClaimsSet{
+		Issuer:         "coap://as.example.com",
+		Audience:       []string{"coap://light.example.com"},
+		ExpirationTime: &exp,
+	}
+	validator := &cose.CWTValidator{
+		Now:      func() time.Time { return time.Unix(1444064000, 0) },
+		Issuer:   "coap://as.example.com",
+		Audience: "coap://light.example.com",
+	}
+
+	for _, tagged := range []bool{false, true} {
+		t.Run(fmt.Sprintf("tagged=%v", tagged), func(t *testing.T) {
+			data, err := cose.SignCWT(rand.Reader, signer, cose.Headers{}, claims, tagged)
+			if err != nil {
+				t.Fatalf("SignCWT() error = %v", err)
+			}
+			if got := bytes.HasPrefix(data, []byte{0xd8, 0x3d, 0xd2}); got != tagged {
+				t.Errorf("SignCWT() tagged = %v, want %v", got, tagged)
+			}
+			msg, got, err := cose.ParseCWT(data)
+			if err != nil {
+				t.Fatalf("ParseCWT() error = %v", err)
+			}
+			if alg, err := msg.Headers.Protected.Algorithm(); err != nil || alg != cose.AlgorithmES256 {
+				t.Errorf("Sign1Message.Headers.Protected.Algorithm() = %v, %v, want %v", alg, err, cose.AlgorithmES256)
+			}
+			if !reflect.DeepEqual(got, claims) {
+				t.Errorf("ParseCWT() = %v, want %v", got, claims)
+			}
+			got, err = cose.VerifyCWT(data, verifier, validator)
+			if err != nil {
+				t.Fatalf("VerifyCWT() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, claims) {
+				t.Errorf("VerifyCWT() = %v, want %v", got, claims)
+			}
+
+			// tampered token
+			data[len(data)-1] ^= 0xff
+			if _, err := cose.VerifyCWT(data, verifier, validator); err != cose.ErrVerification {
+				t.Errorf("VerifyCWT() error = %v, want %v", err, cose.ErrVerification)
+			}
+		})
+	}
+
+	// untagged COSE_Sign1
+	data, err := cose.SignCWT(rand.Reader, signer, cose.Headers{}, claims, false)
+	if err != nil {
+		t.Fatalf("SignCWT() error = %v", err)
+	}
+	if _, got, err := cose.ParseCWT(data[1:]); err != nil || !reflect.DeepEqual(got, claims) {
+		t.Errorf("ParseCWT() = %v, %v, want %v", got, err, claims)
+	}
+
+	// expired token
+	validator.Now = func() time.Time { return time.Unix(1444064944, 0) }
+	if _, err := cose.VerifyCWT(data, verifier, validator); !errors.Is(err, cose.ErrCWTExpired) {
+		t.Errorf("VerifyCWT() error = %v, want %v", err, cose.ErrCWTExpired)
+	}
+}
+
+func TestCWTValidator_Validate(t *testing.T) {
+	now := time.Unix(1444064000, 0)
+	date := func(d time.Duration) *cose.NumericDate {
+		n := cose.NewNumericDate(now.Add(d))
+		return &n
+	}
+	tests := []struct {
+		name      string
+		validator cose.CWTValidator
+		claims    *cose.CWTClaimsSet
+		wantErr   error
+		errString string
+	}{
+		{
+			name:   "valid",
+			claims: &cose.CWTClaimsSet{ExpirationTime: date(time.Minute), NotBefore: date(-time.Minute), IssuedAt: date(0)},
+		},
+		{
+			name:   "no claims",
+			claims: &cose.CWTClaimsSet{},
+		},
+		{
+			name:      "expired",
+			claims:    &cose.CWTClaimsSet{ExpirationTime: date(0)},
+			wantErr:   cose.ErrCWTExpired,
+			errString: "cwt claim: exp: token expired",
+		},
+		{
+			name:      "expired with leeway",
+			validator: cose.CWTValidator{Leeway: time.Minute},
+			claims:    &cose.CWTClaimsSet{ExpirationTime: date(-time.Second)},
+		},
+		{
+			name:      "missing exp",
+			validator: cose.CWTValidator{RequireExpirationTime: true},
+			claims:    &cose.CWTClaimsSet{},
+			wantErr:   cose.ErrCWTMissingClaim,
+			errString: "cwt claim: exp: missing claim",
+		},
+		{
+			name:      "not yet valid",
+			claims:    &cose.CWTClaimsSet{NotBefore: date(time.Second)},
+			wantErr:   cose.ErrCWTNotYetValid,
+			errString: "cwt claim: nbf: token not yet valid",
+		},
+		{
+			name:      "not yet valid with leeway",
+			validator: cose.CWTValidator{Leeway: time.Minute},
+			claims:    &cose.CWTClaimsSet{NotBefore: date(time.Second)},
+		},
+		{
+			name:      "issued in the future",
+			claims:    &cose.CWTClaimsSet{IssuedAt: date(time.Second)},
+			wantErr:   cose.ErrCWTIssuedInFuture,
+			errString: "cwt claim: iat: token issued in the future",
+		},
+		{
+			name:      "issuer mismatch",
+			validator: cose.CWTValidator{Issuer: "issuer"},
+			claims:    &cose.CWTClaimsSet{Issuer: "other"},
+			wantErr:   cose.ErrCWTIssuerMismatch,
+			errString: "cwt claim: iss: issuer mismatch",
+		},
+		{
+			name:      "subject mismatch",
+			validator: cose.CWTValidator{Subject: "subject"},
+			claims:    &cose.CWTClaimsSet{Subject: "other"},
+			wantErr:   cose.ErrCWTSubjectMismatch,
+			errString: "cwt claim: sub: subject mismatch",
+		},
+		{
+			name:      "audience match",
+			validator: cose.CWTValidator{Audience: "b"},
+			claims:    &cose.CWTClaimsSet{Audience: []string{"a", "b"}},
+		},
+		{
+			name:      "audience mismatch",
+			validator: cose.CWTValidator{Audience: "c"},
+			claims:    &cose.CWTClaimsSet{Audience: []string{"a", "b"}},
+			wantErr:   cose.ErrCWTAudienceMismatch,
+			errString: "cwt claim: aud: audience mismatch",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.validator.Now = func() time.Time { return now }
+			err := tt.validator.Validate(tt.claims)
+			if !errors.Is(err, tt.wantErr) || (err != nil && err.Error() != tt.errString) {
+				t.Errorf("CWTValidator.Validate() error = %v, wantErr %v", err, tt.errString)
+			}
+		})
+	}
+}
+
+func TestCWTValidator_Validate_dateOutOfRange(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey() error = %v", err)
+	}
+	signer, err := cose.NewSigner(cose.AlgorithmES256, privateKey)
+	if err != nil {
+		t.Fatalf("NewSigner() error = %v", err)
+	}
+	verifier, err := cose.NewVerifier(cose.AlgorithmES256, privateKey.Public())
+	if err != nil {
+		t.Fatalf("NewVerifier() error = %v", err)
+	}
+	opts := &cose.VerifyOptions{
+		CWTClaims: &cose.CWTValidator{
+			Now: func() time.Time { return time.Unix(1700000000, 0) },
+		},
+	}
+
+	for _, label := range []struct {
+		name  string
+		label int64
+	}{
+		{"exp", cose.CWTClaimExpirationTime},
+		{"nbf", cose.CWTClaimNotBefore},
+		{"iat", cose.CWTClaimIssuedAt},
+	} {
+		for _, value := range []any{1e19, -1e19, 1e300, math.Inf(1), math.Inf(-1), math.NaN(), float32(math.Inf(1))} {
+			t.Run(fmt.Sprintf("%s %v", label.name, value), func(t *testing.T) {
+				wantErr := fmt.Sprintf("cwt claim: %s: date out of range", label.name)
+				claims := cose.CWTClaims{label.label: value}
+				if _, err := claims.ClaimsSet(); err == nil || err.Error() != wantErr {
+					t.Errorf("CWTClaims.ClaimsSet() error = %v, wantErr %v", err, wantErr)
+				}
+
+				// the setters reject such dates, so encode the header directly.
+				protected := cose.ProtectedHeader{
+					cose.HeaderLabelAlgorithm: cose.AlgorithmES256,
+					cose.HeaderLabelCWTClaims: claims,
+				}
+				encoded, err := cbor.Marshal(map[int64]any{
+					cose.HeaderLabelAlgorithm: int64(cose.AlgorithmES256),
+					cose.HeaderLabelCWTClaims: map[int64]any{label.label: value},
+				})
+				if err != nil {
+					t.Fatalf("cbor.Marshal() error = %v", err)
+				}
+				if encoded, err = cbor.Marshal(encoded); err != nil {
+					t.Fatalf("cbor.Marshal() error = %v", err)
+				}
+				msg := &cose.Sign1Message{
+					Headers: cose.Headers{
+						RawProtected: encoded,
+						Protected:    protected,
+					},
+					Payload: []byte("hello world"),
+				}
+				if err := msg.Sign(rand.Reader, nil, signer); err != nil {
+					t.Fatalf("Sign1Message.Sign() error = %v", err)
+				}
+				err = msg.VerifyWithOptions(nil, verifier, opts)
+				var claimsErr *cose.CWTClaimsError
+				if !errors.As(err, &claimsErr) || err.Error() != wantErr {
+					t.Errorf("Sign1Message.VerifyWithOptions() error = %v, wantErr %v", err, wantErr)
+				}
+			})
+		}
+	}
+}
+
+func TestSign1Message_VerifyWithOptions_cwtClaims(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey() error = %v", err)
+	}
+	signer, err := cose.NewSigner(cose.AlgorithmES256, privateKey)
+	if err != nil {
+		t.Fatalf("NewSigner() error = %v", err)
+	}
+	verifier, err := cose.NewVerifier(cose.AlgorithmES256, privateKey.Public())
+	if err != nil {
+		t.Fatalf("NewVerifier() error = %v", err)
+	}
+	now := time.Unix(1444064000, 0)
+	exp := cose.NewNumericDate(now.Add(time.Hour))
+	expired := cose.NewNumericDate(now.Add(-time.Hour))
+	opts := &cose.VerifyOptions{
+		CWTClaims: &cose.CWTValidator{
+			Now:     func() time.Time { return now },
+			Issuer:  "issuer.example",
+			Subject: "subject.example",
+		},
+	}
+
+	tests := []struct {
+		name    string
+		claims  *cose.CWTClaimsSet
+		tamper  bool
+		wantErr error
+	}{
+		{
+			name:   "valid claims",
+			claims: &cose.CWTClaimsSet{Issuer: "issuer.example", Subject: "subject.example", ExpirationTime: &exp},
+		},
+		{
+			name:    "expired claims",
+			claims:  &cose.CWTClaimsSet{Issuer: "issuer.example", Subject: "subject.example", ExpirationTime: &expired},
+			wantErr: cose.ErrCWTExpired,
+		},
+		{
+			name:    "subject mismatch",
+			claims:  &cose.CWTClaimsSet{Issuer: "issuer.example", Subject: "other.example"},
+			wantErr: cose.ErrCWTSubjectMismatch,
+		},
+		{
+			name:    "missing claims",
+			wantErr: cose.ErrCWTIssuerMismatch,
+		},
+		{
+			name:    "invalid signature",
+			claims:  &cose.CWTClaimsSet{Issuer: "issuer.example", Subject: "subject.example", ExpirationTime: &expired},
+			tamper:  true,
+			wantErr: cose.ErrVerification,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := cose.NewSign1Message()
+			msg.Payload = []byte("hello world")
+			msg.Headers.Protected.SetAlgorithm(cose.AlgorithmES256)
+			if tt.claims != nil {
+				if err := msg.Headers.Protected.SetCWTClaimsSet(tt.claims); err != nil {
+					t.Fatalf("ProtectedHeader.SetCWTClaimsSet() error = %v", err)
+				}
+			}
+			if err := msg.Sign(rand.Reader, nil, signer); err != nil {
+				t.Fatalf("Sign1Message.Sign() error = %v", err)
+			}
+			if tt.tamper {
+				msg.Payload = []byte("foobar")
+			}
+
+			err := msg.VerifyWithOptions(nil, verifier, opts)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Sign1Message.VerifyWithOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var claimsErr *cose.CWTClaimsError
+			if got, want := errors.As(err, &claimsErr), tt.wantErr != nil && tt.wantErr != cose.ErrVerification; got != want {
+				t.Errorf("Sign1Message.VerifyWithOptions() error = %v, want CWTClaimsError %v", err, want)
+			}
+			if !tt.tamper {
+				if err := msg.Verify(nil, verifier); err != nil {
+					t.Errorf("Sign1Message.Verify() error = %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestSignMessage_VerifyWithOptions_cwtClaims(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey() error = %v", err)
+	}
+	signer, err := cose.NewSigner(cose.AlgorithmES256, privateKey)
+	if err != nil {
+		t.Fatalf("NewSigner() error = %v", err)
+	}
+	verifier, err := cose.NewVerifier(cose.AlgorithmES256, privateKey.Public())
+	if err != nil {
+		t.Fatalf("NewVerifier() error = %v", err)
+	}
+	opts := &cose.VerifyOptions{
+		CWTClaims: &cose.CWTValidator{Issuer: "issuer.example"},
+	}
+
+	tests := []struct {
+		name      string
+		body      *cose.CWTClaimsSet
+		signature *cose.CWTClaimsSet
+		wantErr   error
+	}{
+		{
+			name: "body claims",
+			body: &cose.CWTClaimsSet{Issuer: "issuer.example"},
+		},
+		{
+			name:    "body claims mismatch",
+			body:    &cose.CWTClaimsSet{Issuer: "other.example"},
+			wantErr: cose.ErrCWTIssuerMismatch,
+		},
+		{
+			name:      "signature claims mismatch",
+			body:      &cose.CWTClaimsSet{Issuer: "issuer.example"},
+			signature: &cose.CWTClaimsSet{Issuer: "other.example"},
+			wantErr:   cose.ErrCWTIssuerMismatch,
+		},
+		{
+			name:      "signature claims only",
+			signature: &cose.CWTClaimsSet{Issuer: "issuer.example"},
+			wantErr:   cose.ErrCWTIssuerMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := cose.NewSignMessage()
+			msg.Payload = []byte("hello world")
+			if tt.body != nil {
+				if err := msg.Headers.Protected.SetCWTClaimsSet(tt.body); err != nil {
+					t.Fatalf("ProtectedHeader.SetCWTClaimsSet() error = %v", err)
+				}
+			}
+			sig := cose.NewSignature()
+			sig.Headers.Protected.SetAlgorithm(cose.AlgorithmES256)
+			if tt.signature != nil {
+				if err := sig.Headers.Protected.SetCWTClaimsSet(tt.signature); err != nil {
+					t.Fatalf("ProtectedHeader.SetCWTClaimsSet() error = %v", err)
+				}
+			}
+			msg.Signatures = append(msg.Signatures, sig)
+			if err := msg.Sign(rand.Reader, nil, signer); err != nil {
+				t.Fatalf("SignMessage.Sign() error = %v", err)
+			}
+
+			err := msg.VerifyWithOptions(nil, opts, verifier)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("SignMessage.VerifyWithOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,390 @@
+package cose
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// DeterministicEncodingError is returned if a CBOR data item is not encoded
+// with the core deterministic encoding requirements.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc8949.html#section-4.2.1
+type DeterministicEncodingError struct {
+	// Path locates the offending data item.
+	//
+	// The path starts with "$", denoting the top-level data item. Array
+	// elements are selected by their index, e.g. "$[1]", and map values by
+	// their key in diagnostic notation, e.g. "$[1][4]" or `$[1]["foo"]`.
+	// "<<>>" selects the CBOR data item embedded in a byte string, e.g.
+	// "$[0]<<>>[1]" for the algorithm in the protected header of a message.
+	Path string
+
+	// Reason describes the violated requirement.
+	Reason string
+}
+
+// Error implements the error interface.
+func (e *DeterministicEncodingError) Error() string {
+	return fmt.Sprintf("cbor: non-deterministic encoding at %s: %s", e.Path, e.Reason)
+}
+
+// CheckDeterministicEncoding checks that data is a single CBOR data item
+// encoded with the core deterministic encoding requirements of RFC 8949:
+//   - integers, lengths and tag numbers use the shortest argument;
+//   - floating-point values use the shortest form preserving the value;
+//   - bignums have no leading zeros and do not fit in an integer;
+//   - indefinite-length items are not present;
+//   - map keys are sorted in the bytewise lexicographic order of their
+//     encodings.
+//
+// If the check fails, the returned error is a [*DeterministicEncodingError].
+//
+// COSE messages are checked when decoded with [CBOROptions.Deterministic].
+// This function can be used to check other data, such as payloads.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc8949.html#section-4.2.1
+func CheckDeterministicEncoding(data []byte) error {
+	if err := decMode.Wellformed(data); err != nil {
+		return err
+	}
+	c := &deterministicChecker{data: data}
+	return c.value("$")
+}
+
+// checkDeterministicMessage checks that the COSE_Sign or COSE_Sign1 object in
+// data, and the CBOR data items embedded in its protected headers, are
+// encoded with the core deterministic encoding requirements.
+func checkDeterministicMessage(data []byte, signatures bool) error {
+	if err := decMode.Wellformed(data); err != nil {
+		return err
+	}
+	c := &deterministicChecker{data: data}
+	return c.message("$", signatures)
+}
+
+// deterministicChecker walks well-formed CBOR data, checking the core
+// deterministic encoding requirements.
+type deterministicChecker struct {
+	data   []byte
+	offset int
+}
+
+// errorf returns a DeterministicEncodingError for the data item at path.
+func (c *deterministicChecker) errorf(path string, format string, args ...any) error {
+	return &DeterministicEncodingError{
+		Path:   path,
+		Reason: fmt.Sprintf(format, args...),
+	}
+}
+
+// peek returns the major type of the next data item.
+func (c *deterministicChecker) peek() byte {
+	return c.data[c.offset] >> 5
+}
+
+// head reads and checks the head of the next data item.
+func (c *deterministicChecker) head(path string) (byte, uint64, error) {
+	major := c.data[c.offset] >> 5
+	ai := c.data[c.offset] & 0x1f
+	c.offset++
+
+	var arg uint64
+	switch {
+	case ai < 24:
+		return major, uint64(ai), nil
+	case ai == 24:
+		arg = uint64(c.data[c.offset])
+		c.offset++
+	case ai == 25:
+		arg = uint64(binary.BigEndian.Uint16(c.data[c.offset:]))
+		c.offset += 2
+	case ai == 26:
+		arg = uint64(binary.BigEndian.Uint32(c.data[c.offset:]))
+		c.offset += 4
+	case ai == 27:
+		arg = binary.BigEndian.Uint64(c.data[c.offset:])
+		c.offset += 8
+	default:
+		return 0, 0, c.errorf(path, "indefinite length")
+	}
+
+	if major == 7 {
+		switch ai {
+		case 26:
+			if float32FitsFloat16(uint32(arg)) {
+				return 0, 0, c.errorf(path, "float32 representable as float16")
+			}
+		case 27:
+			if bits, ok := float64FitsFloat32(arg); ok {
+				if float32FitsFloat16(bits) {
+					return 0, 0, c.errorf(path, "float64 representable as float16")
+				}
+				return 0, 0, c.errorf(path, "float64 representable as float32")
+			}
+		}
+		return major, arg, nil
+	}
+	if (ai == 24 && arg < 24) ||
+		(ai == 25 && arg <= math.MaxUint8) ||
+		(ai == 26 && arg <= math.MaxUint16) ||
+		(ai == 27 && arg <= math.MaxUint32) {
+		return 0, 0, c.errorf(path, "argument %d not encoded in the shortest form", arg)
+	}
+	return major, arg, nil
+}
+
+// value checks the next data item.
+func (c *deterministicChecker) value(path string) error {
+	major, arg, err := c.head(path)
+	if err != nil {
+		return err
+	}
+	switch major {
+	case 2, 3: // bstr, tstr
+		c.offset += int(arg)
+	case 4: // array
+		for i := uint64(0); i < arg; i++ {
+			if err := c.value(path + "[" + strconv.FormatUint(i, 10) + "]"); err != nil {
+				return err
+			}
+		}
+	case 5: // map
+		return c.mapEntries(path, arg, func(_ []byte, path string) error {
+			return c.value(path)
+		})
+	case 6: // tag
+		if (arg == 2 || arg == 3) && c.peek() == 2 {
+			return c.bignum(path)
+		}
+		return c.value(path)
+	}
+	return nil
+}
+
+// bignum checks the content of a bignum tag.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc8949.html#section-3.4.3
+func (c *deterministicChecker) bignum(path string) error {
+	_, n, err := c.head(path)
+	if err != nil {
+		return err
+	}
+	content := c.data[c.offset : c.offset+int(n)]
+	c.offset += int(n)
+	if len(content) > 0 && content[0] == 0 {
+		return c.errorf(path, "bignum with leading zeros")
+	}
+	if len(content) <= 8 {
+		return c.errorf(path, "bignum representable as integer")
+	}
+	return nil
+}
+
+// mapEntries checks the keys of a map with n entries and the ordering of the
+// keys, and checks the values using value.
+func (c *deterministicChecker) mapEntries(path string, n uint64, value func(key []byte, path string) error) error {
+	var prev []byte
+	for i := uint64(0); i < n; i++ {
+		var key cbor.RawMessage
+		rest, err := decMode.UnmarshalFirst(c.data[c.offset:], &key)
+		if err != nil {
+			return err
+		}
+		keyPath := path + "[" + c.keyName(key) + "]"
+		sub := &deterministicChecker{data: key}
+		if err := sub.value(keyPath); err != nil {
+			var e *DeterministicEncodingError
+			if errors.As(err, &e) {
+				e.Reason = "map key: " + e.Reason
+			}
+			return err
+		}
+		if prev != nil {
+			switch bytes.Compare(prev, key) {
+			case 0:
+				return c.errorf(keyPath, "duplicated map key")
+			case 1:
+				return c.errorf(keyPath, "map keys not sorted")
+			}
+		}
+		prev = key
+		c.offset = len(c.data) - len(rest)
+		if err := value(key, keyPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// keyName returns the diagnostic notation of a map key.
+func (c *deterministicChecker) keyName(key []byte) string {
+	name, _, err := ednDiagMode.DiagnoseFirst(key)
+	if err != nil {
+		return "?"
+	}
+	return name
+}
+
+// array checks the next data item, checking the elements with item if it is
+// an array.
+func (c *deterministicChecker) array(path string, item func(i uint64, path string) error) error {
+	if c.peek() != 4 {
+		return c.value(path)
+	}
+	_, n, err := c.head(path)
+	if err != nil {
+		return err
+	}
+	for i := uint64(0); i < n; i++ {
+		if err := item(i, path+"["+strconv.FormatUint(i, 10)+"]"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// message checks a COSE_Sign1 or, if signatures is true, a COSE_Sign object.
+func (c *deterministicChecker) message(path string, signatures bool) error {
+	return c.array(path, func(i uint64, path string) error {
+		switch i {
+		case 0:
+			return c.protected(path)
+		case 1:
+			return c.unprotected(path)
+		case 3:
+			if signatures {
+				return c.array(path, func(_ uint64, path string) error {
+					return c.signature(path)
+				})
+			}
+		}
+		return c.value(path)
+	})
+}
+
+// signature checks a COSE_Signature or COSE_Countersignature object.
+func (c *deterministicChecker) signature(path string) error {
+	return c.array(path, func(i uint64, path string) error {
+		switch i {
+		case 0:
+			return c.protected(path)
+		case 1:
+			return c.unprotected(path)
+		}
+		return c.value(path)
+	})
+}
+
+// protected checks an encoded protected header and the header map it embeds.
+func (c *deterministicChecker) protected(path string) error {
+	if c.peek() != 2 {
+		return c.value(path)
+	}
+	_, n, err := c.head(path)
+	if err != nil {
+		return err
+	}
+	content := c.data[c.offset : c.offset+int(n)]
+	c.offset += int(n)
+	if len(content) == 0 {
+		return nil
+	}
+	// the embedded header map is not covered by the well-formedness check of
+	// the enclosing message.
+	if err := decMode.Wellformed(content); err != nil {
+		return err
+	}
+	sub := &deterministicChecker{data: content}
+	return sub.unprotected(path + "<<>>")
+}
+
+// unprotected checks a header map, including any countersignatures.
+func (c *deterministicChecker) unprotected(path string) error {
+	if c.peek() != 5 {
+		return c.value(path)
+	}
+	_, n, err := c.head(path)
+	if err != nil {
+		return err
+	}
+	return c.mapEntries(path, n, func(key []byte, path string) error {
+		switch {
+		case bytes.Equal(key, []byte{byte(HeaderLabelCounterSignature)}),
+			bytes.Equal(key, []byte{byte(HeaderLabelCounterSignatureV2)}):
+			return c.countersignatures(path)
+		}
+		return c.value(path)
+	})
+}
+
+// countersignatures checks a COSE_Countersignature object or an array of
+// COSE_Countersignature objects.
+func (c *deterministicChecker) countersignatures(path string) error {
+	if c.peek() != 4 {
+		return c.value(path)
+	}
+	offset := c.offset
+	_, n, err := c.head(path)
+	if err != nil {
+		return err
+	}
+	if n == 0 || c.peek() != 4 {
+		c.offset = offset
+		return c.signature(path)
+	}
+	for i := uint64(0); i < n; i++ {
+		if err := c.signature(path + "[" + strconv.FormatUint(i, 10) + "]"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// float32FitsFloat16 reports whether the float32 value with the given bits
+// can be encoded as a float16 value without loss.
+func float32FitsFloat16(bits uint32) bool {
+	exp := int(bits>>23) & 0xff
+	mant := bits & 0x7fffff
+	switch exp {
+	case 0xff: // infinity or NaN
+		return mant&0x1fff == 0
+	case 0: // zero or subnormal
+		return mant == 0
+	}
+	e := exp - 127
+	switch {
+	case e >= -14 && e <= 15: // float16 normal
+		return mant&0x1fff == 0
+	case e >= -24 && e < -14: // float16 subnormal
+		significand := mant | 0x800000
+		shift := uint(-(e + 1))
+		return significand&(1<<shift-1) == 0
+	}
+	return false
+}
+
+// float64FitsFloat32 reports whether the float64 value with the given bits
+// can be encoded as a float32 value without loss, returning the bits of the
+// float32 value.
+func float64FitsFloat32(bits uint64) (uint32, bool) {
+	f := math.Float64frombits(bits)
+	if math.IsNaN(f) {
+		mant := bits & (1<<52 - 1)
+		if mant&(1<<29-1) != 0 {
+			return 0, false
+		}
+		sign := uint32(bits>>63) << 31
+		return sign | 0xff<<23 | uint32(mant>>29), true
+	}
+	f32 := float32(f)
+	if float64(f32) != f {
+		return 0, false
+	}
+	return math.Float32bits(f32), true
+}
@@ -0,0 +1,251 @@
+package cose
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestCheckDeterministicEncoding(t *testing.T) {
+	tests := []struct {
+		name    string
+		edn     string
+		hex     string
+		wantErr string
+	}{
+		{
+			name: "deterministic",
+			edn:  `{1: 1.5, 2: [-1, 1000000, 100000.0], h'': 1.1, "a": 2(h'010000000000000000')}`,
+		},
+		{
+			name:    "non-preferred integer",
+			edn:     `[0, 23_0]`,
+			wantErr: "cbor: non-deterministic encoding at $[1]: argument 23 not encoded in the shortest form",
+		},
+		{
+			name:    "non-preferred negative integer",
+			edn:     `{1: -256_2}`,
+			wantErr: "cbor: non-deterministic encoding at $[1]: argument 255 not encoded in the shortest form",
+		},
+		{
+			name:    "non-preferred length",
+			edn:     `[_1 1]`,
+			wantErr: "cbor: non-deterministic encoding at $: argument 1 not encoded in the shortest form",
+		},
+		{
+			name:    "non-preferred tag number",
+			hex:     "8201d80100", // [1, 1(0)]
+			wantErr: "cbor: non-deterministic encoding at $[1]: argument 1 not encoded in the shortest form",
+		},
+		{
+			name:    "float32 representable as float16",
+			edn:     `1.5_2`,
+			wantErr: "cbor: non-deterministic encoding at $: float32 representable as float16",
+		},
+		{
+			name:    "float64 representable as float16",
+			edn:     `[Infinity_3]`,
+			wantErr: "cbor: non-deterministic encoding at $[0]: float64 representable as float16",
+		},
+		{
+			name:    "float64 representable as float32",
+			edn:     `[100000.0_3]`,
+			wantErr: "cbor: non-deterministic encoding at $[0]: float64 representable as float32",
+		},
+		{
+			name:    "float16 subnormal as float32",
+			edn:     `5.960464477539063e-8_2`,
+			wantErr: "cbor: non-deterministic encoding at $: float32 representable as float16",
+		},
+		{
+			name:    "bignum with leading zeros",
+			edn:     `3(h'00010000000000000000')`,
+			wantErr: "cbor: non-deterministic encoding at $: bignum with leading zeros",
+		},
+		{
+			name:    "bignum representable as integer",
+			edn:     `2(h'01')`,
+			wantErr: "cbor: non-deterministic encoding at $: bignum representable as integer",
+		},
+		{
+			name:    "unsorted map",
+			edn:     `{"a": 1, 1: {10: 0, -1: 0}}`,
+			wantErr: `cbor: non-deterministic encoding at $[1]: map keys not sorted`,
+		},
+		{
+			name:    "unsorted nested map",
+			edn:     `{1: {-1: 0, 10: 0}}`,
+			wantErr: `cbor: non-deterministic encoding at $[1][10]: map keys not sorted`,
+		},
+		{
+			name:    "length-first ordering",
+			edn:     `{"b": 0, "aa": 0}`,
+			wantErr: ``,
+		},
+		{
+			name:    "non-preferred map key",
+			edn:     `{"foo": {1_0: 0}}`,
+			wantErr: `cbor: non-deterministic encoding at $["foo"][1]: map key: argument 1 not encoded in the shortest form`,
+		},
+		{
+			name:    "invalid bignum in map key",
+			hex:     "a1a1c330383030", // {{3(-17): -25}: -17}
+			wantErr: "cbor: tag number 2 or 3 must be followed by byte string, got negative integer",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := mustTestCBOR(t, tt.edn, tt.hex)
+			err := CheckDeterministicEncoding(data)
+			if err != nil && (err.Error() != tt.wantErr) {
+				t.Errorf("CheckDeterministicEncoding() error = %v, wantErr %v", err, tt.wantErr)
+			} else if err == nil && (tt.wantErr != "") {
+				t.Errorf("CheckDeterministicEncoding() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCBOROptions_Deterministic(t *testing.T) {
+	opts := &CBOROptions{Deterministic: true}
+	tests := []struct {
+		name     string
+		edn      string
+		hex      string
+		sign     bool
+		wantPath string
+		wantErr  string
+	}{
+		{
+			name: "deterministic Sign1",
+			edn:  `18([<<{1: -7}>>, {4: h'31', 11: [<<{1: -7}>>, {}, h'01']}, h'', h'0102'])`,
+		},
+		{
+			name:     "protected header",
+			edn:      `18([<<{1: -7_0}>>, {}, h'', h'0102'])`,
+			wantPath: "$[0]<<>>[1]",
+			wantErr:  "cbor: non-deterministic encoding at $[0]<<>>[1]: argument 6 not encoded in the shortest form",
+		},
+		{
+			name:     "unprotected header",
+			edn:      `18([<<{1: -7}>>, {"foo": 1, 4: h'31'}, h'', h'0102'])`,
+			wantPath: "$[1][4]",
+			wantErr:  "cbor: non-deterministic encoding at $[1][4]: map keys not sorted",
+		},
+		{
+			name:     "unprotected header value",
+			edn:      `18([<<{1: -7}>>, {-65537: {"b": 1.5_3}}, h'', h'0102'])`,
+			wantPath: `$[1][-65537]["b"]`,
+			wantErr:  `cbor: non-deterministic encoding at $[1][-65537]["b"]: float64 representable as float16`,
+		},
+		{
+			name:     "payload length",
+			hex:      "d284" + "43a10126" + "a0" + "580100" + "420102", // payload h'00' with 1-byte length
+			wantPath: "$[2]",
+			wantErr:  "cbor: non-deterministic encoding at $[2]: argument 1 not encoded in the shortest form",
+		},
+		{
+			name:     "countersignature",
+			edn:      `18([<<{1: -7}>>, {11: [<<{1: -7, 4_0: h'31'}>>, {}, h'01']}, h'', h'0102'])`,
+			wantPath: "$[1][11][0]<<>>[4]",
+			wantErr:  "cbor: non-deterministic encoding at $[1][11][0]<<>>[4]: map key: argument 4 not encoded in the shortest form",
+		},
+		{
+			name:     "countersignatures",
+			edn:      `18([<<{1: -7}>>, {11: [[<<{1: -7}>>, {}, h'01'], [<<{1: -7}>>, {4_0: h'31'}, h'01']]}, h'', h'0102'])`,
+			wantPath: "$[1][11][1][1][4]",
+			wantErr:  "cbor: non-deterministic encoding at $[1][11][1][1][4]: map key: argument 4 not encoded in the shortest form",
+		},
+		{
+			name: "deterministic Sign",
+			edn:  `98([h'', {}, h'', [[<<{1: -7}>>, {4: h'31'}, h'01']]])`,
+			sign: true,
+		},
+		{
+			name:     "signature protected header",
+			edn:      `98([h'', {}, h'', [[<<{1: -7}>>, {}, h'01'], [<<{1: -7, 3: 0_1}>>, {}, h'01']]])`,
+			sign:     true,
+			wantPath: "$[3][1][0]<<>>[3]",
+			wantErr:  "cbor: non-deterministic encoding at $[3][1][0]<<>>[3]: argument 0 not encoded in the shortest form",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := mustTestCBOR(t, tt.edn, tt.hex)
+			var err error
+
+			// the default options accept non-deterministic encodings
+			if tt.sign {
+				var msg SignMessage
+				if err := msg.UnmarshalCBOR(data); err != nil {
+					t.Fatalf("SignMessage.UnmarshalCBOR() error = %v", err)
+				}
+				err = msg.UnmarshalCBORWithOptions(data, opts)
+			} else {
+				var msg Sign1Message
+				if err := msg.UnmarshalCBOR(data); err != nil {
+					t.Fatalf("Sign1Message.UnmarshalCBOR() error = %v", err)
+				}
+				err = msg.UnmarshalCBORWithOptions(data, opts)
+			}
+			if err != nil && (err.Error() != tt.wantErr) {
+				t.Errorf("UnmarshalCBORWithOptions() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			} else if err == nil && (tt.wantErr != "") {
+				t.Errorf("UnmarshalCBORWithOptions() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr == "" {
+				return
+			}
+			var encErr *DeterministicEncodingError
+			if !errors.As(err, &encErr) {
+				t.Fatalf("UnmarshalCBORWithOptions() error type = %T, want *DeterministicEncodingError", err)
+			}
+			if encErr.Path != tt.wantPath {
+				t.Errorf("DeterministicEncodingError.Path = %s, want %s", encErr.Path, tt.wantPath)
+			}
+		})
+	}
+}
+
+// mustTestCBOR returns the test data given in diagnostic notation
+// or, for encodings not expressible in diagnostic notation, in hex.
+func mustTestCBOR(t *testing.T, edn, hex string) []byte {
+	t.Helper()
+	if hex != "" {
+		return mustHexToBytes(hex)
+	}
+	data, err := ParseDiagnostic(edn)
+	if err != nil {
+		t.Fatalf("ParseDiagnostic() error = %v", err)
+	}
+	return data
+}
+
+func TestCBOROptions_Deterministic_MalformedProtected(t *testing.T) {
+	opts := &CBOROptions{Deterministic: true}
+	tests := []struct {
+		name string
+		hex  string
+	}{
+		{
+			name: "missing map value",
+			hex:  "d284" + "42a101" + "a0" + "f6" + "4100", // protected h'a101'
+		},
+		{
+			name: "truncated argument",
+			hex:  "d284" + "43a10119" + "a0" + "f6" + "4100", // protected h'a10119'
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := mustTestCBOR(t, "", tt.hex)
+			var msg Sign1Message
+			err := msg.UnmarshalCBORWithOptions(data, opts)
+			if err != io.ErrUnexpectedEOF {
+				t.Errorf("UnmarshalCBORWithOptions() error = %v, want %v", err, io.ErrUnexpectedEOF)
+			}
+		})
+	}
+}
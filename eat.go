@@ -0,0 +1,875 @@
+package cose
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// Entity Attestation Token (EAT) claims registered in the IANA "CBOR Web Token
+// (CWT) Claims" registry.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9711.html#section-10.1
+const (
+	CWTClaimNonce         int64 = 10
+	CWTClaimUEID          int64 = 256
+	CWTClaimSUEIDs        int64 = 257
+	CWTClaimOEMID         int64 = 258
+	CWTClaimHardwareModel int64 = 259
+	CWTClaimDebugStatus   int64 = 263
+	CWTClaimLocation      int64 = 264
+	CWTClaimProfile       int64 = 265
+	CWTClaimSubmodules    int64 = 266
+	CWTClaimSoftwareName  int64 = 270
+	CWTClaimIntendedUse   int64 = 275
+)
+
+// eatClaimName returns the name of an EAT claim, or an empty string if the
+// claim is not known.
+func eatClaimName(label int64) string {
+	switch label {
+	case CWTClaimNonce:
+		return "eat_nonce"
+	case CWTClaimUEID:
+		return "ueid"
+	case CWTClaimSUEIDs:
+		return "sueids"
+	case CWTClaimOEMID:
+		return "oemid"
+	case CWTClaimHardwareModel:
+		return "hwmodel"
+	case CWTClaimDebugStatus:
+		return "dbgstat"
+	case CWTClaimLocation:
+		return "location"
+	case CWTClaimProfile:
+		return "eat_profile"
+	case CWTClaimSubmodules:
+		return "submods"
+	case CWTClaimSoftwareName:
+		return "swname"
+	case CWTClaimIntendedUse:
+		return "intuse"
+	}
+	return ""
+}
+
+// EATDebugStatus is the value of the dbgstat claim.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9711.html#section-4.3.1
+type EATDebugStatus uint64
+
+// Debug status of the dbgstat claim.
+const (
+	EATDebugStatusEnabled EATDebugStatus = iota
+	EATDebugStatusDisabled
+	EATDebugStatusDisabledSinceBoot
+	EATDebugStatusDisabledPermanently
+	EATDebugStatusDisabledFullyAndPermanently
+)
+
+// EATIntendedUse is the value of the intuse claim.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9711.html#section-4.3.3
+type EATIntendedUse uint64
+
+// Intended use of the intuse claim.
+const (
+	EATIntendedUseGeneric EATIntendedUse = iota + 1
+	EATIntendedUseRegistration
+	EATIntendedUseProvisioning
+	EATIntendedUseCertificateIssuance
+	EATIntendedUseProofOfPossession
+)
+
+// EATLocation is the value of the location claim.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9711.html#section-4.2.10
+type EATLocation struct {
+	// Latitude and Longitude are in degrees, as in the WGS84 coordinate
+	// system.
+	Latitude  float64
+	Longitude float64
+
+	// Altitude is in meters above the WGS84 ellipsoid.
+	Altitude *float64
+
+	// Accuracy and AltitudeAccuracy are in meters.
+	Accuracy         *float64
+	AltitudeAccuracy *float64
+
+	// Heading is in degrees relative to true north.
+	Heading *float64
+
+	// Speed is in meters per second.
+	Speed *float64
+
+	// Timestamp is the time the location was obtained.
+	Timestamp *NumericDate
+
+	// Age is the number of seconds since the location was obtained.
+	Age *uint64
+}
+
+// Labels of the location claim.
+const (
+	eatLocationLatitude int64 = iota + 1
+	eatLocationLongitude
+	eatLocationAltitude
+	eatLocationAccuracy
+	eatLocationAltitudeAccuracy
+	eatLocationHeading
+	eatLocationSpeed
+	eatLocationTimestamp
+	eatLocationAge
+)
+
+// EATSubmodule is a submodule of the submods claim. Exactly one of the fields
+// is set.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9711.html#section-4.2.18
+type EATSubmodule struct {
+	// Claims is the claims set of a submodule in the same token.
+	Claims CWTClaims
+
+	// Token is the encoded CBOR token of a nested token, e.g. a CWT.
+	Token []byte
+
+	// JSONToken is a nested JSON token, e.g. a JWT.
+	JSONToken string
+
+	// Digest is the digest of a detached submodule.
+	Digest *EATDetachedDigest
+}
+
+// EATDetachedDigest is the digest of the claims set of a submodule conveyed
+// separately from the token.
+//
+//	Detached-Submodule-Digest = [
+//	    hash-algorithm : text / int,
+//	    digest         : binary-data
+//	]
+type EATDetachedDigest struct {
+	// Algorithm is the hash algorithm, e.g. AlgorithmSHA256.
+	Algorithm Algorithm
+
+	// AlgorithmName, if not empty, is the name of the hash algorithm in the
+	// IANA "Named Information Hash Algorithm" registry, e.g. "sha-256", which
+	// is encoded instead of Algorithm.
+	// Decoded names of sha-256, sha-384 and sha-512 also set Algorithm.
+	AlgorithmName string
+
+	// Digest is the hash of the detached claims set.
+	Digest []byte
+}
+
+// eatHashAlgorithms maps the names of the IANA "Named Information Hash
+// Algorithm" registry to hash algorithms.
+//
+// Reference: https://www.iana.org/assignments/named-information/named-information.xhtml
+var eatHashAlgorithms = map[string]Algorithm{
+	"sha-256": AlgorithmSHA256,
+	"sha-384": AlgorithmSHA384,
+	"sha-512": AlgorithmSHA512,
+}
+
+// Verify verifies that data, e.g. an encoded claims set, matches the digest.
+// It fails with ErrVerification otherwise.
+func (d *EATDetachedDigest) Verify(data []byte) error {
+	digest, err := d.Algorithm.computeHash(data)
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare(digest, d.Digest) != 1 {
+		return ErrVerification
+	}
+	return nil
+}
+
+// VerifyToken verifies the signature of a nested CBOR token, a CWT, and
+// returns its claims.
+func (s *EATSubmodule) VerifyToken(verifier Verifier) (CWTClaims, error) {
+	if s.Token == nil {
+		return nil, errors.New("submodule: require nested CBOR token")
+	}
+	claims, err := VerifyCWT(s.Token, verifier, nil)
+	if err != nil {
+		return nil, err
+	}
+	return claims.Claims()
+}
+
+// missingEATClaim returns the error of a missing EAT claim.
+func missingEATClaim(label int64) error {
+	return fmt.Errorf("cwt claim: %s: %w", eatClaimName(label), ErrCWTMissingClaim)
+}
+
+// Nonce returns the values of the eat_nonce claim, a single nonce or an array
+// of nonces.
+func (c CWTClaims) Nonce() ([][]byte, error) {
+	value, ok := c[CWTClaimNonce]
+	if !ok {
+		return nil, missingEATClaim(CWTClaimNonce)
+	}
+	return eatNonce(value)
+}
+
+// SetNonce sets the eat_nonce claim, encoded as a single nonce or as an array
+// if more than one nonce is given. Nonces have 8 to 64 bytes.
+func (c CWTClaims) SetNonce(nonces ...[]byte) error {
+	var value any
+	switch len(nonces) {
+	case 0:
+		return errors.New("cwt claim: eat_nonce: require nonce")
+	case 1:
+		value = nonces[0]
+	default:
+		array := make([]any, 0, len(nonces))
+		for _, nonce := range nonces {
+			array = append(array, nonce)
+		}
+		value = array
+	}
+	if _, err := eatNonce(value); err != nil {
+		return err
+	}
+	c[CWTClaimNonce] = value
+	return nil
+}
+
+// eatNonce returns the values of the eat_nonce claim.
+func eatNonce(value any) ([][]byte, error) {
+	nonces, ok := value.([]any)
+	if !ok {
+		nonces = []any{value}
+	} else if len(nonces) < 2 {
+		return nil, errors.New("cwt claim: eat_nonce: require at least 2 nonces in array")
+	}
+	result := make([][]byte, 0, len(nonces))
+	for _, nonce := range nonces {
+		b, ok := nonce.([]byte)
+		if !ok || len(b) < 8 || len(b) > 64 {
+			return nil, errors.New("cwt claim: eat_nonce: require bstr of 8 to 64 bytes")
+		}
+		result = append(result, b)
+	}
+	return result, nil
+}
+
+// UEID returns the ueid claim, the universal entity ID of the device.
+func (c CWTClaims) UEID() ([]byte, error) {
+	value, ok := c[CWTClaimUEID]
+	if !ok {
+		return nil, missingEATClaim(CWTClaimUEID)
+	}
+	return eatUEID(CWTClaimUEID, value)
+}
+
+// SetUEID sets the ueid claim. A UEID has 7 to 33 bytes.
+func (c CWTClaims) SetUEID(ueid []byte) error {
+	if _, err := eatUEID(CWTClaimUEID, ueid); err != nil {
+		return err
+	}
+	c[CWTClaimUEID] = ueid
+	return nil
+}
+
+// eatUEID returns the value of a UEID.
+func eatUEID(label int64, value any) ([]byte, error) {
+	ueid, ok := value.([]byte)
+	if !ok || len(ueid) < 7 || len(ueid) > 33 {
+		return nil, fmt.Errorf("cwt claim: %s: require bstr of 7 to 33 bytes", eatClaimName(label))
+	}
+	return ueid, nil
+}
+
+// SUEIDs returns the sueids claim, the semi-permanent UEIDs of the device by
+// name.
+func (c CWTClaims) SUEIDs() (map[string][]byte, error) {
+	value, ok := c[CWTClaimSUEIDs]
+	if !ok {
+		return nil, missingEATClaim(CWTClaimSUEIDs)
+	}
+	return eatSUEIDs(value)
+}
+
+// SetSUEIDs sets the sueids claim.
+func (c CWTClaims) SetSUEIDs(sueids map[string][]byte) error {
+	value := make(map[any]any, len(sueids))
+	for name, ueid := range sueids {
+		value[name] = ueid
+	}
+	if _, err := eatSUEIDs(value); err != nil {
+		return err
+	}
+	c[CWTClaimSUEIDs] = value
+	return nil
+}
+
+// eatSUEIDs returns the values of the sueids claim.
+func eatSUEIDs(value any) (map[string][]byte, error) {
+	m, ok := value.(map[any]any)
+	if !ok || len(m) == 0 {
+		return nil, errors.New("cwt claim: sueids: require non-empty map")
+	}
+	sueids := make(map[string][]byte, len(m))
+	for name, ueid := range m {
+		name, ok := name.(string)
+		if !ok {
+			return nil, errors.New("cwt claim: sueids: require tstr label")
+		}
+		b, err := eatUEID(CWTClaimSUEIDs, ueid)
+		if err != nil {
+			return nil, err
+		}
+		sueids[name] = b
+	}
+	return sueids, nil
+}
+
+// OEMID returns the oemid claim, the hardware manufacturer ID, either as a
+// []byte for a random or an IEEE based ID, or as an int64 for an IANA Private
+// Enterprise Number.
+func (c CWTClaims) OEMID() (any, error) {
+	value, ok := c[CWTClaimOEMID]
+	if !ok {
+		return nil, missingEATClaim(CWTClaimOEMID)
+	}
+	return eatOEMID(value)
+}
+
+// SetOEMID sets the oemid claim, either a 16 bytes random ID, a 3 bytes IEEE
+// based ID, or an int IANA Private Enterprise Number.
+func (c CWTClaims) SetOEMID(oemid any) error {
+	if _, err := eatOEMID(oemid); err != nil {
+		return err
+	}
+	c[CWTClaimOEMID] = oemid
+	return nil
+}
+
+// eatOEMID returns the value of the oemid claim.
+func eatOEMID(value any) (any, error) {
+	if b, ok := value.([]byte); ok && (len(b) == 3 || len(b) == 16) {
+		return b, nil
+	}
+	if canInt(value) {
+		if pen, ok := numericDate(value); ok {
+			return pen.value, nil
+		}
+	}
+	return nil, errors.New("cwt claim: oemid: require bstr of 3 or 16 bytes / int")
+}
+
+// HardwareModel returns the hwmodel claim.
+func (c CWTClaims) HardwareModel() ([]byte, error) {
+	value, ok := c[CWTClaimHardwareModel]
+	if !ok {
+		return nil, missingEATClaim(CWTClaimHardwareModel)
+	}
+	return eatHardwareModel(value)
+}
+
+// SetHardwareModel sets the hwmodel claim, of 1 to 32 bytes.
+func (c CWTClaims) SetHardwareModel(model []byte) error {
+	if _, err := eatHardwareModel(model); err != nil {
+		return err
+	}
+	c[CWTClaimHardwareModel] = model
+	return nil
+}
+
+// eatHardwareModel returns the value of the hwmodel claim.
+func eatHardwareModel(value any) ([]byte, error) {
+	b, ok := value.([]byte)
+	if !ok || len(b) < 1 || len(b) > 32 {
+		return nil, errors.New("cwt claim: hwmodel: require bstr of 1 to 32 bytes")
+	}
+	return b, nil
+}
+
+// SoftwareName returns the swname claim.
+func (c CWTClaims) SoftwareName() (string, error) {
+	value, ok := c[CWTClaimSoftwareName]
+	if !ok {
+		return "", missingEATClaim(CWTClaimSoftwareName)
+	}
+	name, ok := value.(string)
+	if !ok {
+		return "", errors.New("cwt claim: swname: require tstr")
+	}
+	return name, nil
+}
+
+// SetSoftwareName sets the swname claim.
+func (c CWTClaims) SetSoftwareName(name string) {
+	c[CWTClaimSoftwareName] = name
+}
+
+// DebugStatus returns the dbgstat claim.
+func (c CWTClaims) DebugStatus() (EATDebugStatus, error) {
+	value, ok := c[CWTClaimDebugStatus]
+	if !ok {
+		return 0, missingEATClaim(CWTClaimDebugStatus)
+	}
+	status, ok := eatUint(value, uint64(EATDebugStatusDisabledFullyAndPermanently))
+	if !ok {
+		return 0, errors.New("cwt claim: dbgstat: require uint of 0 to 4")
+	}
+	return EATDebugStatus(status), nil
+}
+
+// SetDebugStatus sets the dbgstat claim.
+func (c CWTClaims) SetDebugStatus(status EATDebugStatus) error {
+	if status > EATDebugStatusDisabledFullyAndPermanently {
+		return errors.New("cwt claim: dbgstat: require uint of 0 to 4")
+	}
+	c[CWTClaimDebugStatus] = uint64(status)
+	return nil
+}
+
+// IntendedUse returns the intuse claim.
+func (c CWTClaims) IntendedUse() (EATIntendedUse, error) {
+	value, ok := c[CWTClaimIntendedUse]
+	if !ok {
+		return 0, missingEATClaim(CWTClaimIntendedUse)
+	}
+	use, ok := eatUint(value, uint64(EATIntendedUseProofOfPossession))
+	if !ok || use == 0 {
+		return 0, errors.New("cwt claim: intuse: require uint of 1 to 5")
+	}
+	return EATIntendedUse(use), nil
+}
+
+// SetIntendedUse sets the intuse claim.
+func (c CWTClaims) SetIntendedUse(use EATIntendedUse) error {
+	if use < EATIntendedUseGeneric || use > EATIntendedUseProofOfPossession {
+		return errors.New("cwt claim: intuse: require uint of 1 to 5")
+	}
+	c[CWTClaimIntendedUse] = uint64(use)
+	return nil
+}
+
+// eatUint returns the value of a uint claim, up to limit.
+func eatUint(value any, limit uint64) (uint64, bool) {
+	if !canUint(value) {
+		return 0, false
+	}
+	n := reflect.ValueOf(value)
+	if n.CanUint() {
+		return n.Uint(), n.Uint() <= limit
+	}
+	return uint64(n.Int()), uint64(n.Int()) <= limit
+}
+
+// eatNumber returns the value of an int / float claim.
+func eatNumber(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	if !canInt(value) {
+		return 0, false
+	}
+	n := reflect.ValueOf(value)
+	if n.CanUint() {
+		return float64(n.Uint()), true
+	}
+	return float64(n.Int()), true
+}
+
+// Location returns the location claim.
+func (c CWTClaims) Location() (*EATLocation, error) {
+	value, ok := c[CWTClaimLocation]
+	if !ok {
+		return nil, missingEATClaim(CWTClaimLocation)
+	}
+	return eatLocation(value)
+}
+
+// SetLocation sets the location claim.
+func (c CWTClaims) SetLocation(location *EATLocation) error {
+	if location == nil {
+		return errors.New("cwt claim: location: require location")
+	}
+	value := map[any]any{
+		eatLocationLatitude:  location.Latitude,
+		eatLocationLongitude: location.Longitude,
+	}
+	for label, v := range map[int64]*float64{
+		eatLocationAltitude:         location.Altitude,
+		eatLocationAccuracy:         location.Accuracy,
+		eatLocationAltitudeAccuracy: location.AltitudeAccuracy,
+		eatLocationHeading:          location.Heading,
+		eatLocationSpeed:            location.Speed,
+	} {
+		if v != nil {
+			value[label] = *v
+		}
+	}
+	if location.Timestamp != nil {
+		value[eatLocationTimestamp] = location.Timestamp.claim()
+	}
+	if location.Age != nil {
+		value[eatLocationAge] = *location.Age
+	}
+	c[CWTClaimLocation] = value
+	return nil
+}
+
+// eatLocation returns the value of the location claim.
+func eatLocation(value any) (*EATLocation, error) {
+	m, ok := value.(map[any]any)
+	if !ok {
+		return nil, errors.New("cwt claim: location: require map")
+	}
+	var l EATLocation
+	var hasLatitude, hasLongitude bool
+	for label, v := range m {
+		label, ok := normalizeLabel(label)
+		if !ok {
+			return nil, errors.New("cwt claim: location: require int label")
+		}
+		switch label {
+		case eatLocationTimestamp:
+			d, ok := numericDate(v)
+			if !ok {
+				return nil, errors.New("cwt claim: location: timestamp: require int / float")
+			}
+			l.Timestamp = &d
+			continue
+		case eatLocationAge:
+			age, ok := eatUint(v, math.MaxUint64)
+			if !ok {
+				return nil, errors.New("cwt claim: location: age: require uint")
+			}
+			l.Age = &age
+			continue
+		}
+		f, ok := eatNumber(v)
+		if !ok {
+			return nil, errors.New("cwt claim: location: require int / float")
+		}
+		switch label {
+		case eatLocationLatitude:
+			l.Latitude, hasLatitude = f, true
+		case eatLocationLongitude:
+			l.Longitude, hasLongitude = f, true
+		case eatLocationAltitude:
+			l.Altitude = &f
+		case eatLocationAccuracy:
+			l.Accuracy = &f
+		case eatLocationAltitudeAccuracy:
+			l.AltitudeAccuracy = &f
+		case eatLocationHeading:
+			l.Heading = &f
+		case eatLocationSpeed:
+			l.Speed = &f
+		}
+	}
+	if !hasLatitude || !hasLongitude {
+		return nil, errors.New("cwt claim: location: require latitude and longitude")
+	}
+	return &l, nil
+}
+
+// Profile returns the eat_profile claim, either a string URI or a []byte
+// encoded OID.
+func (c CWTClaims) Profile() (any, error) {
+	value, ok := c[CWTClaimProfile]
+	if !ok {
+		return nil, missingEATClaim(CWTClaimProfile)
+	}
+	return eatProfile(value)
+}
+
+// SetProfile sets the eat_profile claim, either a string URI or a []byte
+// encoded OID.
+func (c CWTClaims) SetProfile(profile any) error {
+	if _, err := eatProfile(profile); err != nil {
+		return err
+	}
+	c[CWTClaimProfile] = profile
+	return nil
+}
+
+// eatProfile returns the value of the eat_profile claim.
+func eatProfile(value any) (any, error) {
+	// A profile may be tagged as URI (32) or OID (111).
+	if tag, ok := value.(cbor.Tag); ok && (tag.Number == 32 || tag.Number == 111) {
+		value = tag.Content
+	}
+	switch v := value.(type) {
+	case string:
+		if v != "" {
+			return v, nil
+		}
+	case []byte:
+		if len(v) > 0 {
+			return v, nil
+		}
+	}
+	return nil, errors.New("cwt claim: eat_profile: require URI tstr / OID bstr")
+}
+
+// Submodules returns the submods claim, the submodules by name.
+func (c CWTClaims) Submodules() (map[string]*EATSubmodule, error) {
+	value, ok := c[CWTClaimSubmodules]
+	if !ok {
+		return nil, missingEATClaim(CWTClaimSubmodules)
+	}
+	m, ok := value.(map[any]any)
+	if !ok {
+		return nil, errors.New("cwt claim: submods: require map")
+	}
+	submods := make(map[string]*EATSubmodule, len(m))
+	for name, v := range m {
+		name, ok := name.(string)
+		if !ok {
+			return nil, errors.New("cwt claim: submods: require tstr label")
+		}
+		s, err := eatSubmodule(v)
+		if err != nil {
+			return nil, fmt.Errorf("cwt claim: submods: %s: %w", name, err)
+		}
+		submods[name] = s
+	}
+	return submods, nil
+}
+
+// SetSubmodule sets a submodule of the submods claim by name.
+func (c CWTClaims) SetSubmodule(name string, s *EATSubmodule) error {
+	if s == nil {
+		return fmt.Errorf("cwt claim: submods: %s: require submodule", name)
+	}
+	var value any
+	var count int
+	if s.Claims != nil {
+		value, count = map[any]any(s.Claims), count+1
+	}
+	if s.Token != nil {
+		value, count = s.Token, count+1
+	}
+	if s.JSONToken != "" {
+		value, count = s.JSONToken, count+1
+	}
+	if s.Digest != nil {
+		var alg any = int64(s.Digest.Algorithm)
+		if s.Digest.AlgorithmName != "" {
+			alg = s.Digest.AlgorithmName
+		}
+		value, count = []any{alg, s.Digest.Digest}, count+1
+	}
+	if count != 1 {
+		return fmt.Errorf("cwt claim: submods: %s: require exactly one submodule type", name)
+	}
+	submods, ok := c[CWTClaimSubmodules].(map[any]any)
+	if !ok {
+		submods = make(map[any]any)
+		c[CWTClaimSubmodules] = submods
+	}
+	submods[name] = value
+	return nil
+}
+
+// eatSubmodule returns a submodule of the submods claim.
+func eatSubmodule(value any) (*EATSubmodule, error) {
+	switch v := value.(type) {
+	case map[any]any:
+		return &EATSubmodule{Claims: v}, nil
+	case CWTClaims:
+		return &EATSubmodule{Claims: v}, nil
+	case []byte:
+		return &EATSubmodule{Token: v}, nil
+	case string:
+		return &EATSubmodule{JSONToken: v}, nil
+	case []any:
+		if len(v) == 2 {
+			if digest, ok := v[1].([]byte); ok {
+				switch alg := v[0].(type) {
+				case int64:
+					return &EATSubmodule{Digest: &EATDetachedDigest{
+						Algorithm: Algorithm(alg),
+						Digest:    digest,
+					}}, nil
+				case string:
+					return &EATSubmodule{Digest: &EATDetachedDigest{
+						Algorithm:     eatHashAlgorithms[alg],
+						AlgorithmName: alg,
+						Digest:        digest,
+					}}, nil
+				}
+			}
+		}
+		return nil, errors.New("detached digest: require [int / tstr, bstr]")
+	}
+	return nil, errors.New("require claims set / nested token / detached digest")
+}
+
+// EATValidator validates the claims of Entity Attestation Tokens.
+//
+// Validation failures wrap ErrEATNonceMismatch, ErrEATProfileMismatch or
+// ErrCWTMissingClaim, which can be tested with errors.Is, in addition to the
+// errors of CWTValidator.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9711.html#section-7
+type EATValidator struct {
+	// Claims validates the CWT claims, if not nil.
+	Claims *CWTValidator
+
+	// Nonce, if not nil, must be one of the values of the eat_nonce claim.
+	Nonce []byte
+
+	// Profile, if not nil, must be equal to the eat_profile claim, either a
+	// string URI or a []byte encoded OID.
+	Profile any
+}
+
+// Validate validates the claims, including the type of the EAT claims of the
+// claims and of the claims set submodules.
+func (v *EATValidator) Validate(claims CWTClaims) error {
+	if claims == nil {
+		return errors.New("validating nil CWTClaims")
+	}
+	if err := validateEATClaims(claims); err != nil {
+		return err
+	}
+	if v.Claims != nil {
+		s, err := claims.ClaimsSet()
+		if err != nil {
+			return err
+		}
+		if err := v.Claims.Validate(s); err != nil {
+			return err
+		}
+	}
+	if v.Nonce != nil {
+		nonces, err := claims.Nonce()
+		if err != nil {
+			return err
+		}
+		if !slices.ContainsFunc(nonces, func(nonce []byte) bool {
+			return subtle.ConstantTimeCompare(nonce, v.Nonce) == 1
+		}) {
+			return fmt.Errorf("cwt claim: eat_nonce: %w", ErrEATNonceMismatch)
+		}
+	}
+	if v.Profile != nil {
+		profile, err := claims.Profile()
+		if err != nil {
+			return err
+		}
+		if !eatProfileEqual(profile, v.Profile) {
+			return fmt.Errorf("cwt claim: eat_profile: %w", ErrEATProfileMismatch)
+		}
+	}
+	return nil
+}
+
+// eatProfileEqual reports whether both profiles are equal.
+func eatProfileEqual(a, b any) bool {
+	switch a := a.(type) {
+	case string:
+		b, ok := b.(string)
+		return ok && a == b
+	case []byte:
+		b, ok := b.([]byte)
+		return ok && bytes.Equal(a, b)
+	}
+	return false
+}
+
+// validateEATClaims validates the type of the EAT claims present in the
+// claims, and in the claims set submodules.
+func validateEATClaims(claims CWTClaims) error {
+	for _, label := range []int64{
+		CWTClaimNonce,
+		CWTClaimUEID,
+		CWTClaimSUEIDs,
+		CWTClaimOEMID,
+		CWTClaimHardwareModel,
+		CWTClaimDebugStatus,
+		CWTClaimLocation,
+		CWTClaimProfile,
+		CWTClaimSoftwareName,
+		CWTClaimIntendedUse,
+	} {
+		if _, ok := claims[label]; !ok {
+			continue
+		}
+		var err error
+		switch label {
+		case CWTClaimNonce:
+			_, err = claims.Nonce()
+		case CWTClaimUEID:
+			_, err = claims.UEID()
+		case CWTClaimSUEIDs:
+			_, err = claims.SUEIDs()
+		case CWTClaimOEMID:
+			_, err = claims.OEMID()
+		case CWTClaimHardwareModel:
+			_, err = claims.HardwareModel()
+		case CWTClaimDebugStatus:
+			_, err = claims.DebugStatus()
+		case CWTClaimLocation:
+			_, err = claims.Location()
+		case CWTClaimProfile:
+			_, err = claims.Profile()
+		case CWTClaimSoftwareName:
+			_, err = claims.SoftwareName()
+		case CWTClaimIntendedUse:
+			_, err = claims.IntendedUse()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if _, ok := claims[CWTClaimSubmodules]; !ok {
+		return nil
+	}
+	submods, err := claims.Submodules()
+	if err != nil {
+		return err
+	}
+	for name, s := range submods {
+		if s.Claims == nil {
+			continue
+		}
+		if err := validateEATClaims(s.Claims); err != nil {
+			return fmt.Errorf("cwt claim: submods: %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// VerifyEAT decodes an Entity Attestation Token carried in a CWT, verifies
+// its signature and validates its claims, and returns the verified claims.
+// The claims are not validated if validator is nil.
+//
+// Nested token submodules are not verified, see EATSubmodule.VerifyToken.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9711.html#section-5
+func VerifyEAT(data []byte, verifier Verifier, validator *EATValidator) (CWTClaims, error) {
+	s, err := VerifyCWT(data, verifier, nil)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := s.Claims()
+	if err != nil {
+		return nil, err
+	}
+	if validator != nil {
+		if err := validator.Validate(claims); err != nil {
+			return nil, err
+		}
+	}
+	return claims, nil
+}
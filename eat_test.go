@@ -0,0 +1,382 @@
+package cose
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCWTClaims_EAT(t *testing.T) {
+	signerKey := generateTestECDSAKey(t)
+	signer, err := NewSigner(AlgorithmES256, signerKey)
+	if err != nil {
+		t.Fatalf("NewSigner() error = %v", err)
+	}
+	verifier, err := NewVerifier(AlgorithmES256, signerKey.Public())
+	if err != nil {
+		t.Fatalf("NewVerifier() error = %v", err)
+	}
+
+	// nested token submodule
+	nested := CWTClaims{}
+	nested.SetSoftwareName("tee")
+	nestedSet, err := nested.ClaimsSet()
+	if err != nil {
+		t.Fatalf("CWTClaims.ClaimsSet() error = %v", err)
+	}
+	nestedToken, err := SignCWT(rand.Reader, signer, Headers{
+		Protected: ProtectedHeader{HeaderLabelAlgorithm: AlgorithmES256},
+	}, nestedSet, false)
+	if err != nil {
+		t.Fatalf("SignCWT() error = %v", err)
+	}
+
+	// detached submodule
+	detached, err := encMode.Marshal(map[any]any{CWTClaimSoftwareName: "modem"})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	digest := sha256.Sum256(detached)
+
+	nonce := []byte("0123456789abcdef")
+	ueid := []byte{0x01, 0xde, 0xad, 0xbe, 0xef, 0xde, 0xad, 0xbe, 0xef}
+	accuracy := 10.5
+	age := uint64(60)
+	location := &EATLocation{
+		Latitude:  48.8566,
+		Longitude: 2.3522,
+		Accuracy:  &accuracy,
+		Age:       &age,
+	}
+	claims := CWTClaims{
+		CWTClaimIssuer: "attester.example",
+	}
+	for _, err := range []error{
+		claims.SetNonce(nonce, []byte("fedcba9876543210")),
+		claims.SetUEID(ueid),
+		claims.SetSUEIDs(map[string][]byte{"boot": ueid}),
+		claims.SetOEMID([]byte{0x00, 0x00, 0x5e}),
+		claims.SetHardwareModel([]byte("model")),
+		claims.SetDebugStatus(EATDebugStatusDisabledPermanently),
+		claims.SetLocation(location),
+		claims.SetProfile("tag:example.com,2025:eat"),
+		claims.SetIntendedUse(EATIntendedUseRegistration),
+		claims.SetSubmodule("secure element", &EATSubmodule{Claims: CWTClaims{CWTClaimUEID: ueid}}),
+		claims.SetSubmodule("tee", &EATSubmodule{Token: nestedToken}),
+		claims.SetSubmodule("modem", &EATSubmodule{Digest: &EATDetachedDigest{
+			Algorithm: AlgorithmSHA256,
+			Digest:    digest[:],
+		}}),
+	} {
+		if err != nil {
+			t.Fatalf("CWTClaims setter error = %v", err)
+		}
+	}
+	claims.SetSoftwareName("firmware")
+
+	set, err := claims.ClaimsSet()
+	if err != nil {
+		t.Fatalf("CWTClaims.ClaimsSet() error = %v", err)
+	}
+	token, err := SignCWT(rand.Reader, signer, Headers{
+		Protected: ProtectedHeader{HeaderLabelAlgorithm: AlgorithmES256},
+	}, set, true)
+	if err != nil {
+		t.Fatalf("SignCWT() error = %v", err)
+	}
+	got, err := VerifyEAT(token, verifier, &EATValidator{
+		Claims:  &CWTValidator{Issuer: "attester.example"},
+		Nonce:   nonce,
+		Profile: "tag:example.com,2025:eat",
+	})
+	if err != nil {
+		t.Fatalf("VerifyEAT() error = %v", err)
+	}
+
+	if nonces, err := got.Nonce(); err != nil || len(nonces) != 2 || !reflect.DeepEqual(nonces[0], nonce) {
+		t.Errorf("CWTClaims.Nonce() = %v, %v, want 2 nonces", nonces, err)
+	}
+	if got, err := got.UEID(); err != nil || !reflect.DeepEqual(got, ueid) {
+		t.Errorf("CWTClaims.UEID() = %v, %v, want %v", got, err, ueid)
+	}
+	if got, err := got.SUEIDs(); err != nil || !reflect.DeepEqual(got, map[string][]byte{"boot": ueid}) {
+		t.Errorf("CWTClaims.SUEIDs() = %v, %v", got, err)
+	}
+	if got, err := got.OEMID(); err != nil || !reflect.DeepEqual(got, []byte{0x00, 0x00, 0x5e}) {
+		t.Errorf("CWTClaims.OEMID() = %v, %v", got, err)
+	}
+	if got, err := got.HardwareModel(); err != nil || string(got) != "model" {
+		t.Errorf("CWTClaims.HardwareModel() = %v, %v", got, err)
+	}
+	if got, err := got.SoftwareName(); err != nil || got != "firmware" {
+		t.Errorf("CWTClaims.SoftwareName() = %v, %v", got, err)
+	}
+	if got, err := got.DebugStatus(); err != nil || got != EATDebugStatusDisabledPermanently {
+		t.Errorf("CWTClaims.DebugStatus() = %v, %v", got, err)
+	}
+	if got, err := got.IntendedUse(); err != nil || got != EATIntendedUseRegistration {
+		t.Errorf("CWTClaims.IntendedUse() = %v, %v", got, err)
+	}
+	if got, err := got.Location(); err != nil || !reflect.DeepEqual(got, location) {
+		t.Errorf("CWTClaims.Location() = %v, %v, want %v", got, err, location)
+	}
+	if got, err := got.Profile(); err != nil || got != "tag:example.com,2025:eat" {
+		t.Errorf("CWTClaims.Profile() = %v, %v", got, err)
+	}
+
+	submods, err := got.Submodules()
+	if err != nil {
+		t.Fatalf("CWTClaims.Submodules() error = %v", err)
+	}
+	if len(submods) != 3 {
+		t.Fatalf("CWTClaims.Submodules() = %d submodules, want 3", len(submods))
+	}
+	if got, err := submods["secure element"].Claims.UEID(); err != nil || !reflect.DeepEqual(got, ueid) {
+		t.Errorf("submodule UEID() = %v, %v, want %v", got, err, ueid)
+	}
+	nestedClaims, err := submods["tee"].VerifyToken(verifier)
+	if err != nil {
+		t.Fatalf("EATSubmodule.VerifyToken() error = %v", err)
+	}
+	if got, err := nestedClaims.SoftwareName(); err != nil || got != "tee" {
+		t.Errorf("nested token SoftwareName() = %v, %v", got, err)
+	}
+	if _, err := submods["modem"].VerifyToken(verifier); err == nil {
+		t.Error("EATSubmodule.VerifyToken() error = nil, want error for detached digest")
+	}
+	if err := submods["modem"].Digest.Verify(detached); err != nil {
+		t.Errorf("EATDetachedDigest.Verify() error = %v", err)
+	}
+	if err := submods["modem"].Digest.Verify(nestedToken); err != ErrVerification {
+		t.Errorf("EATDetachedDigest.Verify() error = %v, want %v", err, ErrVerification)
+	}
+
+	// validation failures
+	tests := []struct {
+		name      string
+		claims    CWTClaims
+		validator *EATValidator
+		wantErr   string
+		wantIs    error
+	}{
+		{
+			name:      "nonce mismatch",
+			claims:    got,
+			validator: &EATValidator{Nonce: []byte("other nonce")},
+			wantErr:   "cwt claim: eat_nonce: nonce mismatch",
+			wantIs:    ErrEATNonceMismatch,
+		},
+		{
+			name:      "profile mismatch",
+			claims:    got,
+			validator: &EATValidator{Profile: []byte{0x2b, 0x06, 0x01}},
+			wantErr:   "cwt claim: eat_profile: profile mismatch",
+			wantIs:    ErrEATProfileMismatch,
+		},
+		{
+			name:      "missing nonce",
+			claims:    CWTClaims{},
+			validator: &EATValidator{Nonce: nonce},
+			wantErr:   "cwt claim: eat_nonce: missing claim",
+			wantIs:    ErrCWTMissingClaim,
+		},
+		{
+			name:      "missing profile",
+			claims:    CWTClaims{},
+			validator: &EATValidator{Profile: "tag:example.com,2025:eat"},
+			wantErr:   "cwt claim: eat_profile: missing claim",
+			wantIs:    ErrCWTMissingClaim,
+		},
+		{
+			name: "expired",
+			claims: CWTClaims{
+				CWTClaimExpirationTime: time.Now().Add(-time.Hour).Unix(),
+			},
+			validator: &EATValidator{Claims: &CWTValidator{}},
+			wantErr:   "cwt claim: exp: token expired",
+			wantIs:    ErrCWTExpired,
+		},
+		{
+			name: "invalid submodule claims",
+			claims: CWTClaims{
+				CWTClaimSubmodules: map[any]any{
+					"secure element": map[any]any{CWTClaimUEID: []byte{0x01}},
+				},
+			},
+			validator: &EATValidator{},
+			wantErr:   "cwt claim: submods: secure element: cwt claim: ueid: require bstr of 7 to 33 bytes",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.validator.Validate(tt.claims)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("EATValidator.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantIs != nil && !errors.Is(err, tt.wantIs) {
+				t.Errorf("EATValidator.Validate() error = %v, want %v", err, tt.wantIs)
+			}
+		})
+	}
+}
+
+func TestCWTClaims_Submodules_algorithmName(t *testing.T) {
+	detached, err := encMode.Marshal(map[any]any{CWTClaimSoftwareName: "modem"})
+	if err != nil {
+		t.Fatalf("cbor.Marshal() error = %v", err)
+	}
+	digest := sha256.Sum256(detached)
+
+	// ["sha-256", digest] as defined by RFC 9711
+	data, err := encMode.Marshal(map[any]any{
+		CWTClaimSubmodules: map[any]any{
+			"modem": []any{"sha-256", digest[:]},
+			"gpu":   []any{"sha3-256", digest[:]},
+		},
+	})
+	if err != nil {
+		t.Fatalf("cbor.Marshal() error = %v", err)
+	}
+	var claims CWTClaims
+	if err := decMode.Unmarshal(data, &claims); err != nil {
+		t.Fatalf("cbor.Unmarshal() error = %v", err)
+	}
+	if err := (&EATValidator{}).Validate(claims); err != nil {
+		t.Errorf("EATValidator.Validate() error = %v", err)
+	}
+	submods, err := claims.Submodules()
+	if err != nil {
+		t.Fatalf("CWTClaims.Submodules() error = %v", err)
+	}
+	want := &EATDetachedDigest{
+		Algorithm:     AlgorithmSHA256,
+		AlgorithmName: "sha-256",
+		Digest:        digest[:],
+	}
+	if got := submods["modem"].Digest; !reflect.DeepEqual(got, want) {
+		t.Errorf("CWTClaims.Submodules() digest = %v, want %v", got, want)
+	}
+	if err := submods["modem"].Digest.Verify(detached); err != nil {
+		t.Errorf("EATDetachedDigest.Verify() error = %v", err)
+	}
+	if err := submods["gpu"].Digest.Verify(detached); err != ErrUnavailableHashFunc {
+		t.Errorf("EATDetachedDigest.Verify() error = %v, want %v", err, ErrUnavailableHashFunc)
+	}
+
+	// the name is encoded back
+	got := CWTClaims{}
+	if err := got.SetSubmodule("modem", submods["modem"]); err != nil {
+		t.Fatalf("CWTClaims.SetSubmodule() error = %v", err)
+	}
+	if err := got.SetSubmodule("gpu", submods["gpu"]); err != nil {
+		t.Fatalf("CWTClaims.SetSubmodule() error = %v", err)
+	}
+	gotData, err := encMode.Marshal(got)
+	if err != nil {
+		t.Fatalf("cbor.Marshal() error = %v", err)
+	}
+	if !bytes.Equal(gotData, data) {
+		t.Errorf("CWTClaims.SetSubmodule() = %x, want %x", gotData, data)
+	}
+}
+
+func TestCWTClaims_EATInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		claims  CWTClaims
+		get     func(c CWTClaims) error
+		wantErr string
+	}{
+		{
+			name:    "missing ueid",
+			claims:  CWTClaims{},
+			get:     func(c CWTClaims) error { _, err := c.UEID(); return err },
+			wantErr: "cwt claim: ueid: missing claim",
+		},
+		{
+			name:    "short nonce",
+			claims:  CWTClaims{CWTClaimNonce: []byte("short")},
+			get:     func(c CWTClaims) error { _, err := c.Nonce(); return err },
+			wantErr: "cwt claim: eat_nonce: require bstr of 8 to 64 bytes",
+		},
+		{
+			name:    "single nonce array",
+			claims:  CWTClaims{CWTClaimNonce: []any{[]byte("0123456789")}},
+			get:     func(c CWTClaims) error { _, err := c.Nonce(); return err },
+			wantErr: "cwt claim: eat_nonce: require at least 2 nonces in array",
+		},
+		{
+			name:    "invalid sueids label",
+			claims:  CWTClaims{CWTClaimSUEIDs: map[any]any{int64(1): []byte("0123456")}},
+			get:     func(c CWTClaims) error { _, err := c.SUEIDs(); return err },
+			wantErr: "cwt claim: sueids: require tstr label",
+		},
+		{
+			name:    "invalid oemid",
+			claims:  CWTClaims{CWTClaimOEMID: []byte{0x01, 0x02}},
+			get:     func(c CWTClaims) error { _, err := c.OEMID(); return err },
+			wantErr: "cwt claim: oemid: require bstr of 3 or 16 bytes / int",
+		},
+		{
+			name:    "invalid hwmodel",
+			claims:  CWTClaims{CWTClaimHardwareModel: []byte{}},
+			get:     func(c CWTClaims) error { _, err := c.HardwareModel(); return err },
+			wantErr: "cwt claim: hwmodel: require bstr of 1 to 32 bytes",
+		},
+		{
+			name:    "invalid swname",
+			claims:  CWTClaims{CWTClaimSoftwareName: []byte("firmware")},
+			get:     func(c CWTClaims) error { _, err := c.SoftwareName(); return err },
+			wantErr: "cwt claim: swname: require tstr",
+		},
+		{
+			name:    "invalid dbgstat",
+			claims:  CWTClaims{CWTClaimDebugStatus: int64(5)},
+			get:     func(c CWTClaims) error { _, err := c.DebugStatus(); return err },
+			wantErr: "cwt claim: dbgstat: require uint of 0 to 4",
+		},
+		{
+			name:    "invalid intuse",
+			claims:  CWTClaims{CWTClaimIntendedUse: int64(0)},
+			get:     func(c CWTClaims) error { _, err := c.IntendedUse(); return err },
+			wantErr: "cwt claim: intuse: require uint of 1 to 5",
+		},
+		{
+			name:    "location without longitude",
+			claims:  CWTClaims{CWTClaimLocation: map[any]any{int64(1): 48.8566}},
+			get:     func(c CWTClaims) error { _, err := c.Location(); return err },
+			wantErr: "cwt claim: location: require latitude and longitude",
+		},
+		{
+			name:    "invalid profile",
+			claims:  CWTClaims{CWTClaimProfile: int64(1)},
+			get:     func(c CWTClaims) error { _, err := c.Profile(); return err },
+			wantErr: "cwt claim: eat_profile: require URI tstr / OID bstr",
+		},
+		{
+			name:    "invalid detached digest",
+			claims:  CWTClaims{CWTClaimSubmodules: map[any]any{"modem": []any{"sha-256"}}},
+			get:     func(c CWTClaims) error { _, err := c.Submodules(); return err },
+			wantErr: "cwt claim: submods: modem: detached digest: require [int / tstr, bstr]",
+		},
+		{
+			name:   "multiple submodule types",
+			claims: CWTClaims{},
+			get: func(c CWTClaims) error {
+				return c.SetSubmodule("tee", &EATSubmodule{Token: []byte{}, JSONToken: "jwt"})
+			},
+			wantErr: "cwt claim: submods: tee: require exactly one submodule type",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.get(tt.claims); err == nil || err.Error() != tt.wantErr {
+				t.Errorf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
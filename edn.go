@@ -0,0 +1,1379 @@
+package cose
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// DiagnosticOptions specifies how COSE objects are rendered in the CBOR
+// Extended Diagnostic Notation (EDN).
+//
+// Header labels, CWT claim labels, key labels, algorithms, key types and
+// curves are always named in EDN comments, e.g. `/ alg / 1: -7 / ES256 /`.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc8610.html#appendix-G
+type DiagnosticOptions struct {
+	// Annotate adds EDN comments naming the fields of COSE structures, and
+	// showing the raw encoding of embedded protected headers.
+	Annotate bool
+
+	// Indent is the string used to indent nested arrays and maps.
+	// If Indent is empty, the output is rendered on a single line.
+	Indent string
+}
+
+// Diagnose returns the EDN representation of the COSE_Sign1_Tagged object
+// encoded from m.
+func (m *Sign1Message) Diagnose(opts *DiagnosticOptions) (string, error) {
+	data, err := m.MarshalCBOR()
+	if err != nil {
+		return "", err
+	}
+	return diagnose(data, opts, ednSign1Message)
+}
+
+// String returns the annotated EDN representation of m on a single line.
+func (m *Sign1Message) String() string {
+	return diagnoseString(m.Diagnose(&DiagnosticOptions{Annotate: true}))
+}
+
+// Diagnose returns the EDN representation of the COSE_Sign_Tagged object
+// encoded from m.
+//
+// # Experimental
+//
+// Notice: The COSE Sign API is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func (m *SignMessage) Diagnose(opts *DiagnosticOptions) (string, error) {
+	data, err := m.MarshalCBOR()
+	if err != nil {
+		return "", err
+	}
+	return diagnose(data, opts, ednSignMessage)
+}
+
+// String returns the annotated EDN representation of m on a single line.
+//
+// # Experimental
+//
+// Notice: The COSE Sign API is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func (m *SignMessage) String() string {
+	return diagnoseString(m.Diagnose(&DiagnosticOptions{Annotate: true}))
+}
+
+// Diagnose returns the EDN representation of the COSE_Countersignature
+// object encoded from s.
+//
+// # Experimental
+//
+// Notice: The COSE Countersignature API is EXPERIMENTAL and may be changed or
+// removed in a later release.
+func (s *Countersignature) Diagnose(opts *DiagnosticOptions) (string, error) {
+	data, err := s.MarshalCBOR()
+	if err != nil {
+		return "", err
+	}
+	return diagnose(data, opts, ednSignature)
+}
+
+// String returns the annotated EDN representation of s on a single line.
+//
+// # Experimental
+//
+// Notice: The COSE Countersignature API is EXPERIMENTAL and may be changed or
+// removed in a later release.
+func (s *Countersignature) String() string {
+	return diagnoseString(s.Diagnose(&DiagnosticOptions{Annotate: true}))
+}
+
+// Diagnose returns the EDN representation of the encoded protected and
+// unprotected headers as a CBOR sequence.
+func (h *Headers) Diagnose(opts *DiagnosticOptions) (string, error) {
+	protected, err := h.MarshalProtected()
+	if err != nil {
+		return "", err
+	}
+	unprotected, err := h.MarshalUnprotected()
+	if err != nil {
+		return "", err
+	}
+	w := newEDNWriter(opts)
+	w.comment("protected", ednContent(protected))
+	if _, err := ednProtectedHeader(w, protected); err != nil {
+		return "", err
+	}
+	w.separator(true)
+	w.comment("unprotected", nil)
+	if _, err := ednHeaderMap(w, unprotected); err != nil {
+		return "", err
+	}
+	return w.String(), nil
+}
+
+// String returns the annotated EDN representation of h on a single line.
+func (h *Headers) String() string {
+	return diagnoseString(h.Diagnose(&DiagnosticOptions{Annotate: true}))
+}
+
+// Diagnose returns the EDN representation of the COSE_Key object encoded from
+// k.
+func (k *Key) Diagnose(opts *DiagnosticOptions) (string, error) {
+	data, err := k.MarshalCBOR()
+	if err != nil {
+		return "", err
+	}
+	return diagnose(data, opts, ednKey)
+}
+
+// String returns the EDN representation of k on a single line.
+func (k *Key) String() string {
+	return diagnoseString(k.Diagnose(nil))
+}
+
+// diagnose renders a single CBOR data item using the given renderer.
+func diagnose(data []byte, opts *DiagnosticOptions, render ednRenderer) (string, error) {
+	w := newEDNWriter(opts)
+	rest, err := render(w, data)
+	if err != nil {
+		return "", err
+	}
+	if len(rest) > 0 {
+		return "", errors.New("cbor: extraneous data")
+	}
+	return w.String(), nil
+}
+
+// diagnoseString is used by the String methods, which cannot report errors.
+func diagnoseString(s string, err error) string {
+	if err != nil {
+		return "/ error: " + ednCommentText(err.Error()) + " /"
+	}
+	return s
+}
+
+// ednCommentText replaces the slashes of a comment text, which would end the
+// comment, e.g. in the algorithm name SHA-512/256.
+func ednCommentText(s string) string {
+	return strings.ReplaceAll(s, "/", "_")
+}
+
+// ednDiagMode renders CBOR data items which are not annotated.
+var ednDiagMode cbor.DiagMode
+
+func init() {
+	var err error
+	ednDiagMode, err = cbor.DiagOptions{
+		ByteStringEncoding:      cbor.ByteStringBase16Encoding,
+		FloatPrecisionIndicator: true,
+		MaxNestedLevels:         256,
+	}.DiagMode()
+	if err != nil {
+		panic(err)
+	}
+}
+
+// ednRenderer renders the first CBOR data item of data and returns the
+// remaining bytes.
+type ednRenderer func(w *ednWriter, data []byte) ([]byte, error)
+
+// ednWriter accumulates the EDN output.
+type ednWriter struct {
+	strings.Builder
+	annotate bool
+	indent   string
+	depth    int
+}
+
+func newEDNWriter(opts *DiagnosticOptions) *ednWriter {
+	w := &ednWriter{}
+	if opts != nil {
+		w.annotate = opts.Annotate
+		w.indent = opts.Indent
+	}
+	return w
+}
+
+// newline starts a new line at the current depth if indentation is enabled.
+func (w *ednWriter) newline() {
+	if w.indent == "" {
+		return
+	}
+	w.WriteByte('\n')
+	for i := 0; i < w.depth; i++ {
+		w.WriteString(w.indent)
+	}
+}
+
+// open writes the opening delimiter of a container.
+func (w *ednWriter) open(delim string, empty bool) {
+	w.WriteString(delim)
+	w.depth++
+	if !empty {
+		w.newline()
+	}
+}
+
+// close writes the closing delimiter of a container.
+func (w *ednWriter) close(delim string, empty bool) {
+	w.depth--
+	if !empty {
+		w.newline()
+	}
+	w.WriteString(delim)
+}
+
+// separator writes the separator between container items.
+func (w *ednWriter) separator(more bool) {
+	if !more {
+		return
+	}
+	w.WriteByte(',')
+	if w.indent == "" {
+		w.WriteByte(' ')
+	} else {
+		w.newline()
+	}
+}
+
+// comment writes an annotation naming a field if annotations are enabled.
+// The raw encoding is appended to the annotation if present.
+func (w *ednWriter) comment(field string, raw []byte) {
+	if !w.annotate {
+		return
+	}
+	w.WriteString("/ ")
+	w.WriteString(ednCommentText(field))
+	if len(raw) > 0 {
+		w.WriteString(" h'")
+		w.WriteString(hex.EncodeToString(raw))
+		w.WriteByte('\'')
+	}
+	w.WriteString(" / ")
+}
+
+// name writes a comment with the name of a label or a value.
+func (w *ednWriter) name(name string) {
+	if name == "" {
+		return
+	}
+	w.WriteString(" / ")
+	w.WriteString(ednCommentText(name))
+	w.WriteString(" /")
+}
+
+// ednHead decodes the head of the first CBOR data item.
+// indefinite is set for indefinite-length items, where arg is meaningless.
+func ednHead(data []byte) (major byte, arg uint64, indefinite bool, size int, err error) {
+	if len(data) == 0 {
+		return 0, 0, false, 0, errors.New("cbor: unexpected EOF")
+	}
+	major = data[0] >> 5
+	ai := data[0] & 0x1f
+	switch {
+	case ai < 24:
+		return major, uint64(ai), false, 1, nil
+	case ai <= 27:
+		size = 1 << (ai - 24)
+		if len(data) < 1+size {
+			return 0, 0, false, 0, errors.New("cbor: unexpected EOF")
+		}
+		for _, b := range data[1 : 1+size] {
+			arg = arg<<8 | uint64(b)
+		}
+		return major, arg, false, 1 + size, nil
+	case ai == 31:
+		return major, 0, true, 1, nil
+	}
+	return 0, 0, false, 0, fmt.Errorf("cbor: invalid additional information %d", ai)
+}
+
+// ednIndicator returns the encoding indicator of a head of the given size if
+// the argument is not encoded in the preferred serialization.
+func ednIndicator(arg uint64, size int) string {
+	preferred := 1
+	switch {
+	case arg < 24:
+	case arg <= math.MaxUint8:
+		preferred = 2
+	case arg <= math.MaxUint16:
+		preferred = 3
+	case arg <= math.MaxUint32:
+		preferred = 5
+	default:
+		preferred = 9
+	}
+	switch {
+	case size == preferred:
+		return ""
+	case size == 2:
+		return "_0"
+	case size == 3:
+		return "_1"
+	case size == 5:
+		return "_2"
+	default:
+		return "_3"
+	}
+}
+
+// ednBreak reports whether data starts with a break stop code.
+func ednBreak(data []byte) bool {
+	return len(data) > 0 && data[0] == 0xff
+}
+
+// ednAny renders any CBOR data item without annotation of the nested items.
+func ednAny(w *ednWriter, data []byte) ([]byte, error) {
+	major, arg, indefinite, size, err := ednHead(data)
+	if err != nil {
+		return nil, err
+	}
+	switch major {
+	case 0, 1:
+		if major == 0 {
+			fmt.Fprintf(w, "%d", arg)
+		} else if arg == math.MaxUint64 {
+			w.WriteString("-18446744073709551616")
+		} else {
+			fmt.Fprintf(w, "-%d", arg+1)
+		}
+		w.WriteString(ednIndicator(arg, size))
+		return data[size:], nil
+	case 4:
+		return ednArray(w, data, func(int) ednRenderer { return ednAny })
+	case 5:
+		return ednMap(w, data, func(any) (string, ednRenderer) { return "", ednAny })
+	case 6:
+		if indefinite {
+			return nil, errors.New("cbor: invalid tag")
+		}
+		fmt.Fprintf(w, "%d(", arg)
+		rest, err := ednAny(w, data[size:])
+		if err != nil {
+			return nil, err
+		}
+		w.WriteByte(')')
+		return rest, nil
+	}
+	s, rest, err := ednDiagMode.DiagnoseFirst(data)
+	if err != nil {
+		return nil, err
+	}
+	w.WriteString(s)
+	return rest, nil
+}
+
+// ednArray renders a CBOR array, rendering the i-th item with item(i).
+func ednArray(w *ednWriter, data []byte, item func(i int) ednRenderer) ([]byte, error) {
+	major, count, indefinite, size, err := ednHead(data)
+	if err != nil {
+		return nil, err
+	}
+	if major != 4 {
+		return nil, errors.New("cbor: require array type")
+	}
+	data = data[size:]
+	empty := !indefinite && count == 0 || indefinite && ednBreak(data)
+	if indefinite {
+		w.open("[_ ", empty)
+	} else if indicator := ednIndicator(count, size); indicator != "" {
+		w.open("["+indicator+" ", empty)
+	} else {
+		w.open("[", empty)
+	}
+	for i := 0; ; i++ {
+		if indefinite {
+			if len(data) == 0 {
+				return nil, errors.New("cbor: unexpected EOF")
+			}
+			if ednBreak(data) {
+				data = data[1:]
+				break
+			}
+		} else if uint64(i) >= count {
+			break
+		}
+		w.separator(i > 0)
+		if data, err = item(i)(w, data); err != nil {
+			return nil, err
+		}
+	}
+	w.close("]", empty)
+	return data, nil
+}
+
+// ednMap renders a CBOR map.
+// For each label, entry returns the name of the label and the renderer of
+// the value.
+func ednMap(w *ednWriter, data []byte, entry func(label any) (string, ednRenderer)) ([]byte, error) {
+	major, count, indefinite, size, err := ednHead(data)
+	if err != nil {
+		return nil, err
+	}
+	if major != 5 {
+		return nil, errors.New("cbor: require map type")
+	}
+	data = data[size:]
+	empty := !indefinite && count == 0 || indefinite && ednBreak(data)
+	if indefinite {
+		w.open("{_ ", empty)
+	} else if indicator := ednIndicator(count, size); indicator != "" {
+		w.open("{"+indicator+" ", empty)
+	} else {
+		w.open("{", empty)
+	}
+	for i := 0; ; i++ {
+		if indefinite {
+			if len(data) == 0 {
+				return nil, errors.New("cbor: unexpected EOF")
+			}
+			if ednBreak(data) {
+				data = data[1:]
+				break
+			}
+		} else if uint64(i) >= count {
+			break
+		}
+		w.separator(i > 0)
+
+		var label any
+		name, render := "", ednRenderer(ednAny)
+		if _, err := decMode.UnmarshalFirst(data, &label); err == nil {
+			if label, ok := normalizeLabel(label); ok {
+				name, render = entry(label)
+			}
+		}
+		if name != "" {
+			w.WriteString("/ ")
+			w.WriteString(name)
+			w.WriteString(" / ")
+		}
+		if data, err = ednAny(w, data); err != nil {
+			return nil, err
+		}
+		w.WriteString(": ")
+		if data, err = render(w, data); err != nil {
+			return nil, err
+		}
+	}
+	w.close("}", empty)
+	return data, nil
+}
+
+// ednSign1Message renders a COSE_Sign1_Tagged object.
+func ednSign1Message(w *ednWriter, data []byte) ([]byte, error) {
+	return ednTagged(w, data, CBORTagSign1Message, func(w *ednWriter, data []byte) ([]byte, error) {
+		return ednArray(w, data, func(i int) ednRenderer {
+			switch i {
+			case 0:
+				return ednField("protected", ednProtectedHeader)
+			case 1:
+				return ednField("unprotected", ednHeaderMap)
+			case 2:
+				return ednField("payload", ednAny)
+			case 3:
+				return ednField("signature", ednAny)
+			}
+			return ednAny
+		})
+	})
+}
+
+// ednSignMessage renders a COSE_Sign_Tagged object.
+func ednSignMessage(w *ednWriter, data []byte) ([]byte, error) {
+	return ednTagged(w, data, CBORTagSignMessage, func(w *ednWriter, data []byte) ([]byte, error) {
+		return ednArray(w, data, func(i int) ednRenderer {
+			switch i {
+			case 0:
+				return ednField("protected", ednProtectedHeader)
+			case 1:
+				return ednField("unprotected", ednHeaderMap)
+			case 2:
+				return ednField("payload", ednAny)
+			case 3:
+				return ednField("signatures", func(w *ednWriter, data []byte) ([]byte, error) {
+					return ednArray(w, data, func(int) ednRenderer { return ednSignature })
+				})
+			}
+			return ednAny
+		})
+	})
+}
+
+// ednSignature renders a COSE_Signature or a COSE_Countersignature object.
+func ednSignature(w *ednWriter, data []byte) ([]byte, error) {
+	return ednArray(w, data, func(i int) ednRenderer {
+		switch i {
+		case 0:
+			return ednField("protected", ednProtectedHeader)
+		case 1:
+			return ednField("unprotected", ednHeaderMap)
+		case 2:
+			return ednField("signature", ednAny)
+		}
+		return ednAny
+	})
+}
+
+// ednCountersignature renders the value of a counter signature header, which
+// is either a COSE_Countersignature object or a list of them.
+func ednCountersignature(w *ednWriter, data []byte) ([]byte, error) {
+	_, _, _, size, err := ednHead(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > size && data[size]>>5 == 2 { // first item is a bstr
+		return ednSignature(w, data)
+	}
+	return ednArray(w, data, func(int) ednRenderer { return ednSignature })
+}
+
+// ednTagged renders a CBOR tag with the expected tag number.
+func ednTagged(w *ednWriter, data []byte, number uint64, content ednRenderer) ([]byte, error) {
+	major, arg, indefinite, size, err := ednHead(data)
+	if err != nil {
+		return nil, err
+	}
+	if major != 6 || indefinite || arg != number {
+		return nil, fmt.Errorf("cbor: require tag %d", number)
+	}
+	fmt.Fprintf(w, "%d(", number)
+	rest, err := content(w, data[size:])
+	if err != nil {
+		return nil, err
+	}
+	w.WriteByte(')')
+	return rest, nil
+}
+
+// ednField returns a renderer annotating the rendered item with the field
+// name.
+func ednField(field string, render ednRenderer) ednRenderer {
+	return func(w *ednWriter, data []byte) ([]byte, error) {
+		var raw []byte
+		if field == "protected" {
+			raw = ednContent(data)
+		}
+		w.comment(field, raw)
+		return render(w, data)
+	}
+}
+
+// ednContent returns the content of the bstr at the start of data, or nil if
+// data does not start with a bstr.
+func ednContent(data []byte) []byte {
+	var content []byte
+	if len(data) == 0 || data[0]>>5 != 2 {
+		return nil
+	}
+	if _, err := decMode.UnmarshalFirst(data, &content); err != nil {
+		return nil
+	}
+	return content
+}
+
+// ednProtectedHeader renders a protected header, showing the encoded header
+// map as embedded CBOR.
+func ednProtectedHeader(w *ednWriter, data []byte) ([]byte, error) {
+	return ednEmbedded(w, data, ednHeaderMap)
+}
+
+// ednEmbedded renders a bstr containing an embedded CBOR data item.
+// Empty bstr and bstr not containing a single CBOR data item are rendered as
+// regular bstr.
+func ednEmbedded(w *ednWriter, data []byte, render ednRenderer) ([]byte, error) {
+	var content []byte
+	rest, err := decMode.UnmarshalFirst(data, &content)
+	if err != nil || data[0]>>5 != 2 || len(content) == 0 || decMode.Wellformed(content) != nil {
+		return ednAny(w, data)
+	}
+	embedded := newEDNWriter(nil)
+	embedded.annotate = w.annotate
+	embedded.indent = w.indent
+	embedded.depth = w.depth
+	if _, err := render(embedded, content); err != nil {
+		return ednAny(w, data)
+	}
+	w.WriteString("<< ")
+	w.WriteString(embedded.String())
+	w.WriteString(" >>")
+	return rest, nil
+}
+
+// ednHeaderMap renders a header map.
+func ednHeaderMap(w *ednWriter, data []byte) ([]byte, error) {
+	return ednMap(w, data, func(label any) (string, ednRenderer) {
+		name := headerLabelName(label)
+		switch label {
+		case HeaderLabelAlgorithm, HeaderLabelPayloadHashAlgorithm:
+			return name, ednAlgorithm
+		case HeaderLabelCounterSignature, HeaderLabelCounterSignatureV2:
+			return name, ednCountersignature
+		case HeaderLabelCWTClaims, HeaderLabelKCCS:
+			return name, ednCWTClaims
+		}
+		return name, ednAny
+	})
+}
+
+// ednCWTClaims renders a CWT Claims Set.
+func ednCWTClaims(w *ednWriter, data []byte) ([]byte, error) {
+	return ednMap(w, data, func(label any) (string, ednRenderer) {
+		return cwtClaimName(label), ednAny
+	})
+}
+
+// ednKey renders a COSE_Key object.
+// The key type must be rendered before the key type specific parameters to be
+// named.
+func ednKey(w *ednWriter, data []byte) ([]byte, error) {
+	var kty KeyType
+	return ednMap(w, data, func(label any) (string, ednRenderer) {
+		switch label {
+		case keyLabelKeyType:
+			return "kty", func(w *ednWriter, data []byte) ([]byte, error) {
+				var value int64
+				if _, err := decMode.UnmarshalFirst(data, &value); err == nil {
+					kty = KeyType(value)
+				}
+				return ednNamed(w, data, func(v int64) string {
+					return KeyType(v).String()
+				})
+			}
+		case keyLabelKeyID:
+			return "kid", ednAny
+		case keyLabelAlgorithm:
+			return "alg", ednAlgorithm
+		case keyLabelKeyOps:
+			return "key_ops", ednAny
+		case keyLabelBaseIV:
+			return "Base IV", ednAny
+		}
+		switch kty {
+		case KeyTypeOKP, KeyTypeEC2:
+			switch label {
+			case KeyLabelEC2Curve:
+				return "crv", func(w *ednWriter, data []byte) ([]byte, error) {
+					return ednNamed(w, data, func(v int64) string {
+						return Curve(v).String()
+					})
+				}
+			case KeyLabelEC2X:
+				return "x", ednAny
+			case KeyLabelEC2Y:
+				if kty == KeyTypeEC2 {
+					return "y", ednAny
+				}
+			case KeyLabelEC2D:
+				return "d", ednAny
+			}
+		case KeyTypeSymmetric:
+			if label == KeyLabelSymmetricK {
+				return "k", ednAny
+			}
+		}
+		return "", ednAny
+	})
+}
+
+// ednAlgorithm renders an algorithm value followed by its name.
+func ednAlgorithm(w *ednWriter, data []byte) ([]byte, error) {
+	return ednNamed(w, data, func(v int64) string {
+		name := Algorithm(v).String()
+		if strings.HasPrefix(name, "Algorithm(") {
+			return ""
+		}
+		return name
+	})
+}
+
+// ednNamed renders an integer value followed by its name.
+func ednNamed(w *ednWriter, data []byte, name func(int64) string) ([]byte, error) {
+	rest, err := ednAny(w, data)
+	if err != nil {
+		return nil, err
+	}
+	var value any
+	if _, err := decMode.UnmarshalFirst(data, &value); err == nil {
+		if v, ok := value.(int64); ok {
+			w.name(name(v))
+		}
+	}
+	return rest, nil
+}
+
+// ParseDiagnostic parses a CBOR data item, or a CBOR sequence of comma
+// separated data items, written in the Extended Diagnostic Notation (EDN) and
+// returns its CBOR encoding.
+//
+// The output of the Diagnose methods can be parsed back by ParseDiagnostic.
+// Besides integers, floating-point numbers, text strings, arrays, maps, tags
+// and simple values, the following EDN extensions are supported:
+//   - h'..' base16 and b64'..' base64 / base64url byte strings;
+//   - '..' byte strings containing UTF-8 text;
+//   - << .. >> byte strings containing embedded CBOR sequences;
+//   - encoding indicators: _ for indefinite-length arrays, maps and strings,
+//     and _0 to _3 for the argument size of integers, floating-point numbers,
+//     arrays and maps;
+//   - _ following an empty text or byte string for an empty indefinite-length
+//     string;
+//   - / .. / block comments and # line comments.
+//
+// Map entries are encoded in the order they appear, and duplicated labels are
+// preserved so that non-conforming objects can be written as test fixtures.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc8610.html#appendix-G
+func ParseDiagnostic(edn string) ([]byte, error) {
+	p := &ednParser{input: edn}
+	out, err := p.sequence(nil)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.input) {
+		return nil, p.errorf("unexpected %q", p.input[p.pos])
+	}
+	return out, nil
+}
+
+// ednParser parses EDN text.
+type ednParser struct {
+	input string
+	pos   int
+	depth int
+}
+
+// ednMaxNestedLevels limits the nesting of arrays, maps, tags and embedded
+// CBOR.
+const ednMaxNestedLevels = 256
+
+func (p *ednParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("edn: offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+// skip skips whitespace and comments.
+func (p *ednParser) skip() error {
+	for p.pos < len(p.input) {
+		switch p.input[p.pos] {
+		case ' ', '\t', '\r', '\n':
+			p.pos++
+		case '/':
+			end := strings.IndexByte(p.input[p.pos+1:], '/')
+			if end < 0 {
+				return p.errorf("unterminated comment")
+			}
+			p.pos += end + 2
+		case '#':
+			end := strings.IndexByte(p.input[p.pos:], '\n')
+			if end < 0 {
+				p.pos = len(p.input)
+			} else {
+				p.pos += end + 1
+			}
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// peek returns the next significant byte, or 0 at the end of the input.
+func (p *ednParser) peek() (byte, error) {
+	if err := p.skip(); err != nil {
+		return 0, err
+	}
+	if p.pos >= len(p.input) {
+		return 0, nil
+	}
+	return p.input[p.pos], nil
+}
+
+// consume consumes s if it is the next significant token.
+func (p *ednParser) consume(s string) (bool, error) {
+	if err := p.skip(); err != nil {
+		return false, err
+	}
+	if strings.HasPrefix(p.input[p.pos:], s) {
+		p.pos += len(s)
+		return true, nil
+	}
+	return false, nil
+}
+
+// expect consumes s or reports an error.
+func (p *ednParser) expect(s string) error {
+	ok, err := p.consume(s)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return p.errorf("expected %q", s)
+	}
+	return nil
+}
+
+// sequence parses comma separated items up to the closing delimiter, which is
+// not consumed.
+func (p *ednParser) sequence(out []byte) ([]byte, error) {
+	for n := 0; ; n++ {
+		c, err := p.peek()
+		if err != nil {
+			return nil, err
+		}
+		if c == 0 || c == ']' || c == '}' || c == ')' || c == '>' {
+			if n > 0 {
+				return nil, p.errorf("unexpected trailing comma")
+			}
+			break
+		}
+		if out, err = p.item(out); err != nil {
+			return nil, err
+		}
+		more, err := p.consume(",")
+		if err != nil {
+			return nil, err
+		}
+		if !more {
+			break
+		}
+	}
+	return out, nil
+}
+
+// indicator parses an optional encoding indicator following a token.
+// It returns -1 for none, 31 for indefinite length, and 24 to 27 for _0 to
+// _3.
+func (p *ednParser) indicator() int {
+	if p.pos >= len(p.input) || p.input[p.pos] != '_' {
+		return -1
+	}
+	p.pos++
+	if p.pos < len(p.input) && p.input[p.pos] >= '0' && p.input[p.pos] <= '3' {
+		p.pos++
+		return 24 + int(p.input[p.pos-1]-'0')
+	}
+	return 31
+}
+
+// item parses a single data item and appends its encoding to out.
+func (p *ednParser) item(out []byte) ([]byte, error) {
+	c, err := p.peek()
+	if err != nil {
+		return nil, err
+	}
+	if c == 0 {
+		return nil, p.errorf("unexpected end of input")
+	}
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > ednMaxNestedLevels {
+		return nil, p.errorf("exceeded max nested level %d", ednMaxNestedLevels)
+	}
+
+	rest := p.input[p.pos:]
+	switch {
+	case strings.HasPrefix(rest, "''_"):
+		p.pos += 3
+		return append(out, 0x5f, 0xff), nil
+	case strings.HasPrefix(rest, `""_`):
+		p.pos += 3
+		return append(out, 0x7f, 0xff), nil
+	case c == '[':
+		p.pos++
+		return p.container(out, 4, "]")
+	case c == '{':
+		p.pos++
+		return p.container(out, 5, "}")
+	case c == '"':
+		s, err := p.text()
+		if err != nil {
+			return nil, err
+		}
+		return append(ednAppendHead(out, 3, uint64(len(s)), -1), s...), nil
+	case c == '\'':
+		s, err := p.text()
+		if err != nil {
+			return nil, err
+		}
+		return append(ednAppendHead(out, 2, uint64(len(s)), -1), s...), nil
+	case strings.HasPrefix(rest, "h'"):
+		p.pos += 2
+		b, err := p.encodedBytes(decodeBase16, true)
+		if err != nil {
+			return nil, err
+		}
+		return append(ednAppendHead(out, 2, uint64(len(b)), -1), b...), nil
+	case strings.HasPrefix(rest, "b64'"):
+		p.pos += 4
+		b, err := p.encodedBytes(decodeBase64, false)
+		if err != nil {
+			return nil, err
+		}
+		return append(ednAppendHead(out, 2, uint64(len(b)), -1), b...), nil
+	case strings.HasPrefix(rest, "<<"):
+		p.pos += 2
+		embedded, err := p.sequence(nil)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(">>"); err != nil {
+			return nil, err
+		}
+		return append(ednAppendHead(out, 2, uint64(len(embedded)), -1), embedded...), nil
+	case strings.HasPrefix(rest, "(_"):
+		p.pos += 2
+		return p.indefiniteString(out)
+	case c >= '0' && c <= '9':
+		if n := strings.IndexFunc(rest, func(r rune) bool { return r < '0' || r > '9' }); n > 0 && rest[n] == '(' {
+			return p.tag(out)
+		}
+		return p.number(out)
+	case c == '-':
+		return p.number(out)
+	}
+
+	word := p.word()
+	switch word {
+	case "false":
+		return append(out, 0xf4), nil
+	case "true":
+		return append(out, 0xf5), nil
+	case "null":
+		return append(out, 0xf6), nil
+	case "undefined":
+		return append(out, 0xf7), nil
+	case "NaN", "Infinity":
+		p.pos -= len(word)
+		return p.number(out)
+	case "simple":
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		value, err := p.uint()
+		if err != nil {
+			return nil, err
+		}
+		if value >= 24 && value < 32 || value > 255 {
+			return nil, p.errorf("invalid simple value %d", value)
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return ednAppendHead(out, 7, value, -1), nil
+	case "":
+		return nil, p.errorf("unexpected %q", c)
+	}
+	return nil, p.errorf("unknown token %q", word)
+}
+
+// tag parses a tag number followed by the tag content in parentheses.
+func (p *ednParser) tag(out []byte) ([]byte, error) {
+	number, err := p.uint()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	out = ednAppendHead(out, 6, number, -1)
+	if out, err = p.item(out); err != nil {
+		return nil, err
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// word consumes an identifier.
+func (p *ednParser) word() string {
+	start := p.pos
+	for p.pos < len(p.input) {
+		c := p.input[p.pos]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' {
+			p.pos++
+			continue
+		}
+		break
+	}
+	return p.input[start:p.pos]
+}
+
+// uint parses an unsigned decimal integer.
+func (p *ednParser) uint() (uint64, error) {
+	if err := p.skip(); err != nil {
+		return 0, err
+	}
+	start := p.pos
+	for p.pos < len(p.input) && p.input[p.pos] >= '0' && p.input[p.pos] <= '9' {
+		p.pos++
+	}
+	value, err := strconv.ParseUint(p.input[start:p.pos], 10, 64)
+	if err != nil {
+		return 0, p.errorf("invalid unsigned integer %q", p.input[start:p.pos])
+	}
+	return value, nil
+}
+
+// container parses the items of an array or a map.
+func (p *ednParser) container(out []byte, major byte, closing string) ([]byte, error) {
+	ai := p.indicator()
+	var items []byte
+	var count uint64
+	for {
+		c, err := p.peek()
+		if err != nil {
+			return nil, err
+		}
+		if c == closing[0] {
+			p.pos++
+			break
+		}
+		if count > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		if items, err = p.item(items); err != nil {
+			return nil, err
+		}
+		if major == 5 {
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			if items, err = p.item(items); err != nil {
+				return nil, err
+			}
+		}
+		count++
+	}
+	if err := p.checkIndicator(count, ai); err != nil {
+		return nil, err
+	}
+	out = ednAppendHead(out, major, count, ai)
+	out = append(out, items...)
+	if ai == 31 {
+		out = append(out, 0xff)
+	}
+	return out, nil
+}
+
+// indefiniteString parses the chunks of an indefinite-length string.
+func (p *ednParser) indefiniteString(out []byte) ([]byte, error) {
+	start := len(out)
+	out = append(out, 0)
+	chunks, err := p.sequence(nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	var major byte = 2
+	for rest := chunks; len(rest) > 0; {
+		m, arg, indefinite, size, err := ednHead(rest)
+		if err != nil || indefinite || m != 2 && m != 3 {
+			return nil, p.errorf("invalid indefinite-length string chunk")
+		}
+		if len(rest) == len(chunks) {
+			major = m
+		} else if m != major {
+			return nil, p.errorf("mismatched indefinite-length string chunk type")
+		}
+		rest = rest[size+int(arg):]
+	}
+	out[start] = major<<5 | 31
+	out = append(out, chunks...)
+	return append(out, 0xff), nil
+}
+
+// text parses a quoted string using JSON escapes.
+func (p *ednParser) text() (string, error) {
+	quote := p.input[p.pos]
+	p.pos++
+	var sb strings.Builder
+	for {
+		if p.pos >= len(p.input) {
+			return "", p.errorf("unterminated string")
+		}
+		c := p.input[p.pos]
+		p.pos++
+		switch c {
+		case quote:
+			return sb.String(), nil
+		case '\\':
+			if p.pos >= len(p.input) {
+				return "", p.errorf("unterminated string")
+			}
+			e := p.input[p.pos]
+			p.pos++
+			switch e {
+			case '"', '\'', '\\', '/':
+				sb.WriteByte(e)
+			case 'b':
+				sb.WriteByte('\b')
+			case 'f':
+				sb.WriteByte('\f')
+			case 'n':
+				sb.WriteByte('\n')
+			case 'r':
+				sb.WriteByte('\r')
+			case 't':
+				sb.WriteByte('\t')
+			case 'u':
+				r, err := p.unicodeEscape()
+				if err != nil {
+					return "", err
+				}
+				if utf16.IsSurrogate(r) {
+					if !strings.HasPrefix(p.input[p.pos:], "\\u") {
+						return "", p.errorf("invalid surrogate pair")
+					}
+					p.pos += 2
+					r2, err := p.unicodeEscape()
+					if err != nil {
+						return "", err
+					}
+					if r = utf16.DecodeRune(r, r2); r == utf8.RuneError {
+						return "", p.errorf("invalid surrogate pair")
+					}
+				}
+				sb.WriteRune(r)
+			default:
+				return "", p.errorf("invalid escape %q", e)
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+}
+
+// unicodeEscape parses the 4 hex digits of a \u escape.
+func (p *ednParser) unicodeEscape() (rune, error) {
+	if p.pos+4 > len(p.input) {
+		return 0, p.errorf("invalid unicode escape")
+	}
+	v, err := strconv.ParseUint(p.input[p.pos:p.pos+4], 16, 16)
+	if err != nil {
+		return 0, p.errorf("invalid unicode escape")
+	}
+	p.pos += 4
+	return rune(v), nil
+}
+
+// encodedBytes parses the content of an encoded byte string up to the closing
+// quote, ignoring whitespace.
+// Comments are also ignored in base16 byte strings as '/' is not part of the
+// alphabet.
+func (p *ednParser) encodedBytes(decode func(string) ([]byte, error), comments bool) ([]byte, error) {
+	var sb strings.Builder
+	for {
+		if comments {
+			if err := p.skip(); err != nil {
+				return nil, err
+			}
+		}
+		if p.pos >= len(p.input) {
+			return nil, p.errorf("unterminated byte string")
+		}
+		c := p.input[p.pos]
+		p.pos++
+		switch c {
+		case '\'':
+			b, err := decode(sb.String())
+			if err != nil {
+				return nil, p.errorf("invalid byte string: %v", err)
+			}
+			return b, nil
+		case ' ', '\t', '\r', '\n':
+		default:
+			sb.WriteByte(c)
+		}
+	}
+}
+
+// decodeBase16 decodes case-insensitive base16 text.
+func decodeBase16(s string) ([]byte, error) {
+	return hex.DecodeString(s)
+}
+
+// decodeBase64 decodes base64 or base64url text with optional padding.
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.TrimRight(s, "=")
+	if strings.ContainsAny(s, "-_") {
+		return base64.RawURLEncoding.DecodeString(s)
+	}
+	return base64.RawStdEncoding.DecodeString(s)
+}
+
+// number parses an integer or a floating-point number.
+func (p *ednParser) number(out []byte) ([]byte, error) {
+	start := p.pos
+	negative := p.input[p.pos] == '-'
+	if negative {
+		p.pos++
+	}
+	rest := p.input[p.pos:]
+	switch {
+	case strings.HasPrefix(rest, "Infinity"):
+		p.pos += len("Infinity")
+		if negative {
+			return p.float(out, math.Inf(-1))
+		}
+		return p.float(out, math.Inf(1))
+	case strings.HasPrefix(rest, "NaN") && !negative:
+		p.pos += len("NaN")
+		return p.float(out, math.NaN())
+	}
+
+	base := 10
+	switch {
+	case strings.HasPrefix(rest, "0x"):
+		base = 16
+	case strings.HasPrefix(rest, "0o"):
+		base = 8
+	case strings.HasPrefix(rest, "0b"):
+		base = 2
+	}
+	if base != 10 {
+		p.pos += 2
+	}
+	digits := p.pos
+	isFloat := false
+scan:
+	for ; p.pos < len(p.input); p.pos++ {
+		c := p.input[p.pos]
+		switch {
+		case c >= '0' && c <= '9', base == 16 && (c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'):
+		case base == 10 && (c == '.' || c == 'e' || c == 'E'):
+			isFloat = true
+		case base == 10 && (c == '+' || c == '-') && (p.input[p.pos-1] == 'e' || p.input[p.pos-1] == 'E'):
+		default:
+			break scan
+		}
+	}
+	if isFloat {
+		f, err := strconv.ParseFloat(p.input[start:p.pos], 64)
+		if err != nil {
+			return nil, p.errorf("invalid floating-point number %q", p.input[start:p.pos])
+		}
+		return p.float(out, f)
+	}
+	n, ok := new(big.Int).SetString(p.input[digits:p.pos], base)
+	if !ok {
+		return nil, p.errorf("invalid integer %q", p.input[start:p.pos])
+	}
+	major := byte(0)
+	if negative {
+		// CBOR negative integers encode -1 - n
+		major = 1
+		n.Sub(n, big.NewInt(1))
+		if n.Sign() < 0 { // -0
+			major = 0
+			n.SetInt64(0)
+		}
+	}
+	if !n.IsUint64() {
+		// bignum
+		if p.indicator() != -1 {
+			return nil, p.errorf("encoding indicator not allowed for bignum")
+		}
+		out = ednAppendHead(out, 6, 2+uint64(major), -1)
+		b := n.Bytes()
+		return append(ednAppendHead(out, 2, uint64(len(b)), -1), b...), nil
+	}
+	ai := p.indicator()
+	if ai == 31 {
+		return nil, p.errorf("indefinite length not allowed for integer")
+	}
+	if err := p.checkIndicator(n.Uint64(), ai); err != nil {
+		return nil, err
+	}
+	return ednAppendHead(out, major, n.Uint64(), ai), nil
+}
+
+// checkIndicator checks that arg fits in the argument size of the encoding
+// indicator.
+func (p *ednParser) checkIndicator(arg uint64, ai int) error {
+	if ai >= 24 && ai <= 26 && arg >= 1<<(8<<(ai-24)) {
+		return p.errorf("value %d exceeds encoding indicator _%d", arg, ai-24)
+	}
+	return nil
+}
+
+// float encodes a floating-point number using the preferred serialization,
+// unless an encoding indicator is present.
+func (p *ednParser) float(out []byte, f float64) ([]byte, error) {
+	var data []byte
+	var err error
+	switch p.indicator() {
+	case -1:
+		data, err = ednFloatEncMode.Marshal(f)
+	case 25:
+		// the preferred serialization is half-precision if lossless
+		data, err = ednFloatEncMode.Marshal(f)
+		if err == nil && data[0] != 0xf9 {
+			err = p.errorf("value not representable as half-precision float")
+		}
+	case 26:
+		if f32 := float32(f); float64(f32) == f || math.IsNaN(f) {
+			data = binary.BigEndian.AppendUint32([]byte{0xfa}, math.Float32bits(f32))
+		} else {
+			err = p.errorf("value not representable as single-precision float")
+		}
+	case 27:
+		data = binary.BigEndian.AppendUint64([]byte{0xfb}, math.Float64bits(f))
+	default:
+		err = p.errorf("invalid encoding indicator for floating-point number")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append(out, data...), nil
+}
+
+// ednFloatEncMode encodes floating-point numbers using the preferred
+// serialization.
+var ednFloatEncMode cbor.EncMode
+
+func init() {
+	var err error
+	ednFloatEncMode, err = cbor.EncOptions{
+		ShortestFloat: cbor.ShortestFloat16,
+		NaNConvert:    cbor.NaNConvert7e00,
+		InfConvert:    cbor.InfConvertFloat16,
+	}.EncMode()
+	if err != nil {
+		panic(err)
+	}
+}
+
+// ednAppendHead appends the head of a CBOR data item to out.
+// ai is -1 for the preferred serialization, 31 for indefinite length, or the
+// additional information 24 to 27 for the argument size.
+func ednAppendHead(out []byte, major byte, arg uint64, ai int) []byte {
+	switch {
+	case ai == 31:
+		return append(out, major<<5|31)
+	case ai == -1 && arg < 24:
+		return append(out, major<<5|byte(arg))
+	case ai == -1 && arg <= math.MaxUint8, ai == 24:
+		return append(out, major<<5|24, byte(arg))
+	case ai == -1 && arg <= math.MaxUint16, ai == 25:
+		return binary.BigEndian.AppendUint16(append(out, major<<5|25), uint16(arg))
+	case ai == -1 && arg <= math.MaxUint32, ai == 26:
+		return binary.BigEndian.AppendUint32(append(out, major<<5|26), uint32(arg))
+	default:
+		return binary.BigEndian.AppendUint64(append(out, major<<5|27), arg)
+	}
+}
@@ -0,0 +1,570 @@
+package cose
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSign1Message_Diagnose(t *testing.T) {
+	msg := &Sign1Message{
+		Headers: Headers{
+			Protected: ProtectedHeader{
+				HeaderLabelAlgorithm: AlgorithmES256,
+				HeaderLabelCWTClaims: CWTClaims{
+					CWTClaimIssuer: "issuer.example",
+				},
+			},
+			Unprotected: UnprotectedHeader{
+				HeaderLabelKeyID: []byte("11"),
+				"foo":            []any{1, "bar"},
+			},
+		},
+		Payload:   []byte("hello"),
+		Signature: []byte{0x01, 0x02},
+	}
+	tests := []struct {
+		name string
+		opts *DiagnosticOptions
+		want string
+	}{
+		{
+			name: "default",
+			opts: nil,
+			want: `18([<< {/ alg / 1: -7 / ES256 /, / CWT Claims / 15: {/ iss / 1: "issuer.example"}} >>, {/ kid / 4: h'3131', "foo": [1, "bar"]}, h'68656c6c6f', h'0102'])`,
+		},
+		{
+			name: "annotated",
+			opts: &DiagnosticOptions{Annotate: true},
+			want: `18([/ protected h'a201260fa1016e6973737565722e6578616d706c65' / << {/ alg / 1: -7 / ES256 /, / CWT Claims / 15: {/ iss / 1: "issuer.example"}} >>, / unprotected / {/ kid / 4: h'3131', "foo": [1, "bar"]}, / payload / h'68656c6c6f', / signature / h'0102'])`,
+		},
+		{
+			name: "indented",
+			opts: &DiagnosticOptions{Indent: "  "},
+			want: `18([
+  << {
+    / alg / 1: -7 / ES256 /,
+    / CWT Claims / 15: {
+      / iss / 1: "issuer.example"
+    }
+  } >>,
+  {
+    / kid / 4: h'3131',
+    "foo": [
+      1,
+      "bar"
+    ]
+  },
+  h'68656c6c6f',
+  h'0102'
+])`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := msg.Diagnose(tt.opts)
+			if err != nil {
+				t.Fatalf("Sign1Message.Diagnose() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Sign1Message.Diagnose() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("String", func(t *testing.T) {
+		want := tests[1].want
+		if got := msg.String(); got != want {
+			t.Errorf("Sign1Message.String() = %s, want %s", got, want)
+		}
+	})
+
+	t.Run("String with error", func(t *testing.T) {
+		want := "/ error: empty signature /"
+		if got := (&Sign1Message{}).String(); got != want {
+			t.Errorf("Sign1Message.String() = %s, want %s", got, want)
+		}
+	})
+}
+
+func TestSignMessage_Diagnose(t *testing.T) {
+	msg := &SignMessage{
+		Headers: Headers{
+			Protected:   ProtectedHeader{},
+			Unprotected: UnprotectedHeader{},
+		},
+		Payload: []byte("hello"),
+		Signatures: []*Signature{
+			{
+				Headers: Headers{
+					Protected: ProtectedHeader{
+						HeaderLabelAlgorithm: AlgorithmEdDSA,
+					},
+					Unprotected: UnprotectedHeader{
+						HeaderLabelCounterSignature: &Countersignature{
+							Headers: Headers{
+								Protected: ProtectedHeader{
+									HeaderLabelAlgorithm: AlgorithmES256,
+								},
+							},
+							Signature: []byte{0x02},
+						},
+					},
+				},
+				Signature: []byte{0x01},
+			},
+		},
+	}
+	want := `98([/ protected / h'', / unprotected / {}, / payload / h'68656c6c6f', / signatures / [[/ protected h'a10127' / << {/ alg / 1: -8 / EdDSA /} >>, / unprotected / {/ counter signature / 7: [/ protected h'a10126' / << {/ alg / 1: -7 / ES256 /} >>, / unprotected / {}, / signature / h'02']}, / signature / h'01']]])`
+	got, err := msg.Diagnose(&DiagnosticOptions{Annotate: true})
+	if err != nil {
+		t.Fatalf("SignMessage.Diagnose() error = %v", err)
+	}
+	if got != want {
+		t.Errorf("SignMessage.Diagnose() = %s, want %s", got, want)
+	}
+}
+
+func TestCountersignature_String(t *testing.T) {
+	sig := &Countersignature{
+		Headers: Headers{
+			Protected: ProtectedHeader{
+				HeaderLabelAlgorithm: Algorithm(-65535),
+			},
+			Unprotected: UnprotectedHeader{
+				HeaderLabelKeyID: []byte{0x01},
+			},
+		},
+		Signature: []byte{0x02},
+	}
+	want := `[/ protected h'a10139fffe' / << {/ alg / 1: -65535} >>, / unprotected / {/ kid / 4: h'01'}, / signature / h'02']`
+	if got := sig.String(); got != want {
+		t.Errorf("Countersignature.String() = %s, want %s", got, want)
+	}
+}
+
+func TestHeaders_Diagnose(t *testing.T) {
+	tests := []struct {
+		name    string
+		h       Headers
+		want    string
+		wantErr string
+	}{
+		{
+			name: "headers",
+			h: Headers{
+				Protected: ProtectedHeader{
+					HeaderLabelType: "application/example",
+				},
+				Unprotected: UnprotectedHeader{
+					HeaderLabelContentType: uint(60),
+				},
+			},
+			want: `<< {/ typ / 16: "application/example"} >>, {/ content type / 3: 60}`,
+		},
+		{
+			name: "raw headers",
+			h: Headers{
+				RawProtected:   []byte{0x43, 0xa1, 0x01, 0x26},
+				RawUnprotected: []byte{0xa1, 0x04, 0x40},
+			},
+			want: `<< {/ alg / 1: -7 / ES256 /} >>, {/ kid / 4: h''}`,
+		},
+		{
+			name: "invalid protected header",
+			h: Headers{
+				Protected: ProtectedHeader{
+					HeaderLabelKeyID: "foo",
+				},
+			},
+			wantErr: "protected header: header parameter: kid: require bstr type",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.h.Diagnose(nil)
+			if err != nil {
+				if err.Error() != tt.wantErr {
+					t.Errorf("Headers.Diagnose() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			} else if tt.wantErr != "" {
+				t.Errorf("Headers.Diagnose() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Headers.Diagnose() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKey_String(t *testing.T) {
+	tests := []struct {
+		name string
+		key  *Key
+		want string
+	}{
+		{
+			name: "OKP",
+			key: &Key{
+				Type:      KeyTypeOKP,
+				Algorithm: AlgorithmEdDSA,
+				Params: map[any]any{
+					KeyLabelOKPCurve: CurveEd25519,
+					KeyLabelOKPX:     []byte{0x01},
+				},
+			},
+			want: `{/ kty / 1: 1 / OKP /, / alg / 3: -8 / EdDSA /, / crv / -1: 6 / Ed25519 /, / x / -2: h'01'}`,
+		},
+		{
+			name: "Symmetric",
+			key: &Key{
+				Type: KeyTypeSymmetric,
+				ID:   []byte{0x02},
+				Params: map[any]any{
+					KeyLabelSymmetricK: []byte{0x03},
+					"custom":           true,
+				},
+			},
+			want: `{/ kty / 1: 4 / Symmetric /, / kid / 2: h'02', / k / -1: h'03', "custom": true}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.key.String(); got != tt.want {
+				t.Errorf("Key.String() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_ednAny(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "indefinite-length array",
+			data: []byte{0x9f, 0x01, 0x02, 0xff},
+			want: "[_ 1, 2]",
+		},
+		{
+			name: "indefinite-length map",
+			data: []byte{0xbf, 0x01, 0x02, 0xff},
+			want: "{_ 1: 2}",
+		},
+		{
+			name: "empty indefinite-length array",
+			data: []byte{0x9f, 0xff},
+			want: "[_ ]",
+		},
+		{
+			name: "empty indefinite-length strings",
+			data: []byte{0x82, 0x5f, 0xff, 0x7f, 0xff},
+			want: `[''_, ""_]`,
+		},
+		{
+			name: "tag",
+			data: []byte{0xc1, 0x1a, 0x51, 0x4b, 0x67, 0xb0},
+			want: "1(1363896240)",
+		},
+		{
+			name:    "truncated array",
+			data:    []byte{0x82, 0x01},
+			wantErr: true,
+		},
+		{
+			name:    "truncated indefinite-length array",
+			data:    []byte{0x9f, 0x01},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := diagnose(tt.data, nil, ednAny)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("diagnose() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("diagnose() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDiagnostic(t *testing.T) {
+	tests := []struct {
+		name    string
+		edn     string
+		want    []byte
+		wantErr string
+	}{
+		{
+			name: "integers",
+			edn:  "[0, 23, 24, -1, -25, 65536, 18446744073709551615, -18446744073709551616]",
+			want: []byte{
+				0x88, 0x00, 0x17, 0x18, 0x18, 0x20, 0x38, 0x18,
+				0x1a, 0x00, 0x01, 0x00, 0x00,
+				0x1b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+				0x3b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+			},
+		},
+		{
+			name: "bignums",
+			edn:  "[18446744073709551616, -18446744073709551617]",
+			want: []byte{
+				0x82,
+				0xc2, 0x49, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+				0xc3, 0x49, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			},
+		},
+		{
+			name: "non-decimal integers",
+			edn:  "[0x1f, 0o17, 0b101]",
+			want: []byte{0x83, 0x18, 0x1f, 0x0f, 0x05},
+		},
+		{
+			name: "floating-point numbers",
+			edn:  "[1.5, 100000.0, 1.1, NaN, Infinity, -Infinity, 1.5_2, 1.5_3]",
+			want: []byte{
+				0x88,
+				0xf9, 0x3e, 0x00,
+				0xfa, 0x47, 0xc3, 0x50, 0x00,
+				0xfb, 0x3f, 0xf1, 0x99, 0x99, 0x99, 0x99, 0x99, 0x9a,
+				0xf9, 0x7e, 0x00,
+				0xf9, 0x7c, 0x00,
+				0xf9, 0xfc, 0x00,
+				0xfa, 0x3f, 0xc0, 0x00, 0x00,
+				0xfb, 0x3f, 0xf8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			},
+		},
+		{
+			name: "encoding indicators",
+			edn:  "[_0 1_0, -1_1, {_2 }, [_ ]]",
+			want: []byte{
+				0x98, 0x04,
+				0x18, 0x01,
+				0x39, 0x00, 0x00,
+				0xba, 0x00, 0x00, 0x00, 0x00,
+				0x9f, 0xff,
+			},
+		},
+		{
+			name: "strings",
+			edn:  `["aé\n", 'b\'', h'01 02 /comment/ 0A', b64'AQ ID', b64'-_8=', b64'+/8', (_ h'01', h'02'), (_ "a", "b")]`,
+			want: []byte{
+				0x88,
+				0x64, 0x61, 0xc3, 0xa9, 0x0a,
+				0x42, 0x62, 0x27,
+				0x43, 0x01, 0x02, 0x0a,
+				0x43, 0x01, 0x02, 0x03,
+				0x42, 0xfb, 0xff,
+				0x42, 0xfb, 0xff,
+				0x5f, 0x41, 0x01, 0x41, 0x02, 0xff,
+				0x7f, 0x61, 0x61, 0x61, 0x62, 0xff,
+			},
+		},
+		{
+			name: "embedded CBOR",
+			edn:  "[<< {1: -7} >>, <<>>, <<1, 2>>]",
+			want: []byte{0x83, 0x43, 0xa1, 0x01, 0x26, 0x40, 0x42, 0x01, 0x02},
+		},
+		{
+			name: "tags, maps and simple values",
+			edn:  `18({_ "a": true, 1: null, 2: undefined, 3: simple(16)})`,
+			want: []byte{0xd2, 0xbf, 0x61, 0x61, 0xf5, 0x01, 0xf6, 0x02, 0xf7, 0x03, 0xf0, 0xff},
+		},
+		{
+			name: "duplicated map keys",
+			edn:  "{4: '11', 4: '12'}",
+			want: []byte{0xa2, 0x04, 0x42, 0x31, 0x31, 0x04, 0x42, 0x31, 0x32},
+		},
+		{
+			name: "comments",
+			edn:  "# line comment\n[/ first / 1, 2 # trailing\n]",
+			want: []byte{0x82, 0x01, 0x02},
+		},
+		{
+			name: "sequence",
+			edn:  "1, 2",
+			want: []byte{0x01, 0x02},
+		},
+		{
+			name:    "trailing comma",
+			edn:     "<<1, >>",
+			wantErr: "edn: offset 5: unexpected trailing comma",
+		},
+		{
+			name:    "missing comma",
+			edn:     "[1 2]",
+			wantErr: `edn: offset 3: expected ","`,
+		},
+		{
+			name:    "missing colon",
+			edn:     "{1}",
+			wantErr: `edn: offset 2: expected ":"`,
+		},
+		{
+			name:    "unterminated string",
+			edn:     `"abc`,
+			wantErr: "edn: offset 4: unterminated string",
+		},
+		{
+			name:    "unterminated comment",
+			edn:     `/ abc`,
+			wantErr: "edn: offset 0: unterminated comment",
+		},
+		{
+			name:    "invalid hex",
+			edn:     "h'0'",
+			wantErr: "edn: offset 4: invalid byte string: encoding/hex: odd length hex string",
+		},
+		{
+			name:    "indicator overflow",
+			edn:     "256_0",
+			wantErr: "edn: offset 5: value 256 exceeds encoding indicator _0",
+		},
+		{
+			name:    "half-precision overflow",
+			edn:     "1.1_1",
+			wantErr: "edn: offset 5: value not representable as half-precision float",
+		},
+		{
+			name:    "unknown token",
+			edn:     "foo",
+			wantErr: `edn: offset 3: unknown token "foo"`,
+		},
+		{
+			name:    "invalid simple value",
+			edn:     "simple(24)",
+			wantErr: "edn: offset 9: invalid simple value 24",
+		},
+		{
+			name: "empty indefinite-length strings",
+			edn:  `[''_, ""_, (_ ), (_ '')]`,
+			want: []byte{0x84, 0x5f, 0xff, 0x7f, 0xff, 0x5f, 0xff, 0x5f, 0x40, 0xff},
+		},
+		{
+			name:    "mismatched chunk",
+			edn:     `(_ h'01', "a")`,
+			wantErr: "edn: offset 14: mismatched indefinite-length string chunk type",
+		},
+		{
+			name:    "unexpected delimiter",
+			edn:     "1]",
+			wantErr: `edn: offset 1: unexpected ']'`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDiagnostic(tt.edn)
+			if err != nil {
+				if err.Error() != tt.wantErr {
+					t.Errorf("ParseDiagnostic() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			} else if tt.wantErr != "" {
+				t.Errorf("ParseDiagnostic() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("ParseDiagnostic() = %x, want %x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDiagnostic_roundTrip(t *testing.T) {
+	t.Run("Sign1Message", func(t *testing.T) {
+		msg := &Sign1Message{
+			Headers: Headers{
+				Protected: ProtectedHeader{
+					HeaderLabelAlgorithm: AlgorithmES256,
+				},
+				Unprotected: UnprotectedHeader{
+					HeaderLabelKeyID: []byte("11"),
+					"float":          1.5,
+					"neg":            -1000,
+				},
+			},
+			Payload:   []byte("hello"),
+			Signature: []byte{0x01, 0x02},
+		}
+		want, err := msg.MarshalCBOR()
+		if err != nil {
+			t.Fatalf("Sign1Message.MarshalCBOR() error = %v", err)
+		}
+		edn, err := msg.Diagnose(&DiagnosticOptions{Annotate: true, Indent: "\t"})
+		if err != nil {
+			t.Fatalf("Sign1Message.Diagnose() error = %v", err)
+		}
+		got, err := ParseDiagnostic(edn)
+		if err != nil {
+			t.Fatalf("ParseDiagnostic() error = %v", err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("ParseDiagnostic() = %x, want %x", got, want)
+		}
+		var decoded Sign1Message
+		if err := decoded.UnmarshalCBOR(got); err != nil {
+			t.Fatalf("Sign1Message.UnmarshalCBOR() error = %v", err)
+		}
+	})
+
+	t.Run("Key", func(t *testing.T) {
+		key, err := NewKeyOKP(AlgorithmEdDSA, make([]byte, 32), nil)
+		if err != nil {
+			t.Fatalf("NewKeyOKP() error = %v", err)
+		}
+		want, err := key.MarshalCBOR()
+		if err != nil {
+			t.Fatalf("Key.MarshalCBOR() error = %v", err)
+		}
+		got, err := ParseDiagnostic(key.String())
+		if err != nil {
+			t.Fatalf("ParseDiagnostic() error = %v", err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("ParseDiagnostic() = %x, want %x", got, want)
+		}
+		var decoded Key
+		if err := decoded.UnmarshalCBOR(got); err != nil {
+			t.Fatalf("Key.UnmarshalCBOR() error = %v", err)
+		}
+	})
+
+	t.Run("algorithms", func(t *testing.T) {
+		for v := int64(-65536); v < 65536; v++ {
+			alg := Algorithm(v)
+			if strings.HasPrefix(alg.String(), "Algorithm(") {
+				continue
+			}
+			msg := &Sign1Message{
+				Headers: Headers{
+					Protected: ProtectedHeader{
+						HeaderLabelAlgorithm: alg,
+					},
+				},
+				Payload:   []byte{},
+				Signature: []byte{0x01},
+			}
+			want, err := msg.MarshalCBOR()
+			if err != nil {
+				t.Fatalf("Sign1Message.MarshalCBOR() error = %v", err)
+			}
+			edn, err := msg.Diagnose(&DiagnosticOptions{Annotate: true})
+			if err != nil {
+				t.Fatalf("Sign1Message.Diagnose() error = %v", err)
+			}
+			got, err := ParseDiagnostic(edn)
+			if err != nil {
+				t.Errorf("ParseDiagnostic(%s) error = %v", edn, err)
+				continue
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("ParseDiagnostic(%s) = %x, want %x", edn, got, want)
+			}
+		}
+	})
+}
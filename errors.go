@@ -20,4 +20,18 @@ var (
 	ErrOpNotSupported        = errors.New("key_op not supported by key")
 	ErrEC2NoPub              = errors.New("cannot create PrivateKey from EC2 key: missing x or y")
 	ErrOKPNoPub              = errors.New("cannot create PrivateKey from OKP key: missing x")
+
+	ErrCWTExpired          = errors.New("token expired")
+	ErrCWTNotYetValid      = errors.New("token not yet valid")
+	ErrCWTIssuedInFuture   = errors.New("token issued in the future")
+	ErrCWTIssuerMismatch   = errors.New("issuer mismatch")
+	ErrCWTSubjectMismatch  = errors.New("subject mismatch")
+	ErrCWTAudienceMismatch = errors.New("audience mismatch")
+	ErrCWTMissingClaim     = errors.New("missing claim")
+	ErrCWTCNonceMismatch   = errors.New("cnonce mismatch")
+
+	ErrEATNonceMismatch   = errors.New("nonce mismatch")
+	ErrEATProfileMismatch = errors.New("profile mismatch")
+
+	ErrDuplicateHeaderParameter = errors.New("header parameter present in both protected and unprotected headers")
 )
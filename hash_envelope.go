@@ -147,6 +147,31 @@ func setHashEnvelopeProtectedHeader(base ProtectedHeader, payload *HashEnvelopeP
 	return header
 }
 
+// hashEnvelopeHeaderParameters are the header parameters defined for
+// Hash_Envelope objects.
+// They are checked by validateHashEnvelopeHeaders rather than registered, as
+// their rules only apply to Hash_Envelope objects.
+var hashEnvelopeHeaderParameters = map[any]*HeaderParameter{
+	HeaderLabelPayloadHashAlgorithm: {
+		Label:  HeaderLabelPayloadHashAlgorithm,
+		Name:   "payload hash alg",
+		Bucket: HeaderBucketProtected,
+		Type:   HeaderTypeInt,
+	},
+	HeaderLabelPayloadPreimageContentType: {
+		Label:  HeaderLabelPayloadPreimageContentType,
+		Name:   "payload preimage content type",
+		Bucket: HeaderBucketProtected,
+		Type:   HeaderTypeUint | HeaderTypeTstr,
+	},
+	HeaderLabelPayloadLocation: {
+		Label:  HeaderLabelPayloadLocation,
+		Name:   "payload location",
+		Bucket: HeaderBucketProtected,
+		Type:   HeaderTypeTstr,
+	},
+}
+
 // validateHashEnvelopeHeaders validates the headers of a Hash_Envelope object.
 // See https://www.ietf.org/archive/id/draft-ietf-cose-hash-envelope-05.html
 // section 4 for more details.
@@ -160,30 +185,23 @@ func validateHashEnvelopeHeaders(headers *Headers) error {
 			return errors.New("header label: require int / tstr type")
 		}
 
-		switch label {
-		case HeaderLabelContentType:
+		if label == HeaderLabelContentType {
 			return errors.New("protected header parameter: content type: not allowed")
-		case HeaderLabelPayloadHashAlgorithm:
-			_, isAlg := value.(Algorithm)
-			if !isAlg && !canInt(value) {
-				return errors.New("protected header parameter: payload hash alg: require int type")
+		}
+		if p, ok := hashEnvelopeHeaderParameters[label]; ok {
+			if err := p.validate(value, true); err != nil {
+				return fmt.Errorf("protected %w", err)
 			}
+		}
+		if label == HeaderLabelPayloadHashAlgorithm {
 			foundPayloadHashAlgorithm = true
-		case HeaderLabelPayloadPreimageContentType:
-			if !canUint(value) && !canTstr(value) {
-				return errors.New("protected header parameter: payload preimage content type: require uint / tstr type")
-			}
-		case HeaderLabelPayloadLocation:
-			if !canTstr(value) {
-				return errors.New("protected header parameter: payload location: require tstr type")
-			}
 		}
 	}
 	if !foundPayloadHashAlgorithm {
 		return errors.New("protected header parameter: payload hash alg: required")
 	}
 
-	for label := range headers.Unprotected {
+	for label, value := range headers.Unprotected {
 		// Validate that all header labels are integers or strings.
 		// Reference: https://datatracker.ietf.org/doc/html/rfc8152#section-1.4
 		label, ok := normalizeLabel(label)
@@ -191,15 +209,13 @@ func validateHashEnvelopeHeaders(headers *Headers) error {
 			return errors.New("header label: require int / tstr type")
 		}
 
-		switch label {
-		case HeaderLabelContentType:
+		if label == HeaderLabelContentType {
 			return errors.New("unprotected header parameter: content type: not allowed")
-		case HeaderLabelPayloadHashAlgorithm:
-			return errors.New("unprotected header parameter: payload hash alg: not allowed")
-		case HeaderLabelPayloadPreimageContentType:
-			return errors.New("unprotected header parameter: payload preimage content type: not allowed")
-		case HeaderLabelPayloadLocation:
-			return errors.New("unprotected header parameter: payload location: not allowed")
+		}
+		if p, ok := hashEnvelopeHeaderParameters[label]; ok {
+			if err := p.validate(value, false); err != nil {
+				return fmt.Errorf("unprotected %w", err)
+			}
 		}
 	}
 
@@ -0,0 +1,264 @@
+package cose
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// HeaderBucket indicates in which header bucket a header parameter is allowed.
+type HeaderBucket int
+
+// Header buckets.
+const (
+	// HeaderBucketEither allows the header parameter in both protected and
+	// unprotected headers.
+	HeaderBucketEither HeaderBucket = iota
+
+	// HeaderBucketProtected allows the header parameter only in protected
+	// headers.
+	HeaderBucketProtected
+
+	// HeaderBucketUnprotected allows the header parameter only in unprotected
+	// headers.
+	HeaderBucketUnprotected
+)
+
+// HeaderType is a set of CBOR types allowed for the value of a header
+// parameter.
+type HeaderType uint
+
+// CBOR types of header parameter values. Types can be combined, e.g.
+// HeaderTypeInt | HeaderTypeTstr.
+const (
+	HeaderTypeUint HeaderType = 1 << iota
+	HeaderTypeNint
+	HeaderTypeBstr
+	HeaderTypeTstr
+	HeaderTypeArray
+	HeaderTypeMap
+	HeaderTypeTag
+	HeaderTypeBool
+	HeaderTypeFloat
+	HeaderTypeNull
+
+	// HeaderTypeInt is either a HeaderTypeUint or a HeaderTypeNint.
+	HeaderTypeInt = HeaderTypeUint | HeaderTypeNint
+)
+
+// String returns the CDDL names of the types, e.g. "int / tstr".
+func (t HeaderType) String() string {
+	var names []string
+	switch {
+	case t&HeaderTypeInt == HeaderTypeInt:
+		names = append(names, "int")
+	case t&HeaderTypeUint != 0:
+		names = append(names, "uint")
+	case t&HeaderTypeNint != 0:
+		names = append(names, "nint")
+	}
+	for _, typ := range []struct {
+		t    HeaderType
+		name string
+	}{
+		{HeaderTypeBstr, "bstr"},
+		{HeaderTypeTstr, "tstr"},
+		{HeaderTypeArray, "array"},
+		{HeaderTypeMap, "map"},
+		{HeaderTypeTag, "tag"},
+		{HeaderTypeBool, "bool"},
+		{HeaderTypeFloat, "float"},
+		{HeaderTypeNull, "null"},
+	} {
+		if t&typ.t != 0 {
+			names = append(names, typ.name)
+		}
+	}
+	return strings.Join(names, " / ")
+}
+
+// headerTypeOf returns the type of an encoded CBOR data item, or zero if the
+// type has no HeaderType.
+func headerTypeOf(data []byte) HeaderType {
+	if len(data) == 0 {
+		return 0
+	}
+	switch data[0] >> 5 {
+	case 0:
+		return HeaderTypeUint
+	case 1:
+		return HeaderTypeNint
+	case 2:
+		return HeaderTypeBstr
+	case 3:
+		return HeaderTypeTstr
+	case 4:
+		return HeaderTypeArray
+	case 5:
+		return HeaderTypeMap
+	case 6:
+		return HeaderTypeTag
+	}
+	switch data[0] {
+	case 0xf4, 0xf5: // false, true
+		return HeaderTypeBool
+	case 0xf6: // null
+		return HeaderTypeNull
+	case 0xf9, 0xfa, 0xfb: // float16, float32, float64
+		return HeaderTypeFloat
+	}
+	return 0
+}
+
+// HeaderParameter describes an application-defined header parameter.
+//
+// Registered header parameters are checked whenever headers are checked, i.e.
+// when messages are signed, encoded or decoded, and their values are decoded
+// into the Go type produced by Decode.
+//
+// # Experimental
+//
+// Notice: The header parameter registry is EXPERIMENTAL and may be changed or
+// removed in a later release.
+type HeaderParameter struct {
+	// Label is the label of the header parameter.
+	// It must be an integer or a string.
+	Label any
+
+	// Name is the name of the header parameter used in error messages.
+	// The label is used if Name is empty.
+	Name string
+
+	// Bucket is the header bucket in which the header parameter is allowed.
+	Bucket HeaderBucket
+
+	// Type is the set of CBOR types allowed for the value of the header
+	// parameter. Any type is allowed if Type is zero.
+	//
+	// The type of a Go value is the type of its CBOR encoding.
+	Type HeaderType
+
+	// Decode, if not nil, decodes the CBOR encoded value of the header
+	// parameter when decoding a header. Its result is stored in the header
+	// instead of the generic decoded value, and must encode back to the same
+	// CBOR data item.
+	Decode func(data []byte) (any, error)
+
+	// Validate, if not nil, validates the value of the header parameter after
+	// its bucket and type are checked.
+	Validate func(value any) error
+}
+
+// name returns the name of the header parameter used in error messages.
+func (p *HeaderParameter) name() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return fmt.Sprint(p.Label)
+}
+
+// checkBucket checks that the header parameter is allowed in the header
+// bucket.
+func (p *HeaderParameter) checkBucket(protected bool) error {
+	if (protected && p.Bucket == HeaderBucketUnprotected) ||
+		(!protected && p.Bucket == HeaderBucketProtected) {
+		return fmt.Errorf("header parameter: %s: not allowed", p.name())
+	}
+	return nil
+}
+
+// checkType checks that the encoded value is of one of the allowed types.
+func (p *HeaderParameter) checkType(data []byte) error {
+	if p.Type != 0 && headerTypeOf(data)&p.Type == 0 {
+		return fmt.Errorf("header parameter: %s: require %v type", p.name(), p.Type)
+	}
+	return nil
+}
+
+// validate checks the value of the header parameter in the header bucket.
+func (p *HeaderParameter) validate(value any, protected bool) error {
+	if err := p.checkBucket(protected); err != nil {
+		return err
+	}
+	if p.Type != 0 {
+		data, err := encMode.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("header parameter: %s: %w", p.name(), err)
+		}
+		if err := p.checkType(data); err != nil {
+			return err
+		}
+	}
+	if p.Validate != nil {
+		if err := p.Validate(value); err != nil {
+			return fmt.Errorf("header parameter: %s: %w", p.name(), err)
+		}
+	}
+	return nil
+}
+
+// unmarshal decodes the encoded value of the header parameter in the header
+// bucket.
+func (p *HeaderParameter) unmarshal(data []byte, protected bool, opts *CBOROptions) (any, error) {
+	if err := p.checkBucket(protected); err != nil {
+		return nil, err
+	}
+	if err := p.checkType(data); err != nil {
+		return nil, err
+	}
+	if p.Decode == nil {
+		return unmarshalAsAny(data, opts)
+	}
+	value, err := p.Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("header parameter: %s: %w", p.name(), err)
+	}
+	return value, nil
+}
+
+// headerRegistry holds the registered header parameters by label.
+var headerRegistry = struct {
+	sync.RWMutex
+	params map[any]*HeaderParameter
+}{
+	params: make(map[any]*HeaderParameter),
+}
+
+// RegisterHeaderParameter registers an application-defined header parameter.
+//
+// Header parameters defined in the IANA "COSE Header Parameters" registry and
+// known to this library cannot be registered, nor can a label be registered
+// twice.
+// RegisterHeaderParameter is typically called from an init function.
+//
+// # Experimental
+//
+// Notice: The header parameter registry is EXPERIMENTAL and may be changed or
+// removed in a later release.
+func RegisterHeaderParameter(p HeaderParameter) error {
+	label, ok := normalizeLabel(p.Label)
+	if !ok {
+		return errors.New("header label: require int / tstr type")
+	}
+	if headerLabelName(label) != "" {
+		return fmt.Errorf("header label: %v: reserved", label)
+	}
+	p.Label = label
+
+	headerRegistry.Lock()
+	defer headerRegistry.Unlock()
+	if _, ok := headerRegistry.params[label]; ok {
+		return fmt.Errorf("header label: %v: already registered", label)
+	}
+	headerRegistry.params[label] = &p
+	return nil
+}
+
+// lookupHeaderParameter returns the registered header parameter of a
+// normalized label, or nil if the label is not registered.
+func lookupHeaderParameter(label any) *HeaderParameter {
+	headerRegistry.RLock()
+	defer headerRegistry.RUnlock()
+	return headerRegistry.params[label]
+}
@@ -0,0 +1,255 @@
+package cose
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// testVersion is the Go type of the registered test header parameters.
+type testVersion uint8
+
+// registerTestHeaderParameter registers p for the duration of the test.
+func registerTestHeaderParameter(t *testing.T, p HeaderParameter) {
+	t.Helper()
+	if err := RegisterHeaderParameter(p); err != nil {
+		t.Fatalf("RegisterHeaderParameter() error = %v", err)
+	}
+	t.Cleanup(func() {
+		label, _ := normalizeLabel(p.Label)
+		headerRegistry.Lock()
+		delete(headerRegistry.params, label)
+		headerRegistry.Unlock()
+	})
+}
+
+func TestRegisterHeaderParameter(t *testing.T) {
+	registerTestHeaderParameter(t, HeaderParameter{Label: -65537})
+
+	tests := []struct {
+		name    string
+		p       HeaderParameter
+		wantErr string
+	}{
+		{
+			name:    "invalid label",
+			p:       HeaderParameter{Label: 1.5},
+			wantErr: "header label: require int / tstr type",
+		},
+		{
+			name:    "IANA label",
+			p:       HeaderParameter{Label: 4},
+			wantErr: "header label: 4: reserved",
+		},
+		{
+			name:    "hash envelope label",
+			p:       HeaderParameter{Label: HeaderLabelPayloadLocation},
+			wantErr: "header label: 260: reserved",
+		},
+		{
+			name:    "already registered",
+			p:       HeaderParameter{Label: int64(-65537)},
+			wantErr: "header label: -65537: already registered",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := RegisterHeaderParameter(tt.p)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("RegisterHeaderParameter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHeaderParameter_validate(t *testing.T) {
+	registerTestHeaderParameter(t, HeaderParameter{
+		Label:  -65537,
+		Name:   "version",
+		Bucket: HeaderBucketProtected,
+		Type:   HeaderTypeUint,
+		Validate: func(value any) error {
+			if reflect.ValueOf(value).Uint() > 2 {
+				return errors.New("unsupported version")
+			}
+			return nil
+		},
+	})
+	registerTestHeaderParameter(t, HeaderParameter{
+		Label:  "nonce",
+		Bucket: HeaderBucketUnprotected,
+		Type:   HeaderTypeBstr | HeaderTypeNull,
+	})
+
+	tests := []struct {
+		name    string
+		h       Headers
+		wantErr string
+	}{
+		{
+			name: "valid",
+			h: Headers{
+				Protected:   ProtectedHeader{int64(-65537): testVersion(1)},
+				Unprotected: UnprotectedHeader{"nonce": []byte{0x01}},
+			},
+		},
+		{
+			name: "null",
+			h: Headers{
+				Unprotected: UnprotectedHeader{"nonce": nil},
+			},
+		},
+		{
+			name: "protected only",
+			h: Headers{
+				Unprotected: UnprotectedHeader{-65537: 1},
+			},
+			wantErr: "unprotected header: header parameter: version: not allowed",
+		},
+		{
+			name: "unprotected only",
+			h: Headers{
+				Protected: ProtectedHeader{"nonce": []byte{0x01}},
+			},
+			wantErr: "protected header: header parameter: nonce: not allowed",
+		},
+		{
+			name: "type mismatch",
+			h: Headers{
+				Protected: ProtectedHeader{-65537: -1},
+			},
+			wantErr: "protected header: header parameter: version: require uint type",
+		},
+		{
+			name: "type mismatch with multiple types",
+			h: Headers{
+				Unprotected: UnprotectedHeader{"nonce": "01"},
+			},
+			wantErr: "unprotected header: header parameter: nonce: require bstr / null type",
+		},
+		{
+			name: "validator",
+			h: Headers{
+				Protected: ProtectedHeader{-65537: uint8(3)},
+			},
+			wantErr: "protected header: header parameter: version: unsupported version",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := tt.h.marshal(nil)
+			if err != nil && (err.Error() != tt.wantErr) {
+				t.Errorf("Headers.marshal() error = %v, wantErr %v", err, tt.wantErr)
+			} else if err == nil && tt.wantErr != "" {
+				t.Errorf("Headers.marshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHeaderParameter_unmarshal(t *testing.T) {
+	registerTestHeaderParameter(t, HeaderParameter{
+		Label:  -65537,
+		Name:   "version",
+		Bucket: HeaderBucketEither,
+		Type:   HeaderTypeUint,
+		Decode: func(data []byte) (any, error) {
+			var v testVersion
+			if err := decMode.Unmarshal(data, &v); err != nil {
+				return nil, err
+			}
+			if v == 0 {
+				return nil, errors.New("require non-zero version")
+			}
+			return v, nil
+		},
+	})
+	registerTestHeaderParameter(t, HeaderParameter{
+		Label:  "nonce",
+		Bucket: HeaderBucketUnprotected,
+		Type:   HeaderTypeBstr,
+	})
+
+	tests := []struct {
+		name            string
+		edn             string
+		wantProtected   ProtectedHeader
+		wantUnprotected UnprotectedHeader
+		wantErr         string
+	}{
+		{
+			name:            "registered types",
+			edn:             `[<<{-65537: 1}>>, {-65537: 2, "nonce": h'01'}]`,
+			wantProtected:   ProtectedHeader{int64(-65537): testVersion(1)},
+			wantUnprotected: UnprotectedHeader{int64(-65537): testVersion(2), "nonce": []byte{0x01}},
+		},
+		{
+			name:    "protected type mismatch",
+			edn:     `[<<{-65537: "1"}>>, {}]`,
+			wantErr: "cbor: invalid protected header: protected header: header parameter: version: require uint type",
+		},
+		{
+			name:    "unprotected type mismatch",
+			edn:     `[h'', {-65537: -1}]`,
+			wantErr: "cbor: invalid unprotected header: unprotected header: header parameter: version: require uint type",
+		},
+		{
+			name:    "decoder error",
+			edn:     `[<<{-65537: 0}>>, {}]`,
+			wantErr: "cbor: invalid protected header: protected header: header parameter: version: require non-zero version",
+		},
+		{
+			name:    "bucket mismatch",
+			edn:     `[<<{"nonce": h'01'}>>, {}]`,
+			wantErr: "cbor: invalid protected header: protected header: header parameter: nonce: not allowed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := mustTestCBOR(t, tt.edn, "")
+			var raw []cbor.RawMessage
+			if err := decMode.Unmarshal(data, &raw); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			var h Headers
+			h.RawProtected = []byte(raw[0])
+			h.RawUnprotected = []byte(raw[1])
+			err := h.UnmarshalFromRaw()
+			if err != nil && (err.Error() != tt.wantErr) {
+				t.Errorf("Headers.UnmarshalFromRaw() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			} else if err == nil && tt.wantErr != "" {
+				t.Errorf("Headers.UnmarshalFromRaw() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr != "" {
+				return
+			}
+			if !reflect.DeepEqual(h.Protected, tt.wantProtected) {
+				t.Errorf("Headers.UnmarshalFromRaw() Protected = %v, want %v", h.Protected, tt.wantProtected)
+			}
+			if !reflect.DeepEqual(h.Unprotected, tt.wantUnprotected) {
+				t.Errorf("Headers.UnmarshalFromRaw() Unprotected = %v, want %v", h.Unprotected, tt.wantUnprotected)
+			}
+		})
+	}
+}
+
+func TestHeaderType_String(t *testing.T) {
+	tests := []struct {
+		t    HeaderType
+		want string
+	}{
+		{HeaderTypeInt, "int"},
+		{HeaderTypeUint | HeaderTypeTstr, "uint / tstr"},
+		{HeaderTypeNint | HeaderTypeArray | HeaderTypeMap, "nint / array / map"},
+		{HeaderTypeTag | HeaderTypeBool | HeaderTypeFloat, "tag / bool / float"},
+	}
+	for _, tt := range tests {
+		if got := tt.t.String(); got != tt.want {
+			t.Errorf("HeaderType.String() = %v, want %v", got, tt.want)
+		}
+	}
+}
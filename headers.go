@@ -3,7 +3,9 @@ package cose
 import (
 	"errors"
 	"fmt"
+	"math"
 	"math/big"
+	"reflect"
 	"strings"
 
 	"github.com/fxamacker/cbor/v2"
@@ -23,6 +25,8 @@ const (
 	HeaderLabelCounterSignature0   int64 = 9
 	HeaderLabelCounterSignatureV2  int64 = 11
 	HeaderLabelCounterSignature0V2 int64 = 12
+	HeaderLabelKCWT                int64 = 13
+	HeaderLabelKCCS                int64 = 14
 	HeaderLabelCWTClaims           int64 = 15
 	HeaderLabelType                int64 = 16
 	HeaderLabelX5Bag               int64 = 32
@@ -43,6 +47,81 @@ const (
 	HeaderLabelPayloadLocation            int64 = 260 // registered 2025-03-05, expires 2026-03-05
 )
 
+// COSE Header labels of CBOR encoded certificates (C509), as requested to be
+// registered in the IANA "COSE Header Parameters" registry.
+// These labels are subject to change until the specification is published.
+//
+// Reference: https://datatracker.ietf.org/doc/html/draft-ietf-cose-cbor-encoded-cert-12#section-9
+const (
+	HeaderLabelC5T int64 = 22
+	HeaderLabelC5U int64 = 23
+	HeaderLabelC5B int64 = 24
+	HeaderLabelC5C int64 = 25
+)
+
+// COSE Header labels of Selective Disclosure CWTs (SD-CWT), as requested to be
+// registered in the IANA "COSE Header Parameters" registry.
+// These labels are subject to change until the specification is published.
+//
+// Reference: https://datatracker.ietf.org/doc/html/draft-ietf-spice-sd-cwt-04#section-14.1
+const (
+	HeaderLabelSDClaims int64 = 17
+	HeaderLabelSDAlg    int64 = 18
+)
+
+// headerLabelNames maps the header labels registered in the IANA "COSE Header
+// Parameters" registry and known to this library to their names.
+var headerLabelNames = map[int64]string{
+	HeaderLabelAlgorithm:                  "alg",
+	HeaderLabelCritical:                   "crit",
+	HeaderLabelContentType:                "content type",
+	HeaderLabelKeyID:                      "kid",
+	HeaderLabelIV:                         "IV",
+	HeaderLabelPartialIV:                  "Partial IV",
+	HeaderLabelCounterSignature:           "counter signature",
+	HeaderLabelCounterSignature0:          "CounterSignature0",
+	HeaderLabelCounterSignatureV2:         "Countersignature version 2",
+	HeaderLabelCounterSignature0V2:        "Countersignature0 version 2",
+	HeaderLabelKCWT:                       "kcwt",
+	HeaderLabelKCCS:                       "kccs",
+	HeaderLabelCWTClaims:                  "CWT Claims",
+	HeaderLabelSDClaims:                   "sd_claims",
+	HeaderLabelSDAlg:                      "sd_alg",
+	HeaderLabelType:                       "typ",
+	HeaderLabelX5Bag:                      "x5bag",
+	HeaderLabelX5Chain:                    "x5chain",
+	HeaderLabelX5T:                        "x5t",
+	HeaderLabelX5U:                        "x5u",
+	HeaderLabelC5T:                        "c5t",
+	HeaderLabelC5U:                        "c5u",
+	HeaderLabelC5B:                        "c5b",
+	HeaderLabelC5C:                        "c5c",
+	HeaderLabelPayloadHashAlgorithm:       "payload_hash_alg",
+	HeaderLabelPayloadPreimageContentType: "payload_preimage_content_type",
+	HeaderLabelPayloadLocation:            "payload_location",
+}
+
+// headerLabelName returns the name of a header label registered in the IANA
+// "COSE Header Parameters" registry, or an empty string if the label is not
+// known.
+func headerLabelName(label any) string {
+	if label, ok := label.(int64); ok {
+		return headerLabelNames[label]
+	}
+	return ""
+}
+
+// headerLabelFromName returns the header label with the given name in the IANA
+// "COSE Header Parameters" registry.
+func headerLabelFromName(name string) (int64, bool) {
+	for label, labelName := range headerLabelNames {
+		if labelName == name {
+			return label, true
+		}
+	}
+	return 0, false
+}
+
 // ProtectedHeader contains parameters that are to be cryptographically
 // protected.
 type ProtectedHeader map[any]any
@@ -51,6 +130,12 @@ type ProtectedHeader map[any]any
 // A zero-length header is encoded as a zero-length string rather than as a
 // zero-length map (encoded as h'a0').
 func (h ProtectedHeader) MarshalCBOR() ([]byte, error) {
+	return h.marshal(nil)
+}
+
+// marshal encodes the protected header into a CBOR bstr object using the
+// header value encoding mode of opts.
+func (h ProtectedHeader) marshal(opts *CBOROptions) ([]byte, error) {
 	var encoded []byte
 	if len(h) == 0 {
 		encoded = []byte{}
@@ -59,7 +144,11 @@ func (h ProtectedHeader) MarshalCBOR() ([]byte, error) {
 		if err != nil {
 			return nil, fmt.Errorf("protected header: %w", err)
 		}
-		encoded, err = encMode.Marshal(map[any]any(h))
+		em, err := opts.protectedEncMode()
+		if err != nil {
+			return nil, err
+		}
+		encoded, err = em.Marshal(map[any]any(h))
 		if err != nil {
 			return nil, err
 		}
@@ -76,6 +165,12 @@ func (h *ProtectedHeader) UnmarshalCBOR(data []byte) error {
 	if h == nil {
 		return errors.New("cbor: UnmarshalCBOR on nil ProtectedHeader pointer")
 	}
+	return h.unmarshal(data, nil)
+}
+
+// unmarshal decodes a CBOR bstr object into ProtectedHeader using the header
+// value decoding mode of opts.
+func (h *ProtectedHeader) unmarshal(data []byte, opts *CBOROptions) error {
 	var encoded byteString
 	if err := encoded.UnmarshalCBOR(data); err != nil {
 		return err
@@ -92,10 +187,20 @@ func (h *ProtectedHeader) UnmarshalCBOR(data []byte) error {
 		if err := validateHeaderLabelCBOR(encoded); err != nil {
 			return err
 		}
-		var header map[any]any
-		if err := decMode.Unmarshal(encoded, &header); err != nil {
+		// labels are decoded with the built-in mode so that integer labels are
+		// int64 regardless of opts; values are decoded with opts
+		var partialHeader map[any]cbor.RawMessage
+		if err := decMode.Unmarshal(encoded, &partialHeader); err != nil {
 			return err
 		}
+		header := make(map[any]any, len(partialHeader))
+		for k, v := range partialHeader {
+			v, err := unmarshalProtected(k, v, opts)
+			if err != nil {
+				return fmt.Errorf("protected header: %w", err)
+			}
+			header[k] = v
+		}
 		candidate := ProtectedHeader(header)
 		if err := validateHeaderParameters(candidate, true); err != nil {
 			return fmt.Errorf("protected header: %w", err)
@@ -126,44 +231,66 @@ func (h ProtectedHeader) SetType(typ any) (any, error) {
 }
 
 // SetCWTClaims sets the CWT Claims value of the protected header.
+// The registered claims are checked to have the types of RFC 8392.
 func (h ProtectedHeader) SetCWTClaims(claims CWTClaims) (CWTClaims, error) {
-	iss, hasIss := claims[1]
-	if hasIss && !canTstr(iss) {
-		return claims, errors.New("cwt claim: iss: require tstr")
-	}
-	sub, hasSub := claims[2]
-	if hasSub && !canTstr(sub) {
-		return claims, errors.New("cwt claim: sub: require tstr")
+	if _, err := claims.ClaimsSet(); err != nil {
+		return claims, err
 	}
-	// TODO: validate claims, other claims
 	h[HeaderLabelCWTClaims] = claims
 	return claims, nil
 }
 
+// SetCWTClaimsSet sets the CWT Claims value of the protected header from its
+// typed form.
+func (h ProtectedHeader) SetCWTClaimsSet(s *CWTClaimsSet) error {
+	claims, err := s.Claims()
+	if err != nil {
+		return err
+	}
+	h[HeaderLabelCWTClaims] = claims
+	return nil
+}
+
+// CWTClaims returns the CWT Claims value of the protected header, or nil if
+// the CWT Claims header parameter is not present.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9597.html
+func (h ProtectedHeader) CWTClaims() (CWTClaims, error) {
+	value, ok := h[HeaderLabelCWTClaims]
+	if !ok {
+		return nil, nil
+	}
+	switch v := value.(type) {
+	case CWTClaims:
+		return v, nil
+	case map[any]any:
+		return CWTClaims(v), nil
+	}
+	return nil, errors.New("header parameter: CWT Claims: require map type")
+}
+
+// CWTClaimsSet returns the typed CWT Claims value of the protected header, or
+// nil if the CWT Claims header parameter is not present.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9597.html
+func (h ProtectedHeader) CWTClaimsSet() (*CWTClaimsSet, error) {
+	claims, err := h.CWTClaims()
+	if err != nil || claims == nil {
+		return nil, err
+	}
+	return claims.ClaimsSet()
+}
+
 // Algorithm gets the algorithm value from the algorithm header.
 func (h ProtectedHeader) Algorithm() (Algorithm, error) {
 	value, ok := h[HeaderLabelAlgorithm]
 	if !ok {
 		return AlgorithmReserved, ErrAlgorithmNotFound
 	}
-	switch alg := value.(type) {
-	case Algorithm:
-		return alg, nil
-	case int:
-		return Algorithm(alg), nil
-	case int8:
-		return Algorithm(alg), nil
-	case int16:
-		return Algorithm(alg), nil
-	case int32:
-		return Algorithm(alg), nil
-	case int64:
-		return Algorithm(alg), nil
-	case string:
+	if alg, ok := value.(string); ok {
 		return AlgorithmReserved, fmt.Errorf("Algorithm(%q): %w", alg, ErrAlgorithmNotSupported)
-	default:
-		return AlgorithmReserved, ErrInvalidAlgorithm
 	}
+	return algorithmValue(value)
 }
 
 // PayloadHashAlgorithm gets the payload hash algorithm value from the protected
@@ -178,6 +305,12 @@ func (h ProtectedHeader) PayloadHashAlgorithm() (Algorithm, error) {
 	if !ok {
 		return AlgorithmReserved, ErrAlgorithmNotFound
 	}
+	return algorithmValue(value)
+}
+
+// algorithmValue converts an algorithm header parameter value of any CBOR int
+// type into an Algorithm.
+func algorithmValue(value any) (Algorithm, error) {
 	switch alg := value.(type) {
 	case Algorithm:
 		return alg, nil
@@ -191,9 +324,22 @@ func (h ProtectedHeader) PayloadHashAlgorithm() (Algorithm, error) {
 		return Algorithm(alg), nil
 	case int64:
 		return Algorithm(alg), nil
-	default:
-		return AlgorithmReserved, ErrInvalidAlgorithm
+	case uint:
+		if uint64(alg) <= math.MaxInt64 {
+			return Algorithm(alg), nil
+		}
+	case uint8:
+		return Algorithm(alg), nil
+	case uint16:
+		return Algorithm(alg), nil
+	case uint32:
+		return Algorithm(alg), nil
+	case uint64:
+		if uint64(alg) <= math.MaxInt64 {
+			return Algorithm(alg), nil
+		}
 	}
+	return AlgorithmReserved, ErrInvalidAlgorithm
 }
 
 // Critical indicates which protected header labels an application that is
@@ -241,13 +387,19 @@ type UnprotectedHeader map[any]any
 // MarshalCBOR encodes the unprotected header into a CBOR map object.
 // A zero-length header is encoded as a zero-length map (encoded as h'a0').
 func (h UnprotectedHeader) MarshalCBOR() ([]byte, error) {
+	return h.marshal(nil)
+}
+
+// marshal encodes the unprotected header into a CBOR map object using the
+// header value encoding mode of opts.
+func (h UnprotectedHeader) marshal(opts *CBOROptions) ([]byte, error) {
 	if len(h) == 0 {
 		return []byte{0xa0}, nil
 	}
 	if err := validateHeaderParameters(h, false); err != nil {
 		return nil, fmt.Errorf("unprotected header: %w", err)
 	}
-	return encMode.Marshal(map[any]any(h))
+	return opts.encMode().Marshal(map[any]any(h))
 }
 
 // UnmarshalCBOR decodes a CBOR map object into UnprotectedHeader.
@@ -257,6 +409,12 @@ func (h *UnprotectedHeader) UnmarshalCBOR(data []byte) error {
 	if h == nil {
 		return errors.New("cbor: UnmarshalCBOR on nil UnprotectedHeader pointer")
 	}
+	return h.unmarshal(data, nil)
+}
+
+// unmarshal decodes a CBOR map object into UnprotectedHeader using the header
+// value decoding mode of opts.
+func (h *UnprotectedHeader) unmarshal(data []byte, opts *CBOROptions) error {
 	if data == nil {
 		return errors.New("cbor: nil unprotected header")
 	}
@@ -278,7 +436,7 @@ func (h *UnprotectedHeader) UnmarshalCBOR(data []byte) error {
 	}
 	header := make(map[any]any, len(partialHeader))
 	for k, v := range partialHeader {
-		v, err := unmarshalUnprotected(k, v)
+		v, err := unmarshalUnprotected(k, v, opts)
 		if err != nil {
 			return err
 		}
@@ -292,41 +450,71 @@ func (h *UnprotectedHeader) UnmarshalCBOR(data []byte) error {
 	return nil
 }
 
+// unmarshalProtected produces the Go types of registered header parameters,
+// otherwise it defaults to regular unmarshaling to simple types.
+func unmarshalProtected(key any, value cbor.RawMessage, opts *CBOROptions) (any, error) {
+	if label, ok := normalizeLabel(key); ok {
+		if p := lookupHeaderParameter(label); p != nil {
+			return p.unmarshal(value, true, opts)
+		}
+	}
+	return unmarshalAsAny(value, opts)
+}
+
 // unmarshalUnprotected produces known structs such as counter signature
-// headers, otherwise it defaults to regular unmarshaling to simple types.
-func unmarshalUnprotected(key any, value cbor.RawMessage) (any, error) {
+// headers and the Go types of registered header parameters, otherwise it
+// defaults to regular unmarshaling to simple types.
+func unmarshalUnprotected(key any, value cbor.RawMessage, opts *CBOROptions) (any, error) {
 	label, ok := normalizeLabel(key)
 	if ok {
 		switch label {
 		case HeaderLabelCounterSignature, HeaderLabelCounterSignatureV2:
-			return unmarshalAsCountersignature(value)
+			return unmarshalAsCountersignature(value, opts)
 		default:
 		}
+		if p := lookupHeaderParameter(label); p != nil {
+			v, err := p.unmarshal(value, false, opts)
+			if err != nil {
+				return nil, fmt.Errorf("unprotected header: %w", err)
+			}
+			return v, nil
+		}
 	}
 
-	return unmarshalAsAny(value)
+	return unmarshalAsAny(value, opts)
 }
 
 // unmarshalAsCountersignature produces a Countersignature struct or a list of
 // Countersignatures.
-func unmarshalAsCountersignature(value cbor.RawMessage) (any, error) {
+func unmarshalAsCountersignature(value cbor.RawMessage, opts *CBOROptions) (any, error) {
 	var result1 Countersignature
-	err := decMode.Unmarshal(value, &result1)
+	err := (*Signature)(&result1).unmarshal(value, opts)
 	if err == nil {
 		return &result1, nil
 	}
+	var raw []cbor.RawMessage
+	if err := decMode.Unmarshal(value, &raw); err != nil {
+		return nil, errInvalidCountersignature
+	}
 	var result2 []*Countersignature
-	err = decMode.Unmarshal(value, &result2)
-	if err == nil {
-		return result2, nil
+	for _, data := range raw {
+		var cs Countersignature
+		if err := (*Signature)(&cs).unmarshal(data, opts); err != nil {
+			return nil, errInvalidCountersignature
+		}
+		result2 = append(result2, &cs)
 	}
-	return nil, errors.New("invalid Countersignature object / list of objects")
+	return result2, nil
 }
 
+// errInvalidCountersignature is returned when the value of a counter signature
+// header cannot be decoded.
+var errInvalidCountersignature = errors.New("invalid Countersignature object / list of objects")
+
 // unmarshalAsAny produces simple types.
-func unmarshalAsAny(value cbor.RawMessage) (any, error) {
+func unmarshalAsAny(value cbor.RawMessage, opts *CBOROptions) (any, error) {
 	var result any
-	err := decMode.Unmarshal(value, &result)
+	err := opts.decMode().Unmarshal(value, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -388,15 +576,15 @@ type Headers struct {
 
 // marshal encoded both headers.
 // It returns RawProtected and RawUnprotected if those are set.
-func (h *Headers) marshal() (cbor.RawMessage, cbor.RawMessage, error) {
+func (h *Headers) marshal(opts *CBOROptions) (cbor.RawMessage, cbor.RawMessage, error) {
 	if err := h.ensureIV(); err != nil {
 		return nil, nil, err
 	}
-	protected, err := h.MarshalProtected()
+	protected, err := h.MarshalProtectedWithOptions(opts)
 	if err != nil {
 		return nil, nil, err
 	}
-	unprotected, err := h.MarshalUnprotected()
+	unprotected, err := h.MarshalUnprotectedWithOptions(opts)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -406,28 +594,49 @@ func (h *Headers) marshal() (cbor.RawMessage, cbor.RawMessage, error) {
 // MarshalProtected encodes the protected header.
 // RawProtected is returned if it is not set to nil.
 func (h *Headers) MarshalProtected() ([]byte, error) {
+	return h.MarshalProtectedWithOptions(nil)
+}
+
+// MarshalProtectedWithOptions encodes the protected header, encoding header
+// values with the modes specified by opts.
+// RawProtected is returned if it is not set to nil.
+func (h *Headers) MarshalProtectedWithOptions(opts *CBOROptions) ([]byte, error) {
 	if len(h.RawProtected) > 0 {
 		return h.RawProtected, nil
 	}
-	return encMode.Marshal(h.Protected)
+	return h.Protected.marshal(opts)
 }
 
 // MarshalUnprotected encodes the unprotected header.
 // RawUnprotected is returned if it is not set to nil.
 func (h *Headers) MarshalUnprotected() ([]byte, error) {
+	return h.MarshalUnprotectedWithOptions(nil)
+}
+
+// MarshalUnprotectedWithOptions encodes the unprotected header, encoding
+// header values with the modes specified by opts.
+// RawUnprotected is returned if it is not set to nil.
+func (h *Headers) MarshalUnprotectedWithOptions(opts *CBOROptions) ([]byte, error) {
 	if len(h.RawUnprotected) > 0 {
 		return h.RawUnprotected, nil
 	}
-	return encMode.Marshal(h.Unprotected)
+	return h.Unprotected.marshal(opts)
 }
 
 // UnmarshalFromRaw decodes Protected from RawProtected and Unprotected from
 // RawUnprotected.
 func (h *Headers) UnmarshalFromRaw() error {
-	if err := decMode.Unmarshal(h.RawProtected, &h.Protected); err != nil {
+	return h.UnmarshalFromRawWithOptions(nil)
+}
+
+// UnmarshalFromRawWithOptions decodes Protected from RawProtected and
+// Unprotected from RawUnprotected, decoding header values with the modes
+// specified by opts.
+func (h *Headers) UnmarshalFromRawWithOptions(opts *CBOROptions) error {
+	if err := decodeWithOptions(h.RawProtected, &h.Protected, opts); err != nil {
 		return fmt.Errorf("cbor: invalid protected header: %w", err)
 	}
-	if err := decMode.Unmarshal(h.RawUnprotected, &h.Unprotected); err != nil {
+	if err := decodeWithOptions(h.RawUnprotected, &h.Unprotected, opts); err != nil {
 		return fmt.Errorf("cbor: invalid unprotected header: %w", err)
 	}
 	if err := h.ensureIV(); err != nil {
@@ -506,6 +715,345 @@ func hasLabel(h map[any]any, label any) bool {
 	return ok
 }
 
+// KeyID returns the key identifier in the protected header, or nil if the
+// key identifier is not present.
+func (h ProtectedHeader) KeyID() ([]byte, error) {
+	return headerBytes(h, true, HeaderLabelKeyID)
+}
+
+// SetKeyID sets the key identifier in the protected header.
+func (h ProtectedHeader) SetKeyID(kid []byte) error {
+	return setHeaderParameter(h, true, HeaderLabelKeyID, kid)
+}
+
+// ContentType returns the content type in the protected header as a string
+// media type or as an uint64 CoAP Content-Format, or nil if the content type
+// is not present.
+func (h ProtectedHeader) ContentType() (any, error) {
+	return headerTstrOrUint(h, true, HeaderLabelContentType)
+}
+
+// SetContentType sets the content type in the protected header to a media
+// type string or to an unsigned integer CoAP Content-Format.
+func (h ProtectedHeader) SetContentType(ct any) error {
+	return setHeaderParameter(h, true, HeaderLabelContentType, ct)
+}
+
+// IV returns the full initialization vector in the protected header, or nil
+// if the IV is not present.
+func (h ProtectedHeader) IV() ([]byte, error) {
+	return headerBytes(h, true, HeaderLabelIV)
+}
+
+// SetIV sets the full initialization vector in the protected header.
+// It fails if the protected header contains a partial IV.
+func (h ProtectedHeader) SetIV(iv []byte) error {
+	return setHeaderParameter(h, true, HeaderLabelIV, iv)
+}
+
+// PartialIV returns the partial initialization vector in the protected header,
+// or nil if the partial IV is not present.
+func (h ProtectedHeader) PartialIV() ([]byte, error) {
+	return headerBytes(h, true, HeaderLabelPartialIV)
+}
+
+// SetPartialIV sets the partial initialization vector in the protected
+// header.
+// It fails if the protected header contains a full IV.
+func (h ProtectedHeader) SetPartialIV(piv []byte) error {
+	return setHeaderParameter(h, true, HeaderLabelPartialIV, piv)
+}
+
+// Type returns the type of the COSE object in the protected header as a
+// string media type or as an uint64 CoAP Content-Format, or nil if the type is
+// not present.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9596.html
+func (h ProtectedHeader) Type() (any, error) {
+	return headerTstrOrUint(h, true, HeaderLabelType)
+}
+
+// KeyID returns the key identifier in the unprotected header, or nil if the
+// key identifier is not present.
+func (h UnprotectedHeader) KeyID() ([]byte, error) {
+	return headerBytes(h, false, HeaderLabelKeyID)
+}
+
+// SetKeyID sets the key identifier in the unprotected header.
+func (h UnprotectedHeader) SetKeyID(kid []byte) error {
+	return setHeaderParameter(h, false, HeaderLabelKeyID, kid)
+}
+
+// ContentType returns the content type in the unprotected header as a string
+// media type or as an uint64 CoAP Content-Format, or nil if the content type
+// is not present.
+func (h UnprotectedHeader) ContentType() (any, error) {
+	return headerTstrOrUint(h, false, HeaderLabelContentType)
+}
+
+// SetContentType sets the content type in the unprotected header to a media
+// type string or to an unsigned integer CoAP Content-Format.
+func (h UnprotectedHeader) SetContentType(ct any) error {
+	return setHeaderParameter(h, false, HeaderLabelContentType, ct)
+}
+
+// IV returns the full initialization vector in the unprotected header, or nil
+// if the IV is not present.
+func (h UnprotectedHeader) IV() ([]byte, error) {
+	return headerBytes(h, false, HeaderLabelIV)
+}
+
+// SetIV sets the full initialization vector in the unprotected header.
+// It fails if the unprotected header contains a partial IV.
+func (h UnprotectedHeader) SetIV(iv []byte) error {
+	return setHeaderParameter(h, false, HeaderLabelIV, iv)
+}
+
+// PartialIV returns the partial initialization vector in the unprotected
+// header, or nil if the partial IV is not present.
+func (h UnprotectedHeader) PartialIV() ([]byte, error) {
+	return headerBytes(h, false, HeaderLabelPartialIV)
+}
+
+// SetPartialIV sets the partial initialization vector in the unprotected
+// header.
+// It fails if the unprotected header contains a full IV.
+func (h UnprotectedHeader) SetPartialIV(piv []byte) error {
+	return setHeaderParameter(h, false, HeaderLabelPartialIV, piv)
+}
+
+// Type returns the type of the COSE object in the unprotected header as a
+// string media type or as an uint64 CoAP Content-Format, or nil if the type is
+// not present.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9596.html
+func (h UnprotectedHeader) Type() (any, error) {
+	return headerTstrOrUint(h, false, HeaderLabelType)
+}
+
+// SetType sets the type of the COSE object in the unprotected header to a
+// media type string or to an unsigned integer CoAP Content-Format.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9596.html
+func (h UnprotectedHeader) SetType(typ any) error {
+	return setHeaderParameter(h, false, HeaderLabelType, typ)
+}
+
+// KeyID returns the key identifier from the protected or the unprotected
+// header, or nil if the key identifier is not present.
+// It fails with ErrDuplicateHeaderParameter if both headers contain a key
+// identifier.
+func (h *Headers) KeyID() ([]byte, error) {
+	if err := h.ensureSingleBucket(HeaderLabelKeyID); err != nil {
+		return nil, err
+	}
+	if hasLabel(h.Protected, HeaderLabelKeyID) {
+		return h.Protected.KeyID()
+	}
+	return h.Unprotected.KeyID()
+}
+
+// SetKeyID sets the key identifier in the unprotected header.
+// It fails with ErrDuplicateHeaderParameter if the protected header contains
+// a key identifier.
+func (h *Headers) SetKeyID(kid []byte) error {
+	if hasLabel(h.Protected, HeaderLabelKeyID) {
+		return fmt.Errorf("header parameter: kid: %w", ErrDuplicateHeaderParameter)
+	}
+	if h.Unprotected == nil {
+		h.Unprotected = make(UnprotectedHeader)
+	}
+	return h.Unprotected.SetKeyID(kid)
+}
+
+// ContentType returns the content type from the protected or the unprotected
+// header as a string media type or as an uint64 CoAP Content-Format, or nil if
+// the content type is not present.
+// It fails with ErrDuplicateHeaderParameter if both headers contain a content
+// type.
+func (h *Headers) ContentType() (any, error) {
+	if err := h.ensureSingleBucket(HeaderLabelContentType); err != nil {
+		return nil, err
+	}
+	if hasLabel(h.Protected, HeaderLabelContentType) {
+		return h.Protected.ContentType()
+	}
+	return h.Unprotected.ContentType()
+}
+
+// SetContentType sets the content type in the protected header to a media
+// type string or to an unsigned integer CoAP Content-Format.
+// It fails with ErrDuplicateHeaderParameter if the unprotected header contains
+// a content type.
+func (h *Headers) SetContentType(ct any) error {
+	if hasLabel(h.Unprotected, HeaderLabelContentType) {
+		return fmt.Errorf("header parameter: content type: %w", ErrDuplicateHeaderParameter)
+	}
+	if h.Protected == nil {
+		h.Protected = make(ProtectedHeader)
+	}
+	return h.Protected.SetContentType(ct)
+}
+
+// IV returns the full initialization vector from the protected or the
+// unprotected header, or nil if the IV is not present.
+// It fails with ErrDuplicateHeaderParameter if both headers contain an IV.
+func (h *Headers) IV() ([]byte, error) {
+	if err := h.ensureSingleBucket(HeaderLabelIV); err != nil {
+		return nil, err
+	}
+	if hasLabel(h.Protected, HeaderLabelIV) {
+		return h.Protected.IV()
+	}
+	return h.Unprotected.IV()
+}
+
+// SetIV sets the full initialization vector in the unprotected header.
+// It fails with ErrDuplicateHeaderParameter if the protected header contains
+// an IV, and fails if either header contains a partial IV.
+func (h *Headers) SetIV(iv []byte) error {
+	if hasLabel(h.Protected, HeaderLabelIV) {
+		return fmt.Errorf("header parameter: IV: %w", ErrDuplicateHeaderParameter)
+	}
+	if hasLabel(h.Protected, HeaderLabelPartialIV) {
+		return errors.New("header parameter: IV and PartialIV: parameters must not both be present")
+	}
+	if h.Unprotected == nil {
+		h.Unprotected = make(UnprotectedHeader)
+	}
+	return h.Unprotected.SetIV(iv)
+}
+
+// PartialIV returns the partial initialization vector from the protected or
+// the unprotected header, or nil if the partial IV is not present.
+// It fails with ErrDuplicateHeaderParameter if both headers contain a partial
+// IV.
+func (h *Headers) PartialIV() ([]byte, error) {
+	if err := h.ensureSingleBucket(HeaderLabelPartialIV); err != nil {
+		return nil, err
+	}
+	if hasLabel(h.Protected, HeaderLabelPartialIV) {
+		return h.Protected.PartialIV()
+	}
+	return h.Unprotected.PartialIV()
+}
+
+// SetPartialIV sets the partial initialization vector in the unprotected
+// header.
+// It fails with ErrDuplicateHeaderParameter if the protected header contains
+// a partial IV, and fails if either header contains a full IV.
+func (h *Headers) SetPartialIV(piv []byte) error {
+	if hasLabel(h.Protected, HeaderLabelPartialIV) {
+		return fmt.Errorf("header parameter: Partial IV: %w", ErrDuplicateHeaderParameter)
+	}
+	if hasLabel(h.Protected, HeaderLabelIV) {
+		return errors.New("header parameter: IV and PartialIV: parameters must not both be present")
+	}
+	if h.Unprotected == nil {
+		h.Unprotected = make(UnprotectedHeader)
+	}
+	return h.Unprotected.SetPartialIV(piv)
+}
+
+// Type returns the type of the COSE object from the protected or the
+// unprotected header as a string media type or as an uint64 CoAP
+// Content-Format, or nil if the type is not present.
+// It fails with ErrDuplicateHeaderParameter if both headers contain a type.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9596.html
+func (h *Headers) Type() (any, error) {
+	if err := h.ensureSingleBucket(HeaderLabelType); err != nil {
+		return nil, err
+	}
+	if hasLabel(h.Protected, HeaderLabelType) {
+		return h.Protected.Type()
+	}
+	return h.Unprotected.Type()
+}
+
+// SetType sets the type of the COSE object in the protected header to a media
+// type string or to an unsigned integer CoAP Content-Format.
+// It fails with ErrDuplicateHeaderParameter if the unprotected header contains
+// a type.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9596.html
+func (h *Headers) SetType(typ any) error {
+	if hasLabel(h.Unprotected, HeaderLabelType) {
+		return fmt.Errorf("header parameter: type: %w", ErrDuplicateHeaderParameter)
+	}
+	if h.Protected == nil {
+		h.Protected = make(ProtectedHeader)
+	}
+	return setHeaderParameter(h.Protected, true, HeaderLabelType, typ)
+}
+
+// ensureSingleBucket ensures that the header parameter with the given label is
+// not present in both the protected and the unprotected headers.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9052.html#section-3
+func (h *Headers) ensureSingleBucket(label int64) error {
+	if hasLabel(h.Protected, label) && hasLabel(h.Unprotected, label) {
+		return fmt.Errorf("header parameter: %s: %w", headerLabelName(label), ErrDuplicateHeaderParameter)
+	}
+	return nil
+}
+
+// headerParameter returns the value of the header parameter with the given
+// label, validated against the rules of validateHeaderParameters.
+func headerParameter(h map[any]any, protected bool, label int64) (any, bool, error) {
+	value, ok := h[label]
+	if !ok {
+		return nil, false, nil
+	}
+	if err := validateHeaderParameters(map[any]any{label: value}, protected); err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// headerBytes returns the value of a bstr header parameter.
+func headerBytes(h map[any]any, protected bool, label int64) ([]byte, error) {
+	value, ok, err := headerParameter(h, protected, label)
+	if !ok {
+		return nil, err
+	}
+	return value.([]byte), nil
+}
+
+// headerTstrOrUint returns the value of a "tstr / uint" header parameter as a
+// string or an uint64.
+func headerTstrOrUint(h map[any]any, protected bool, label int64) (any, error) {
+	value, ok, err := headerParameter(h, protected, label)
+	if !ok {
+		return nil, err
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	return reflect.ValueOf(value).Convert(reflect.TypeOf(uint64(0))).Interface(), nil
+}
+
+// setHeaderParameter sets the header parameter with the given label after
+// validating it against the rules of validateHeaderParameters.
+func setHeaderParameter(h map[any]any, protected bool, label int64, value any) error {
+	candidate := map[any]any{label: value}
+	switch label {
+	case HeaderLabelIV:
+		if piv, ok := h[HeaderLabelPartialIV]; ok {
+			candidate[HeaderLabelPartialIV] = piv
+		}
+	case HeaderLabelPartialIV:
+		if iv, ok := h[HeaderLabelIV]; ok {
+			candidate[HeaderLabelIV] = iv
+		}
+	}
+	if err := validateHeaderParameters(candidate, protected); err != nil {
+		return err
+	}
+	h[label] = value
+	return nil
+}
+
 // validateHeaderParameters validates all headers conform to the spec.
 func validateHeaderParameters(h map[any]any, protected bool) error {
 	existing := make(map[any]struct{}, len(h))
@@ -628,6 +1176,57 @@ func validateHeaderParameters(h map[any]any, protected bool) error {
 			if !canBstr(value) {
 				return errors.New("header parameter: Countersignature0 version 2: require bstr type")
 			}
+		case HeaderLabelKCWT:
+			// tagged CWTs can not be decoded from the unprotected header
+			if !protected {
+				return errors.New("header parameter: kcwt: not allowed")
+			}
+			switch value.(type) {
+			case []any, cbor.Tag, cbor.RawMessage:
+			default:
+				return errors.New("header parameter: kcwt: require COSE_Messages type")
+			}
+		case HeaderLabelKCCS:
+			claims, ok := value.(map[any]any)
+			if c, isClaims := value.(CWTClaims); isClaims {
+				claims, ok = c, true
+			}
+			if !ok {
+				return errors.New("header parameter: kccs: require map type")
+			}
+			if _, err := CWTClaims(claims).ClaimsSet(); err != nil {
+				return fmt.Errorf("header parameter: kccs: %w", err)
+			}
+		case HeaderLabelSDClaims:
+			if protected {
+				return errors.New("header parameter: sd_claims: not allowed")
+			}
+			if _, err := sdDisclosures(value); err != nil {
+				return fmt.Errorf("header parameter: sd_claims: %w", err)
+			}
+		case HeaderLabelSDAlg:
+			if _, err := algorithmValue(value); err != nil {
+				return errors.New("header parameter: sd_alg: require int type")
+			}
+		case HeaderLabelX5Bag, HeaderLabelX5Chain, HeaderLabelC5B, HeaderLabelC5C:
+			if _, err := coseX509(value); err != nil {
+				return fmt.Errorf("header parameter: %s: %w", headerLabelName(label), err)
+			}
+		case HeaderLabelX5U, HeaderLabelC5U:
+			if !canTstr(value) {
+				return fmt.Errorf("header parameter: %s: require tstr type", headerLabelName(label))
+			}
+		case HeaderLabelX5T, HeaderLabelC5T:
+			if _, err := coseCertHash(value); err != nil && !errors.Is(err, ErrAlgorithmNotSupported) {
+				return fmt.Errorf("header parameter: %s: %w", headerLabelName(label), err)
+			}
+		default:
+			// Validate the parameters registered by the application.
+			if p := lookupHeaderParameter(label); p != nil {
+				if err := p.validate(value, protected); err != nil {
+					return err
+				}
+			}
 		}
 	}
 	return nil
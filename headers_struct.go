@@ -0,0 +1,402 @@
+package cose
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Struct tags for typed headers.
+//
+// EncodeProtectedHeader, EncodeUnprotectedHeader, ProtectedHeader.Decode and
+// UnprotectedHeader.Decode convert between header maps and Go structs whose
+// fields are tagged with the "cose" key. The format of the tag is
+//
+//	cose:"<label>[,<type>][,omitempty]"
+//
+// where <label> is an integer label, e.g. "4", or a text label, e.g. "foo",
+// and <type> optionally constrains the CBOR type of the value:
+//
+//	bstr  byte string, for []byte fields
+//	tstr  text string, for string fields
+//	int   integer, for integer fields
+//	uint  unsigned integer, for integer fields
+//
+// Fields without type are encoded with the CBOR encoder, and decoded by
+// re-encoding the header value and decoding it into the field, so that any
+// type supported by the CBOR library can be used.
+//
+// With omitempty, the field is omitted from the header if it has a zero value.
+// Nil pointers, slices and maps are always omitted.
+//
+// A field of type map[any]any, ProtectedHeader or UnprotectedHeader tagged
+// with cose:",unknown" captures the header parameters without a
+// corresponding field when decoding, and adds its entries to the header when
+// encoding.
+//
+// Fields without "cose" tag, tagged with cose:"-", or unexported are
+// ignored. Embedded structs are not flattened.
+//
+// Encoded and decoded headers are checked with the same rules as header maps,
+// e.g. a kid must be a bstr and crit is only allowed in protected headers.
+//
+// For example:
+//
+//	type ProfileHeader struct {
+//	    Algorithm cose.Algorithm `cose:"1,int"`
+//	    KeyID     []byte         `cose:"4,bstr,omitempty"`
+//	    Nonce     []byte         `cose:"-65537,bstr"`
+//	    Issuer    string         `cose:"issuer,tstr,omitempty"`
+//	    Unknown   map[any]any    `cose:",unknown"`
+//	}
+
+// headerField describes a struct field mapped to a header parameter.
+type headerField struct {
+	index     int
+	name      string
+	label     any
+	typ       string
+	omitEmpty bool
+}
+
+// headerStructFields parses the "cose" tags of the fields of struct type t.
+// It returns the mapped fields and the index of the field capturing unknown
+// labels, or -1 if there is none.
+func headerStructFields(t reflect.Type) ([]headerField, int, error) {
+	var fields []headerField
+	unknown := -1
+	labels := make(map[any]string)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, ok := f.Tag.Lookup("cose")
+		if !ok || tag == "-" || !f.IsExported() {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		if parts[0] == "" {
+			if len(parts) != 2 || parts[1] != "unknown" {
+				return nil, 0, fmt.Errorf("cose: field %s: missing label", f.Name)
+			}
+			if f.Type.Kind() != reflect.Map || f.Type.Key().Kind() != reflect.Interface || f.Type.Elem().Kind() != reflect.Interface {
+				return nil, 0, fmt.Errorf("cose: field %s: require map[any]any type for unknown labels", f.Name)
+			}
+			if unknown >= 0 {
+				return nil, 0, fmt.Errorf("cose: field %s: duplicated unknown labels field", f.Name)
+			}
+			unknown = i
+			continue
+		}
+
+		field := headerField{
+			index: i,
+			name:  f.Name,
+			label: parts[0],
+		}
+		if label, err := strconv.ParseInt(parts[0], 10, 64); err == nil {
+			field.label = label
+		}
+		for _, opt := range parts[1:] {
+			switch opt {
+			case "omitempty":
+				field.omitEmpty = true
+			case "bstr", "tstr", "int", "uint":
+				if field.typ != "" {
+					return nil, 0, fmt.Errorf("cose: field %s: duplicated type", f.Name)
+				}
+				field.typ = opt
+			default:
+				return nil, 0, fmt.Errorf("cose: field %s: unknown tag option %q", f.Name, opt)
+			}
+		}
+		if err := checkHeaderFieldType(f.Type, field.typ); err != nil {
+			return nil, 0, fmt.Errorf("cose: field %s: %w", f.Name, err)
+		}
+		if other, ok := labels[field.label]; ok {
+			return nil, 0, fmt.Errorf("cose: field %s: label %v already used by field %s", f.Name, field.label, other)
+		}
+		labels[field.label] = f.Name
+		fields = append(fields, field)
+	}
+	return fields, unknown, nil
+}
+
+// checkHeaderFieldType checks that a field of type t can hold values of the
+// CBOR type typ.
+func checkHeaderFieldType(t reflect.Type, typ string) error {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch typ {
+	case "bstr":
+		if t.Kind() != reflect.Slice || t.Elem().Kind() != reflect.Uint8 {
+			return errors.New("bstr requires []byte type")
+		}
+	case "tstr":
+		if t.Kind() != reflect.String {
+			return errors.New("tstr requires string type")
+		}
+	case "int", "uint":
+		if t.Kind() < reflect.Int || t.Kind() > reflect.Uint64 {
+			return fmt.Errorf("%s requires integer type", typ)
+		}
+	}
+	return nil
+}
+
+// structValue returns the struct value v points to.
+func structValue(v any) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return reflect.Value{}, errors.New("cose: nil struct pointer")
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("cose: require struct type, got %T", v)
+	}
+	return rv, nil
+}
+
+// EncodeProtectedHeader encodes a struct with "cose" tagged fields into a
+// protected header.
+// The resulting header is checked as any other protected header.
+func EncodeProtectedHeader(v any) (ProtectedHeader, error) {
+	h, err := encodeHeaderStruct(v)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateHeaderParameters(h, true); err != nil {
+		return nil, fmt.Errorf("protected header: %w", err)
+	}
+	return h, nil
+}
+
+// EncodeUnprotectedHeader encodes a struct with "cose" tagged fields into an
+// unprotected header.
+// The resulting header is checked as any other unprotected header.
+func EncodeUnprotectedHeader(v any) (UnprotectedHeader, error) {
+	h, err := encodeHeaderStruct(v)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateHeaderParameters(h, false); err != nil {
+		return nil, fmt.Errorf("unprotected header: %w", err)
+	}
+	return h, nil
+}
+
+// Decode decodes the protected header into a struct with "cose" tagged
+// fields. v must be a non-nil pointer to a struct.
+// The header is checked as any other protected header prior to decoding.
+func (h ProtectedHeader) Decode(v any) error {
+	if err := validateHeaderParameters(h, true); err != nil {
+		return fmt.Errorf("protected header: %w", err)
+	}
+	return decodeHeaderStruct(h, v)
+}
+
+// Decode decodes the unprotected header into a struct with "cose" tagged
+// fields. v must be a non-nil pointer to a struct.
+// The header is checked as any other unprotected header prior to decoding.
+func (h UnprotectedHeader) Decode(v any) error {
+	if err := validateHeaderParameters(h, false); err != nil {
+		return fmt.Errorf("unprotected header: %w", err)
+	}
+	return decodeHeaderStruct(h, v)
+}
+
+// encodeHeaderStruct encodes a struct with "cose" tagged fields into a header
+// map.
+func encodeHeaderStruct(v any) (map[any]any, error) {
+	rv, err := structValue(v)
+	if err != nil {
+		return nil, err
+	}
+	fields, unknown, err := headerStructFields(rv.Type())
+	if err != nil {
+		return nil, err
+	}
+
+	h := make(map[any]any, len(fields))
+	for _, field := range fields {
+		fv := rv.Field(field.index)
+		if field.omitEmpty && fv.IsZero() {
+			continue
+		}
+		switch fv.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Map, reflect.Interface:
+			if fv.IsNil() {
+				continue
+			}
+		}
+		if fv.Kind() == reflect.Pointer {
+			fv = fv.Elem()
+		}
+		value, err := encodeHeaderField(fv, field.typ)
+		if err != nil {
+			return nil, fmt.Errorf("cose: field %s: %w", field.name, err)
+		}
+		h[field.label] = value
+	}
+	if unknown >= 0 {
+		iter := rv.Field(unknown).MapRange()
+		for iter.Next() {
+			label, ok := normalizeLabel(iter.Key().Interface())
+			if !ok {
+				return nil, errors.New("header label: require int / tstr type")
+			}
+			if _, ok := h[label]; ok {
+				return nil, fmt.Errorf("header label: duplicated label: %v", label)
+			}
+			h[label] = iter.Value().Interface()
+		}
+	}
+	return h, nil
+}
+
+// encodeHeaderField converts a field value into a header value of the CBOR
+// type typ.
+func encodeHeaderField(fv reflect.Value, typ string) (any, error) {
+	switch typ {
+	case "bstr":
+		return fv.Bytes(), nil
+	case "tstr":
+		return fv.String(), nil
+	case "int", "uint":
+		if fv.CanUint() {
+			u := fv.Uint()
+			if u > math.MaxInt64 {
+				return u, nil
+			}
+			return int64(u), nil
+		}
+		i := fv.Int()
+		if typ == "uint" && i < 0 {
+			return nil, errors.New("uint requires non-negative value")
+		}
+		return i, nil
+	}
+	return fv.Interface(), nil
+}
+
+// decodeHeaderStruct decodes a header map into a struct with "cose" tagged
+// fields.
+func decodeHeaderStruct(h map[any]any, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer {
+		return fmt.Errorf("cose: require struct pointer, got %T", v)
+	}
+	rv, err := structValue(v)
+	if err != nil {
+		return err
+	}
+	fields, unknown, err := headerStructFields(rv.Type())
+	if err != nil {
+		return err
+	}
+
+	// index the values by normalized label, as labels may be of any integer
+	// type; duplicated labels are rejected when the header is validated
+	values := make(map[any]any, len(h))
+	for label, value := range h {
+		if label, ok := normalizeLabel(label); ok {
+			values[label] = value
+		}
+	}
+	known := make(map[any]struct{}, len(fields))
+	for _, field := range fields {
+		known[field.label] = struct{}{}
+		value, ok := values[field.label]
+		if !ok {
+			continue
+		}
+		fv := rv.Field(field.index)
+		if fv.Kind() == reflect.Pointer {
+			fv.Set(reflect.New(fv.Type().Elem()))
+			fv = fv.Elem()
+		}
+		if err := decodeHeaderField(fv, field.typ, value); err != nil {
+			return fmt.Errorf("cose: field %s: %w", field.name, err)
+		}
+	}
+	if unknown >= 0 {
+		fv := rv.Field(unknown)
+		captured := reflect.MakeMap(fv.Type())
+		for label, value := range h {
+			if normalized, ok := normalizeLabel(label); ok {
+				if _, ok := known[normalized]; ok {
+					continue
+				}
+			}
+			rv := reflect.Zero(fv.Type().Elem())
+			if value != nil {
+				rv = reflect.ValueOf(value)
+			}
+			captured.SetMapIndex(reflect.ValueOf(label), rv)
+		}
+		if captured.Len() > 0 {
+			fv.Set(captured)
+		}
+	}
+	return nil
+}
+
+// decodeHeaderField converts a header value of the CBOR type typ into a field
+// value.
+func decodeHeaderField(fv reflect.Value, typ string, value any) error {
+	switch typ {
+	case "bstr":
+		b, ok := value.([]byte)
+		if !ok {
+			return errors.New("require bstr type")
+		}
+		fv.SetBytes(b)
+		return nil
+	case "tstr":
+		s, ok := value.(string)
+		if !ok {
+			return errors.New("require tstr type")
+		}
+		fv.SetString(s)
+		return nil
+	case "int", "uint":
+		n := reflect.ValueOf(value)
+		if !n.CanInt() && !n.CanUint() || (typ == "uint" && n.CanInt() && n.Int() < 0) {
+			return fmt.Errorf("require %s type", typ)
+		}
+		if fv.CanUint() {
+			if n.CanInt() && n.Int() < 0 {
+				return fmt.Errorf("value %v overflows %v", value, fv.Type())
+			}
+			u := n.Convert(reflect.TypeOf(uint64(0))).Uint()
+			if fv.OverflowUint(u) {
+				return fmt.Errorf("value %v overflows %v", value, fv.Type())
+			}
+			fv.SetUint(u)
+			return nil
+		}
+		if n.CanUint() && n.Uint() > math.MaxInt64 {
+			return fmt.Errorf("value %v overflows %v", value, fv.Type())
+		}
+		i := n.Convert(reflect.TypeOf(int64(0))).Int()
+		if fv.OverflowInt(i) {
+			return fmt.Errorf("value %v overflows %v", value, fv.Type())
+		}
+		fv.SetInt(i)
+		return nil
+	}
+
+	if rv := reflect.ValueOf(value); rv.IsValid() && rv.Type().AssignableTo(fv.Type()) {
+		fv.Set(rv)
+		return nil
+	}
+	data, err := encMode.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return decMode.Unmarshal(data, fv.Addr().Interface())
+}
@@ -0,0 +1,267 @@
+package cose
+
+import (
+	"reflect"
+	"testing"
+)
+
+type testProfileHeader struct {
+	Algorithm   Algorithm   `cose:"1,int"`
+	ContentType any         `cose:"3,omitempty"`
+	KeyID       []byte      `cose:"4,bstr,omitempty"`
+	Nonce       []byte      `cose:"-65537,bstr"`
+	Issuer      string      `cose:"issuer,tstr,omitempty"`
+	Version     *uint8      `cose:"-65538,uint"`
+	Claims      CWTClaims   `cose:"15,omitempty"`
+	Unknown     map[any]any `cose:",unknown"`
+	Ignored     string
+	Skipped     string `cose:"-"`
+}
+
+func TestEncodeProtectedHeader(t *testing.T) {
+	version := uint8(2)
+	tests := []struct {
+		name    string
+		v       any
+		want    ProtectedHeader
+		wantErr string
+	}{
+		{
+			name: "typed header",
+			v: &testProfileHeader{
+				Algorithm: AlgorithmES256,
+				KeyID:     []byte("11"),
+				Nonce:     []byte{0x01},
+				Issuer:    "issuer.example",
+				Version:   &version,
+				Unknown: map[any]any{
+					int64(-65539): "extra",
+				},
+				Ignored: "ignored",
+				Skipped: "skipped",
+			},
+			want: ProtectedHeader{
+				HeaderLabelAlgorithm: int64(-7),
+				HeaderLabelKeyID:     []byte("11"),
+				int64(-65537):        []byte{0x01},
+				"issuer":             "issuer.example",
+				int64(-65538):        int64(2),
+				int64(-65539):        "extra",
+			},
+		},
+		{
+			name: "omitted fields",
+			v: testProfileHeader{
+				Algorithm: AlgorithmEdDSA,
+			},
+			want: ProtectedHeader{
+				HeaderLabelAlgorithm: int64(-8),
+			},
+		},
+		{
+			name: "invalid content type",
+			v: testProfileHeader{
+				Algorithm:   AlgorithmES256,
+				ContentType: "text",
+			},
+			wantErr: "protected header: header parameter: content type: require text of form type/subtype",
+		},
+		{
+			name: "duplicated unknown label",
+			v: testProfileHeader{
+				Unknown: map[any]any{1: -7},
+			},
+			wantErr: "header label: duplicated label: 1",
+		},
+		{
+			name: "crit",
+			v: struct {
+				Critical []any `cose:"2"`
+				Foo      int   `cose:"foo,int"`
+			}{
+				Critical: []any{"foo"},
+				Foo:      1,
+			},
+			want: ProtectedHeader{
+				HeaderLabelCritical: []any{"foo"},
+				"foo":               int64(1),
+			},
+		},
+		{
+			name: "type mismatch",
+			v: struct {
+				KeyID string `cose:"4,bstr"`
+			}{},
+			wantErr: "cose: field KeyID: bstr requires []byte type",
+		},
+		{
+			name: "duplicated label",
+			v: struct {
+				A int `cose:"7"`
+				B int `cose:"7"`
+			}{},
+			wantErr: "cose: field B: label 7 already used by field A",
+		},
+		{
+			name: "unknown option",
+			v: struct {
+				A int `cose:"7,float"`
+			}{},
+			wantErr: `cose: field A: unknown tag option "float"`,
+		},
+		{
+			name:    "not a struct",
+			v:       map[any]any{},
+			wantErr: "cose: require struct type, got map[interface {}]interface {}",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := EncodeProtectedHeader(tt.v)
+			if err != nil && (err.Error() != tt.wantErr) {
+				t.Errorf("EncodeProtectedHeader() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			} else if err == nil && tt.wantErr != "" {
+				t.Errorf("EncodeProtectedHeader() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("EncodeProtectedHeader() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeUnprotectedHeader(t *testing.T) {
+	_, err := EncodeUnprotectedHeader(struct {
+		Critical []any `cose:"2"`
+	}{
+		Critical: []any{int64(1)},
+	})
+	wantErr := "unprotected header: header parameter: crit: not allowed"
+	if err == nil || err.Error() != wantErr {
+		t.Errorf("EncodeUnprotectedHeader() error = %v, wantErr %v", err, wantErr)
+	}
+}
+
+func TestProtectedHeader_Decode(t *testing.T) {
+	version := uint8(2)
+	tests := []struct {
+		name    string
+		data    []byte
+		want    testProfileHeader
+		wantErr string
+	}{
+		{
+			name: "typed header",
+			data: mustTestCBOR(t, `<<{1: -7, 3: 60, 4: h'3131', 15: {1: "iss"}, -65537: h'01', -65538: 2, -65539: "extra", "issuer": "issuer.example"}>>`, ""),
+			want: testProfileHeader{
+				Algorithm:   AlgorithmES256,
+				ContentType: int64(60),
+				KeyID:       []byte("11"),
+				Nonce:       []byte{0x01},
+				Issuer:      "issuer.example",
+				Version:     &version,
+				Claims:      CWTClaims{int64(1): "iss"},
+				Unknown: map[any]any{
+					int64(-65539): "extra",
+				},
+			},
+		},
+		{
+			name:    "invalid bstr",
+			data:    mustTestCBOR(t, `<<{1: -7, -65537: "01"}>>`, ""),
+			wantErr: "cose: field Nonce: require bstr type",
+		},
+		{
+			name:    "negative uint",
+			data:    mustTestCBOR(t, `<<{1: -7, -65538: -1}>>`, ""),
+			wantErr: "cose: field Version: require uint type",
+		},
+		{
+			name:    "uint overflow",
+			data:    mustTestCBOR(t, `<<{1: -7, -65538: 256}>>`, ""),
+			wantErr: "cose: field Version: value 256 overflows uint8",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var h ProtectedHeader
+			if err := h.UnmarshalCBOR(tt.data); err != nil {
+				t.Fatalf("ProtectedHeader.UnmarshalCBOR() error = %v", err)
+			}
+			var got testProfileHeader
+			err := h.Decode(&got)
+			if err != nil && (err.Error() != tt.wantErr) {
+				t.Errorf("ProtectedHeader.Decode() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			} else if err == nil && tt.wantErr != "" {
+				t.Errorf("ProtectedHeader.Decode() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr != "" {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ProtectedHeader.Decode() = %+v, want %+v", got, tt.want)
+			}
+
+			// encode back
+			encoded, err := EncodeProtectedHeader(&got)
+			if err != nil {
+				t.Fatalf("EncodeProtectedHeader() error = %v", err)
+			}
+			want, err := h.MarshalCBOR()
+			if err != nil {
+				t.Fatalf("ProtectedHeader.MarshalCBOR() error = %v", err)
+			}
+			gotCBOR, err := encoded.MarshalCBOR()
+			if err != nil {
+				t.Fatalf("ProtectedHeader.MarshalCBOR() error = %v", err)
+			}
+			if !reflect.DeepEqual(gotCBOR, want) {
+				t.Errorf("EncodeProtectedHeader() = %x, want %x", gotCBOR, want)
+			}
+		})
+	}
+}
+
+func TestUnprotectedHeader_Decode(t *testing.T) {
+	h := UnprotectedHeader{
+		HeaderLabelKeyID: []byte("11"),
+		int64(-65537):    []byte{0x01},
+	}
+	var got struct {
+		KeyID []byte `cose:"4,bstr"`
+		Nonce []byte `cose:"-65537"`
+	}
+	if err := h.Decode(&got); err != nil {
+		t.Fatalf("UnprotectedHeader.Decode() error = %v", err)
+	}
+	if string(got.KeyID) != "11" || len(got.Nonce) != 1 {
+		t.Errorf("UnprotectedHeader.Decode() = %+v", got)
+	}
+
+	// labels of other integer types
+	got.KeyID, got.Nonce = nil, nil
+	if err := (UnprotectedHeader{
+		4:      []byte("22"),
+		-65537: []byte{0x02},
+	}).Decode(&got); err != nil {
+		t.Fatalf("UnprotectedHeader.Decode() error = %v", err)
+	}
+	if string(got.KeyID) != "22" || len(got.Nonce) != 1 || got.Nonce[0] != 0x02 {
+		t.Errorf("UnprotectedHeader.Decode() = %+v", got)
+	}
+
+	h[HeaderLabelKeyID] = "11"
+	wantErr := "unprotected header: header parameter: kid: require bstr type"
+	if err := h.Decode(&got); err == nil || err.Error() != wantErr {
+		t.Errorf("UnprotectedHeader.Decode() error = %v, wantErr %v", err, wantErr)
+	}
+	wantErr = "cose: require struct pointer, got struct { KeyID []uint8 \"cose:\\\"4,bstr\\\"\"; Nonce []uint8 \"cose:\\\"-65537\\\"\" }"
+	delete(h, HeaderLabelKeyID)
+	if err := h.Decode(got); err == nil || err.Error() != wantErr {
+		t.Errorf("UnprotectedHeader.Decode() error = %v, wantErr %v", err, wantErr)
+	}
+}
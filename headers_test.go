@@ -1,6 +1,7 @@
 package cose
 
 import (
+	"bytes"
 	"errors"
 	"math"
 	"reflect"
@@ -81,8 +82,8 @@ func TestProtectedHeader_MarshalCBOR(t *testing.T) {
 			name: "various types of integer label",
 			h: ProtectedHeader{
 				uint(10):   0,
-				uint8(13):  0,
-				uint16(14): 0,
+				uint8(19):  0,
+				uint16(20): 0,
 				uint32(15): 0,
 				uint64(16): 0,
 				int(-1):    0,
@@ -95,10 +96,10 @@ func TestProtectedHeader_MarshalCBOR(t *testing.T) {
 				0x55, // bstr
 				0xaa, // map
 				0x0a, 0x00,
-				0x0d, 0x00,
-				0x0e, 0x00,
 				0x0f, 0x00,
 				0x10, 0x00,
+				0x13, 0x00,
+				0x14, 0x00,
 				0x20, 0x00,
 				0x21, 0x00,
 				0x22, 0x00,
@@ -747,8 +748,8 @@ func TestUnprotectedHeader_MarshalCBOR(t *testing.T) {
 			name: "various types of integer label",
 			h: UnprotectedHeader{
 				uint(10):   0,
-				uint8(13):  0,
-				uint16(14): 0,
+				uint8(19):  0,
+				uint16(20): 0,
 				uint32(15): 0,
 				uint64(16): 0,
 				int(-1):    0,
@@ -760,10 +761,10 @@ func TestUnprotectedHeader_MarshalCBOR(t *testing.T) {
 			want: []byte{
 				0xaa, // map
 				0x0a, 0x00,
-				0x0d, 0x00,
-				0x0e, 0x00,
 				0x0f, 0x00,
 				0x10, 0x00,
+				0x13, 0x00,
+				0x14, 0x00,
 				0x20, 0x00,
 				0x21, 0x00,
 				0x22, 0x00,
@@ -1468,3 +1469,272 @@ func TestHeaders_UnmarshalFromRaw(t *testing.T) {
 		})
 	}
 }
+
+func TestHeaders_KeyID(t *testing.T) {
+	tests := []struct {
+		name    string
+		h       Headers
+		want    []byte
+		wantErr string
+	}{
+		{
+			name: "absent",
+			h:    Headers{},
+		},
+		{
+			name: "protected",
+			h: Headers{
+				Protected: ProtectedHeader{HeaderLabelKeyID: []byte("11")},
+			},
+			want: []byte("11"),
+		},
+		{
+			name: "unprotected",
+			h: Headers{
+				Unprotected: UnprotectedHeader{HeaderLabelKeyID: []byte("11")},
+			},
+			want: []byte("11"),
+		},
+		{
+			name: "both buckets",
+			h: Headers{
+				Protected:   ProtectedHeader{HeaderLabelKeyID: []byte("11")},
+				Unprotected: UnprotectedHeader{HeaderLabelKeyID: []byte("22")},
+			},
+			wantErr: "header parameter: kid: header parameter present in both protected and unprotected headers",
+		},
+		{
+			name: "invalid type",
+			h: Headers{
+				Unprotected: UnprotectedHeader{HeaderLabelKeyID: "11"},
+			},
+			wantErr: "header parameter: kid: require bstr type",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.h.KeyID()
+			if err != nil && (err.Error() != tt.wantErr) {
+				t.Errorf("Headers.KeyID() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			} else if err == nil && tt.wantErr != "" {
+				t.Errorf("Headers.KeyID() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("Headers.KeyID() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeaders_ContentType(t *testing.T) {
+	tests := []struct {
+		name    string
+		h       Headers
+		want    any
+		wantErr string
+	}{
+		{
+			name: "absent",
+			h:    Headers{},
+		},
+		{
+			name: "media type",
+			h: Headers{
+				Protected: ProtectedHeader{HeaderLabelContentType: "text/plain"},
+			},
+			want: "text/plain",
+		},
+		{
+			name: "int64 content format",
+			h: Headers{
+				Protected: ProtectedHeader{HeaderLabelContentType: int64(60)},
+			},
+			want: uint64(60),
+		},
+		{
+			name: "uint16 content format",
+			h: Headers{
+				Unprotected: UnprotectedHeader{HeaderLabelContentType: uint16(60)},
+			},
+			want: uint64(60),
+		},
+		{
+			name: "negative content format",
+			h: Headers{
+				Protected: ProtectedHeader{HeaderLabelContentType: int64(-1)},
+			},
+			wantErr: "header parameter: content type: require tstr / uint type",
+		},
+		{
+			name: "both buckets",
+			h: Headers{
+				Protected:   ProtectedHeader{HeaderLabelContentType: "text/plain"},
+				Unprotected: UnprotectedHeader{HeaderLabelContentType: "text/plain"},
+			},
+			wantErr: "header parameter: content type: header parameter present in both protected and unprotected headers",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.h.ContentType()
+			if err != nil && (err.Error() != tt.wantErr) {
+				t.Errorf("Headers.ContentType() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			} else if err == nil && tt.wantErr != "" {
+				t.Errorf("Headers.ContentType() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Headers.ContentType() = %v (%T), want %v (%T)", got, got, tt.want, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeaders_Type(t *testing.T) {
+	h := Headers{}
+	if err := h.SetType("application/cwt"); err != nil {
+		t.Fatalf("Headers.SetType() error = %v", err)
+	}
+	if got, err := h.Type(); err != nil || got != "application/cwt" {
+		t.Errorf("Headers.Type() = %v, %v, want application/cwt", got, err)
+	}
+	if got, err := h.Protected.Type(); err != nil || got != "application/cwt" {
+		t.Errorf("ProtectedHeader.Type() = %v, %v, want application/cwt", got, err)
+	}
+	wantErr := "header parameter: type: require text of form type/subtype"
+	if err := h.SetType("cwt"); err == nil || err.Error() != wantErr {
+		t.Errorf("Headers.SetType() error = %v, wantErr %v", err, wantErr)
+	}
+
+	h.Unprotected = UnprotectedHeader{}
+	if err := h.Unprotected.SetType(uint(61)); err != nil {
+		t.Fatalf("UnprotectedHeader.SetType() error = %v", err)
+	}
+	if got, err := h.Unprotected.Type(); err != nil || got != uint64(61) {
+		t.Errorf("UnprotectedHeader.Type() = %v, %v, want 61", got, err)
+	}
+	if _, err := h.Type(); !errors.Is(err, ErrDuplicateHeaderParameter) {
+		t.Errorf("Headers.Type() error = %v, want %v", err, ErrDuplicateHeaderParameter)
+	}
+	if err := h.SetType("application/cwt"); !errors.Is(err, ErrDuplicateHeaderParameter) {
+		t.Errorf("Headers.SetType() error = %v, want %v", err, ErrDuplicateHeaderParameter)
+	}
+}
+
+func TestHeaders_SetIV(t *testing.T) {
+	tests := []struct {
+		name    string
+		h       Headers
+		set     func(h *Headers) error
+		wantErr string
+	}{
+		{
+			name: "IV",
+			set: func(h *Headers) error {
+				return h.SetIV([]byte{0x01})
+			},
+		},
+		{
+			name: "Partial IV",
+			set: func(h *Headers) error {
+				return h.SetPartialIV([]byte{0x01})
+			},
+		},
+		{
+			name: "IV with protected Partial IV",
+			h: Headers{
+				Protected: ProtectedHeader{HeaderLabelPartialIV: []byte{0x01}},
+			},
+			set: func(h *Headers) error {
+				return h.SetIV([]byte{0x01})
+			},
+			wantErr: "header parameter: IV and PartialIV: parameters must not both be present",
+		},
+		{
+			name: "IV with unprotected Partial IV",
+			h: Headers{
+				Unprotected: UnprotectedHeader{HeaderLabelPartialIV: []byte{0x01}},
+			},
+			set: func(h *Headers) error {
+				return h.SetIV([]byte{0x01})
+			},
+			wantErr: "header parameter: IV and PartialIV: parameters must not both be present",
+		},
+		{
+			name: "Partial IV with protected IV",
+			h: Headers{
+				Protected: ProtectedHeader{HeaderLabelIV: []byte{0x01}},
+			},
+			set: func(h *Headers) error {
+				return h.SetPartialIV([]byte{0x01})
+			},
+			wantErr: "header parameter: IV and PartialIV: parameters must not both be present",
+		},
+		{
+			name: "protected Partial IV with protected IV",
+			h: Headers{
+				Protected: ProtectedHeader{HeaderLabelIV: []byte{0x01}},
+			},
+			set: func(h *Headers) error {
+				return h.Protected.SetPartialIV([]byte{0x01})
+			},
+			wantErr: "header parameter: IV and PartialIV: parameters must not both be present",
+		},
+		{
+			name: "IV in both buckets",
+			h: Headers{
+				Protected: ProtectedHeader{HeaderLabelIV: []byte{0x01}},
+			},
+			set: func(h *Headers) error {
+				return h.SetIV([]byte{0x01})
+			},
+			wantErr: "header parameter: IV: header parameter present in both protected and unprotected headers",
+		},
+		{
+			name: "key ID in both buckets",
+			h: Headers{
+				Protected: ProtectedHeader{HeaderLabelKeyID: []byte{0x01}},
+			},
+			set: func(h *Headers) error {
+				return h.SetKeyID([]byte{0x01})
+			},
+			wantErr: "header parameter: kid: header parameter present in both protected and unprotected headers",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.set(&tt.h)
+			if err != nil && (err.Error() != tt.wantErr) {
+				t.Errorf("set error = %v, wantErr %v", err, tt.wantErr)
+				return
+			} else if err == nil && tt.wantErr != "" {
+				t.Errorf("set error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			if err := validateHeaderParameters(tt.h.Unprotected, false); err != nil {
+				t.Errorf("validateHeaderParameters() error = %v", err)
+			}
+			if err := tt.h.ensureIV(); err != nil {
+				t.Errorf("Headers.ensureIV() error = %v", err)
+			}
+		})
+	}
+
+	// accessors round trip
+	var h Headers
+	if err := h.SetIV([]byte{0x01, 0x02}); err != nil {
+		t.Fatalf("Headers.SetIV() error = %v", err)
+	}
+	if got, err := h.IV(); err != nil || !bytes.Equal(got, []byte{0x01, 0x02}) {
+		t.Errorf("Headers.IV() = %v, %v, want [1 2]", got, err)
+	}
+	if got, err := h.PartialIV(); err != nil || got != nil {
+		t.Errorf("Headers.PartialIV() = %v, %v, want nil", got, err)
+	}
+}
@@ -0,0 +1,254 @@
+package cose
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// errIndefiniteLengthProtected is returned if an encoded protected header is
+// an indefinite-length byte string.
+var errIndefiniteLengthProtected = errors.New("cbor: protected header: indefinite-length byte string isn't allowed")
+
+// toDefiniteLength converts the indefinite-length items of an encoded
+// COSE_Sign1 or, if signatures is true, COSE_Sign object into definite-length
+// items, optionally preceded by a tag.
+//
+// Encoded protected headers of the message, its signatures and its
+// countersignatures are copied byte by byte. They must be definite-length
+// byte strings, and their content is later decoded with indefinite lengths
+// forbidden.
+func toDefiniteLength(data []byte, signatures bool) ([]byte, error) {
+	if err := decModeWithIndefLength.Wellformed(data); err != nil {
+		return nil, err
+	}
+	c := &definiteLengthConverter{
+		data: data,
+		out:  make([]byte, 0, len(data)),
+	}
+	if c.peek() == 6 { // major type 6: tag
+		c.copyHead()
+	}
+	if err := c.message(signatures); err != nil {
+		return nil, err
+	}
+	return c.out, nil
+}
+
+// definiteLengthConverter converts well-formed CBOR data into CBOR data
+// without indefinite-length items.
+type definiteLengthConverter struct {
+	data   []byte
+	offset int
+	out    []byte
+}
+
+// peek returns the major type of the next data item.
+func (c *definiteLengthConverter) peek() byte {
+	return c.data[c.offset] >> 5
+}
+
+// indefinite reports whether the next data item has an indefinite length.
+func (c *definiteLengthConverter) indefinite() bool {
+	return c.data[c.offset]&0x1f == 31
+}
+
+// head reads the head of the next data item without copying it.
+func (c *definiteLengthConverter) head() (byte, uint64) {
+	major := c.data[c.offset] >> 5
+	ai := c.data[c.offset] & 0x1f
+	c.offset++
+	switch ai {
+	case 24:
+		c.offset++
+		return major, uint64(c.data[c.offset-1])
+	case 25:
+		c.offset += 2
+		return major, uint64(binary.BigEndian.Uint16(c.data[c.offset-2:]))
+	case 26:
+		c.offset += 4
+		return major, uint64(binary.BigEndian.Uint32(c.data[c.offset-4:]))
+	case 27:
+		c.offset += 8
+		return major, binary.BigEndian.Uint64(c.data[c.offset-8:])
+	case 31:
+		return major, 0
+	}
+	return major, uint64(ai)
+}
+
+// copyHead copies the head of the next data item.
+func (c *definiteLengthConverter) copyHead() (byte, uint64) {
+	start := c.offset
+	major, arg := c.head()
+	c.out = append(c.out, c.data[start:c.offset]...)
+	return major, arg
+}
+
+// isBreak reports whether the next byte is the "break" stop code, consuming
+// it if so.
+func (c *definiteLengthConverter) isBreak() bool {
+	if c.data[c.offset] == 0xff {
+		c.offset++
+		return true
+	}
+	return false
+}
+
+// container converts an array or a map, converting its n items with item.
+func (c *definiteLengthConverter) container(item func(i int) error) error {
+	if !c.indefinite() {
+		major, n := c.copyHead()
+		if major == 5 { // major type 5: map
+			n *= 2
+		}
+		for i := uint64(0); i < n; i++ {
+			if err := item(int(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// convert the items first to learn their number
+	major, _ := c.head()
+	out := c.out
+	c.out = nil
+	n := 0
+	for ; !c.isBreak(); n++ {
+		if err := item(n); err != nil {
+			return err
+		}
+	}
+	items := c.out
+	if major == 5 { // major type 5: map
+		n /= 2
+	}
+	c.out = append(ednAppendHead(out, major, uint64(n), -1), items...)
+	return nil
+}
+
+// value converts the next data item.
+func (c *definiteLengthConverter) value() error {
+	switch major := c.peek(); major {
+	case 2, 3: // bstr, tstr
+		if !c.indefinite() {
+			_, n := c.copyHead()
+			c.out = append(c.out, c.data[c.offset:c.offset+int(n)]...)
+			c.offset += int(n)
+			return nil
+		}
+		c.head()
+		var content []byte
+		for !c.isBreak() {
+			_, n := c.head()
+			content = append(content, c.data[c.offset:c.offset+int(n)]...)
+			c.offset += int(n)
+		}
+		c.out = append(ednAppendHead(c.out, major, uint64(len(content)), -1), content...)
+		return nil
+	case 4, 5: // array, map
+		return c.container(func(int) error {
+			return c.value()
+		})
+	case 6: // tag
+		c.copyHead()
+		return c.value()
+	default:
+		c.copyHead()
+		return nil
+	}
+}
+
+// message converts a COSE_Sign1 or, if signatures is true, a COSE_Sign object.
+func (c *definiteLengthConverter) message(signatures bool) error {
+	if c.peek() != 4 {
+		return c.value()
+	}
+	return c.container(func(i int) error {
+		switch i {
+		case 0:
+			return c.protected()
+		case 1:
+			return c.unprotected()
+		case 3:
+			if signatures && c.peek() == 4 {
+				return c.container(func(int) error {
+					return c.signature()
+				})
+			}
+		}
+		return c.value()
+	})
+}
+
+// signature converts a COSE_Signature or COSE_Countersignature object.
+func (c *definiteLengthConverter) signature() error {
+	if c.peek() != 4 {
+		return c.value()
+	}
+	return c.container(func(i int) error {
+		switch i {
+		case 0:
+			return c.protected()
+		case 1:
+			return c.unprotected()
+		}
+		return c.value()
+	})
+}
+
+// protected copies an encoded protected header.
+func (c *definiteLengthConverter) protected() error {
+	if c.peek() == 2 && c.indefinite() {
+		return errIndefiniteLengthProtected
+	}
+	return c.value()
+}
+
+// unprotected converts a header map, including any countersignatures.
+func (c *definiteLengthConverter) unprotected() error {
+	if c.peek() != 5 {
+		return c.value()
+	}
+	var label any
+	return c.container(func(i int) error {
+		if i%2 == 0 {
+			start := len(c.out)
+			if err := c.value(); err != nil {
+				return err
+			}
+			label = nil
+			if err := decMode.Unmarshal(c.out[start:], &label); err != nil {
+				return err
+			}
+			return nil
+		}
+		switch label {
+		case HeaderLabelCounterSignature, HeaderLabelCounterSignatureV2:
+			return c.countersignatures()
+		}
+		return c.value()
+	})
+}
+
+// countersignatures converts a COSE_Countersignature object or an array of
+// COSE_Countersignature objects.
+func (c *definiteLengthConverter) countersignatures() error {
+	if c.peek() != 4 {
+		return c.value()
+	}
+
+	// look ahead for the type of the first item
+	offset := c.offset
+	indefinite := c.indefinite()
+	_, n := c.head()
+	nested := (indefinite || n > 0) && c.peek() == 4
+	c.offset = offset
+
+	if !nested {
+		return c.signature()
+	}
+	return c.container(func(int) error {
+		return c.signature()
+	})
+}
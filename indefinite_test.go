@@ -0,0 +1,177 @@
+package cose
+
+import (
+	"bytes"
+	"crypto/rand"
+	"strings"
+	"testing"
+)
+
+func TestCBOROptions_AllowIndefiniteLength(t *testing.T) {
+	opts := &CBOROptions{AllowIndefiniteLength: true}
+	tests := []struct {
+		name    string
+		edn     string
+		hex     string
+		untag   bool
+		sign    bool
+		want    string
+		wantErr string
+	}{
+		{
+			name: "indefinite-length message",
+			edn:  `18([_ <<{1: -7}>>, {_ 4: h'31', "foo": [_ 1, (_ "a", "b")]}, (_ h'68', h'656c6c6f'), (_ h'0102')])`,
+			want: `18([<<{1: -7}>>, {4: h'31', "foo": [1, "ab"]}, h'68656c6c6f', h'0102'])`,
+		},
+		{
+			name: "definite-length message",
+			edn:  `18([<<{1: -7}>>, {4: h'31'}, h'', h'0102'])`,
+			want: `18([<<{1: -7}>>, {4: h'31'}, h'', h'0102'])`,
+		},
+		{
+			name:  "untagged message",
+			edn:   `[_ <<{1: -7}>>, {_}, null, h'0102']`,
+			untag: true,
+			want:  `[<<{1: -7}>>, {}, null, h'0102']`,
+		},
+		{
+			name: "countersignatures",
+			edn:  `18([<<{1: -7}>>, {_ 11: [_ [_ <<{1: -7}>>, {_}, h'01'], [<<{1: -7}>>, {}, (_ h'02')]]}, h'', h'0102'])`,
+			want: `18([<<{1: -7}>>, {11: [[<<{1: -7}>>, {}, h'01'], [<<{1: -7}>>, {}, h'02']]}, h'', h'0102'])`,
+		},
+		{
+			name: "indefinite-length signatures",
+			edn:  `98([_ h'', {_}, h'', [_ [_ <<{1: -7}>>, {_ 4: h'31'}, (_ h'01', h'02')]]])`,
+			sign: true,
+			want: `98([h'', {}, h'', [[<<{1: -7}>>, {4: h'31'}, h'0102']]])`,
+		},
+		{
+			name:    "indefinite-length protected header",
+			edn:     `18([(_ h'a1', h'0126'), {}, h'', h'0102'])`,
+			wantErr: "cbor: protected header: indefinite-length byte string isn't allowed",
+		},
+		{
+			name:    "indefinite-length protected header content",
+			edn:     `18([<<{_ 1: -7}>>, {}, h'', h'0102'])`,
+			wantErr: "cbor: invalid protected header: cbor: indefinite-length map isn't allowed",
+		},
+		{
+			name:    "indefinite-length countersignature protected header",
+			edn:     `18([<<{1: -7}>>, {11: [(_ h'a10126'), {}, h'01']}, h'', h'0102'])`,
+			wantErr: "cbor: protected header: indefinite-length byte string isn't allowed",
+		},
+		{
+			name:    "indefinite-length signature protected header",
+			edn:     `98([h'', {}, h'', [[(_ h'a10126'), {}, h'01']]])`,
+			sign:    true,
+			wantErr: "cbor: protected header: indefinite-length byte string isn't allowed",
+		},
+		{
+			name:    "malformed message",
+			hex:     "d29f40a040420102", // missing break
+			wantErr: "unexpected EOF",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := mustTestCBOR(t, tt.edn, tt.hex)
+
+			var got []byte
+			var err error
+			switch {
+			case tt.sign:
+				var msg SignMessage
+				if err = msg.UnmarshalCBORWithOptions(data, opts); err == nil {
+					got, err = msg.MarshalCBOR()
+				}
+			case tt.untag:
+				var msg UntaggedSign1Message
+				if err = msg.UnmarshalCBORWithOptions(data, opts); err == nil {
+					got, err = msg.MarshalCBOR()
+				}
+			default:
+				var msg Sign1Message
+				if err = msg.UnmarshalCBORWithOptions(data, opts); err == nil {
+					got, err = msg.MarshalCBOR()
+				}
+			}
+			if err != nil && (err.Error() != tt.wantErr) {
+				t.Errorf("UnmarshalCBORWithOptions() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			} else if err == nil && (tt.wantErr != "") {
+				t.Errorf("UnmarshalCBORWithOptions() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr != "" {
+				return
+			}
+			want, err := ParseDiagnostic(tt.want)
+			if err != nil {
+				t.Fatalf("ParseDiagnostic() error = %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("MarshalCBOR() = %x, want %x", got, want)
+			}
+		})
+	}
+}
+
+func TestCBOROptions_AllowIndefiniteLength_verify(t *testing.T) {
+	alg := AlgorithmES256
+	key := generateTestECDSAKey(t)
+	signer, err := NewSigner(alg, key)
+	if err != nil {
+		t.Fatalf("NewSigner() error = %v", err)
+	}
+	verifier, err := NewVerifier(alg, key.Public())
+	if err != nil {
+		t.Fatalf("NewVerifier() error = %v", err)
+	}
+	msg := NewSign1Message()
+	msg.Headers.Protected.SetAlgorithm(alg)
+	msg.Headers.Unprotected[HeaderLabelKeyID] = []byte("11")
+	msg.Payload = []byte("hello world")
+	if err := msg.Sign(rand.Reader, nil, signer); err != nil {
+		t.Fatalf("Sign1Message.Sign() error = %v", err)
+	}
+	want, err := msg.MarshalCBOR()
+	if err != nil {
+		t.Fatalf("Sign1Message.MarshalCBOR() error = %v", err)
+	}
+
+	// stream the outer array and the unprotected header
+	edn, err := msg.Diagnose(nil)
+	if err != nil {
+		t.Fatalf("Sign1Message.Diagnose() error = %v", err)
+	}
+	edn = strings.Replace(edn, "18([", "18([_ ", 1)
+	edn = strings.Replace(edn, ">>, {", ">>, {_ ", 1)
+	data, err := ParseDiagnostic(edn)
+	if err != nil {
+		t.Fatalf("ParseDiagnostic() error = %v", err)
+	}
+
+	var got Sign1Message
+	if err := got.UnmarshalCBOR(data); err == nil {
+		t.Error("Sign1Message.UnmarshalCBOR() error = nil, want error")
+	}
+	if err := got.UnmarshalCBORWithOptions(data, &CBOROptions{
+		AllowIndefiniteLength: true,
+		Deterministic:         true,
+	}); err == nil {
+		t.Error("Sign1Message.UnmarshalCBORWithOptions() with Deterministic error = nil, want error")
+	}
+	if err := got.UnmarshalCBORWithOptions(data, &CBOROptions{AllowIndefiniteLength: true}); err != nil {
+		t.Fatalf("Sign1Message.UnmarshalCBORWithOptions() error = %v", err)
+	}
+	if err := got.Verify(nil, verifier); err != nil {
+		t.Errorf("Sign1Message.Verify() error = %v", err)
+	}
+	encoded, err := got.MarshalCBOR()
+	if err != nil {
+		t.Fatalf("Sign1Message.MarshalCBOR() error = %v", err)
+	}
+	if !bytes.Equal(encoded, want) {
+		t.Errorf("Sign1Message.MarshalCBOR() = %x, want %x", encoded, want)
+	}
+}
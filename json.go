@@ -0,0 +1,822 @@
+package cose
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// JSON representation of COSE objects.
+//
+// COSE structures are mapped to JSON objects whose members are named after the
+// fields of the structures:
+//
+//	Sign1Message = {
+//	    "protected": header_map,
+//	    ? "rawProtected": base64url,
+//	    "unprotected": header_map,
+//	    "payload": base64url / null,
+//	    "signature": base64url
+//	}
+//
+//	SignMessage = {
+//	    "protected": header_map,
+//	    ? "rawProtected": base64url,
+//	    "unprotected": header_map,
+//	    "payload": base64url / null,
+//	    "signatures": [* Signature]
+//	}
+//
+//	Signature = {
+//	    "protected": header_map,
+//	    ? "rawProtected": base64url,
+//	    "unprotected": header_map,
+//	    "signature": base64url
+//	}
+//
+// Header labels registered in the IANA "COSE Header Parameters" registry are
+// named, e.g. "alg" for label 1. Other integer labels are written in decimal.
+// Text labels are written as is, unless they could be confused with a named
+// or an integer label, in which case they are prefixed with "tstr:".
+//
+// The values of the kid, IV, Partial IV and abbreviated countersignature
+// headers are base64url strings, x5bag and x5chain are base64url strings or
+// arrays of base64url strings, and countersignatures are Signature objects or
+// arrays of Signature objects. Other values use a generic mapping:
+//
+//	value = int / tstr / bool / null / [* value] /
+//	        { "bstr": base64url } /
+//	        { "float": number / "NaN" / "Infinity" / "-Infinity" } /
+//	        { "map": [* [value, value]] } /
+//	        { "tag": uint, "value": value } /
+//	        { "simple": uint }
+//
+// rawProtected holds the encoded protected header if it is available, so that
+// signatures can be verified after a round trip through JSON. When decoding,
+// the protected header decoded from rawProtected must match the protected
+// header map.
+//
+// The mapping preserves values, not their encoding: floating-point numbers are
+// decoded as float64, and maps are encoded with sorted keys. Only the bytes of
+// rawProtected are kept as is. Unprotected headers, and protected headers
+// without rawProtected, are re-encoded from the decoded values, e.g. a float16
+// 1.5 is encoded as a float64.
+
+// jsonHeaderPrefixTstr prefixes text labels that could be confused with named
+// or integer labels.
+const jsonHeaderPrefixTstr = "tstr:"
+
+// jsonHeaders is the JSON representation of Headers.
+type jsonHeaders struct {
+	Protected    json.RawMessage `json:"protected"`
+	RawProtected *string         `json:"rawProtected,omitempty"`
+	Unprotected  json.RawMessage `json:"unprotected"`
+}
+
+// jsonSign1Message is the JSON representation of Sign1Message.
+type jsonSign1Message struct {
+	jsonHeaders
+	Payload   *string `json:"payload"`
+	Signature string  `json:"signature"`
+}
+
+// jsonSignature is the JSON representation of Signature.
+type jsonSignature struct {
+	jsonHeaders
+	Signature string `json:"signature"`
+}
+
+// jsonSignMessage is the JSON representation of SignMessage.
+type jsonSignMessage struct {
+	jsonHeaders
+	Payload    *string           `json:"payload"`
+	Signatures []json.RawMessage `json:"signatures"`
+}
+
+// MarshalJSON encodes Headers into a JSON object.
+func (h *Headers) MarshalJSON() ([]byte, error) {
+	jh, err := h.toJSON()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jh)
+}
+
+// UnmarshalJSON decodes a JSON object into Headers.
+func (h *Headers) UnmarshalJSON(data []byte) error {
+	if h == nil {
+		return errors.New("json: UnmarshalJSON on nil Headers pointer")
+	}
+	var jh jsonHeaders
+	if err := json.Unmarshal(data, &jh); err != nil {
+		return err
+	}
+	return h.fromJSON(&jh)
+}
+
+// MarshalJSON encodes Sign1Message into a JSON object.
+func (m *Sign1Message) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return nil, errors.New("json: MarshalJSON on nil Sign1Message pointer")
+	}
+	jh, err := m.Headers.toJSON()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonSign1Message{
+		jsonHeaders: *jh,
+		Payload:     jsonOptionalBytes(m.Payload),
+		Signature:   base64.RawURLEncoding.EncodeToString(m.Signature),
+	})
+}
+
+// UnmarshalJSON decodes a JSON object into Sign1Message.
+func (m *Sign1Message) UnmarshalJSON(data []byte) error {
+	if m == nil {
+		return errors.New("json: UnmarshalJSON on nil Sign1Message pointer")
+	}
+	var jm jsonSign1Message
+	if err := json.Unmarshal(data, &jm); err != nil {
+		return err
+	}
+	var msg Sign1Message
+	if err := msg.Headers.fromJSON(&jm.jsonHeaders); err != nil {
+		return err
+	}
+	var err error
+	if msg.Payload, err = jsonDecodeOptionalBytes(jm.Payload); err != nil {
+		return fmt.Errorf("json: payload: %w", err)
+	}
+	if msg.Signature, err = base64.RawURLEncoding.DecodeString(jm.Signature); err != nil {
+		return fmt.Errorf("json: signature: %w", err)
+	}
+	*m = msg
+	return nil
+}
+
+// MarshalJSON encodes Signature into a JSON object.
+//
+// # Experimental
+//
+// Notice: The COSE Sign API is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func (s *Signature) MarshalJSON() ([]byte, error) {
+	if s == nil {
+		return nil, errors.New("json: MarshalJSON on nil Signature pointer")
+	}
+	jh, err := s.Headers.toJSON()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonSignature{
+		jsonHeaders: *jh,
+		Signature:   base64.RawURLEncoding.EncodeToString(s.Signature),
+	})
+}
+
+// UnmarshalJSON decodes a JSON object into Signature.
+//
+// # Experimental
+//
+// Notice: The COSE Sign API is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func (s *Signature) UnmarshalJSON(data []byte) error {
+	if s == nil {
+		return errors.New("json: UnmarshalJSON on nil Signature pointer")
+	}
+	var js jsonSignature
+	if err := json.Unmarshal(data, &js); err != nil {
+		return err
+	}
+	var sig Signature
+	if err := sig.Headers.fromJSON(&js.jsonHeaders); err != nil {
+		return err
+	}
+	var err error
+	if sig.Signature, err = base64.RawURLEncoding.DecodeString(js.Signature); err != nil {
+		return fmt.Errorf("json: signature: %w", err)
+	}
+	*s = sig
+	return nil
+}
+
+// MarshalJSON encodes SignMessage into a JSON object.
+//
+// # Experimental
+//
+// Notice: The COSE Sign API is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func (m *SignMessage) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return nil, errors.New("json: MarshalJSON on nil SignMessage pointer")
+	}
+	jh, err := m.Headers.toJSON()
+	if err != nil {
+		return nil, err
+	}
+	signatures := make([]json.RawMessage, 0, len(m.Signatures))
+	for _, sig := range m.Signatures {
+		data, err := sig.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		signatures = append(signatures, data)
+	}
+	return json.Marshal(jsonSignMessage{
+		jsonHeaders: *jh,
+		Payload:     jsonOptionalBytes(m.Payload),
+		Signatures:  signatures,
+	})
+}
+
+// UnmarshalJSON decodes a JSON object into SignMessage.
+//
+// # Experimental
+//
+// Notice: The COSE Sign API is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func (m *SignMessage) UnmarshalJSON(data []byte) error {
+	if m == nil {
+		return errors.New("json: UnmarshalJSON on nil SignMessage pointer")
+	}
+	var jm jsonSignMessage
+	if err := json.Unmarshal(data, &jm); err != nil {
+		return err
+	}
+	var msg SignMessage
+	if err := msg.Headers.fromJSON(&jm.jsonHeaders); err != nil {
+		return err
+	}
+	var err error
+	if msg.Payload, err = jsonDecodeOptionalBytes(jm.Payload); err != nil {
+		return fmt.Errorf("json: payload: %w", err)
+	}
+	for _, data := range jm.Signatures {
+		sig := &Signature{}
+		if err := sig.UnmarshalJSON(data); err != nil {
+			return err
+		}
+		msg.Signatures = append(msg.Signatures, sig)
+	}
+	*m = msg
+	return nil
+}
+
+// toJSON converts the headers into their JSON representation.
+func (h *Headers) toJSON() (*jsonHeaders, error) {
+	protected := h.Protected
+	if len(h.RawProtected) > 0 && protected == nil {
+		if err := decodeWithOptions(h.RawProtected, &protected, nil); err != nil {
+			return nil, fmt.Errorf("json: protected header: %w", err)
+		}
+	}
+	var jh jsonHeaders
+	var err error
+	if jh.Protected, err = jsonMarshalHeaderMap(protected); err != nil {
+		return nil, fmt.Errorf("json: protected header: %w", err)
+	}
+	if len(h.RawProtected) > 0 {
+		var encoded []byte
+		if err := decModeWithTagsForbidden.Unmarshal(h.RawProtected, &encoded); err != nil {
+			return nil, fmt.Errorf("json: protected header: %w", err)
+		}
+		jh.RawProtected = jsonOptionalBytes(encoded)
+	}
+	if jh.Unprotected, err = jsonMarshalHeaderMap(h.Unprotected); err != nil {
+		return nil, fmt.Errorf("json: unprotected header: %w", err)
+	}
+	return &jh, nil
+}
+
+// fromJSON converts the JSON representation into the headers.
+func (h *Headers) fromJSON(jh *jsonHeaders) error {
+	protected, err := jsonUnmarshalHeaderMap(jh.Protected)
+	if err != nil {
+		return fmt.Errorf("json: protected header: %w", err)
+	}
+	unprotected, err := jsonUnmarshalHeaderMap(jh.Unprotected)
+	if err != nil {
+		return fmt.Errorf("json: unprotected header: %w", err)
+	}
+	if len(protected) > 0 {
+		if err := validateHeaderParameters(protected, true); err != nil {
+			return fmt.Errorf("json: protected header: %w", err)
+		}
+	}
+	if len(unprotected) > 0 {
+		if err := validateHeaderParameters(unprotected, false); err != nil {
+			return fmt.Errorf("json: unprotected header: %w", err)
+		}
+	}
+	headers := Headers{
+		Protected:   ProtectedHeader(protected),
+		Unprotected: UnprotectedHeader(unprotected),
+	}
+
+	if jh.RawProtected != nil {
+		encoded, err := base64.RawURLEncoding.DecodeString(*jh.RawProtected)
+		if err != nil {
+			return fmt.Errorf("json: rawProtected: %w", err)
+		}
+		raw, err := encMode.Marshal(encoded)
+		if err != nil {
+			return err
+		}
+		var decoded ProtectedHeader
+		if err := decodeWithOptions(raw, &decoded, nil); err != nil {
+			return fmt.Errorf("json: rawProtected: %w", err)
+		}
+		want, err := decoded.MarshalCBOR()
+		if err != nil {
+			return fmt.Errorf("json: rawProtected: %w", err)
+		}
+		got, err := headers.Protected.MarshalCBOR()
+		if err != nil {
+			return fmt.Errorf("json: protected header: %w", err)
+		}
+		if !bytes.Equal(got, want) {
+			return errors.New("json: rawProtected: mismatched protected header")
+		}
+		headers.RawProtected = raw
+		headers.Protected = decoded
+	}
+
+	if err := headers.ensureIV(); err != nil {
+		return fmt.Errorf("json: %w", err)
+	}
+	*h = headers
+	return nil
+}
+
+// jsonOptionalBytes encodes a "bstr / nil" value.
+func jsonOptionalBytes(b []byte) *string {
+	if b == nil {
+		return nil
+	}
+	s := base64.RawURLEncoding.EncodeToString(b)
+	return &s
+}
+
+// jsonDecodeOptionalBytes decodes a "bstr / nil" value.
+func jsonDecodeOptionalBytes(s *string) ([]byte, error) {
+	if s == nil {
+		return nil, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(*s)
+	if err != nil {
+		return nil, err
+	}
+	if b == nil {
+		b = []byte{}
+	}
+	return b, nil
+}
+
+// jsonHeaderLabel returns the JSON object member name of a header label.
+func jsonHeaderLabel(label any) (string, error) {
+	label, ok := normalizeLabel(label)
+	if !ok {
+		return "", errors.New("header label: require int / tstr type")
+	}
+	switch label := label.(type) {
+	case int64:
+		if name := headerLabelName(label); name != "" {
+			return name, nil
+		}
+		return strconv.FormatInt(label, 10), nil
+	default:
+		s := label.(string)
+		if _, err := jsonParseHeaderLabel(s); err == nil {
+			return jsonHeaderPrefixTstr + s, nil
+		}
+		return s, nil
+	}
+}
+
+// jsonParseHeaderLabel parses the JSON object member name of a header label.
+func jsonParseHeaderLabel(name string) (any, error) {
+	if s, ok := strings.CutPrefix(name, jsonHeaderPrefixTstr); ok {
+		return s, nil
+	}
+	if label, ok := headerLabelFromName(name); ok {
+		return label, nil
+	}
+	if label, err := strconv.ParseInt(name, 10, 64); err == nil {
+		return label, nil
+	}
+	return nil, errors.New("not an integer label")
+}
+
+// jsonMarshalHeaderMap encodes a header map into a JSON object.
+func jsonMarshalHeaderMap(h map[any]any) (json.RawMessage, error) {
+	obj := make(map[string]any, len(h))
+	for label, value := range h {
+		name, err := jsonHeaderLabel(label)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := obj[name]; ok {
+			return nil, fmt.Errorf("header label: duplicated label: %v", name)
+		}
+		label, _ = normalizeLabel(label)
+		v, err := jsonMarshalHeaderValue(label, value)
+		if err != nil {
+			return nil, fmt.Errorf("header parameter: %v: %w", name, err)
+		}
+		obj[name] = v
+	}
+	return json.Marshal(obj)
+}
+
+// jsonUnmarshalHeaderMap decodes a JSON object into a header map.
+func jsonUnmarshalHeaderMap(data json.RawMessage) (map[any]any, error) {
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		return map[any]any{}, nil
+	}
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, err
+	}
+	h := make(map[any]any, len(obj))
+	for name, raw := range obj {
+		label, err := jsonParseHeaderLabel(name)
+		if err != nil {
+			label = name
+		}
+		if _, ok := h[label]; ok {
+			return nil, fmt.Errorf("header label: duplicated label: %v", label)
+		}
+		value, err := jsonUnmarshalHeaderValue(label, raw)
+		if err != nil {
+			return nil, fmt.Errorf("header parameter: %v: %w", name, err)
+		}
+		h[label] = value
+	}
+	return h, nil
+}
+
+// jsonMarshalHeaderValue converts a header value into its JSON representation.
+func jsonMarshalHeaderValue(label, value any) (any, error) {
+	switch label {
+	case HeaderLabelKeyID, HeaderLabelIV, HeaderLabelPartialIV,
+		HeaderLabelCounterSignature0, HeaderLabelCounterSignature0V2:
+		if b, ok := value.([]byte); ok {
+			return base64.RawURLEncoding.EncodeToString(b), nil
+		}
+	case HeaderLabelX5Bag, HeaderLabelX5Chain:
+		switch v := value.(type) {
+		case []byte:
+			return base64.RawURLEncoding.EncodeToString(v), nil
+		case [][]byte:
+			certs := make([]string, 0, len(v))
+			for _, cert := range v {
+				certs = append(certs, base64.RawURLEncoding.EncodeToString(cert))
+			}
+			return certs, nil
+		case []any:
+			certs := make([]string, 0, len(v))
+			for _, cert := range v {
+				b, ok := cert.([]byte)
+				if !ok {
+					return jsonMarshalValue(value)
+				}
+				certs = append(certs, base64.RawURLEncoding.EncodeToString(b))
+			}
+			return certs, nil
+		}
+	case HeaderLabelCounterSignature, HeaderLabelCounterSignatureV2:
+		switch v := value.(type) {
+		case *Countersignature:
+			return (*Signature)(v), nil
+		case []*Countersignature:
+			sigs := make([]*Signature, 0, len(v))
+			for _, cs := range v {
+				sigs = append(sigs, (*Signature)(cs))
+			}
+			return sigs, nil
+		}
+	}
+	return jsonMarshalValue(value)
+}
+
+// jsonUnmarshalHeaderValue converts the JSON representation of a header value.
+func jsonUnmarshalHeaderValue(label any, data json.RawMessage) (any, error) {
+	switch label {
+	case HeaderLabelKeyID, HeaderLabelIV, HeaderLabelPartialIV,
+		HeaderLabelCounterSignature0, HeaderLabelCounterSignature0V2:
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return nil, errors.New("require base64url string")
+		}
+		return jsonDecodeOptionalBytes(&s)
+	case HeaderLabelX5Bag, HeaderLabelX5Chain:
+		var s string
+		if err := json.Unmarshal(data, &s); err == nil {
+			return jsonDecodeOptionalBytes(&s)
+		}
+		var certs []string
+		if err := json.Unmarshal(data, &certs); err != nil {
+			return jsonUnmarshalValue(data)
+		}
+		value := make([]any, 0, len(certs))
+		for _, cert := range certs {
+			b, err := jsonDecodeOptionalBytes(&cert)
+			if err != nil {
+				return nil, err
+			}
+			value = append(value, b)
+		}
+		return value, nil
+	case HeaderLabelCounterSignature, HeaderLabelCounterSignatureV2:
+		if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+			var sigs []*Signature
+			if err := json.Unmarshal(data, &sigs); err != nil {
+				return nil, err
+			}
+			value := make([]*Countersignature, 0, len(sigs))
+			for _, sig := range sigs {
+				value = append(value, (*Countersignature)(sig))
+			}
+			return value, nil
+		}
+		var sig Signature
+		if err := json.Unmarshal(data, &sig); err != nil {
+			return nil, err
+		}
+		return (*Countersignature)(&sig), nil
+	}
+	return jsonUnmarshalValue(data)
+}
+
+// jsonMarshalValue converts a value into the generic JSON representation.
+func jsonMarshalValue(value any) (any, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case bool, string, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return v, nil
+	case []byte:
+		return map[string]any{"bstr": base64.RawURLEncoding.EncodeToString(v)}, nil
+	case cbor.ByteString:
+		return map[string]any{"bstr": base64.RawURLEncoding.EncodeToString([]byte(v))}, nil
+	case float32:
+		return jsonMarshalFloat(float64(v)), nil
+	case float64:
+		return jsonMarshalFloat(v), nil
+	case []any:
+		arr := make([]any, 0, len(v))
+		for _, item := range v {
+			jv, err := jsonMarshalValue(item)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, jv)
+		}
+		return arr, nil
+	case map[any]any:
+		return jsonMarshalMap(v)
+	case cbor.Tag:
+		content, err := jsonMarshalValue(v.Content)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"tag": v.Number, "value": content}, nil
+	case big.Int:
+		return jsonMarshalValue(&v)
+	case *big.Int:
+		if v.IsInt64() {
+			return v.Int64(), nil
+		}
+		if v.IsUint64() {
+			return v.Uint64(), nil
+		}
+		if v.Sign() >= 0 {
+			return map[string]any{"tag": 2, "value": map[string]any{
+				"bstr": base64.RawURLEncoding.EncodeToString(v.Bytes()),
+			}}, nil
+		}
+		n := new(big.Int).Neg(v)
+		n.Sub(n, big.NewInt(1))
+		return map[string]any{"tag": 3, "value": map[string]any{
+			"bstr": base64.RawURLEncoding.EncodeToString(n.Bytes()),
+		}}, nil
+	case cbor.SimpleValue:
+		return map[string]any{"simple": uint8(v)}, nil
+	}
+
+	// integer types such as Algorithm, and other encodable types are
+	// converted through CBOR
+	rv := reflect.ValueOf(value)
+	switch {
+	case rv.CanInt():
+		return rv.Int(), nil
+	case rv.CanUint():
+		return rv.Uint(), nil
+	}
+	data, err := encMode.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var decoded any
+	if err := decMode.Unmarshal(data, &decoded); err != nil {
+		return nil, err
+	}
+	if reflect.TypeOf(decoded) == reflect.TypeOf(value) {
+		return nil, fmt.Errorf("unsupported type %T", value)
+	}
+	return jsonMarshalValue(decoded)
+}
+
+// jsonMarshalFloat converts a floating-point number into the generic JSON
+// representation.
+func jsonMarshalFloat(f float64) map[string]any {
+	switch {
+	case math.IsNaN(f):
+		return map[string]any{"float": "NaN"}
+	case math.IsInf(f, 1):
+		return map[string]any{"float": "Infinity"}
+	case math.IsInf(f, -1):
+		return map[string]any{"float": "-Infinity"}
+	}
+	return map[string]any{"float": f}
+}
+
+// jsonMarshalMap converts a map into the generic JSON representation.
+// Entries are sorted by the deterministic encoding of the keys.
+func jsonMarshalMap(m map[any]any) (any, error) {
+	type entry struct {
+		key     []byte
+		jsonKey any
+		value   any
+	}
+	entries := make([]entry, 0, len(m))
+	for k, v := range m {
+		key, err := encMode.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		jk, err := jsonMarshalValue(k)
+		if err != nil {
+			return nil, err
+		}
+		jv, err := jsonMarshalValue(v)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry{key: key, jsonKey: jk, value: jv})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].key, entries[j].key) < 0
+	})
+	pairs := make([][2]any, 0, len(entries))
+	for _, e := range entries {
+		pairs = append(pairs, [2]any{e.jsonKey, e.value})
+	}
+	return map[string]any{"map": pairs}, nil
+}
+
+// jsonUnmarshalValue converts the generic JSON representation into a value.
+func jsonUnmarshalValue(data json.RawMessage) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return jsonConvertValue(v)
+}
+
+// jsonConvertValue converts a JSON value decoded with numbers preserved.
+func jsonConvertValue(v any) (any, error) {
+	switch v := v.(type) {
+	case nil, bool, string:
+		return v, nil
+	case json.Number:
+		if i, err := strconv.ParseInt(v.String(), 10, 64); err == nil {
+			return i, nil
+		}
+		if u, err := strconv.ParseUint(v.String(), 10, 64); err == nil {
+			return u, nil
+		}
+		return nil, fmt.Errorf("invalid integer %v", v)
+	case []any:
+		arr := make([]any, 0, len(v))
+		for _, item := range v {
+			cv, err := jsonConvertValue(item)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, cv)
+		}
+		return arr, nil
+	case map[string]any:
+		return jsonConvertObject(v)
+	}
+	return nil, fmt.Errorf("unexpected JSON value %v", v)
+}
+
+// jsonConvertObject converts a typed JSON object of the generic JSON
+// representation.
+func jsonConvertObject(obj map[string]any) (any, error) {
+	switch {
+	case len(obj) == 1 && obj["bstr"] != nil:
+		s, ok := obj["bstr"].(string)
+		if !ok {
+			return nil, errors.New("bstr: require base64url string")
+		}
+		return jsonDecodeOptionalBytes(&s)
+	case len(obj) == 1 && obj["float"] != nil:
+		switch f := obj["float"].(type) {
+		case json.Number:
+			return f.Float64()
+		case string:
+			switch f {
+			case "NaN":
+				return math.NaN(), nil
+			case "Infinity":
+				return math.Inf(1), nil
+			case "-Infinity":
+				return math.Inf(-1), nil
+			}
+		}
+		return nil, errors.New("float: require number")
+	case len(obj) == 1 && obj["simple"] != nil:
+		n, ok := obj["simple"].(json.Number)
+		if !ok {
+			return nil, errors.New("simple: require uint")
+		}
+		v, err := strconv.ParseUint(n.String(), 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("simple: %w", err)
+		}
+		return cbor.SimpleValue(v), nil
+	case len(obj) == 1 && obj["map"] != nil:
+		pairs, ok := obj["map"].([]any)
+		if !ok {
+			return nil, errors.New("map: require array of pairs")
+		}
+		m := make(map[any]any, len(pairs))
+		for _, pair := range pairs {
+			kv, ok := pair.([]any)
+			if !ok || len(kv) != 2 {
+				return nil, errors.New("map: require array of pairs")
+			}
+			k, err := jsonConvertValue(kv[0])
+			if err != nil {
+				return nil, err
+			}
+			// byte string keys are decoded from CBOR as cbor.ByteString
+			if b, ok := k.([]byte); ok {
+				k = cbor.ByteString(b)
+			}
+			if k != nil && !reflect.TypeOf(k).Comparable() {
+				return nil, fmt.Errorf("map: invalid key type %T", k)
+			}
+			if _, ok := m[k]; ok {
+				return nil, fmt.Errorf("map: duplicated key %v", k)
+			}
+			if m[k], err = jsonConvertValue(kv[1]); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	case len(obj) == 2 && obj["tag"] != nil:
+		n, ok := obj["tag"].(json.Number)
+		if !ok {
+			return nil, errors.New("tag: require uint")
+		}
+		number, err := strconv.ParseUint(n.String(), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("tag: %w", err)
+		}
+		content, ok := obj["value"]
+		if !ok {
+			return nil, errors.New("tag: missing value")
+		}
+		value, err := jsonConvertValue(content)
+		if err != nil {
+			return nil, err
+		}
+		if number == 2 || number == 3 {
+			if b, ok := value.([]byte); ok {
+				n := new(big.Int).SetBytes(b)
+				if number == 3 {
+					n.Neg(n).Sub(n, big.NewInt(1))
+				}
+				return *n, nil
+			}
+		}
+		return cbor.Tag{Number: number, Content: value}, nil
+	}
+	return nil, errors.New("unknown typed value")
+}
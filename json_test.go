@@ -0,0 +1,423 @@
+package cose
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+func TestSign1Message_MarshalJSON(t *testing.T) {
+	msg := &Sign1Message{
+		Headers: Headers{
+			Protected: ProtectedHeader{
+				HeaderLabelAlgorithm: AlgorithmES256,
+			},
+			Unprotected: UnprotectedHeader{
+				HeaderLabelKeyID: []byte("11"),
+				int64(-70000):    []byte{0x01},
+				"foo":            []any{int64(1), "bar"},
+				"alg":            "text",
+				"-1":             1.5,
+			},
+		},
+		Payload:   []byte("hello"),
+		Signature: []byte{0x01, 0x02},
+	}
+	want := `{"protected":{"alg":-7},"unprotected":{"-70000":{"bstr":"AQ"},"foo":[1,"bar"],"kid":"MTE","tstr:-1":{"float":1.5},"tstr:alg":"text"},"payload":"aGVsbG8","signature":"AQI"}`
+	got, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	if string(got) != want {
+		t.Errorf("json.Marshal() = %s, want %s", got, want)
+	}
+
+	var decoded Sign1Message
+	if err := json.Unmarshal(got, &decoded); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	wantCBOR, err := msg.MarshalCBOR()
+	if err != nil {
+		t.Fatalf("Sign1Message.MarshalCBOR() error = %v", err)
+	}
+	gotCBOR, err := decoded.MarshalCBOR()
+	if err != nil {
+		t.Fatalf("Sign1Message.MarshalCBOR() error = %v", err)
+	}
+	if !bytes.Equal(gotCBOR, wantCBOR) {
+		t.Errorf("Sign1Message.MarshalCBOR() = %x, want %x", gotCBOR, wantCBOR)
+	}
+}
+
+func TestSign1Message_JSON_roundTrip(t *testing.T) {
+	alg := AlgorithmES256
+	key := generateTestECDSAKey(t)
+	signer, err := NewSigner(alg, key)
+	if err != nil {
+		t.Fatalf("NewSigner() error = %v", err)
+	}
+	verifier, err := NewVerifier(alg, key.Public())
+	if err != nil {
+		t.Fatalf("NewVerifier() error = %v", err)
+	}
+
+	msg := NewSign1Message()
+	msg.Headers.Protected.SetAlgorithm(alg)
+	msg.Headers.Protected[HeaderLabelContentType] = "text/plain"
+	msg.Headers.Protected[HeaderLabelCWTClaims] = CWTClaims{
+		CWTClaimIssuer:         "issuer.example",
+		CWTClaimExpirationTime: int64(1700000000),
+	}
+	msg.Headers.Unprotected[HeaderLabelKeyID] = []byte("11")
+	msg.Headers.Unprotected[int64(-65537)] = map[any]any{"x": []byte{0x01}}
+	msg.Payload = []byte("hello world")
+	if err := msg.Sign(rand.Reader, nil, signer); err != nil {
+		t.Fatalf("Sign1Message.Sign() error = %v", err)
+	}
+	encoded, err := msg.MarshalCBOR()
+	if err != nil {
+		t.Fatalf("Sign1Message.MarshalCBOR() error = %v", err)
+	}
+	var want Sign1Message
+	if err := want.UnmarshalCBOR(encoded); err != nil {
+		t.Fatalf("Sign1Message.UnmarshalCBOR() error = %v", err)
+	}
+
+	data, err := json.Marshal(&want)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	var got Sign1Message
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	if err := got.Verify(nil, verifier); err != nil {
+		t.Errorf("Sign1Message.Verify() error = %v", err)
+	}
+	gotCBOR, err := got.MarshalCBOR()
+	if err != nil {
+		t.Fatalf("Sign1Message.MarshalCBOR() error = %v", err)
+	}
+	if !bytes.Equal(gotCBOR, encoded) {
+		t.Errorf("Sign1Message.MarshalCBOR() = %x, want %x", gotCBOR, encoded)
+	}
+}
+
+func TestSign1Message_JSON_nonDeterministic(t *testing.T) {
+	alg := AlgorithmES256
+	key := generateTestECDSAKey(t)
+	signer, err := NewSigner(alg, key)
+	if err != nil {
+		t.Fatalf("NewSigner() error = %v", err)
+	}
+	verifier, err := NewVerifier(alg, key.Public())
+	if err != nil {
+		t.Fatalf("NewVerifier() error = %v", err)
+	}
+
+	// protected header with a float16 value and an unsorted nested map
+	rawProtected := []byte{
+		0x55,       // bstr
+		0xa3,       // map(3)
+		0x01, 0x26, // alg: ES256
+		0x3a, 0x00, 0x01, 0x11, 0x6f, // -70000
+		0xf9, 0x3e, 0x00, // 1.5 (float16)
+		0x3a, 0x00, 0x01, 0x11, 0x70, // -70001
+		0xa2, 0x02, 0x01, 0x01, 0x02, // {2: 1, 1: 2}
+	}
+	var protected ProtectedHeader
+	if err := protected.UnmarshalCBOR(rawProtected); err != nil {
+		t.Fatalf("ProtectedHeader.UnmarshalCBOR() error = %v", err)
+	}
+	msg := &Sign1Message{
+		Headers: Headers{
+			RawProtected: rawProtected,
+			Protected:    protected,
+			Unprotected: UnprotectedHeader{
+				int64(-70000): float32(1.5),
+			},
+		},
+		Payload: []byte("hello world"),
+	}
+	if err := msg.Sign(rand.Reader, nil, signer); err != nil {
+		t.Fatalf("Sign1Message.Sign() error = %v", err)
+	}
+	encoded, err := msg.MarshalCBOR()
+	if err != nil {
+		t.Fatalf("Sign1Message.MarshalCBOR() error = %v", err)
+	}
+	var want Sign1Message
+	if err := want.UnmarshalCBOR(encoded); err != nil {
+		t.Fatalf("Sign1Message.UnmarshalCBOR() error = %v", err)
+	}
+
+	data, err := json.Marshal(&want)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	var got Sign1Message
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	if !bytes.Equal(got.Headers.RawProtected, rawProtected) {
+		t.Errorf("Headers.RawProtected = %x, want %x", got.Headers.RawProtected, rawProtected)
+	}
+	if err := got.Verify(nil, verifier); err != nil {
+		t.Errorf("Sign1Message.Verify() error = %v", err)
+	}
+
+	// values of the unprotected header are re-encoded
+	gotCBOR, err := got.MarshalCBOR()
+	if err != nil {
+		t.Fatalf("Sign1Message.MarshalCBOR() error = %v", err)
+	}
+	wantCBOR := bytes.Replace(encoded,
+		[]byte{0xfa, 0x3f, 0xc0, 0x00, 0x00},
+		[]byte{0xfb, 0x3f, 0xf8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, 1)
+	if !bytes.Equal(gotCBOR, wantCBOR) {
+		t.Errorf("Sign1Message.MarshalCBOR() = %x, want %x", gotCBOR, wantCBOR)
+	}
+}
+
+func TestSign1Message_JSON_mapKeys(t *testing.T) {
+	// 18([<<{1: -7}>>, {-70000: {h'30': -17, null: 1}}, h'', h'01'])
+	data := []byte{
+		0xd2, 0x84, 0x43, 0xa1, 0x01, 0x26,
+		0xa1, 0x3a, 0x00, 0x01, 0x11, 0x6f,
+		0xa2, 0x41, 0x30, 0x30, 0xf6, 0x01,
+		0x40, 0x41, 0x01,
+	}
+	var msg Sign1Message
+	if err := msg.UnmarshalCBOR(data); err != nil {
+		t.Fatalf("Sign1Message.UnmarshalCBOR() error = %v", err)
+	}
+	encoded, err := json.Marshal(&msg)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	want := `{"protected":{"alg":-7},"rawProtected":"oQEm","unprotected":{"-70000":{"map":[[{"bstr":"MA"},-17],[null,1]]}},"payload":"","signature":"AQ"}`
+	if string(encoded) != want {
+		t.Errorf("json.Marshal() = %s, want %s", encoded, want)
+	}
+	var got Sign1Message
+	if err := json.Unmarshal(encoded, &got); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	gotCBOR, err := got.MarshalCBOR()
+	if err != nil {
+		t.Fatalf("Sign1Message.MarshalCBOR() error = %v", err)
+	}
+	if !bytes.Equal(gotCBOR, data) {
+		t.Errorf("Sign1Message.MarshalCBOR() = %x, want %x", gotCBOR, data)
+	}
+}
+
+func TestSignMessage_JSON_roundTrip(t *testing.T) {
+	alg := AlgorithmES256
+	key := generateTestECDSAKey(t)
+	signer, err := NewSigner(alg, key)
+	if err != nil {
+		t.Fatalf("NewSigner() error = %v", err)
+	}
+	verifier, err := NewVerifier(alg, key.Public())
+	if err != nil {
+		t.Fatalf("NewVerifier() error = %v", err)
+	}
+
+	sig := NewSignature()
+	sig.Headers.Protected.SetAlgorithm(alg)
+	sig.Headers.Unprotected[HeaderLabelKeyID] = []byte("11")
+	msg := NewSignMessage()
+	msg.Headers.Protected[HeaderLabelContentType] = int64(0)
+	msg.Payload = []byte("hello world")
+	msg.Signatures = []*Signature{sig}
+	if err := msg.Sign(rand.Reader, nil, signer); err != nil {
+		t.Fatalf("SignMessage.Sign() error = %v", err)
+	}
+	cs := NewCountersignature()
+	cs.Headers.Protected.SetAlgorithm(alg)
+	if err := cs.Sign(rand.Reader, signer, msg, nil); err != nil {
+		t.Fatalf("Countersignature.Sign() error = %v", err)
+	}
+	msg.Headers.Unprotected[HeaderLabelCounterSignatureV2] = cs
+	encoded, err := msg.MarshalCBOR()
+	if err != nil {
+		t.Fatalf("SignMessage.MarshalCBOR() error = %v", err)
+	}
+	var want SignMessage
+	if err := want.UnmarshalCBOR(encoded); err != nil {
+		t.Fatalf("SignMessage.UnmarshalCBOR() error = %v", err)
+	}
+
+	data, err := json.Marshal(&want)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	var got SignMessage
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	if err := got.Verify(nil, verifier); err != nil {
+		t.Errorf("SignMessage.Verify() error = %v", err)
+	}
+	gotCS, ok := got.Headers.Unprotected[HeaderLabelCounterSignatureV2].(*Countersignature)
+	if !ok {
+		t.Fatalf("countersignature type = %T, want *Countersignature", got.Headers.Unprotected[HeaderLabelCounterSignatureV2])
+	}
+	if err := gotCS.Verify(verifier, &got, nil); err != nil {
+		t.Errorf("Countersignature.Verify() error = %v", err)
+	}
+	gotCBOR, err := got.MarshalCBOR()
+	if err != nil {
+		t.Fatalf("SignMessage.MarshalCBOR() error = %v", err)
+	}
+	if !bytes.Equal(gotCBOR, encoded) {
+		t.Errorf("SignMessage.MarshalCBOR() = %x, want %x", gotCBOR, encoded)
+	}
+}
+
+func TestHeaders_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    Headers
+		wantErr string
+	}{
+		{
+			name: "labels",
+			data: `{"protected":{"alg":-7},"unprotected":{"kid":"MTE","1000":1,"tstr:1000":2,"tstr:tstr:x":3,"x":4}}`,
+			want: Headers{
+				Protected: ProtectedHeader{
+					HeaderLabelAlgorithm: int64(-7),
+				},
+				Unprotected: UnprotectedHeader{
+					HeaderLabelKeyID: []byte("11"),
+					int64(1000):      int64(1),
+					"1000":           int64(2),
+					"tstr:x":         int64(3),
+					"x":              int64(4),
+				},
+			},
+		},
+		{
+			name: "generic values",
+			data: `{"protected":{},"unprotected":{"1000":[{"bstr":""},{"float":"NaN"},{"map":[[1,"a"],["b",{"simple":16}]]},{"tag":2,"value":{"bstr":"AQAAAAAAAAAA"}},18446744073709551615,-1,true,null]}}`,
+			want: Headers{
+				Protected: ProtectedHeader{},
+				Unprotected: UnprotectedHeader{
+					int64(1000): []any{
+						[]byte{},
+						math.NaN(),
+						map[any]any{int64(1): "a", "b": cbor.SimpleValue(16)},
+						cbor.Tag{Number: 2, Content: []byte{0x01, 0, 0, 0, 0, 0, 0, 0, 0}},
+						uint64(math.MaxUint64),
+						int64(-1),
+						true,
+						nil,
+					},
+				},
+			},
+		},
+		{
+			name: "rawProtected",
+			data: `{"protected":{"alg":-7},"rawProtected":"oQEm","unprotected":{}}`,
+			want: Headers{
+				RawProtected: []byte{0x43, 0xa1, 0x01, 0x26},
+				Protected: ProtectedHeader{
+					HeaderLabelAlgorithm: int64(-7),
+				},
+				Unprotected: UnprotectedHeader{},
+			},
+		},
+		{
+			name:    "mismatched rawProtected",
+			data:    `{"protected":{"alg":-8},"rawProtected":"oQEm","unprotected":{}}`,
+			wantErr: "json: rawProtected: mismatched protected header",
+		},
+		{
+			name:    "invalid kid",
+			data:    `{"protected":{},"unprotected":{"kid":1}}`,
+			wantErr: "json: unprotected header: header parameter: kid: require base64url string",
+		},
+		{
+			name:    "invalid header parameter",
+			data:    `{"protected":{"alg":{"bstr":"AQ"}},"unprotected":{}}`,
+			wantErr: "json: protected header: header parameter: alg: require int / tstr type",
+		},
+		{
+			name:    "float number",
+			data:    `{"protected":{},"unprotected":{"1000":1.5}}`,
+			wantErr: "json: unprotected header: header parameter: 1000: invalid integer 1.5",
+		},
+		{
+			name:    "unknown typed value",
+			data:    `{"protected":{},"unprotected":{"1000":{"foo":1}}}`,
+			wantErr: "json: unprotected header: header parameter: 1000: unknown typed value",
+		},
+		{
+			name:    "invalid map key",
+			data:    `{"protected":{},"unprotected":{"1000":{"map":[[[1],1]]}}}`,
+			wantErr: "json: unprotected header: header parameter: 1000: map: invalid key type []interface {}",
+		},
+		{
+			name:    "IV and Partial IV",
+			data:    `{"protected":{"IV":"AQ"},"unprotected":{"Partial IV":"AQ"}}`,
+			wantErr: "json: IV (protected) and PartialIV (unprotected) parameters must not both be present",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Headers
+			err := json.Unmarshal([]byte(tt.data), &got)
+			if err != nil && (err.Error() != tt.wantErr) {
+				t.Errorf("json.Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			} else if err == nil && (tt.wantErr != "") {
+				t.Errorf("json.Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr != "" {
+				return
+			}
+			gotDiag, err := got.Diagnose(nil)
+			if err != nil {
+				t.Fatalf("Headers.Diagnose() error = %v", err)
+			}
+			wantDiag, err := tt.want.Diagnose(nil)
+			if err != nil {
+				t.Fatalf("Headers.Diagnose() error = %v", err)
+			}
+			if gotDiag != wantDiag {
+				t.Errorf("json.Unmarshal() = %s, want %s", gotDiag, wantDiag)
+			}
+			if !bytes.Equal(got.RawProtected, tt.want.RawProtected) {
+				t.Errorf("Headers.RawProtected = %x, want %x", got.RawProtected, tt.want.RawProtected)
+			}
+
+			// marshal back and compare
+			data, err := json.Marshal(&got)
+			if err != nil {
+				t.Fatalf("json.Marshal() error = %v", err)
+			}
+			var again Headers
+			if err := json.Unmarshal(data, &again); err != nil {
+				t.Fatalf("json.Unmarshal() error = %v", err)
+			}
+			if !reflect.DeepEqual(again.RawProtected, got.RawProtected) {
+				t.Errorf("json round trip RawProtected = %x, want %x", again.RawProtected, got.RawProtected)
+			}
+			againDiag, err := again.Diagnose(nil)
+			if err != nil {
+				t.Fatalf("Headers.Diagnose() error = %v", err)
+			}
+			if againDiag != gotDiag {
+				t.Errorf("json round trip = %s, want %s", againDiag, gotDiag)
+			}
+		})
+	}
+}
@@ -0,0 +1,438 @@
+package mdoc
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/veraison/go-cose"
+)
+
+// AlgorithmHMAC256 is HMAC w/ SHA-256 (HMAC 256/256), the MAC algorithm of the
+// DeviceMac structure.
+//
+// Reference: https://www.iana.org/assignments/cose/cose.xhtml#algorithms
+const AlgorithmHMAC256 cose.Algorithm = 5
+
+// SessionTranscript binds the device authentication to the session between
+// the mdoc and the mdoc reader.
+// The fields are left encoded since the transcript is authenticated as
+// received.
+//
+// Reference: ISO/IEC 18013-5:2021 Section 9.1.5.1
+type SessionTranscript struct {
+	_ struct{} `cbor:",toarray"`
+
+	// DeviceEngagementBytes is the device engagement wrapped in a CBOR tag 24,
+	// or null.
+	DeviceEngagementBytes cbor.RawMessage
+
+	// EReaderKeyBytes is the ephemeral COSE_Key of the mdoc reader wrapped in a
+	// CBOR tag 24, or null.
+	EReaderKeyBytes cbor.RawMessage
+
+	// Handover is the handover structure, null for QR code handover.
+	Handover cbor.RawMessage
+}
+
+// NewSessionTranscript returns the session transcript of the encoded device
+// engagement, the ephemeral key of the mdoc reader and the handover.
+// The device engagement and the reader key are encoded as null if nil.
+func NewSessionTranscript(deviceEngagement []byte, eReaderKey *cose.Key, handover any) (*SessionTranscript, error) {
+	var transcript SessionTranscript
+	var err error
+	if deviceEngagement != nil {
+		if transcript.DeviceEngagementBytes, err = encMode.Marshal(cbor.Tag{
+			Number:  CBORTagEncodedCBOR,
+			Content: deviceEngagement,
+		}); err != nil {
+			return nil, err
+		}
+	}
+	if eReaderKey != nil {
+		if transcript.EReaderKeyBytes, err = encodeEmbedded(eReaderKey); err != nil {
+			return nil, err
+		}
+	}
+	if transcript.Handover, err = encMode.Marshal(handover); err != nil {
+		return nil, err
+	}
+	return &transcript, nil
+}
+
+// EReaderKey returns the ephemeral key of the mdoc reader, or nil if the
+// session transcript has no reader key.
+func (s *SessionTranscript) EReaderKey() (*cose.Key, error) {
+	if len(s.EReaderKeyBytes) == 0 || bytes.Equal(s.EReaderKeyBytes, []byte{0xf6}) {
+		return nil, nil
+	}
+	var key cose.Key
+	if err := decodeEmbedded(s.EReaderKeyBytes, &key); err != nil {
+		return nil, fmt.Errorf("session transcript: EReaderKey: %w", err)
+	}
+	return &key, nil
+}
+
+// DeviceNameSpaces holds the data elements signed by the device, by name
+// space and data element identifier.
+type DeviceNameSpaces map[string]map[string]any
+
+// EncodeDeviceNameSpaces encodes the name spaces as DeviceNameSpacesBytes, that
+// is the encoded name spaces wrapped in a CBOR tag 24.
+func EncodeDeviceNameSpaces(nameSpaces DeviceNameSpaces) ([]byte, error) {
+	if nameSpaces == nil {
+		nameSpaces = DeviceNameSpaces{}
+	}
+	return encodeEmbedded(nameSpaces)
+}
+
+// DecodeDeviceNameSpaces decodes DeviceNameSpacesBytes.
+func DecodeDeviceNameSpaces(data []byte) (DeviceNameSpaces, error) {
+	var nameSpaces DeviceNameSpaces
+	if err := decodeEmbedded(data, &nameSpaces); err != nil {
+		return nil, fmt.Errorf("device name spaces: %w", err)
+	}
+	return nameSpaces, nil
+}
+
+// DeviceAuthenticationBytes returns the DeviceAuthenticationBytes of the
+// session, that is the detached payload of the device signature or MAC.
+//
+// Reference: ISO/IEC 18013-5:2021 Section 9.1.3.4
+func DeviceAuthenticationBytes(transcript *SessionTranscript, docType string, nameSpacesBytes []byte) ([]byte, error) {
+	if transcript == nil {
+		return nil, errors.New("session transcript: missing")
+	}
+	return encodeEmbedded([]any{
+		"DeviceAuthentication",
+		transcript,
+		docType,
+		cbor.RawMessage(nameSpacesBytes),
+	})
+}
+
+// DeviceSigned holds the data elements signed by the device and their device
+// authentication.
+//
+// Reference: ISO/IEC 18013-5:2021 Section 8.3.2.1.2.2
+type DeviceSigned struct {
+	// NameSpaces is the DeviceNameSpacesBytes.
+	NameSpaces cbor.RawMessage `cbor:"nameSpaces"`
+	DeviceAuth DeviceAuth      `cbor:"deviceAuth"`
+}
+
+// DeviceAuth holds either the untagged COSE_Sign1 DeviceSignature or the
+// untagged COSE_Mac0 DeviceMac structure, with detached payloads.
+type DeviceAuth struct {
+	DeviceSignature cbor.RawMessage `cbor:"deviceSignature,omitempty"`
+	DeviceMac       cbor.RawMessage `cbor:"deviceMac,omitempty"`
+}
+
+// SignDeviceAuth signs the name spaces for the session with the device key,
+// and returns the DeviceSigned structure in the DeviceSignature mode.
+func SignDeviceAuth(rand io.Reader, signer cose.Signer, transcript *SessionTranscript, docType string, nameSpaces DeviceNameSpaces) (*DeviceSigned, error) {
+	nameSpacesBytes, err := EncodeDeviceNameSpaces(nameSpaces)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := DeviceAuthenticationBytes(transcript, docType, nameSpacesBytes)
+	if err != nil {
+		return nil, err
+	}
+	msg := cose.UntaggedSign1Message{
+		Headers: cose.Headers{
+			Protected: cose.ProtectedHeader{
+				cose.HeaderLabelAlgorithm: signer.Algorithm(),
+			},
+		},
+		Payload: payload,
+	}
+	if err := msg.Sign(rand, nil, signer); err != nil {
+		return nil, err
+	}
+	msg.Payload = nil // detached
+	deviceSignature, err := msg.MarshalCBOR()
+	if err != nil {
+		return nil, err
+	}
+	return &DeviceSigned{
+		NameSpaces: nameSpacesBytes,
+		DeviceAuth: DeviceAuth{
+			DeviceSignature: deviceSignature,
+		},
+	}, nil
+}
+
+// MACDeviceAuth authenticates the name spaces for the session with the
+// EMacKey derived from the private key and the public key, and returns the
+// DeviceSigned structure in the DeviceMac mode.
+//
+// The mdoc computes the MAC with the private device key and the ephemeral
+// reader key of the session transcript.
+func MACDeviceAuth(privateKey *ecdh.PrivateKey, publicKey *cose.Key, transcript *SessionTranscript, docType string, nameSpaces DeviceNameSpaces) (*DeviceSigned, error) {
+	nameSpacesBytes, err := EncodeDeviceNameSpaces(nameSpaces)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := DeviceAuthenticationBytes(transcript, docType, nameSpacesBytes)
+	if err != nil {
+		return nil, err
+	}
+	key, err := deriveEMacKey(privateKey, publicKey, transcript)
+	if err != nil {
+		return nil, err
+	}
+	headers := cose.Headers{
+		Protected: cose.ProtectedHeader{
+			cose.HeaderLabelAlgorithm: AlgorithmHMAC256,
+		},
+	}
+	protected, err := headers.MarshalProtected()
+	if err != nil {
+		return nil, err
+	}
+	unprotected, err := headers.MarshalUnprotected()
+	if err != nil {
+		return nil, err
+	}
+	tag, err := mac0Tag(key, protected, payload)
+	if err != nil {
+		return nil, err
+	}
+	deviceMac, err := encMode.Marshal(mac0Message{
+		Protected:   protected,
+		Unprotected: unprotected,
+		Tag:         tag,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &DeviceSigned{
+		NameSpaces: nameSpacesBytes,
+		DeviceAuth: DeviceAuth{
+			DeviceMac: deviceMac,
+		},
+	}, nil
+}
+
+// VerifyDeviceSigned verifies the device authentication of the DeviceSigned
+// structure for the session with the device key of the mobile security object,
+// and returns the data elements signed by the device.
+//
+// The ephemeral private key of the mdoc reader is required to verify the
+// DeviceMac mode, and ignored in the DeviceSignature mode.
+// The mobile security object must be verified beforehand, see
+// [Verifier.VerifyIssuerAuth]. The key authorizations of the device key are
+// not enforced.
+func (m *MobileSecurityObject) VerifyDeviceSigned(deviceSigned *DeviceSigned, transcript *SessionTranscript, eReaderKey *ecdh.PrivateKey) (DeviceNameSpaces, error) {
+	if deviceSigned == nil {
+		return nil, errors.New("verifying nil DeviceSigned")
+	}
+	deviceKey := m.DeviceKeyInfo.DeviceKey
+	if deviceKey == nil {
+		return nil, errors.New("mso: deviceKeyInfo: missing device key")
+	}
+	nameSpaces, err := DecodeDeviceNameSpaces(deviceSigned.NameSpaces)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := DeviceAuthenticationBytes(transcript, m.DocType, deviceSigned.NameSpaces)
+	if err != nil {
+		return nil, err
+	}
+
+	auth := deviceSigned.DeviceAuth
+	switch {
+	case len(auth.DeviceSignature) > 0 && len(auth.DeviceMac) > 0:
+		return nil, errors.New("deviceAuth: require either deviceSignature or deviceMac")
+	case len(auth.DeviceSignature) > 0:
+		err = verifyDeviceSignature(auth.DeviceSignature, deviceKey, payload)
+	case len(auth.DeviceMac) > 0:
+		if eReaderKey == nil {
+			return nil, errors.New("deviceMac: missing reader private key")
+		}
+		err = verifyDeviceMac(auth.DeviceMac, eReaderKey, deviceKey, transcript, payload)
+	default:
+		return nil, errors.New("deviceAuth: missing deviceSignature or deviceMac")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nameSpaces, nil
+}
+
+// verifyDeviceSignature verifies the DeviceSignature structure over the
+// detached payload with the device key.
+func verifyDeviceSignature(data []byte, deviceKey *cose.Key, payload []byte) error {
+	var msg cose.UntaggedSign1Message
+	if err := msg.UnmarshalCBOR(data); err != nil {
+		return fmt.Errorf("deviceSignature: %w", err)
+	}
+	if msg.Payload != nil {
+		return errors.New("deviceSignature: require detached payload")
+	}
+	verifier, err := deviceKey.Verifier()
+	if err != nil {
+		return fmt.Errorf("mso: deviceKeyInfo: %w", err)
+	}
+	msg.Payload = payload
+	return msg.Verify(nil, verifier)
+}
+
+// verifyDeviceMac verifies the DeviceMac structure over the detached payload
+// with the EMacKey derived from the private key and the public key.
+func verifyDeviceMac(data []byte, privateKey *ecdh.PrivateKey, publicKey *cose.Key, transcript *SessionTranscript, payload []byte) error {
+	var msg mac0Message
+	if err := decMode.Unmarshal(data, &msg); err != nil {
+		return fmt.Errorf("deviceMac: %w", err)
+	}
+	if msg.Payload != nil {
+		return errors.New("deviceMac: require detached payload")
+	}
+	headers := cose.Headers{
+		RawProtected:   msg.Protected,
+		RawUnprotected: msg.Unprotected,
+	}
+	if err := headers.UnmarshalFromRaw(); err != nil {
+		return fmt.Errorf("deviceMac: %w", err)
+	}
+	alg, err := headers.Protected.Algorithm()
+	if err != nil {
+		return fmt.Errorf("deviceMac: %w", err)
+	}
+	if alg != AlgorithmHMAC256 {
+		return fmt.Errorf("deviceMac: %w: %v", cose.ErrAlgorithmNotSupported, alg)
+	}
+	key, err := deriveEMacKey(privateKey, publicKey, transcript)
+	if err != nil {
+		return err
+	}
+	tag, err := mac0Tag(key, msg.Protected, payload)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(tag, msg.Tag) {
+		return cose.ErrVerification
+	}
+	return nil
+}
+
+// mac0Message represents an untagged COSE_Mac0 CBOR object.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9052.html#section-6.2
+type mac0Message struct {
+	_           struct{} `cbor:",toarray"`
+	Protected   cbor.RawMessage
+	Unprotected cbor.RawMessage
+	Payload     []byte
+	Tag         []byte
+}
+
+// mac0Tag computes the HMAC 256/256 tag of the MAC_structure of a COSE_Mac0
+// object.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9052.html#section-6.3
+func mac0Tag(key []byte, protected cbor.RawMessage, payload []byte) ([]byte, error) {
+	// MAC_structure = [
+	//     context : "MAC0",
+	//     protected : empty_or_serialized_map,
+	//     external_aad : bstr,
+	//     payload : bstr
+	// ]
+	toBeMaced, err := encMode.Marshal([]any{
+		"MAC0",
+		protected,
+		[]byte{},
+		payload,
+	})
+	if err != nil {
+		return nil, err
+	}
+	h := hmac.New(sha256.New, key)
+	h.Write(toBeMaced)
+	return h.Sum(nil), nil
+}
+
+// deriveEMacKey derives the EMacKey from the ECDH shared secret of the private
+// key and the public key, salted with the digest of the session transcript.
+//
+// Reference: ISO/IEC 18013-5:2021 Section 9.1.3.5
+func deriveEMacKey(privateKey *ecdh.PrivateKey, publicKey *cose.Key, transcript *SessionTranscript) ([]byte, error) {
+	if privateKey == nil {
+		return nil, errors.New("deriving EMacKey from nil private key")
+	}
+	pub, err := ecdhPublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := privateKey.ECDH(pub)
+	if err != nil {
+		return nil, err
+	}
+	transcriptBytes, err := encodeEmbedded(transcript)
+	if err != nil {
+		return nil, err
+	}
+	salt := sha256.Sum256(transcriptBytes)
+
+	// HKDF-SHA-256 with an output length of a single block.
+	// Reference: https://www.rfc-editor.org/rfc/rfc5869.html#section-2
+	extract := hmac.New(sha256.New, salt[:])
+	extract.Write(secret)
+	expand := hmac.New(sha256.New, extract.Sum(nil))
+	expand.Write([]byte("EMacKey"))
+	expand.Write([]byte{0x01})
+	return expand.Sum(nil), nil
+}
+
+// ecdhPublicKey returns the ECDH public key of an EC2 or OKP X25519 key.
+func ecdhPublicKey(key *cose.Key) (*ecdh.PublicKey, error) {
+	if key == nil {
+		return nil, errors.New("deriving EMacKey from nil public key")
+	}
+	switch key.Type {
+	case cose.KeyTypeEC2:
+		crv, x, y, _ := key.EC2()
+		var curve ecdh.Curve
+		var size int
+		switch crv {
+		case cose.CurveP256:
+			curve, size = ecdh.P256(), 32
+		case cose.CurveP384:
+			curve, size = ecdh.P384(), 48
+		case cose.CurveP521:
+			curve, size = ecdh.P521(), 66
+		default:
+			return nil, fmt.Errorf("%w: unsupported curve %v", cose.ErrInvalidPubKey, crv)
+		}
+		if len(x) > size || len(y) > size {
+			return nil, cose.ErrInvalidPubKey
+		}
+		point := make([]byte, 1+2*size)
+		point[0] = 0x04 // uncompressed
+		copy(point[1+size-len(x):], x)
+		copy(point[1+2*size-len(y):], y)
+		pub, err := curve.NewPublicKey(point)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", cose.ErrInvalidPubKey, err)
+		}
+		return pub, nil
+	case cose.KeyTypeOKP:
+		crv, x, _ := key.OKP()
+		if crv != cose.CurveX25519 {
+			return nil, fmt.Errorf("%w: unsupported curve %v", cose.ErrInvalidPubKey, crv)
+		}
+		pub, err := ecdh.X25519().NewPublicKey(x)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", cose.ErrInvalidPubKey, err)
+		}
+		return pub, nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported key type %v", cose.ErrInvalidPubKey, key.Type)
+	}
+}
@@ -0,0 +1,326 @@
+package mdoc
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"reflect"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/veraison/go-cose"
+)
+
+// testDeviceNameSpaces are the data elements signed by the device.
+var testDeviceNameSpaces = DeviceNameSpaces{
+	NameSpaceMDL: {
+		"age_over_21": true,
+	},
+}
+
+// roundTripDeviceSigned encodes and decodes the DeviceSigned structure.
+func roundTripDeviceSigned(t *testing.T, deviceSigned *DeviceSigned) *DeviceSigned {
+	t.Helper()
+	data, err := cbor.Marshal(deviceSigned)
+	if err != nil {
+		t.Fatalf("cbor.Marshal() error = %v", err)
+	}
+	var got DeviceSigned
+	if err := cbor.Unmarshal(data, &got); err != nil {
+		t.Fatalf("cbor.Unmarshal() error = %v", err)
+	}
+	return &got
+}
+
+func TestSessionTranscript(t *testing.T) {
+	eReaderKey, err := cose.NewKeyFromPublic(generateTestECDSAKey(t).Public())
+	if err != nil {
+		t.Fatalf("NewKeyFromPublic() error = %v", err)
+	}
+	transcript, err := NewSessionTranscript([]byte{0xa0}, eReaderKey, nil)
+	if err != nil {
+		t.Fatalf("NewSessionTranscript() error = %v", err)
+	}
+	got, err := transcript.EReaderKey()
+	if err != nil {
+		t.Fatalf("SessionTranscript.EReaderKey() error = %v", err)
+	}
+	// coordinates are padded when encoded, compare the encoded keys
+	gotKey, err := got.MarshalCBOR()
+	if err != nil {
+		t.Fatalf("Key.MarshalCBOR() error = %v", err)
+	}
+	wantKey, err := eReaderKey.MarshalCBOR()
+	if err != nil {
+		t.Fatalf("Key.MarshalCBOR() error = %v", err)
+	}
+	if !bytes.Equal(gotKey, wantKey) {
+		t.Errorf("SessionTranscript.EReaderKey() = %v, want %v", got, eReaderKey)
+	}
+	data, err := encMode.Marshal(transcript)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	want := []byte{0x83, 0xd8, 0x18, 0x41, 0xa0}
+	if !bytes.HasPrefix(data, want) || !bytes.HasSuffix(data, []byte{0xf6}) {
+		t.Errorf("Marshal() = %x, want prefix %x and null handover", data, want)
+	}
+
+	// OpenID4VP handover without device engagement and reader key
+	transcript, err = NewSessionTranscript(nil, nil, []any{"clientIdHash", "responseUriHash", "nonce"})
+	if err != nil {
+		t.Fatalf("NewSessionTranscript() error = %v", err)
+	}
+	if got, err := transcript.EReaderKey(); err != nil || got != nil {
+		t.Errorf("SessionTranscript.EReaderKey() = %v, %v, want nil", got, err)
+	}
+	data, err = encMode.Marshal(transcript)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if want := []byte{0x83, 0xf6, 0xf6, 0x83}; !bytes.HasPrefix(data, want) {
+		t.Errorf("Marshal() = %x, want prefix %x", data, want)
+	}
+}
+
+func TestDeviceSignature(t *testing.T) {
+	deviceKey := generateTestECDSAKey(t)
+	signer, err := cose.NewSigner(cose.AlgorithmES256, deviceKey)
+	if err != nil {
+		t.Fatalf("NewSigner() error = %v", err)
+	}
+	mso := newTestMSO(t)
+	if mso.DeviceKeyInfo.DeviceKey, err = cose.NewKeyFromPublic(deviceKey.Public()); err != nil {
+		t.Fatalf("NewKeyFromPublic() error = %v", err)
+	}
+	transcript, err := NewSessionTranscript([]byte{0xa0}, nil, nil)
+	if err != nil {
+		t.Fatalf("NewSessionTranscript() error = %v", err)
+	}
+
+	deviceSigned, err := SignDeviceAuth(rand.Reader, signer, transcript, DocTypeMDL, testDeviceNameSpaces)
+	if err != nil {
+		t.Fatalf("SignDeviceAuth() error = %v", err)
+	}
+	deviceSigned = roundTripDeviceSigned(t, deviceSigned)
+	got, err := mso.VerifyDeviceSigned(deviceSigned, transcript, nil)
+	if err != nil {
+		t.Fatalf("MobileSecurityObject.VerifyDeviceSigned() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, testDeviceNameSpaces) {
+		t.Errorf("MobileSecurityObject.VerifyDeviceSigned() = %v, want %v", got, testDeviceNameSpaces)
+	}
+
+	// another session
+	otherTranscript, err := NewSessionTranscript([]byte{0xa1, 0x00, 0x00}, nil, nil)
+	if err != nil {
+		t.Fatalf("NewSessionTranscript() error = %v", err)
+	}
+	if _, err := mso.VerifyDeviceSigned(deviceSigned, otherTranscript, nil); err != cose.ErrVerification {
+		t.Errorf("MobileSecurityObject.VerifyDeviceSigned() error = %v, want %v", err, cose.ErrVerification)
+	}
+
+	// another document type
+	otherMSO := *mso
+	otherMSO.DocType = "org.iso.23220.photoid.1"
+	if _, err := otherMSO.VerifyDeviceSigned(deviceSigned, transcript, nil); err != cose.ErrVerification {
+		t.Errorf("MobileSecurityObject.VerifyDeviceSigned() error = %v, want %v", err, cose.ErrVerification)
+	}
+}
+
+func TestDeviceMac(t *testing.T) {
+	tests := []struct {
+		name     string
+		generate func(t *testing.T) (*ecdh.PrivateKey, *cose.Key)
+	}{
+		{
+			name: "P-256",
+			generate: func(t *testing.T) (*ecdh.PrivateKey, *cose.Key) {
+				priv := generateTestECDSAKey(t)
+				key, err := cose.NewKeyFromPublic(priv.Public())
+				if err != nil {
+					t.Fatalf("NewKeyFromPublic() error = %v", err)
+				}
+				ecdhPriv, err := priv.ECDH()
+				if err != nil {
+					t.Fatalf("ecdsa.PrivateKey.ECDH() error = %v", err)
+				}
+				return ecdhPriv, key
+			},
+		},
+		{
+			name: "X25519",
+			generate: func(t *testing.T) (*ecdh.PrivateKey, *cose.Key) {
+				priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+				if err != nil {
+					t.Fatalf("ecdh.X25519().GenerateKey() error = %v", err)
+				}
+				return priv, &cose.Key{
+					Type: cose.KeyTypeOKP,
+					Params: map[any]any{
+						cose.KeyLabelOKPCurve: cose.CurveX25519,
+						cose.KeyLabelOKPX:     priv.PublicKey().Bytes(),
+					},
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			devicePriv, deviceKey := tt.generate(t)
+			readerPriv, readerKey := tt.generate(t)
+			otherReaderPriv, _ := tt.generate(t)
+			mso := newTestMSO(t)
+			mso.DeviceKeyInfo.DeviceKey = deviceKey
+			transcript, err := NewSessionTranscript([]byte{0xa0}, readerKey, nil)
+			if err != nil {
+				t.Fatalf("NewSessionTranscript() error = %v", err)
+			}
+
+			// mdoc side
+			eReaderKey, err := transcript.EReaderKey()
+			if err != nil {
+				t.Fatalf("SessionTranscript.EReaderKey() error = %v", err)
+			}
+			deviceSigned, err := MACDeviceAuth(devicePriv, eReaderKey, transcript, DocTypeMDL, testDeviceNameSpaces)
+			if err != nil {
+				t.Fatalf("MACDeviceAuth() error = %v", err)
+			}
+			deviceSigned = roundTripDeviceSigned(t, deviceSigned)
+
+			// mdoc reader side
+			got, err := mso.VerifyDeviceSigned(deviceSigned, transcript, readerPriv)
+			if err != nil {
+				t.Fatalf("MobileSecurityObject.VerifyDeviceSigned() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, testDeviceNameSpaces) {
+				t.Errorf("MobileSecurityObject.VerifyDeviceSigned() = %v, want %v", got, testDeviceNameSpaces)
+			}
+			if _, err := mso.VerifyDeviceSigned(deviceSigned, transcript, otherReaderPriv); err != cose.ErrVerification {
+				t.Errorf("MobileSecurityObject.VerifyDeviceSigned() error = %v, want %v", err, cose.ErrVerification)
+			}
+		})
+	}
+}
+
+func TestMobileSecurityObject_VerifyDeviceSigned_Error(t *testing.T) {
+	deviceKey := generateTestECDSAKey(t)
+	signer, err := cose.NewSigner(cose.AlgorithmES256, deviceKey)
+	if err != nil {
+		t.Fatalf("NewSigner() error = %v", err)
+	}
+	mso := newTestMSO(t)
+	if mso.DeviceKeyInfo.DeviceKey, err = cose.NewKeyFromPublic(deviceKey.Public()); err != nil {
+		t.Fatalf("NewKeyFromPublic() error = %v", err)
+	}
+	readerPriv, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdh.P256().GenerateKey() error = %v", err)
+	}
+	transcript, err := NewSessionTranscript([]byte{0xa0}, nil, nil)
+	if err != nil {
+		t.Fatalf("NewSessionTranscript() error = %v", err)
+	}
+	signed, err := SignDeviceAuth(rand.Reader, signer, transcript, DocTypeMDL, testDeviceNameSpaces)
+	if err != nil {
+		t.Fatalf("SignDeviceAuth() error = %v", err)
+	}
+	attached := func() []byte {
+		var msg cose.UntaggedSign1Message
+		if err := msg.UnmarshalCBOR(signed.DeviceAuth.DeviceSignature); err != nil {
+			t.Fatalf("UntaggedSign1Message.UnmarshalCBOR() error = %v", err)
+		}
+		msg.Payload = []byte("payload")
+		data, err := msg.MarshalCBOR()
+		if err != nil {
+			t.Fatalf("UntaggedSign1Message.MarshalCBOR() error = %v", err)
+		}
+		return data
+	}()
+	hmac384, err := encMode.Marshal(mac0Message{
+		Protected:   []byte{0x43, 0xa1, 0x01, 0x06}, // {1: 6}
+		Unprotected: []byte{0xa0},
+		Tag:         make([]byte, 48),
+	})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		deviceSigned *DeviceSigned
+		eReaderKey   *ecdh.PrivateKey
+		wantErr      string
+	}{
+		{
+			name: "both modes",
+			deviceSigned: &DeviceSigned{
+				NameSpaces: signed.NameSpaces,
+				DeviceAuth: DeviceAuth{
+					DeviceSignature: signed.DeviceAuth.DeviceSignature,
+					DeviceMac:       signed.DeviceAuth.DeviceSignature,
+				},
+			},
+			wantErr: "deviceAuth: require either deviceSignature or deviceMac",
+		},
+		{
+			name: "no mode",
+			deviceSigned: &DeviceSigned{
+				NameSpaces: signed.NameSpaces,
+			},
+			wantErr: "deviceAuth: missing deviceSignature or deviceMac",
+		},
+		{
+			name: "attached payload",
+			deviceSigned: &DeviceSigned{
+				NameSpaces: signed.NameSpaces,
+				DeviceAuth: DeviceAuth{
+					DeviceSignature: attached,
+				},
+			},
+			wantErr: "deviceSignature: require detached payload",
+		},
+		{
+			name: "mac without reader key",
+			deviceSigned: &DeviceSigned{
+				NameSpaces: signed.NameSpaces,
+				DeviceAuth: DeviceAuth{
+					DeviceMac: hmac384,
+				},
+			},
+			wantErr: "deviceMac: missing reader private key",
+		},
+		{
+			name: "unsupported mac algorithm",
+			deviceSigned: &DeviceSigned{
+				NameSpaces: signed.NameSpaces,
+				DeviceAuth: DeviceAuth{
+					DeviceMac: hmac384,
+				},
+			},
+			eReaderKey: readerPriv,
+			wantErr:    "deviceMac: algorithm not supported: Algorithm(6)",
+		},
+		{
+			name: "name spaces not wrapped",
+			deviceSigned: &DeviceSigned{
+				NameSpaces: []byte{0xa0},
+				DeviceAuth: signed.DeviceAuth,
+			},
+			wantErr: "device name spaces: require embedded CBOR data item",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := mso.VerifyDeviceSigned(tt.deviceSigned, transcript, tt.eReaderKey)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("MobileSecurityObject.VerifyDeviceSigned() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	wantErr := "session transcript: missing"
+	if _, err := mso.VerifyDeviceSigned(signed, nil, nil); err == nil || err.Error() != wantErr {
+		t.Errorf("MobileSecurityObject.VerifyDeviceSigned() error = %v, wantErr %v", err, wantErr)
+	}
+}
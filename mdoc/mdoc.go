@@ -0,0 +1,475 @@
+// Package mdoc implements the issuer and device authentication of mobile
+// documents (mdoc) such as mobile driving licences (mDL), on top of COSE_Sign1.
+//
+// An mdoc is issued with a COSE_Sign1 IssuerAuth structure, signed by a
+// document signer (DS) certificate, whose payload is the mobile security object
+// (MSO). The MSO carries the digests of the data elements of the mdoc, so that
+// a holder can release a subset of the data elements, each of which is
+// verified against the digests of the MSO.
+//
+// The MSO also carries the device key of the mdoc, which authenticates each
+// session with the mdoc reader, either with a COSE_Sign1 DeviceSignature or a
+// COSE_Mac0 DeviceMac structure. Since this library does not implement MAC
+// messages, the DeviceMac structure is supported by this package only, with
+// the HMAC 256/256 algorithm.
+//
+// Reference: ISO/IEC 18013-5:2021 Section 9.1.2 and 9.1.3
+//
+// # Experimental
+//
+// Notice: The mdoc package is EXPERIMENTAL and may be changed or removed in a
+// later release.
+package mdoc
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/veraison/go-cose"
+)
+
+// CBORTagEncodedCBOR is the CBOR tag of embedded CBOR data items, used to wrap
+// the mobile security object and the issuer signed items.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc8949.html#section-3.4.5.1
+const CBORTagEncodedCBOR = 24
+
+// Document type and name space of the mobile driving licence.
+//
+// Reference: ISO/IEC 18013-5:2021 Section 7.1
+const (
+	DocTypeMDL   = "org.iso.18013.5.1.mDL"
+	NameSpaceMDL = "org.iso.18013.5.1"
+)
+
+// MSOVersion is the supported version of the mobile security object.
+const MSOVersion = "1.0"
+
+// Digest algorithms of the mobile security object.
+//
+// Reference: ISO/IEC 18013-5:2021 Section 9.1.2.5
+const (
+	DigestAlgorithmSHA256 = "SHA-256"
+	DigestAlgorithmSHA384 = "SHA-384"
+	DigestAlgorithmSHA512 = "SHA-512"
+)
+
+// oidDocumentSigner is the extended key usage of document signer certificates.
+//
+// Reference: ISO/IEC 18013-5:2021 Annex B.1.4
+var oidDocumentSigner = asn1.ObjectIdentifier{1, 0, 18013, 5, 1, 2}
+
+// Common errors
+var (
+	ErrMSOExpired      = errors.New("mso expired")
+	ErrMSONotYetValid  = errors.New("mso not yet valid")
+	ErrDigestMismatch  = errors.New("digest mismatch")
+	ErrDocTypeMismatch = errors.New("doctype mismatch")
+)
+
+// Pre-configured modes for CBOR encoding and decoding.
+var (
+	encMode cbor.EncMode
+	decMode cbor.DecMode
+)
+
+func init() {
+	var err error
+
+	// init encode mode, encoding time.Time as tdate
+	encOpts := cbor.EncOptions{
+		Sort:        cbor.SortCoreDeterministic, // sort map keys
+		IndefLength: cbor.IndefLengthForbidden,  // no streaming
+		Time:        cbor.TimeRFC3339,           // no fractions of seconds
+		TimeTag:     cbor.EncTagRequired,        // tdate
+	}
+	encMode, err = encOpts.EncMode()
+	if err != nil {
+		panic(err)
+	}
+
+	// init decode mode
+	decOpts := cbor.DecOptions{
+		DupMapKey: cbor.DupMapKeyEnforcedAPF, // duplicated key not allowed
+		IntDec:    cbor.IntDecConvertSigned,  // decode CBOR uint/int to Go int64
+		TimeTag:   cbor.DecTagRequired,       // tdate
+	}
+	decMode, err = decOpts.DecMode()
+	if err != nil {
+		panic(err)
+	}
+}
+
+// MobileSecurityObject is the payload of the IssuerAuth structure.
+//
+// Reference: ISO/IEC 18013-5:2021 Section 9.1.2.4
+type MobileSecurityObject struct {
+	Version         string                       `cbor:"version"`
+	DigestAlgorithm string                       `cbor:"digestAlgorithm"`
+	ValueDigests    map[string]map[uint64][]byte `cbor:"valueDigests"`
+	DeviceKeyInfo   DeviceKeyInfo                `cbor:"deviceKeyInfo"`
+	DocType         string                       `cbor:"docType"`
+	ValidityInfo    ValidityInfo                 `cbor:"validityInfo"`
+}
+
+// DeviceKeyInfo holds the key of the mdoc device, used for the device
+// authentication.
+type DeviceKeyInfo struct {
+	DeviceKey *cose.Key `cbor:"deviceKey"`
+
+	// KeyAuthorizations and KeyInfo are left encoded.
+	KeyAuthorizations cbor.RawMessage `cbor:"keyAuthorizations,omitempty"`
+	KeyInfo           cbor.RawMessage `cbor:"keyInfo,omitempty"`
+}
+
+// ValidityInfo is the validity of the mobile security object.
+type ValidityInfo struct {
+	Signed         time.Time  `cbor:"signed"`
+	ValidFrom      time.Time  `cbor:"validFrom"`
+	ValidUntil     time.Time  `cbor:"validUntil"`
+	ExpectedUpdate *time.Time `cbor:"expectedUpdate,omitempty"`
+}
+
+// MarshalCBOR encodes the validity with UTC dates.
+func (v ValidityInfo) MarshalCBOR() ([]byte, error) {
+	type validityInfo ValidityInfo
+	utc := validityInfo{
+		Signed:     v.Signed.UTC(),
+		ValidFrom:  v.ValidFrom.UTC(),
+		ValidUntil: v.ValidUntil.UTC(),
+	}
+	if v.ExpectedUpdate != nil {
+		t := v.ExpectedUpdate.UTC()
+		utc.ExpectedUpdate = &t
+	}
+	return encMode.Marshal(utc)
+}
+
+// IssuerSignedItem is a data element signed by the issuer.
+//
+// Dates such as full-date values are given as cbor.Tag, and time.Time values
+// are encoded as tdate.
+//
+// Reference: ISO/IEC 18013-5:2021 Section 8.3.2.1.2.2
+type IssuerSignedItem struct {
+	DigestID          uint64 `cbor:"digestID"`
+	Random            []byte `cbor:"random"`
+	ElementIdentifier string `cbor:"elementIdentifier"`
+	ElementValue      any    `cbor:"elementValue"`
+}
+
+// EncodeIssuerSignedItem encodes the item as IssuerSignedItemBytes, that is the
+// encoded item wrapped in a CBOR tag 24.
+// Issuers should use at least 16 random bytes to prevent the value of the item
+// to be guessed from its digest.
+func EncodeIssuerSignedItem(item *IssuerSignedItem) ([]byte, error) {
+	if item == nil {
+		return nil, errors.New("encoding nil IssuerSignedItem")
+	}
+	return encodeEmbedded(item)
+}
+
+// DecodeIssuerSignedItem decodes IssuerSignedItemBytes.
+func DecodeIssuerSignedItem(data []byte) (*IssuerSignedItem, error) {
+	var item IssuerSignedItem
+	if err := decodeEmbedded(data, &item); err != nil {
+		return nil, fmt.Errorf("issuer signed item: %w", err)
+	}
+	return &item, nil
+}
+
+// IssuerSigned holds the issuer signed items of an mdoc, by name space, and
+// its IssuerAuth structure.
+//
+// The items are left encoded since their digests are computed over their
+// encoding.
+type IssuerSigned struct {
+	NameSpaces map[string][]cbor.RawMessage `cbor:"nameSpaces,omitempty"`
+	IssuerAuth cbor.RawMessage              `cbor:"issuerAuth"`
+}
+
+// Digest returns the digest of the IssuerSignedItemBytes with the digest
+// algorithm of the mobile security object.
+func (m *MobileSecurityObject) Digest(itemBytes []byte) ([]byte, error) {
+	hash, err := digestHash(m.DigestAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	h := hash.New()
+	h.Write(itemBytes)
+	return h.Sum(nil), nil
+}
+
+// SetValueDigest sets the digest of the IssuerSignedItemBytes in the name
+// space, indexed by the digest ID of the item.
+func (m *MobileSecurityObject) SetValueDigest(nameSpace string, itemBytes []byte) error {
+	item, err := DecodeIssuerSignedItem(itemBytes)
+	if err != nil {
+		return err
+	}
+	digest, err := m.Digest(itemBytes)
+	if err != nil {
+		return err
+	}
+	if m.ValueDigests == nil {
+		m.ValueDigests = make(map[string]map[uint64][]byte)
+	}
+	digests, ok := m.ValueDigests[nameSpace]
+	if !ok {
+		digests = make(map[uint64][]byte)
+		m.ValueDigests[nameSpace] = digests
+	}
+	digests[item.DigestID] = digest
+	return nil
+}
+
+// VerifyItem verifies the IssuerSignedItemBytes in the name space against the
+// value digests, and returns the decoded item.
+func (m *MobileSecurityObject) VerifyItem(nameSpace string, itemBytes []byte) (*IssuerSignedItem, error) {
+	item, err := DecodeIssuerSignedItem(itemBytes)
+	if err != nil {
+		return nil, err
+	}
+	want, ok := m.ValueDigests[nameSpace][item.DigestID]
+	if !ok {
+		return nil, fmt.Errorf("%s: digest ID %d: missing value digest", nameSpace, item.DigestID)
+	}
+	got, err := m.Digest(itemBytes)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare(got, want) != 1 {
+		return nil, fmt.Errorf("%s: %s: %w", nameSpace, item.ElementIdentifier, ErrDigestMismatch)
+	}
+	return item, nil
+}
+
+// validate validates the structure of the mobile security object.
+func (m *MobileSecurityObject) validate() error {
+	if m.Version != MSOVersion {
+		return fmt.Errorf("mso: version: unsupported version %q", m.Version)
+	}
+	if _, err := digestHash(m.DigestAlgorithm); err != nil {
+		return err
+	}
+	if m.DocType == "" {
+		return errors.New("mso: docType: missing")
+	}
+	if m.DeviceKeyInfo.DeviceKey == nil {
+		return errors.New("mso: deviceKeyInfo: missing device key")
+	}
+	return nil
+}
+
+// SignIssuerAuth signs the mobile security object with the key of the document
+// signer certificate, and returns the encoded IssuerAuth structure.
+// The chain, starting with the document signer certificate, is added to the
+// x5chain header parameter of the unprotected header.
+func SignIssuerAuth(rand io.Reader, signer cose.Signer, chain []*x509.Certificate, mso *MobileSecurityObject) ([]byte, error) {
+	if mso == nil {
+		return nil, errors.New("signing nil MobileSecurityObject")
+	}
+	if err := mso.validate(); err != nil {
+		return nil, err
+	}
+	payload, err := encodeEmbedded(mso)
+	if err != nil {
+		return nil, err
+	}
+	msg := cose.UntaggedSign1Message{
+		Headers: cose.Headers{
+			Protected: cose.ProtectedHeader{
+				cose.HeaderLabelAlgorithm: signer.Algorithm(),
+			},
+			Unprotected: cose.UnprotectedHeader{},
+		},
+		Payload: payload,
+	}
+	if err := msg.Headers.Unprotected.SetX5Chain(chain); err != nil {
+		return nil, err
+	}
+	if err := msg.Sign(rand, nil, signer); err != nil {
+		return nil, err
+	}
+	return msg.MarshalCBOR()
+}
+
+// ParseIssuerAuth decodes the IssuerAuth structure and its mobile security
+// object, without verifying the signature.
+// Both untagged and tagged COSE_Sign1 objects are accepted.
+func ParseIssuerAuth(data []byte) (*cose.Sign1Message, *MobileSecurityObject, error) {
+	var msg cose.Sign1Message
+	var err error
+	if bytes.HasPrefix(data, []byte{0xd2}) { // tag 18
+		err = msg.UnmarshalCBOR(data)
+	} else {
+		err = (*cose.UntaggedSign1Message)(&msg).UnmarshalCBOR(data)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if msg.Payload == nil {
+		return nil, nil, cose.ErrMissingPayload
+	}
+	var mso MobileSecurityObject
+	if err := decodeEmbedded(msg.Payload, &mso); err != nil {
+		return nil, nil, fmt.Errorf("mso: %w", err)
+	}
+	if err := mso.validate(); err != nil {
+		return nil, nil, err
+	}
+	return &msg, &mso, nil
+}
+
+// Verifier verifies the issuer authentication of mdocs against the trusted
+// issuing authority certificates.
+type Verifier struct {
+	// Roots is the set of trusted issuing authority CA (IACA) certificates.
+	Roots *x509.CertPool
+
+	// Now returns the current time. If nil, time.Now is used.
+	Now func() time.Time
+}
+
+// VerifyIssuerAuth verifies the document signer certificate chain and the
+// signature of the IssuerAuth structure, and the validity of its mobile
+// security object.
+// It returns the verified mobile security object and certificate chain.
+func (v *Verifier) VerifyIssuerAuth(data []byte) (*MobileSecurityObject, []*x509.Certificate, error) {
+	msg, mso, err := ParseIssuerAuth(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	now := v.now()
+	x509Verifier := cose.NewX509Verifier(v.Roots, x509.VerifyOptions{
+		CurrentTime: now,
+	})
+	chain, err := x509Verifier.VerifySign1(msg, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !isDocumentSigner(chain[0]) {
+		return nil, nil, errors.New("x5chain: certificate is not a document signer certificate")
+	}
+
+	validity := mso.ValidityInfo
+	switch {
+	case validity.Signed.Before(chain[0].NotBefore) || validity.Signed.After(chain[0].NotAfter):
+		return nil, nil, errors.New("mso: validityInfo: signed outside of the document signer certificate validity")
+	case validity.ValidFrom.Before(validity.Signed):
+		return nil, nil, errors.New("mso: validityInfo: validFrom before signed")
+	case !validity.ValidUntil.After(validity.ValidFrom):
+		return nil, nil, errors.New("mso: validityInfo: validUntil not after validFrom")
+	case now.Before(validity.ValidFrom):
+		return nil, nil, ErrMSONotYetValid
+	case now.After(validity.ValidUntil):
+		return nil, nil, ErrMSOExpired
+	}
+	return mso, chain, nil
+}
+
+// VerifyIssuerSigned verifies the IssuerAuth structure of the mdoc with the
+// document type, and all its issuer signed items against the value digests.
+// It returns the verified mobile security object and the decoded items, by
+// name space.
+func (v *Verifier) VerifyIssuerSigned(docType string, issuerSigned *IssuerSigned) (*MobileSecurityObject, map[string][]*IssuerSignedItem, error) {
+	if issuerSigned == nil {
+		return nil, nil, errors.New("verifying nil IssuerSigned")
+	}
+	mso, _, err := v.VerifyIssuerAuth(issuerSigned.IssuerAuth)
+	if err != nil {
+		return nil, nil, err
+	}
+	if mso.DocType != docType {
+		return nil, nil, ErrDocTypeMismatch
+	}
+	items := make(map[string][]*IssuerSignedItem, len(issuerSigned.NameSpaces))
+	for nameSpace, itemsBytes := range issuerSigned.NameSpaces {
+		for _, itemBytes := range itemsBytes {
+			item, err := mso.VerifyItem(nameSpace, itemBytes)
+			if err != nil {
+				return nil, nil, err
+			}
+			items[nameSpace] = append(items[nameSpace], item)
+		}
+	}
+	return mso, items, nil
+}
+
+// now returns the current time of the verifier.
+func (v *Verifier) now() time.Time {
+	if v.Now != nil {
+		return v.Now()
+	}
+	return time.Now()
+}
+
+// isDocumentSigner reports whether the certificate has the extended key usage
+// of document signer certificates.
+func isDocumentSigner(cert *x509.Certificate) bool {
+	for _, oid := range cert.UnknownExtKeyUsage {
+		if oid.Equal(oidDocumentSigner) {
+			return true
+		}
+	}
+	return false
+}
+
+// digestHash returns the hash function of the digest algorithm.
+func digestHash(name string) (crypto.Hash, error) {
+	var hash crypto.Hash
+	switch name {
+	case DigestAlgorithmSHA256:
+		hash = crypto.SHA256
+	case DigestAlgorithmSHA384:
+		hash = crypto.SHA384
+	case DigestAlgorithmSHA512:
+		hash = crypto.SHA512
+	default:
+		return 0, fmt.Errorf("mso: digestAlgorithm: unsupported algorithm %q", name)
+	}
+	if !hash.Available() {
+		return 0, cose.ErrUnavailableHashFunc
+	}
+	return hash, nil
+}
+
+// encodeEmbedded encodes the value wrapped in a CBOR tag 24.
+func encodeEmbedded(v any) ([]byte, error) {
+	content, err := encMode.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return encMode.Marshal(cbor.Tag{
+		Number:  CBORTagEncodedCBOR,
+		Content: content,
+	})
+}
+
+// decodeEmbedded decodes a value wrapped in a CBOR tag 24.
+func decodeEmbedded(data []byte, v any) error {
+	var tag cbor.RawTag
+	if err := decMode.Unmarshal(data, &tag); err != nil {
+		var typeErr *cbor.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return errors.New("require embedded CBOR data item")
+		}
+		return err
+	}
+	if tag.Number != CBORTagEncodedCBOR {
+		return errors.New("require embedded CBOR data item")
+	}
+	var content []byte
+	if err := decMode.Unmarshal(tag.Content, &content); err != nil {
+		return errors.New("require embedded CBOR data item")
+	}
+	return decMode.Unmarshal(content, v)
+}
@@ -0,0 +1,508 @@
+package mdoc
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/veraison/go-cose"
+)
+
+// testNow is the verification time of the tests.
+var testNow = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func generateTestECDSAKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey() error = %v", err)
+	}
+	return key
+}
+
+// createTestCertificate creates a certificate from template signed by the
+// parent certificate, or self-signed if parent is nil.
+func createTestCertificate(t *testing.T, template, parent *x509.Certificate, pub crypto.PublicKey, priv crypto.Signer) *x509.Certificate {
+	t.Helper()
+	if parent == nil {
+		parent = template
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, pub, priv)
+	if err != nil {
+		t.Fatalf("x509.CreateCertificate() error = %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("x509.ParseCertificate() error = %v", err)
+	}
+	return cert
+}
+
+// generateTestIssuer returns an IACA certificate, and a document signer
+// certificate issued by it with its signer.
+// The document signer certificate has the extended key usages.
+func generateTestIssuer(t *testing.T, name string, extKeyUsages ...asn1.ObjectIdentifier) (*x509.Certificate, *x509.Certificate, cose.Signer) {
+	t.Helper()
+	iacaKey := generateTestECDSAKey(t)
+	iaca := createTestCertificate(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name + " IACA", Country: []string{"US"}},
+		NotBefore:             testNow.AddDate(-1, 0, 0),
+		NotAfter:              testNow.AddDate(5, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, nil, iacaKey.Public(), iacaKey)
+
+	dsKey := generateTestECDSAKey(t)
+	ds := createTestCertificate(t, &x509.Certificate{
+		SerialNumber:       big.NewInt(2),
+		Subject:            pkix.Name{CommonName: name + " DS", Country: []string{"US"}},
+		NotBefore:          testNow.AddDate(0, -1, 0),
+		NotAfter:           testNow.AddDate(1, 0, 0),
+		KeyUsage:           x509.KeyUsageDigitalSignature,
+		UnknownExtKeyUsage: extKeyUsages,
+	}, iaca, dsKey.Public(), iacaKey)
+
+	signer, err := cose.NewSigner(cose.AlgorithmES256, dsKey)
+	if err != nil {
+		t.Fatalf("NewSigner() error = %v", err)
+	}
+	return iaca, ds, signer
+}
+
+// newTestMSO returns a mobile security object valid at testNow, without value
+// digests.
+func newTestMSO(t *testing.T) *MobileSecurityObject {
+	t.Helper()
+	deviceKey, err := cose.NewKeyFromPublic(generateTestECDSAKey(t).Public())
+	if err != nil {
+		t.Fatalf("NewKeyFromPublic() error = %v", err)
+	}
+	return &MobileSecurityObject{
+		Version:         MSOVersion,
+		DigestAlgorithm: DigestAlgorithmSHA256,
+		DeviceKeyInfo: DeviceKeyInfo{
+			DeviceKey: deviceKey,
+		},
+		DocType: DocTypeMDL,
+		ValidityInfo: ValidityInfo{
+			Signed:     testNow.AddDate(0, 0, -1),
+			ValidFrom:  testNow.AddDate(0, 0, -1),
+			ValidUntil: testNow.AddDate(0, 1, 0),
+		},
+	}
+}
+
+// testItems are the issuer signed items of the mDL name space.
+var testItems = []*IssuerSignedItem{
+	{
+		DigestID:          0,
+		Random:            bytes.Repeat([]byte{0x01}, 16),
+		ElementIdentifier: "family_name",
+		ElementValue:      "Doe",
+	},
+	{
+		DigestID:          1,
+		Random:            bytes.Repeat([]byte{0x02}, 16),
+		ElementIdentifier: "birth_date",
+		ElementValue:      cbor.Tag{Number: 1004, Content: "1990-01-01"},
+	},
+	{
+		DigestID:          2,
+		Random:            bytes.Repeat([]byte{0x03}, 16),
+		ElementIdentifier: "age_over_18",
+		ElementValue:      true,
+	},
+}
+
+// newTestIssuerSigned returns the IssuerSigned structure of the test items,
+// signed by the document signer with the mobile security object.
+func newTestIssuerSigned(t *testing.T, ds *x509.Certificate, signer cose.Signer, mso *MobileSecurityObject) *IssuerSigned {
+	t.Helper()
+	issuerSigned := &IssuerSigned{
+		NameSpaces: map[string][]cbor.RawMessage{},
+	}
+	for _, item := range testItems {
+		itemBytes, err := EncodeIssuerSignedItem(item)
+		if err != nil {
+			t.Fatalf("EncodeIssuerSignedItem() error = %v", err)
+		}
+		if err := mso.SetValueDigest(NameSpaceMDL, itemBytes); err != nil {
+			t.Fatalf("MobileSecurityObject.SetValueDigest() error = %v", err)
+		}
+		issuerSigned.NameSpaces[NameSpaceMDL] = append(issuerSigned.NameSpaces[NameSpaceMDL], itemBytes)
+	}
+	issuerAuth, err := SignIssuerAuth(rand.Reader, signer, []*x509.Certificate{ds}, mso)
+	if err != nil {
+		t.Fatalf("SignIssuerAuth() error = %v", err)
+	}
+	issuerSigned.IssuerAuth = issuerAuth
+	return issuerSigned
+}
+
+func TestVerifier_VerifyIssuerSigned(t *testing.T) {
+	iaca, ds, signer := generateTestIssuer(t, "Test", oidDocumentSigner)
+	roots := x509.NewCertPool()
+	roots.AddCert(iaca)
+	verifier := &Verifier{
+		Roots: roots,
+		Now:   func() time.Time { return testNow },
+	}
+
+	mso := newTestMSO(t)
+	issuerSigned := newTestIssuerSigned(t, ds, signer, mso)
+	data, err := cbor.Marshal(issuerSigned)
+	if err != nil {
+		t.Fatalf("cbor.Marshal() error = %v", err)
+	}
+	var decoded IssuerSigned
+	if err := cbor.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("cbor.Unmarshal() error = %v", err)
+	}
+
+	gotMSO, items, err := verifier.VerifyIssuerSigned(DocTypeMDL, &decoded)
+	if err != nil {
+		t.Fatalf("Verifier.VerifyIssuerSigned() error = %v", err)
+	}
+	if !gotMSO.ValidityInfo.ValidUntil.Equal(mso.ValidityInfo.ValidUntil) {
+		t.Errorf("Verifier.VerifyIssuerSigned() validUntil = %v, want %v", gotMSO.ValidityInfo.ValidUntil, mso.ValidityInfo.ValidUntil)
+	}
+	if !reflect.DeepEqual(gotMSO.ValueDigests, mso.ValueDigests) {
+		t.Errorf("Verifier.VerifyIssuerSigned() valueDigests = %v, want %v", gotMSO.ValueDigests, mso.ValueDigests)
+	}
+	// coordinates are padded when encoded, compare the encoded keys
+	gotKey, err := gotMSO.DeviceKeyInfo.DeviceKey.MarshalCBOR()
+	if err != nil {
+		t.Fatalf("Key.MarshalCBOR() error = %v", err)
+	}
+	wantKey, err := mso.DeviceKeyInfo.DeviceKey.MarshalCBOR()
+	if err != nil {
+		t.Fatalf("Key.MarshalCBOR() error = %v", err)
+	}
+	if !bytes.Equal(gotKey, wantKey) {
+		t.Errorf("Verifier.VerifyIssuerSigned() deviceKey = %v, want %v", gotMSO.DeviceKeyInfo.DeviceKey, mso.DeviceKeyInfo.DeviceKey)
+	}
+	if !reflect.DeepEqual(items[NameSpaceMDL], testItems) {
+		t.Errorf("Verifier.VerifyIssuerSigned() items = %v, want %v", items[NameSpaceMDL], testItems)
+	}
+
+	_, chain, err := verifier.VerifyIssuerAuth(issuerSigned.IssuerAuth)
+	if err != nil {
+		t.Fatalf("Verifier.VerifyIssuerAuth() error = %v", err)
+	}
+	if want := []*x509.Certificate{ds, iaca}; !reflect.DeepEqual(chain, want) {
+		t.Errorf("Verifier.VerifyIssuerAuth() chain = %v, want %v", chain, want)
+	}
+}
+
+func TestVerifier_VerifyIssuerSigned_Error(t *testing.T) {
+	iaca, ds, signer := generateTestIssuer(t, "Test", oidDocumentSigner)
+	roots := x509.NewCertPool()
+	roots.AddCert(iaca)
+	_, otherDS, otherSigner := generateTestIssuer(t, "Other", oidDocumentSigner)
+	notDSIACA, notDS, notDSSigner := generateTestIssuer(t, "Not DS")
+	roots.AddCert(notDSIACA)
+
+	tests := []struct {
+		name         string
+		docType      string
+		now          time.Time
+		issuerSigned func() *IssuerSigned
+		wantErr      error
+		wantErrText  string
+	}{
+		{
+			name:    "expired",
+			docType: DocTypeMDL,
+			now:     testNow.AddDate(0, 2, 0),
+			issuerSigned: func() *IssuerSigned {
+				return newTestIssuerSigned(t, ds, signer, newTestMSO(t))
+			},
+			wantErr: ErrMSOExpired,
+		},
+		{
+			name:    "not yet valid",
+			docType: DocTypeMDL,
+			now:     testNow,
+			issuerSigned: func() *IssuerSigned {
+				mso := newTestMSO(t)
+				mso.ValidityInfo.ValidFrom = testNow.Add(time.Hour)
+				return newTestIssuerSigned(t, ds, signer, mso)
+			},
+			wantErr: ErrMSONotYetValid,
+		},
+		{
+			name:    "signed outside of the certificate validity",
+			docType: DocTypeMDL,
+			now:     testNow,
+			issuerSigned: func() *IssuerSigned {
+				mso := newTestMSO(t)
+				mso.ValidityInfo.Signed = testNow.AddDate(0, -2, 0)
+				return newTestIssuerSigned(t, ds, signer, mso)
+			},
+			wantErrText: "mso: validityInfo: signed outside of the document signer certificate validity",
+		},
+		{
+			name:    "valid from before signed",
+			docType: DocTypeMDL,
+			now:     testNow,
+			issuerSigned: func() *IssuerSigned {
+				mso := newTestMSO(t)
+				mso.ValidityInfo.ValidFrom = testNow.AddDate(0, 0, -2)
+				return newTestIssuerSigned(t, ds, signer, mso)
+			},
+			wantErrText: "mso: validityInfo: validFrom before signed",
+		},
+		{
+			name:    "doctype mismatch",
+			docType: "org.iso.23220.photoid.1",
+			now:     testNow,
+			issuerSigned: func() *IssuerSigned {
+				return newTestIssuerSigned(t, ds, signer, newTestMSO(t))
+			},
+			wantErr: ErrDocTypeMismatch,
+		},
+		{
+			name:    "untrusted document signer",
+			docType: DocTypeMDL,
+			now:     testNow,
+			issuerSigned: func() *IssuerSigned {
+				return newTestIssuerSigned(t, otherDS, otherSigner, newTestMSO(t))
+			},
+			wantErrText: "x5chain: x509: certificate signed by unknown authority",
+		},
+		{
+			name:    "not a document signer certificate",
+			docType: DocTypeMDL,
+			now:     testNow,
+			issuerSigned: func() *IssuerSigned {
+				return newTestIssuerSigned(t, notDS, notDSSigner, newTestMSO(t))
+			},
+			wantErrText: "x5chain: certificate is not a document signer certificate",
+		},
+		{
+			name:    "signed by another key",
+			docType: DocTypeMDL,
+			now:     testNow,
+			issuerSigned: func() *IssuerSigned {
+				return newTestIssuerSigned(t, ds, otherSigner, newTestMSO(t))
+			},
+			wantErr: cose.ErrVerification,
+		},
+		{
+			name:    "tampered item",
+			docType: DocTypeMDL,
+			now:     testNow,
+			issuerSigned: func() *IssuerSigned {
+				issuerSigned := newTestIssuerSigned(t, ds, signer, newTestMSO(t))
+				item := *testItems[0]
+				item.ElementValue = "Roe"
+				itemBytes, err := EncodeIssuerSignedItem(&item)
+				if err != nil {
+					t.Fatalf("EncodeIssuerSignedItem() error = %v", err)
+				}
+				issuerSigned.NameSpaces[NameSpaceMDL][0] = itemBytes
+				return issuerSigned
+			},
+			wantErr:     ErrDigestMismatch,
+			wantErrText: "org.iso.18013.5.1: family_name: digest mismatch",
+		},
+		{
+			name:    "item of unknown name space",
+			docType: DocTypeMDL,
+			now:     testNow,
+			issuerSigned: func() *IssuerSigned {
+				issuerSigned := newTestIssuerSigned(t, ds, signer, newTestMSO(t))
+				issuerSigned.NameSpaces["org.example"] = issuerSigned.NameSpaces[NameSpaceMDL][:1]
+				delete(issuerSigned.NameSpaces, NameSpaceMDL)
+				return issuerSigned
+			},
+			wantErrText: "org.example: digest ID 0: missing value digest",
+		},
+		{
+			name:    "item not wrapped",
+			docType: DocTypeMDL,
+			now:     testNow,
+			issuerSigned: func() *IssuerSigned {
+				issuerSigned := newTestIssuerSigned(t, ds, signer, newTestMSO(t))
+				itemBytes, err := encMode.Marshal(testItems[0])
+				if err != nil {
+					t.Fatalf("Marshal() error = %v", err)
+				}
+				issuerSigned.NameSpaces[NameSpaceMDL][0] = itemBytes
+				return issuerSigned
+			},
+			wantErrText: "issuer signed item: require embedded CBOR data item",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier := &Verifier{
+				Roots: roots,
+				Now:   func() time.Time { return tt.now },
+			}
+			_, _, err := verifier.VerifyIssuerSigned(tt.docType, tt.issuerSigned())
+			if err == nil {
+				t.Fatal("Verifier.VerifyIssuerSigned() error = nil")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verifier.VerifyIssuerSigned() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErrText != "" && err.Error() != tt.wantErrText {
+				t.Errorf("Verifier.VerifyIssuerSigned() error = %v, wantErr %v", err, tt.wantErrText)
+			}
+		})
+	}
+}
+
+func TestParseIssuerAuth(t *testing.T) {
+	_, ds, signer := generateTestIssuer(t, "Test", oidDocumentSigner)
+
+	sign := func(t *testing.T, payload []byte) []byte {
+		t.Helper()
+		msg := cose.UntaggedSign1Message{
+			Headers: cose.Headers{
+				Protected: cose.ProtectedHeader{
+					cose.HeaderLabelAlgorithm: signer.Algorithm(),
+				},
+			},
+			Payload: payload,
+		}
+		if err := msg.Sign(rand.Reader, nil, signer); err != nil {
+			t.Fatalf("UntaggedSign1Message.Sign() error = %v", err)
+		}
+		data, err := msg.MarshalCBOR()
+		if err != nil {
+			t.Fatalf("UntaggedSign1Message.MarshalCBOR() error = %v", err)
+		}
+		return data
+	}
+	signMSO := func(t *testing.T, mso *MobileSecurityObject) []byte {
+		t.Helper()
+		payload, err := encodeEmbedded(mso)
+		if err != nil {
+			t.Fatalf("encodeEmbedded() error = %v", err)
+		}
+		return sign(t, payload)
+	}
+
+	t.Run("tagged", func(t *testing.T) {
+		data, err := SignIssuerAuth(rand.Reader, signer, []*x509.Certificate{ds}, newTestMSO(t))
+		if err != nil {
+			t.Fatalf("SignIssuerAuth() error = %v", err)
+		}
+		var msg cose.UntaggedSign1Message
+		if err := msg.UnmarshalCBOR(data); err != nil {
+			t.Fatalf("UntaggedSign1Message.UnmarshalCBOR() error = %v", err)
+		}
+		tagged, err := (*cose.Sign1Message)(&msg).MarshalCBOR()
+		if err != nil {
+			t.Fatalf("Sign1Message.MarshalCBOR() error = %v", err)
+		}
+		if _, _, err := ParseIssuerAuth(tagged); err != nil {
+			t.Errorf("ParseIssuerAuth() error = %v", err)
+		}
+	})
+
+	tests := []struct {
+		name    string
+		data    func(t *testing.T) []byte
+		wantErr string
+	}{
+		{
+			name: "payload not wrapped",
+			data: func(t *testing.T) []byte {
+				payload, err := encMode.Marshal(newTestMSO(t))
+				if err != nil {
+					t.Fatalf("Marshal() error = %v", err)
+				}
+				return sign(t, payload)
+			},
+			wantErr: "mso: require embedded CBOR data item",
+		},
+		{
+			name: "unsupported version",
+			data: func(t *testing.T) []byte {
+				mso := newTestMSO(t)
+				mso.Version = "2.0"
+				return signMSO(t, mso)
+			},
+			wantErr: `mso: version: unsupported version "2.0"`,
+		},
+		{
+			name: "unsupported digest algorithm",
+			data: func(t *testing.T) []byte {
+				mso := newTestMSO(t)
+				mso.DigestAlgorithm = "SHA-1"
+				return signMSO(t, mso)
+			},
+			wantErr: `mso: digestAlgorithm: unsupported algorithm "SHA-1"`,
+		},
+		{
+			name: "missing device key",
+			data: func(t *testing.T) []byte {
+				mso := newTestMSO(t)
+				mso.DeviceKeyInfo.DeviceKey = nil
+				return signMSO(t, mso)
+			},
+			wantErr: "mso: deviceKeyInfo: missing device key",
+		},
+		{
+			name: "missing doctype",
+			data: func(t *testing.T) []byte {
+				mso := newTestMSO(t)
+				mso.DocType = ""
+				return signMSO(t, mso)
+			},
+			wantErr: "mso: docType: missing",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := ParseIssuerAuth(tt.data(t))
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("ParseIssuerAuth() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidityInfo_MarshalCBOR(t *testing.T) {
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	expectedUpdate := time.Date(2025, 7, 1, 2, 0, 0, 0, loc)
+	validity := ValidityInfo{
+		Signed:         time.Date(2025, 6, 1, 14, 0, 0, 500, loc),
+		ValidFrom:      time.Date(2025, 6, 1, 14, 0, 0, 0, loc),
+		ValidUntil:     time.Date(2026, 6, 1, 14, 0, 0, 0, loc),
+		ExpectedUpdate: &expectedUpdate,
+	}
+	data, err := encMode.Marshal(validity)
+	if err != nil {
+		t.Fatalf("ValidityInfo.MarshalCBOR() error = %v", err)
+	}
+	var got map[string]cbor.Tag
+	if err := decMode.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	want := map[string]cbor.Tag{
+		"signed":         {Number: 0, Content: "2025-06-01T12:00:00Z"},
+		"validFrom":      {Number: 0, Content: "2025-06-01T12:00:00Z"},
+		"validUntil":     {Number: 0, Content: "2026-06-01T12:00:00Z"},
+		"expectedUpdate": {Number: 0, Content: "2025-07-01T00:00:00Z"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ValidityInfo.MarshalCBOR() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,483 @@
+package cose
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Profiles of PSA attestation tokens.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9783.html#section-5
+const (
+	// PSAProfile2019 is the legacy profile of PSA attestation tokens, using
+	// private use claim keys.
+	PSAProfile2019 = "PSA_IOT_PROFILE_1"
+
+	// PSAProfile2023 is the profile of RFC 9783.
+	PSAProfile2023 = "tag:psacertified.org,2023:psa#tfm"
+)
+
+// PSA attestation token claims registered in the IANA "CBOR Web Token (CWT)
+// Claims" registry.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9783.html#section-10.1
+const (
+	CWTClaimPSAClientID                     int64 = 2394
+	CWTClaimPSASecurityLifecycle            int64 = 2395
+	CWTClaimPSAImplementationID             int64 = 2396
+	CWTClaimPSABootSeed                     int64 = 2397
+	CWTClaimPSACertificationReference       int64 = 2398
+	CWTClaimPSASoftwareComponents           int64 = 2399
+	CWTClaimPSAVerificationServiceIndicator int64 = 2400
+)
+
+// Claims of the legacy PSA_IOT_PROFILE_1 profile.
+const (
+	psaLegacyProfile                int64 = -75000
+	psaLegacyClientID               int64 = -75001
+	psaLegacySecurityLifecycle      int64 = -75002
+	psaLegacyImplementationID       int64 = -75003
+	psaLegacyBootSeed               int64 = -75004
+	psaLegacyHardwareVersion        int64 = -75005
+	psaLegacySoftwareComponents     int64 = -75006
+	psaLegacyNoSoftwareMeasurements int64 = -75007
+	psaLegacyNonce                  int64 = -75008
+	psaLegacyInstanceID             int64 = -75009
+	psaLegacyVerificationService    int64 = -75010
+)
+
+// psaClaims are the claim keys of a profile.
+type psaClaims struct {
+	profile, clientID, securityLifecycle, implementationID, bootSeed,
+	certificationReference, softwareComponents, nonce, instanceID,
+	verificationService int64
+}
+
+var (
+	psaClaims2019 = psaClaims{
+		profile:                psaLegacyProfile,
+		clientID:               psaLegacyClientID,
+		securityLifecycle:      psaLegacySecurityLifecycle,
+		implementationID:       psaLegacyImplementationID,
+		bootSeed:               psaLegacyBootSeed,
+		certificationReference: psaLegacyHardwareVersion,
+		softwareComponents:     psaLegacySoftwareComponents,
+		nonce:                  psaLegacyNonce,
+		instanceID:             psaLegacyInstanceID,
+		verificationService:    psaLegacyVerificationService,
+	}
+	psaClaims2023 = psaClaims{
+		profile:                CWTClaimProfile,
+		clientID:               CWTClaimPSAClientID,
+		securityLifecycle:      CWTClaimPSASecurityLifecycle,
+		implementationID:       CWTClaimPSAImplementationID,
+		bootSeed:               CWTClaimPSABootSeed,
+		certificationReference: CWTClaimPSACertificationReference,
+		softwareComponents:     CWTClaimPSASoftwareComponents,
+		nonce:                  CWTClaimNonce,
+		instanceID:             CWTClaimUEID,
+		verificationService:    CWTClaimPSAVerificationServiceIndicator,
+	}
+)
+
+// Labels of the software components of PSA attestation tokens.
+const (
+	psaComponentMeasurementType        int64 = 1
+	psaComponentMeasurementValue       int64 = 2
+	psaComponentVersion                int64 = 4
+	psaComponentSignerID               int64 = 5
+	psaComponentMeasurementDescription int64 = 6
+)
+
+// PSASoftwareComponent is a measured software component of a PSA attestation
+// token.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9783.html#section-4.4.1
+type PSASoftwareComponent struct {
+	// MeasurementType is the role of the component, e.g. "BL" or "PRoT".
+	// It is optional.
+	MeasurementType string
+
+	// MeasurementValue is the hash of the component.
+	MeasurementValue []byte
+
+	// Version is the version of the component. It is optional.
+	Version string
+
+	// SignerID is the hash of the key of the signer of the component.
+	SignerID []byte
+
+	// MeasurementDescription is the hash algorithm of the measurement, e.g.
+	// "sha-256". It is optional.
+	MeasurementDescription string
+}
+
+// PSAToken is a PSA attestation token, a CWT signed by the Initial Attestation
+// Key (IAK) of a device.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9783.html
+type PSAToken struct {
+	// Profile is either PSAProfile2019 or PSAProfile2023.
+	Profile string
+
+	// ClientID is the partition of the caller of the attestation service.
+	ClientID int64
+
+	// SecurityLifecycle is the security lifecycle state of the device.
+	SecurityLifecycle uint16
+
+	// ImplementationID identifies the implementation of the immutable PSA
+	// Root of Trust.
+	ImplementationID []byte
+
+	// BootSeed is the random value of the boot session. It is mandatory in
+	// the PSAProfile2019 profile only, where it is 32 bytes long, and 8 to 32
+	// bytes long in the PSAProfile2023 profile.
+	BootSeed []byte
+
+	// CertificationReference is the PSA Certified certification reference, or
+	// the hardware version in the PSAProfile2019 profile. It is optional.
+	CertificationReference string
+
+	// SoftwareComponents are the measured software components.
+	SoftwareComponents []PSASoftwareComponent
+
+	// NoSoftwareMeasurements indicates that the software is not measured,
+	// in place of SoftwareComponents, in the PSAProfile2019 profile only.
+	NoSoftwareMeasurements bool
+
+	// Nonce is the challenge of the verifier.
+	Nonce []byte
+
+	// InstanceID is the UEID of the IAK.
+	InstanceID []byte
+
+	// VerificationServiceIndicator is the hint to locate a verification
+	// service. It is optional.
+	VerificationServiceIndicator string
+
+	// Message is the COSE_Sign1 message of the token, set by ParsePSAToken.
+	Message *Sign1Message
+}
+
+// PSAKeyResolver resolves the Initial Attestation Key (IAK) of a device.
+type PSAKeyResolver interface {
+	// ResolveIAK returns a verifier for the IAK identified by the Instance ID.
+	ResolveIAK(instanceID []byte) (Verifier, error)
+}
+
+// ParsePSAToken decodes a PSA attestation token of the PSAProfile2019 or the
+// PSAProfile2023 profile, and validates its claims.
+//
+// The signature is not verified, see PSAToken.Verify.
+func ParsePSAToken(data []byte) (*PSAToken, error) {
+	msg, set, err := ParseCWT(data)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := set.Claims()
+	if err != nil {
+		return nil, err
+	}
+	t, err := psaToken(claims)
+	if err != nil {
+		return nil, err
+	}
+	t.Message = msg
+	return t, nil
+}
+
+// psaToken decodes and validates the claims of a PSA attestation token.
+func psaToken(claims CWTClaims) (*PSAToken, error) {
+	t := &PSAToken{}
+	var keys psaClaims
+	switch {
+	case hasLabel(claims, CWTClaimProfile):
+		keys = psaClaims2023
+	case hasLabel(claims, psaLegacyProfile):
+		keys = psaClaims2019
+	default:
+		return nil, fmt.Errorf("cwt claim: eat_profile: %w", ErrCWTMissingClaim)
+	}
+	profile, ok := claims[keys.profile].(string)
+	if !ok {
+		return nil, errors.New("cwt claim: eat_profile: require tstr")
+	}
+	switch {
+	case keys == psaClaims2023 && profile == PSAProfile2023,
+		keys == psaClaims2019 && profile == PSAProfile2019:
+		t.Profile = profile
+	default:
+		return nil, fmt.Errorf("cwt claim: eat_profile: unsupported profile %q", profile)
+	}
+
+	value, ok := claims[keys.clientID]
+	if !ok {
+		return nil, fmt.Errorf("cwt claim: psa-client-id: %w", ErrCWTMissingClaim)
+	}
+	clientID, ok := numericDate(value)
+	if !ok || !canInt(value) {
+		return nil, errors.New("cwt claim: psa-client-id: require int")
+	}
+	t.ClientID = clientID.value.(int64)
+
+	value, ok = claims[keys.securityLifecycle]
+	if !ok {
+		return nil, fmt.Errorf("cwt claim: psa-lifecycle: %w", ErrCWTMissingClaim)
+	}
+	lifecycle, ok := eatUint(value, 0xffff)
+	if !ok || !psaValidLifecycle(uint16(lifecycle)) {
+		return nil, errors.New("cwt claim: psa-lifecycle: require security lifecycle state")
+	}
+	t.SecurityLifecycle = uint16(lifecycle)
+
+	var err error
+	if t.ImplementationID, err = psaBytes(claims, keys.implementationID, "psa-implementation-id", 32); err != nil {
+		return nil, err
+	}
+	if t.InstanceID, err = psaBytes(claims, keys.instanceID, "ueid", 33); err != nil {
+		return nil, err
+	}
+	if t.InstanceID[0] != 0x01 {
+		return nil, errors.New("cwt claim: ueid: require RAND type instance ID")
+	}
+	if t.Nonce, err = psaBytes(claims, keys.nonce, "eat_nonce", 32, 48, 64); err != nil {
+		return nil, err
+	}
+	if keys == psaClaims2019 {
+		if t.BootSeed, err = psaBytes(claims, keys.bootSeed, "psa-boot-seed", 32); err != nil {
+			return nil, err
+		}
+	} else if hasLabel(claims, keys.bootSeed) {
+		if t.BootSeed, err = psaBytesRange(claims, keys.bootSeed, "psa-boot-seed", 8, 32); err != nil {
+			return nil, err
+		}
+	}
+	if t.CertificationReference, err = psaTstr(claims, keys.certificationReference, "psa-certification-reference"); err != nil {
+		return nil, err
+	}
+	if t.VerificationServiceIndicator, err = psaTstr(claims, keys.verificationService, "psa-verification-service-indicator"); err != nil {
+		return nil, err
+	}
+
+	value, ok = claims[keys.softwareComponents]
+	switch {
+	case ok:
+		components, isArray := value.([]any)
+		if !isArray || len(components) == 0 {
+			return nil, errors.New("cwt claim: psa-software-components: require non-empty array")
+		}
+		for i, c := range components {
+			component, err := psaSoftwareComponent(c)
+			if err != nil {
+				return nil, fmt.Errorf("cwt claim: psa-software-components: %d: %w", i, err)
+			}
+			t.SoftwareComponents = append(t.SoftwareComponents, *component)
+		}
+		if keys == psaClaims2019 && hasLabel(claims, psaLegacyNoSoftwareMeasurements) {
+			return nil, errors.New("cwt claim: psa-software-components: not allowed with no software measurements")
+		}
+	case keys == psaClaims2019 && hasLabel(claims, psaLegacyNoSoftwareMeasurements):
+		if v, ok := eatUint(claims[psaLegacyNoSoftwareMeasurements], 1); !ok || v != 1 {
+			return nil, errors.New("cwt claim: no-sw-measurements: require 1")
+		}
+		t.NoSoftwareMeasurements = true
+	default:
+		return nil, fmt.Errorf("cwt claim: psa-software-components: %w", ErrCWTMissingClaim)
+	}
+	return t, nil
+}
+
+// psaValidLifecycle reports whether the security lifecycle is a known state:
+// unknown, assembly and test, PSA RoT provisioning, secured, non-PSA RoT
+// debug, recoverable PSA RoT debug or decommissioned.
+func psaValidLifecycle(lifecycle uint16) bool {
+	return lifecycle&0x0f00 == 0 && lifecycle>>12 <= 6
+}
+
+// psaBytes returns a mandatory bstr claim of one of the given sizes.
+func psaBytes(claims CWTClaims, label int64, name string, sizes ...int) ([]byte, error) {
+	b, err := psaBstr(claims, label, name)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(sizes, len(b)) {
+		return nil, fmt.Errorf("cwt claim: %s: invalid size %d", name, len(b))
+	}
+	return b, nil
+}
+
+// psaBytesRange returns a mandatory bstr claim with a size between minSize and
+// maxSize, inclusive.
+func psaBytesRange(claims CWTClaims, label int64, name string, minSize, maxSize int) ([]byte, error) {
+	b, err := psaBstr(claims, label, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < minSize || len(b) > maxSize {
+		return nil, fmt.Errorf("cwt claim: %s: invalid size %d", name, len(b))
+	}
+	return b, nil
+}
+
+// psaBstr returns a mandatory bstr claim.
+func psaBstr(claims CWTClaims, label int64, name string) ([]byte, error) {
+	value, ok := claims[label]
+	if !ok {
+		return nil, fmt.Errorf("cwt claim: %s: %w", name, ErrCWTMissingClaim)
+	}
+	b, ok := value.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("cwt claim: %s: require bstr", name)
+	}
+	return b, nil
+}
+
+// psaTstr returns an optional tstr claim.
+func psaTstr(claims CWTClaims, label int64, name string) (string, error) {
+	value, ok := claims[label]
+	if !ok {
+		return "", nil
+	}
+	str, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("cwt claim: %s: require tstr", name)
+	}
+	return str, nil
+}
+
+// psaSoftwareComponent decodes a software component.
+func psaSoftwareComponent(value any) (*PSASoftwareComponent, error) {
+	m, ok := value.(map[any]any)
+	if !ok {
+		return nil, errors.New("require map")
+	}
+	var c PSASoftwareComponent
+	for label, v := range m {
+		label, ok := normalizeLabel(label)
+		if !ok {
+			return nil, errors.New("require int label")
+		}
+		switch label {
+		case psaComponentMeasurementType, psaComponentVersion, psaComponentMeasurementDescription:
+			str, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("%d: require tstr", label)
+			}
+			switch label {
+			case psaComponentMeasurementType:
+				c.MeasurementType = str
+			case psaComponentVersion:
+				c.Version = str
+			default:
+				c.MeasurementDescription = str
+			}
+		case psaComponentMeasurementValue, psaComponentSignerID:
+			b, ok := v.([]byte)
+			if !ok {
+				return nil, fmt.Errorf("%d: require bstr", label)
+			}
+			if label == psaComponentMeasurementValue {
+				c.MeasurementValue = b
+			} else {
+				c.SignerID = b
+			}
+		}
+	}
+	if c.MeasurementValue == nil {
+		return nil, errors.New("measurement-value: missing")
+	}
+	if c.SignerID == nil {
+		return nil, errors.New("signer-id: missing")
+	}
+	return &c, nil
+}
+
+// Claims returns the claims of the token, with the claim keys of its profile.
+// It fails if the claims are not valid.
+func (t *PSAToken) Claims() (CWTClaims, error) {
+	keys := psaClaims2023
+	if t.Profile == PSAProfile2019 {
+		keys = psaClaims2019
+	}
+	claims := CWTClaims{
+		keys.profile:           t.Profile,
+		keys.clientID:          t.ClientID,
+		keys.securityLifecycle: uint64(t.SecurityLifecycle),
+		keys.implementationID:  t.ImplementationID,
+		keys.nonce:             t.Nonce,
+		keys.instanceID:        t.InstanceID,
+	}
+	if t.BootSeed != nil {
+		claims[keys.bootSeed] = t.BootSeed
+	}
+	if t.CertificationReference != "" {
+		claims[keys.certificationReference] = t.CertificationReference
+	}
+	if t.VerificationServiceIndicator != "" {
+		claims[keys.verificationService] = t.VerificationServiceIndicator
+	}
+	if t.NoSoftwareMeasurements {
+		claims[psaLegacyNoSoftwareMeasurements] = uint64(1)
+	}
+	if len(t.SoftwareComponents) > 0 {
+		components := make([]any, 0, len(t.SoftwareComponents))
+		for _, c := range t.SoftwareComponents {
+			component := map[any]any{
+				psaComponentMeasurementValue: c.MeasurementValue,
+				psaComponentSignerID:         c.SignerID,
+			}
+			for label, str := range map[int64]string{
+				psaComponentMeasurementType:        c.MeasurementType,
+				psaComponentVersion:                c.Version,
+				psaComponentMeasurementDescription: c.MeasurementDescription,
+			} {
+				if str != "" {
+					component[label] = str
+				}
+			}
+			components = append(components, component)
+		}
+		claims[keys.softwareComponents] = components
+	}
+	if _, err := psaToken(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// Verify verifies the signature of the token with the Initial Attestation Key
+// (IAK) of the device.
+//
+// The kid header parameter, if present, must be equal to the Instance ID, or
+// to the Instance ID without its UEID type byte.
+func (t *PSAToken) Verify(iak Verifier) error {
+	if t.Message == nil {
+		return errors.New("verifying PSA token without message")
+	}
+	kid, err := t.Message.Headers.KeyID()
+	if err != nil {
+		return err
+	}
+	if kid != nil {
+		if len(t.InstanceID) == 0 {
+			return errors.New("cwt claim: ueid: missing instance ID")
+		}
+		if !bytes.Equal(kid, t.InstanceID) && !bytes.Equal(kid, t.InstanceID[1:]) {
+			return errors.New("header parameter: kid: instance ID mismatch")
+		}
+	}
+	return t.Message.Verify(nil, iak)
+}
+
+// VerifyWithResolver verifies the signature of the token with the Initial
+// Attestation Key (IAK) of the device, resolved by its Instance ID.
+func (t *PSAToken) VerifyWithResolver(resolver PSAKeyResolver) error {
+	iak, err := resolver.ResolveIAK(t.InstanceID)
+	if err != nil {
+		return err
+	}
+	return t.Verify(iak)
+}
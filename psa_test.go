@@ -0,0 +1,295 @@
+package cose
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// testPSAKeyResolver resolves IAKs by Instance ID.
+type testPSAKeyResolver map[string]Verifier
+
+func (r testPSAKeyResolver) ResolveIAK(instanceID []byte) (Verifier, error) {
+	v, ok := r[string(instanceID)]
+	if !ok {
+		return nil, errors.New("unknown instance ID")
+	}
+	return v, nil
+}
+
+// newTestPSAToken returns a valid PSA token of the profile.
+func newTestPSAToken(profile string) *PSAToken {
+	t := &PSAToken{
+		Profile:           profile,
+		ClientID:          1,
+		SecurityLifecycle: 0x3000,
+		ImplementationID:  bytes.Repeat([]byte{0xaa}, 32),
+		Nonce:             bytes.Repeat([]byte{0x01}, 32),
+		InstanceID:        append([]byte{0x01}, bytes.Repeat([]byte{0xbb}, 32)...),
+		SoftwareComponents: []PSASoftwareComponent{
+			{
+				MeasurementType:  "BL",
+				MeasurementValue: bytes.Repeat([]byte{0xcc}, 32),
+				Version:          "1.0.0",
+				SignerID:         bytes.Repeat([]byte{0xdd}, 32),
+			},
+		},
+		CertificationReference: "1234567890123-12345",
+	}
+	if profile == PSAProfile2019 {
+		t.BootSeed = bytes.Repeat([]byte{0xee}, 32)
+	}
+	return t
+}
+
+func TestPSAToken(t *testing.T) {
+	iak := generateTestECDSAKey(t)
+	signer, err := NewSigner(AlgorithmES256, iak)
+	if err != nil {
+		t.Fatalf("NewSigner() error = %v", err)
+	}
+	verifier, err := NewVerifier(AlgorithmES256, iak.Public())
+	if err != nil {
+		t.Fatalf("NewVerifier() error = %v", err)
+	}
+	otherVerifier, err := NewVerifier(AlgorithmES256, generateTestECDSAKey(t).Public())
+	if err != nil {
+		t.Fatalf("NewVerifier() error = %v", err)
+	}
+
+	for _, profile := range []string{PSAProfile2019, PSAProfile2023} {
+		t.Run(profile, func(t *testing.T) {
+			want := newTestPSAToken(profile)
+			claims, err := want.Claims()
+			if err != nil {
+				t.Fatalf("PSAToken.Claims() error = %v", err)
+			}
+			set, err := claims.ClaimsSet()
+			if err != nil {
+				t.Fatalf("CWTClaims.ClaimsSet() error = %v", err)
+			}
+			data, err := SignCWT(rand.Reader, signer, Headers{
+				Protected: ProtectedHeader{
+					HeaderLabelAlgorithm: AlgorithmES256,
+					HeaderLabelKeyID:     want.InstanceID,
+				},
+			}, set, false)
+			if err != nil {
+				t.Fatalf("SignCWT() error = %v", err)
+			}
+
+			got, err := ParsePSAToken(data)
+			if err != nil {
+				t.Fatalf("ParsePSAToken() error = %v", err)
+			}
+			if got.Message == nil {
+				t.Fatal("ParsePSAToken().Message = nil")
+			}
+			got.Message, want.Message = nil, nil
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ParsePSAToken() = %v, want %v", got, want)
+			}
+			got, err = ParsePSAToken(data)
+			if err != nil {
+				t.Fatalf("ParsePSAToken() error = %v", err)
+			}
+			if err := got.Verify(verifier); err != nil {
+				t.Errorf("PSAToken.Verify() error = %v", err)
+			}
+			if err := got.Verify(otherVerifier); err != ErrVerification {
+				t.Errorf("PSAToken.Verify() error = %v, want %v", err, ErrVerification)
+			}
+			resolver := testPSAKeyResolver{string(want.InstanceID): verifier}
+			if err := got.VerifyWithResolver(resolver); err != nil {
+				t.Errorf("PSAToken.VerifyWithResolver() error = %v", err)
+			}
+			got.InstanceID = append([]byte{0x01}, bytes.Repeat([]byte{0x00}, 32)...)
+			wantErr := "header parameter: kid: instance ID mismatch"
+			if err := got.Verify(verifier); err == nil || err.Error() != wantErr {
+				t.Errorf("PSAToken.Verify() error = %v, wantErr %v", err, wantErr)
+			}
+			got.InstanceID = nil
+			wantErr = "cwt claim: ueid: missing instance ID"
+			if err := got.Verify(verifier); err == nil || err.Error() != wantErr {
+				t.Errorf("PSAToken.Verify() error = %v, wantErr %v", err, wantErr)
+			}
+			got.InstanceID = want.InstanceID
+			kid, err := got.Message.Headers.KeyID()
+			if err != nil {
+				t.Fatalf("Headers.KeyID() error = %v", err)
+			}
+			got.Message.Headers.Protected[HeaderLabelKeyID] = kid
+			got.Message.Headers.Unprotected[HeaderLabelKeyID] = kid
+			if err := got.Verify(verifier); !errors.Is(err, ErrDuplicateHeaderParameter) {
+				t.Errorf("PSAToken.Verify() error = %v, want %v", err, ErrDuplicateHeaderParameter)
+			}
+		})
+	}
+}
+
+func TestPSAToken_Claims(t *testing.T) {
+	tests := []struct {
+		name    string
+		token   func() *PSAToken
+		wantErr string
+	}{
+		{
+			name: "no software measurements",
+			token: func() *PSAToken {
+				t := newTestPSAToken(PSAProfile2019)
+				t.SoftwareComponents = nil
+				t.NoSoftwareMeasurements = true
+				return t
+			},
+		},
+		{
+			name: "2023 profile without boot seed",
+			token: func() *PSAToken {
+				t := newTestPSAToken(PSAProfile2023)
+				t.BootSeed = nil
+				return t
+			},
+		},
+		{
+			name: "2023 profile with 12-byte boot seed",
+			token: func() *PSAToken {
+				t := newTestPSAToken(PSAProfile2023)
+				t.BootSeed = bytes.Repeat([]byte{0xee}, 12)
+				return t
+			},
+		},
+		{
+			name: "2023 profile with 7-byte boot seed",
+			token: func() *PSAToken {
+				t := newTestPSAToken(PSAProfile2023)
+				t.BootSeed = bytes.Repeat([]byte{0xee}, 7)
+				return t
+			},
+			wantErr: "cwt claim: psa-boot-seed: invalid size 7",
+		},
+		{
+			name: "2023 profile with 33-byte boot seed",
+			token: func() *PSAToken {
+				t := newTestPSAToken(PSAProfile2023)
+				t.BootSeed = bytes.Repeat([]byte{0xee}, 33)
+				return t
+			},
+			wantErr: "cwt claim: psa-boot-seed: invalid size 33",
+		},
+		{
+			name: "no software measurements in 2023 profile",
+			token: func() *PSAToken {
+				t := newTestPSAToken(PSAProfile2023)
+				t.SoftwareComponents = nil
+				t.NoSoftwareMeasurements = true
+				return t
+			},
+			wantErr: "cwt claim: psa-software-components: missing claim",
+		},
+		{
+			name: "unsupported profile",
+			token: func() *PSAToken {
+				t := newTestPSAToken(PSAProfile2023)
+				t.Profile = "tag:example.com,2025:psa"
+				return t
+			},
+			wantErr: `cwt claim: eat_profile: unsupported profile "tag:example.com,2025:psa"`,
+		},
+		{
+			name: "missing boot seed in 2019 profile",
+			token: func() *PSAToken {
+				t := newTestPSAToken(PSAProfile2019)
+				t.BootSeed = nil
+				return t
+			},
+			wantErr: "cwt claim: psa-boot-seed: missing claim",
+		},
+		{
+			name: "invalid nonce size",
+			token: func() *PSAToken {
+				t := newTestPSAToken(PSAProfile2023)
+				t.Nonce = []byte("nonce")
+				return t
+			},
+			wantErr: "cwt claim: eat_nonce: invalid size 5",
+		},
+		{
+			name: "invalid instance ID type",
+			token: func() *PSAToken {
+				t := newTestPSAToken(PSAProfile2023)
+				t.InstanceID[0] = 0x02
+				return t
+			},
+			wantErr: "cwt claim: ueid: require RAND type instance ID",
+		},
+		{
+			name: "invalid security lifecycle",
+			token: func() *PSAToken {
+				t := newTestPSAToken(PSAProfile2023)
+				t.SecurityLifecycle = 0x7000
+				return t
+			},
+			wantErr: "cwt claim: psa-lifecycle: require security lifecycle state",
+		},
+		{
+			name: "software component without signer ID",
+			token: func() *PSAToken {
+				t := newTestPSAToken(PSAProfile2023)
+				t.SoftwareComponents[0].SignerID = nil
+				return t
+			},
+			wantErr: "cwt claim: psa-software-components: 0: signer-id: missing",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := tt.token()
+			claims, err := want.Claims()
+			if err != nil && (err.Error() != tt.wantErr) {
+				t.Errorf("PSAToken.Claims() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			} else if err == nil && tt.wantErr != "" {
+				t.Errorf("PSAToken.Claims() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr != "" {
+				return
+			}
+			got, err := psaToken(claims)
+			if err != nil {
+				t.Fatalf("psaToken() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("psaToken() = %v, want %v", got, want)
+			}
+		})
+	}
+
+	// invalid claims
+	for _, tt := range []struct {
+		claims  CWTClaims
+		wantErr string
+	}{
+		{
+			claims:  CWTClaims{},
+			wantErr: "cwt claim: eat_profile: missing claim",
+		},
+		{
+			claims:  CWTClaims{CWTClaimProfile: PSAProfile2019},
+			wantErr: `cwt claim: eat_profile: unsupported profile "PSA_IOT_PROFILE_1"`,
+		},
+		{
+			claims: CWTClaims{
+				CWTClaimProfile:     PSAProfile2023,
+				CWTClaimPSAClientID: "1",
+			},
+			wantErr: "cwt claim: psa-client-id: require int",
+		},
+	} {
+		if _, err := psaToken(tt.claims); err == nil || err.Error() != tt.wantErr {
+			t.Errorf("psaToken() error = %v, wantErr %v", err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,791 @@
+package cose
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// CBOR tags and simple value of Selective Disclosure CWTs (SD-CWT), as
+// requested to be registered in the IANA "CBOR Tags" and "CBOR Simple Values"
+// registries.
+// These values are subject to change until the specification is published.
+//
+// Reference: https://datatracker.ietf.org/doc/html/draft-ietf-spice-sd-cwt-04#section-14
+const (
+	// CBORTagToBeRedacted marks a claim key or an array element to be redacted
+	// by the issuer.
+	CBORTagToBeRedacted = 58
+
+	// CBORTagRedactedClaimElement wraps the digest of a redacted array
+	// element.
+	CBORTagRedactedClaimElement = 60
+
+	// SDRedactedClaimKeys is the map key of the digests of the redacted
+	// claims of a map.
+	SDRedactedClaimKeys = cbor.SimpleValue(59)
+)
+
+// CWTClaimCNonce is the cnonce claim of the key binding token of an SD-CWT,
+// registered in the IANA "CBOR Web Token (CWT) Claims" registry.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9200.html#section-5.3.1
+const CWTClaimCNonce int64 = 39
+
+// SDKBTType is the typ header parameter of the key binding token of an
+// SD-CWT.
+const SDKBTType = "application/kb+cwt"
+
+// sdSaltSize is the size in bytes of the salt of a disclosure.
+const sdSaltSize = 16
+
+// SDRedact marks a claim key or an array element to be redacted by
+// RedactSDClaims and SignSDCWT.
+//
+// # Experimental
+//
+// Notice: The SD-CWT API is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func SDRedact(v any) cbor.Tag {
+	return cbor.Tag{
+		Number:  CBORTagToBeRedacted,
+		Content: v,
+	}
+}
+
+// SDDisclosure is a salted disclosure of a redacted claim or array element of
+// an SD-CWT.
+//
+//	salted-claim = [ salt: bstr, value: any, key: int / tstr ]
+//	salted-element = [ salt: bstr, value: any ]
+//
+// # Experimental
+//
+// Notice: The SD-CWT API is EXPERIMENTAL and may be changed or removed in a
+// later release.
+//
+// Reference: https://datatracker.ietf.org/doc/html/draft-ietf-spice-sd-cwt-04#section-6
+type SDDisclosure struct {
+	// Salt is the random salt of the disclosure.
+	Salt []byte
+
+	// Value is the value of the claim or of the array element, which may
+	// contain redacted claims and array elements.
+	Value any
+
+	// Key is the key of the claim, or nil for an array element.
+	Key any
+
+	raw []byte // encoded disclosure, from which the digest is computed
+}
+
+// MarshalCBOR encodes the disclosure as a CBOR array.
+func (d *SDDisclosure) MarshalCBOR() ([]byte, error) {
+	if d.raw != nil {
+		return d.raw, nil
+	}
+	if d.Key == nil {
+		return encMode.Marshal([]any{d.Salt, d.Value})
+	}
+	return encMode.Marshal([]any{d.Salt, d.Value, d.Key})
+}
+
+// UnmarshalCBOR decodes a CBOR array into the disclosure.
+func (d *SDDisclosure) UnmarshalCBOR(data []byte) error {
+	if d == nil {
+		return errors.New("cbor: UnmarshalCBOR on nil SDDisclosure pointer")
+	}
+	var salted []any
+	if err := decMode.Unmarshal(data, &salted); err != nil {
+		return err
+	}
+	if len(salted) != 2 && len(salted) != 3 {
+		return errors.New("invalid disclosure: require salted claim / salted element")
+	}
+	salt, ok := salted[0].([]byte)
+	if !ok {
+		return errors.New("invalid disclosure: salt: require bstr")
+	}
+	var key any
+	if len(salted) == 3 {
+		if key, ok = normalizeLabel(salted[2]); !ok {
+			return errors.New("invalid disclosure: key: require int / tstr")
+		}
+	}
+	*d = SDDisclosure{
+		Salt:  salt,
+		Value: salted[1],
+		Key:   key,
+		raw:   slices.Clone(data),
+	}
+	return nil
+}
+
+// digest returns the digest of the encoded disclosure.
+func (d *SDDisclosure) digest(alg Algorithm) ([]byte, error) {
+	data, err := d.MarshalCBOR()
+	if err != nil {
+		return nil, err
+	}
+	return alg.computeHash(data)
+}
+
+// sdDisclosures decodes the value of the sd_claims header parameter.
+func sdDisclosures(value any) ([]*SDDisclosure, error) {
+	var encoded []any
+	switch v := value.(type) {
+	case []any:
+		encoded = v
+	case [][]byte:
+		for _, data := range v {
+			encoded = append(encoded, data)
+		}
+	default:
+		return nil, errors.New("require array of bstr type")
+	}
+	disclosures := make([]*SDDisclosure, 0, len(encoded))
+	for _, data := range encoded {
+		data, ok := data.([]byte)
+		if !ok {
+			return nil, errors.New("require array of bstr type")
+		}
+		var d SDDisclosure
+		if err := d.UnmarshalCBOR(data); err != nil {
+			return nil, err
+		}
+		disclosures = append(disclosures, &d)
+	}
+	return disclosures, nil
+}
+
+// SDClaims returns the disclosures of the sd_claims header parameter in the
+// unprotected header, or nil if the header parameter is not present.
+//
+// # Experimental
+//
+// Notice: The SD-CWT API is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func (h UnprotectedHeader) SDClaims() ([]*SDDisclosure, error) {
+	value, ok := h[HeaderLabelSDClaims]
+	if !ok {
+		return nil, nil
+	}
+	disclosures, err := sdDisclosures(value)
+	if err != nil {
+		return nil, fmt.Errorf("header parameter: sd_claims: %w", err)
+	}
+	return disclosures, nil
+}
+
+// SetSDClaims sets the disclosures of the sd_claims header parameter in the
+// unprotected header. The header parameter is removed if disclosures is
+// empty.
+//
+// # Experimental
+//
+// Notice: The SD-CWT API is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func (h UnprotectedHeader) SetSDClaims(disclosures []*SDDisclosure) error {
+	if len(disclosures) == 0 {
+		delete(h, HeaderLabelSDClaims)
+		return nil
+	}
+	encoded := make([]any, 0, len(disclosures))
+	for _, d := range disclosures {
+		data, err := d.MarshalCBOR()
+		if err != nil {
+			return fmt.Errorf("header parameter: sd_claims: %w", err)
+		}
+		encoded = append(encoded, data)
+	}
+	h[HeaderLabelSDClaims] = encoded
+	return nil
+}
+
+// SDAlg returns the hash algorithm of the disclosures of an SD-CWT from the
+// sd_alg header parameter in the protected header, or AlgorithmSHA256 if the
+// header parameter is not present.
+//
+// # Experimental
+//
+// Notice: The SD-CWT API is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func (h ProtectedHeader) SDAlg() (Algorithm, error) {
+	value, ok := h[HeaderLabelSDAlg]
+	if !ok {
+		return AlgorithmSHA256, nil
+	}
+	return algorithmValue(value)
+}
+
+// SDCWTOptions are the options of the issuer of SD-CWTs.
+//
+// # Experimental
+//
+// Notice: The SD-CWT API is EXPERIMENTAL and may be changed or removed in a
+// later release.
+type SDCWTOptions struct {
+	// HashAlgorithm is the hash algorithm of the disclosures.
+	// If zero, AlgorithmSHA256 is used.
+	HashAlgorithm Algorithm
+
+	// Decoys is the number of decoy digests added to each map containing
+	// redacted claims, hiding the number of redacted claims.
+	Decoys int
+}
+
+// hashAlgorithm returns the hash algorithm of the options.
+func (o *SDCWTOptions) hashAlgorithm() Algorithm {
+	if o == nil || o.HashAlgorithm == 0 {
+		return AlgorithmSHA256
+	}
+	return o.HashAlgorithm
+}
+
+// sdNonRedactableClaims are the claims which must not be redacted, as they
+// are required to validate the SD-CWT.
+var sdNonRedactableClaims = []any{
+	CWTClaimIssuer,
+	CWTClaimExpirationTime,
+	CWTClaimNotBefore,
+	CWTClaimConfirmation,
+}
+
+// RedactSDClaims redacts the claim keys and array elements of claims marked
+// with SDRedact, at any depth, and returns the redacted claims with the
+// disclosures of all the redacted claims and array elements.
+//
+// The key of a redacted claim is replaced by its digest in the
+// SDRedactedClaimKeys array of its map, and a redacted array element by its
+// digest wrapped in the CBORTagRedactedClaimElement tag. The iss, exp, nbf
+// and cnf claims must not be redacted.
+//
+// # Experimental
+//
+// Notice: The SD-CWT API is EXPERIMENTAL and may be changed or removed in a
+// later release.
+//
+// Reference: https://datatracker.ietf.org/doc/html/draft-ietf-spice-sd-cwt-04#section-6
+func RedactSDClaims(rand io.Reader, claims CWTClaims, opts *SDCWTOptions) (CWTClaims, []*SDDisclosure, error) {
+	for label := range claims {
+		tag, ok := label.(cbor.Tag)
+		if !ok || tag.Number != CBORTagToBeRedacted {
+			continue
+		}
+		if key, ok := normalizeLabel(tag.Content); ok && slices.Contains(sdNonRedactableClaims, key) {
+			return nil, nil, fmt.Errorf("cwt claim: %s: must not be redacted", cwtClaimName(key))
+		}
+	}
+	r := sdRedactor{
+		rand: rand,
+		alg:  opts.hashAlgorithm(),
+	}
+	if opts != nil {
+		r.decoys = opts.Decoys
+	}
+	redacted, err := r.redact(map[any]any(claims))
+	if err != nil {
+		return nil, nil, err
+	}
+	return CWTClaims(redacted.(map[any]any)), r.disclosures, nil
+}
+
+// sdRedactor redacts claims, collecting their disclosures.
+type sdRedactor struct {
+	rand        io.Reader
+	alg         Algorithm
+	decoys      int
+	disclosures []*SDDisclosure
+}
+
+// redact returns the value with its marked claims and array elements
+// redacted.
+func (r *sdRedactor) redact(value any) (any, error) {
+	switch v := value.(type) {
+	case CWTClaims:
+		return r.redact(map[any]any(v))
+	case map[any]any:
+		redacted := make(map[any]any, len(v))
+		var digests [][]byte
+		for key, value := range v {
+			if key == SDRedactedClaimKeys {
+				return nil, errors.New("cwt claim: redacted claim keys: not allowed before redaction")
+			}
+			value, err := r.redact(value)
+			if err != nil {
+				return nil, err
+			}
+			tag, ok := key.(cbor.Tag)
+			if !ok || tag.Number != CBORTagToBeRedacted {
+				redacted[key] = value
+				continue
+			}
+			label, ok := normalizeLabel(tag.Content)
+			if !ok {
+				return nil, errors.New("cwt claim: redacted claim: require int / tstr key")
+			}
+			digest, err := r.disclose(value, label)
+			if err != nil {
+				return nil, err
+			}
+			digests = append(digests, digest)
+		}
+		if len(digests) == 0 {
+			return redacted, nil
+		}
+		for i := 0; i < r.decoys; i++ {
+			digest, err := r.decoy()
+			if err != nil {
+				return nil, err
+			}
+			digests = append(digests, digest)
+		}
+		// Sort the digests to not reveal the order of the claims.
+		slices.SortFunc(digests, bytes.Compare)
+		array := make([]any, 0, len(digests))
+		for _, digest := range digests {
+			array = append(array, digest)
+		}
+		redacted[SDRedactedClaimKeys] = array
+		return redacted, nil
+	case []any:
+		redacted := make([]any, 0, len(v))
+		for _, elem := range v {
+			tag, ok := elem.(cbor.Tag)
+			if !ok || tag.Number != CBORTagToBeRedacted {
+				elem, err := r.redact(elem)
+				if err != nil {
+					return nil, err
+				}
+				redacted = append(redacted, elem)
+				continue
+			}
+			elem, err := r.redact(tag.Content)
+			if err != nil {
+				return nil, err
+			}
+			digest, err := r.disclose(elem, nil)
+			if err != nil {
+				return nil, err
+			}
+			redacted = append(redacted, cbor.Tag{
+				Number:  CBORTagRedactedClaimElement,
+				Content: digest,
+			})
+		}
+		return redacted, nil
+	case cbor.Tag:
+		if v.Number == CBORTagToBeRedacted {
+			return nil, errors.New("cwt claim: redacted claim: require map key / array element")
+		}
+		content, err := r.redact(v.Content)
+		if err != nil {
+			return nil, err
+		}
+		return cbor.Tag{Number: v.Number, Content: content}, nil
+	}
+	return value, nil
+}
+
+// disclose creates the disclosure of a redacted claim, or of a redacted array
+// element if key is nil, and returns its digest.
+func (r *sdRedactor) disclose(value, key any) ([]byte, error) {
+	salt, err := r.salt()
+	if err != nil {
+		return nil, err
+	}
+	d := &SDDisclosure{
+		Salt:  salt,
+		Value: value,
+		Key:   key,
+	}
+	if d.raw, err = d.MarshalCBOR(); err != nil {
+		return nil, err
+	}
+	r.disclosures = append(r.disclosures, d)
+	return d.digest(r.alg)
+}
+
+// decoy returns a decoy digest, the digest of a salted decoy which is never
+// disclosed.
+//
+//	salted-decoy = [ salt: bstr ]
+func (r *sdRedactor) decoy() ([]byte, error) {
+	salt, err := r.salt()
+	if err != nil {
+		return nil, err
+	}
+	data, err := encMode.Marshal([]any{salt})
+	if err != nil {
+		return nil, err
+	}
+	return r.alg.computeHash(data)
+}
+
+// salt returns a random salt.
+func (r *sdRedactor) salt() ([]byte, error) {
+	salt := make([]byte, sdSaltSize)
+	if _, err := io.ReadFull(r.rand, salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// DiscloseSDClaims reconstructs the redacted claims and array elements of
+// claims from their disclosures, hashed with alg, and returns the disclosed
+// claims. Redacted claims and array elements without a disclosure, and decoy
+// digests, are removed.
+//
+// It fails if a disclosure is not referenced by the claims or by another
+// disclosure, if a digest is referenced more than once, or if a disclosed
+// claim is already present.
+//
+// # Experimental
+//
+// Notice: The SD-CWT API is EXPERIMENTAL and may be changed or removed in a
+// later release.
+//
+// Reference: https://datatracker.ietf.org/doc/html/draft-ietf-spice-sd-cwt-04#section-8.1
+func DiscloseSDClaims(claims CWTClaims, disclosures []*SDDisclosure, alg Algorithm) (CWTClaims, error) {
+	d := sdDiscloser{
+		disclosures: make(map[string]*SDDisclosure, len(disclosures)),
+		used:        make(map[string]bool, len(disclosures)),
+	}
+	for _, disclosure := range disclosures {
+		digest, err := disclosure.digest(alg)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := d.disclosures[string(digest)]; ok {
+			return nil, errors.New("sd_claims: duplicate disclosure")
+		}
+		d.disclosures[string(digest)] = disclosure
+	}
+	disclosed, err := d.disclose(map[any]any(claims))
+	if err != nil {
+		return nil, err
+	}
+	if len(d.used) != len(d.disclosures) {
+		return nil, errors.New("sd_claims: disclosure not referenced")
+	}
+	return CWTClaims(disclosed.(map[any]any)), nil
+}
+
+// sdDiscloser reconstructs redacted claims from their disclosures, indexed
+// by digest.
+type sdDiscloser struct {
+	disclosures map[string]*SDDisclosure
+	used        map[string]bool
+}
+
+// lookup returns the disclosure of a digest, or nil if the digest is not
+// disclosed.
+func (d *sdDiscloser) lookup(digest any) (*SDDisclosure, error) {
+	b, ok := digest.([]byte)
+	if !ok {
+		return nil, errors.New("cwt claim: redacted digest: require bstr")
+	}
+	disclosure, ok := d.disclosures[string(b)]
+	if !ok {
+		return nil, nil
+	}
+	if d.used[string(b)] {
+		return nil, errors.New("cwt claim: redacted digest: referenced more than once")
+	}
+	d.used[string(b)] = true
+	return disclosure, nil
+}
+
+// disclose returns the value with its disclosed claims and array elements
+// reconstructed, and its undisclosed ones removed.
+func (d *sdDiscloser) disclose(value any) (any, error) {
+	switch v := value.(type) {
+	case CWTClaims:
+		return d.disclose(map[any]any(v))
+	case map[any]any:
+		disclosed := make(map[any]any, len(v))
+		for key, value := range v {
+			if key == SDRedactedClaimKeys {
+				continue
+			}
+			value, err := d.disclose(value)
+			if err != nil {
+				return nil, err
+			}
+			disclosed[key] = value
+		}
+		digests, ok := v[SDRedactedClaimKeys]
+		if !ok {
+			return disclosed, nil
+		}
+		array, ok := digests.([]any)
+		if !ok {
+			return nil, errors.New("cwt claim: redacted claim keys: require array of bstr")
+		}
+		for _, digest := range array {
+			disclosure, err := d.lookup(digest)
+			if err != nil {
+				return nil, err
+			}
+			if disclosure == nil {
+				continue
+			}
+			if disclosure.Key == nil {
+				return nil, errors.New("sd_claims: require salted claim for redacted claim key")
+			}
+			if hasLabel(disclosed, disclosure.Key) {
+				return nil, fmt.Errorf("sd_claims: claim %v: already present", disclosure.Key)
+			}
+			value, err := d.disclose(disclosure.Value)
+			if err != nil {
+				return nil, err
+			}
+			disclosed[disclosure.Key] = value
+		}
+		return disclosed, nil
+	case []any:
+		disclosed := make([]any, 0, len(v))
+		for _, elem := range v {
+			tag, ok := elem.(cbor.Tag)
+			if !ok || tag.Number != CBORTagRedactedClaimElement {
+				elem, err := d.disclose(elem)
+				if err != nil {
+					return nil, err
+				}
+				disclosed = append(disclosed, elem)
+				continue
+			}
+			disclosure, err := d.lookup(tag.Content)
+			if err != nil {
+				return nil, err
+			}
+			if disclosure == nil {
+				continue
+			}
+			if disclosure.Key != nil {
+				return nil, errors.New("sd_claims: require salted element for redacted claim element")
+			}
+			elem, err = d.disclose(disclosure.Value)
+			if err != nil {
+				return nil, err
+			}
+			disclosed = append(disclosed, elem)
+		}
+		return disclosed, nil
+	case cbor.Tag:
+		content, err := d.disclose(v.Content)
+		if err != nil {
+			return nil, err
+		}
+		return cbor.Tag{Number: v.Number, Content: content}, nil
+	}
+	return value, nil
+}
+
+// SignSDCWT redacts the claims marked with SDRedact as RedactSDClaims does,
+// and signs them as a CBOR Web Token carried in the payload of a
+// COSE_Sign1_Tagged object. The disclosures of all the redacted claims are
+// carried in the sd_claims header parameter of the unprotected header, and
+// the hash algorithm in the sd_alg header parameter of the protected header.
+//
+// The holder of the SD-CWT selects the disclosures to present with
+// PresentSDCWT.
+//
+// # Experimental
+//
+// Notice: The SD-CWT API is EXPERIMENTAL and may be changed or removed in a
+// later release.
+//
+// Reference: https://datatracker.ietf.org/doc/html/draft-ietf-spice-sd-cwt-04#section-7
+func SignSDCWT(rand io.Reader, signer Signer, headers Headers, claims CWTClaims, opts *SDCWTOptions) ([]byte, error) {
+	redacted, disclosures, err := RedactSDClaims(rand, claims, opts)
+	if err != nil {
+		return nil, err
+	}
+	claimsSet, err := redacted.ClaimsSet()
+	if err != nil {
+		return nil, err
+	}
+	headers.Protected = maps.Clone(headers.Protected)
+	if headers.Protected == nil {
+		headers.Protected = make(ProtectedHeader)
+	}
+	headers.Protected[HeaderLabelSDAlg] = opts.hashAlgorithm()
+	headers.Unprotected = maps.Clone(headers.Unprotected)
+	if headers.Unprotected == nil {
+		headers.Unprotected = make(UnprotectedHeader)
+	}
+	if err := headers.Unprotected.SetSDClaims(disclosures); err != nil {
+		return nil, err
+	}
+	return SignCWT(rand, signer, headers, claimsSet, false)
+}
+
+// PresentSDCWT presents an SD-CWT with the selected disclosures, and returns
+// the key binding token (SD-KBT) carrying the SD-CWT in its kcwt header
+// parameter, signed by the holder with the key of the cnf claim.
+//
+// The disclosures are selected from the sd_claims header parameter of the
+// SD-CWT; a nested disclosure requires the disclosure containing it. The
+// claims of the key binding token should contain the aud and iat claims, and
+// the cnonce claim if required by the verifier.
+//
+// # Experimental
+//
+// Notice: The SD-CWT API is EXPERIMENTAL and may be changed or removed in a
+// later release.
+//
+// Reference: https://datatracker.ietf.org/doc/html/draft-ietf-spice-sd-cwt-04#section-8
+func PresentSDCWT(rand io.Reader, holder Signer, sdcwt []byte, disclosures []*SDDisclosure, claims *CWTClaimsSet) ([]byte, error) {
+	msg, _, err := ParseCWT(sdcwt)
+	if err != nil {
+		return nil, fmt.Errorf("kcwt: %w", err)
+	}
+	msg.Headers.Unprotected = maps.Clone(msg.Headers.Unprotected)
+	if msg.Headers.Unprotected == nil {
+		msg.Headers.Unprotected = make(UnprotectedHeader)
+	}
+	if err := msg.Headers.Unprotected.SetSDClaims(disclosures); err != nil {
+		return nil, err
+	}
+	msg.Headers.RawUnprotected = nil
+	token, err := msg.MarshalCBOR()
+	if err != nil {
+		return nil, err
+	}
+
+	headers := Headers{
+		Protected: ProtectedHeader{
+			HeaderLabelType: SDKBTType,
+		},
+	}
+	if err := headers.SetKCWT(token); err != nil {
+		return nil, err
+	}
+	return SignCWT(rand, holder, headers, claims, false)
+}
+
+// SDCWTValidator validates the SD-CWTs presented with a key binding token.
+//
+// # Experimental
+//
+// Notice: The SD-CWT API is EXPERIMENTAL and may be changed or removed in a
+// later release.
+type SDCWTValidator struct {
+	// Claims validates the disclosed claims of the SD-CWT, if not nil.
+	Claims *CWTValidator
+
+	// KeyBinding validates the claims of the key binding token, if not nil.
+	// Its Audience should identify the verifier.
+	KeyBinding *CWTValidator
+
+	// CNonce, if not nil, must be equal to the cnonce claim of the key binding
+	// token.
+	CNonce []byte
+}
+
+// VerifySDCWT verifies a key binding token (SD-KBT) and the SD-CWT carried in
+// the kcwt header parameter of its protected header, and returns the disclosed
+// claims of the SD-CWT.
+//
+// The signature of the SD-CWT is verified with issuer, and the signature of
+// the key binding token with the COSE_Key of the cnf claim of the SD-CWT.
+// The key binding token must contain the aud and iat claims. The claims are
+// validated with validator, if not nil.
+//
+// Errors related to the SD-CWT are prefixed with "kcwt: ".
+//
+// # Experimental
+//
+// Notice: The SD-CWT API is EXPERIMENTAL and may be changed or removed in a
+// later release.
+//
+// Reference: https://datatracker.ietf.org/doc/html/draft-ietf-spice-sd-cwt-04#section-9
+func VerifySDCWT(data []byte, issuer Verifier, validator *SDCWTValidator) (*CWTClaimsSet, error) {
+	kbt, kbtClaims, err := ParseCWT(data)
+	if err != nil {
+		return nil, err
+	}
+	if typ, err := kbt.Headers.Protected.Type(); err != nil {
+		return nil, err
+	} else if typ != SDKBTType {
+		return nil, fmt.Errorf("header parameter: typ: require %s", SDKBTType)
+	}
+	msg, _, err := kbt.Headers.KCWT()
+	if err != nil {
+		return nil, err
+	}
+	if msg == nil {
+		return nil, errors.New("header parameter: kcwt: missing SD-CWT")
+	}
+	if issuer == nil {
+		return nil, errors.New("kcwt: require issuer verifier")
+	}
+	if err := msg.Verify(nil, issuer); err != nil {
+		return nil, fmt.Errorf("kcwt: %w", err)
+	}
+	claims, err := discloseSDCWT(msg)
+	if err != nil {
+		return nil, fmt.Errorf("kcwt: %w", err)
+	}
+	if validator != nil && validator.Claims != nil {
+		if err := validator.Claims.Validate(claims); err != nil {
+			return nil, fmt.Errorf("kcwt: %w", err)
+		}
+	}
+
+	verifier, err := credentialVerifier("kcwt", claims)
+	if err != nil {
+		return nil, err
+	}
+	if err := kbt.Verify(nil, verifier); err != nil {
+		return nil, err
+	}
+	if len(kbtClaims.Audience) == 0 {
+		return nil, fmt.Errorf("cwt claim: aud: %w", ErrCWTMissingClaim)
+	}
+	if kbtClaims.IssuedAt == nil {
+		return nil, fmt.Errorf("cwt claim: iat: %w", ErrCWTMissingClaim)
+	}
+	if validator != nil && validator.KeyBinding != nil {
+		if err := validator.KeyBinding.Validate(kbtClaims); err != nil {
+			return nil, err
+		}
+	}
+	if validator != nil && validator.CNonce != nil {
+		cnonce, ok := kbtClaims.Other[CWTClaimCNonce]
+		if !ok {
+			return nil, fmt.Errorf("cwt claim: cnonce: %w", ErrCWTMissingClaim)
+		}
+		if b, ok := cnonce.([]byte); !ok || !bytes.Equal(b, validator.CNonce) {
+			return nil, fmt.Errorf("cwt claim: cnonce: %w", ErrCWTCNonceMismatch)
+		}
+	}
+	return claims, nil
+}
+
+// discloseSDCWT returns the disclosed claims of a verified SD-CWT.
+func discloseSDCWT(msg *Sign1Message) (*CWTClaimsSet, error) {
+	alg, err := msg.Headers.Protected.SDAlg()
+	if err != nil {
+		return nil, fmt.Errorf("header parameter: sd_alg: %w", err)
+	}
+	disclosures, err := msg.Headers.Unprotected.SDClaims()
+	if err != nil {
+		return nil, err
+	}
+	var redacted CWTClaims
+	if err := decMode.Unmarshal(msg.Payload, &redacted); err != nil {
+		return nil, err
+	}
+	disclosed, err := DiscloseSDClaims(redacted, disclosures, alg)
+	if err != nil {
+		return nil, err
+	}
+	return disclosed.ClaimsSet()
+}
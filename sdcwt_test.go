@@ -0,0 +1,484 @@
+package cose
+
+import (
+	"crypto/rand"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+func TestSDCWT(t *testing.T) {
+	issuerKey := generateTestECDSAKey(t)
+	issuerSigner, err := NewSigner(AlgorithmES256, issuerKey)
+	if err != nil {
+		t.Fatalf("NewSigner() error = %v", err)
+	}
+	issuerVerifier, err := NewVerifier(AlgorithmES256, issuerKey.Public())
+	if err != nil {
+		t.Fatalf("NewVerifier() error = %v", err)
+	}
+	holderKey := generateTestECDSAKey(t)
+	holderSigner, err := NewSigner(AlgorithmES256, holderKey)
+	if err != nil {
+		t.Fatalf("NewSigner() error = %v", err)
+	}
+	key, err := NewKeyFromPublic(holderKey.Public())
+	if err != nil {
+		t.Fatalf("NewKeyFromPublic() error = %v", err)
+	}
+
+	// issue
+	claims := CWTClaims{
+		CWTClaimIssuer:       "https://issuer.example",
+		CWTClaimConfirmation: map[any]any{ConfirmationMethodCOSEKey: key},
+		SDRedact(int64(500)): true,
+		SDRedact(int64(501)): "ABCD-123456",
+		int64(502): []any{
+			SDRedact("fr"),
+			"de",
+			SDRedact("it"),
+		},
+		SDRedact(int64(503)): map[any]any{
+			"country":          "us",
+			SDRedact("region"): "ca",
+		},
+	}
+	sdcwt, err := SignSDCWT(rand.Reader, issuerSigner, Headers{
+		Protected: ProtectedHeader{
+			HeaderLabelAlgorithm: AlgorithmES256,
+		},
+	}, claims, &SDCWTOptions{Decoys: 2})
+	if err != nil {
+		t.Fatalf("SignSDCWT() error = %v", err)
+	}
+	msg, redacted, err := ParseCWT(sdcwt)
+	if err != nil {
+		t.Fatalf("ParseCWT() error = %v", err)
+	}
+	if got := len(redacted.Other[SDRedactedClaimKeys].([]any)); got != 5 {
+		t.Errorf("redacted claim keys = %d, want 5", got)
+	}
+	if redacted.Issuer != "https://issuer.example" {
+		t.Errorf("ParseCWT().Issuer = %v, want issuer", redacted.Issuer)
+	}
+	if alg, err := msg.Headers.Protected.SDAlg(); err != nil || alg != AlgorithmSHA256 {
+		t.Errorf("ProtectedHeader.SDAlg() = %v, %v, want %v", alg, err, AlgorithmSHA256)
+	}
+	disclosures, err := msg.Headers.Unprotected.SDClaims()
+	if err != nil {
+		t.Fatalf("UnprotectedHeader.SDClaims() error = %v", err)
+	}
+	if len(disclosures) != 6 {
+		t.Fatalf("UnprotectedHeader.SDClaims() = %d disclosures, want 6", len(disclosures))
+	}
+
+	// present all but claim 501, the element "it" and the nested region
+	var selected []*SDDisclosure
+	for _, d := range disclosures {
+		if d.Key == int64(501) || d.Value == "it" || d.Key == "region" {
+			continue
+		}
+		selected = append(selected, d)
+	}
+	now := time.Now()
+	iat := NewNumericDate(now)
+	cnonce := []byte("nonce")
+	kbt, err := PresentSDCWT(rand.Reader, holderSigner, sdcwt, selected, &CWTClaimsSet{
+		Audience: []string{"https://verifier.example"},
+		IssuedAt: &iat,
+		Other:    CWTClaims{CWTClaimCNonce: cnonce},
+	})
+	if err != nil {
+		t.Fatalf("PresentSDCWT() error = %v", err)
+	}
+
+	// verify
+	got, err := VerifySDCWT(kbt, issuerVerifier, &SDCWTValidator{
+		Claims: &CWTValidator{
+			Issuer: "https://issuer.example",
+		},
+		KeyBinding: &CWTValidator{
+			Audience: "https://verifier.example",
+		},
+		CNonce: cnonce,
+	})
+	if err != nil {
+		t.Fatalf("VerifySDCWT() error = %v", err)
+	}
+	want := CWTClaims{
+		int64(500): true,
+		int64(502): []any{"fr", "de"},
+		int64(503): map[any]any{"country": "us"},
+	}
+	if !reflect.DeepEqual(got.Other, want) {
+		t.Errorf("VerifySDCWT().Other = %v, want %v", got.Other, want)
+	}
+	if got.Issuer != "https://issuer.example" {
+		t.Errorf("VerifySDCWT().Issuer = %v, want issuer", got.Issuer)
+	}
+
+	// verification errors
+	otherVerifier, err := NewVerifier(AlgorithmES256, generateTestECDSAKey(t).Public())
+	if err != nil {
+		t.Fatalf("NewVerifier() error = %v", err)
+	}
+	present := func(signer Signer, claims *CWTClaimsSet) []byte {
+		kbt, err := PresentSDCWT(rand.Reader, signer, sdcwt, selected, claims)
+		if err != nil {
+			t.Fatalf("PresentSDCWT() error = %v", err)
+		}
+		return kbt
+	}
+	tests := []struct {
+		name      string
+		kbt       []byte
+		issuer    Verifier
+		validator *SDCWTValidator
+		wantErr   string
+		wantIs    error
+	}{
+		{
+			name:    "SD-CWT from another issuer",
+			kbt:     kbt,
+			issuer:  otherVerifier,
+			wantErr: "kcwt: verification error",
+			wantIs:  ErrVerification,
+		},
+		{
+			name:    "key binding token signed by another key",
+			kbt:     present(issuerSigner, &CWTClaimsSet{Audience: []string{"v"}, IssuedAt: &iat}),
+			issuer:  issuerVerifier,
+			wantErr: "verification error",
+			wantIs:  ErrVerification,
+		},
+		{
+			name:    "missing aud",
+			kbt:     present(holderSigner, &CWTClaimsSet{IssuedAt: &iat}),
+			issuer:  issuerVerifier,
+			wantErr: "cwt claim: aud: missing claim",
+			wantIs:  ErrCWTMissingClaim,
+		},
+		{
+			name:    "missing iat",
+			kbt:     present(holderSigner, &CWTClaimsSet{Audience: []string{"v"}}),
+			issuer:  issuerVerifier,
+			wantErr: "cwt claim: iat: missing claim",
+			wantIs:  ErrCWTMissingClaim,
+		},
+		{
+			name:   "cnonce mismatch",
+			kbt:    kbt,
+			issuer: issuerVerifier,
+			validator: &SDCWTValidator{
+				CNonce: []byte("other"),
+			},
+			wantErr: "cwt claim: cnonce: cnonce mismatch",
+			wantIs:  ErrCWTCNonceMismatch,
+		},
+		{
+			name:   "rejected SD-CWT claims",
+			kbt:    kbt,
+			issuer: issuerVerifier,
+			validator: &SDCWTValidator{
+				Claims: &CWTValidator{Issuer: "https://other.example"},
+			},
+			wantErr: "kcwt: cwt claim: iss: issuer mismatch",
+			wantIs:  ErrCWTIssuerMismatch,
+		},
+		{
+			name:    "not a key binding token",
+			kbt:     sdcwt,
+			issuer:  issuerVerifier,
+			wantErr: "header parameter: typ: require application/kb+cwt",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := VerifySDCWT(tt.kbt, tt.issuer, tt.validator)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("VerifySDCWT() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantIs != nil && !errors.Is(err, tt.wantIs) {
+				t.Errorf("VerifySDCWT() error = %v, want %v", err, tt.wantIs)
+			}
+		})
+	}
+}
+
+func TestRedactSDClaims(t *testing.T) {
+	tests := []struct {
+		name    string
+		claims  CWTClaims
+		wantErr string
+	}{
+		{
+			name:    "redacted cnf",
+			claims:  CWTClaims{SDRedact(CWTClaimConfirmation): map[any]any{}},
+			wantErr: "cwt claim: cnf: must not be redacted",
+		},
+		{
+			name:    "redacted exp",
+			claims:  CWTClaims{SDRedact(int(CWTClaimExpirationTime)): int64(0)},
+			wantErr: "cwt claim: exp: must not be redacted",
+		},
+		{
+			name:    "redacted value",
+			claims:  CWTClaims{int64(500): SDRedact("value")},
+			wantErr: "cwt claim: redacted claim: require map key / array element",
+		},
+		{
+			name:    "invalid redacted key",
+			claims:  CWTClaims{SDRedact(1.5): "value"},
+			wantErr: "cwt claim: redacted claim: require int / tstr key",
+		},
+		{
+			name:    "redacted claim keys",
+			claims:  CWTClaims{SDRedactedClaimKeys: []any{}},
+			wantErr: "cwt claim: redacted claim keys: not allowed before redaction",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := RedactSDClaims(rand.Reader, tt.claims, nil)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("RedactSDClaims() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDiscloseSDClaims(t *testing.T) {
+	redacted, disclosures, err := RedactSDClaims(rand.Reader, CWTClaims{
+		SDRedact(int64(500)): "value",
+		int64(501): []any{
+			SDRedact(nil),
+		},
+	}, &SDCWTOptions{HashAlgorithm: AlgorithmSHA384})
+	if err != nil {
+		t.Fatalf("RedactSDClaims() error = %v", err)
+	}
+	if len(disclosures) != 2 {
+		t.Fatalf("RedactSDClaims() = %d disclosures, want 2", len(disclosures))
+	}
+
+	// round trip through the sd_claims header parameter
+	h := UnprotectedHeader{}
+	if err := h.SetSDClaims(disclosures); err != nil {
+		t.Fatalf("UnprotectedHeader.SetSDClaims() error = %v", err)
+	}
+	data, err := h.MarshalCBOR()
+	if err != nil {
+		t.Fatalf("UnprotectedHeader.MarshalCBOR() error = %v", err)
+	}
+	var decoded UnprotectedHeader
+	if err := decoded.UnmarshalCBOR(data); err != nil {
+		t.Fatalf("UnprotectedHeader.UnmarshalCBOR() error = %v", err)
+	}
+	disclosures, err = decoded.SDClaims()
+	if err != nil {
+		t.Fatalf("UnprotectedHeader.SDClaims() error = %v", err)
+	}
+	// claims are redacted in map order: put the claim before the element
+	if disclosures[0].Key == nil {
+		disclosures[0], disclosures[1] = disclosures[1], disclosures[0]
+	}
+
+	got, err := DiscloseSDClaims(redacted, disclosures, AlgorithmSHA384)
+	if err != nil {
+		t.Fatalf("DiscloseSDClaims() error = %v", err)
+	}
+	want := CWTClaims{
+		int64(500): "value",
+		int64(501): []any{nil},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DiscloseSDClaims() = %v, want %v", got, want)
+	}
+	got, err = DiscloseSDClaims(redacted, nil, AlgorithmSHA384)
+	if err != nil {
+		t.Fatalf("DiscloseSDClaims() error = %v", err)
+	}
+	want = CWTClaims{
+		int64(501): []any{},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DiscloseSDClaims() = %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		name        string
+		claims      CWTClaims
+		disclosures []*SDDisclosure
+		alg         Algorithm
+		wantErr     string
+	}{
+		{
+			name:        "wrong hash algorithm",
+			claims:      redacted,
+			disclosures: disclosures,
+			alg:         AlgorithmSHA256,
+			wantErr:     "sd_claims: disclosure not referenced",
+		},
+		{
+			name:        "duplicate disclosure",
+			claims:      redacted,
+			disclosures: append(disclosures, disclosures[0]),
+			alg:         AlgorithmSHA384,
+			wantErr:     "sd_claims: duplicate disclosure",
+		},
+		{
+			name: "claim already present",
+			claims: CWTClaims{
+				int64(500):          "other",
+				SDRedactedClaimKeys: redacted[SDRedactedClaimKeys],
+			},
+			disclosures: disclosures[:1],
+			alg:         AlgorithmSHA384,
+			wantErr:     "sd_claims: claim 500: already present",
+		},
+		{
+			name: "digest referenced twice",
+			claims: CWTClaims{
+				int64(600): redacted[SDRedactedClaimKeys],
+				int64(601): map[any]any{
+					SDRedactedClaimKeys: redacted[SDRedactedClaimKeys],
+				},
+				SDRedactedClaimKeys: redacted[SDRedactedClaimKeys],
+			},
+			disclosures: disclosures[:1],
+			alg:         AlgorithmSHA384,
+			wantErr:     "cwt claim: redacted digest: referenced more than once",
+		},
+		{
+			name: "element digest as claim key",
+			claims: CWTClaims{
+				SDRedactedClaimKeys: []any{redacted[int64(501)].([]any)[0].(cbor.Tag).Content},
+			},
+			disclosures: disclosures[1:],
+			alg:         AlgorithmSHA384,
+			wantErr:     "sd_claims: require salted claim for redacted claim key",
+		},
+		{
+			name: "invalid redacted claim keys",
+			claims: CWTClaims{
+				SDRedactedClaimKeys: "digest",
+			},
+			alg:     AlgorithmSHA384,
+			wantErr: "cwt claim: redacted claim keys: require array of bstr",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DiscloseSDClaims(tt.claims, tt.disclosures, tt.alg)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("DiscloseSDClaims() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHeaders_SDClaims(t *testing.T) {
+	tests := []struct {
+		name    string
+		h       Headers
+		wantErr string
+	}{
+		{
+			name: "sd_claims in protected header",
+			h: Headers{
+				Protected: ProtectedHeader{HeaderLabelSDClaims: []any{}},
+			},
+			wantErr: "protected header: header parameter: sd_claims: not allowed",
+		},
+		{
+			name: "invalid sd_claims",
+			h: Headers{
+				Unprotected: UnprotectedHeader{HeaderLabelSDClaims: []any{"disclosure"}},
+			},
+			wantErr: "unprotected header: header parameter: sd_claims: require array of bstr type",
+		},
+		{
+			name: "invalid disclosure",
+			h: Headers{
+				Unprotected: UnprotectedHeader{HeaderLabelSDClaims: []any{[]byte{0x81, 0x40}}},
+			},
+			wantErr: "unprotected header: header parameter: sd_claims: invalid disclosure: require salted claim / salted element",
+		},
+		{
+			name: "invalid sd_alg",
+			h: Headers{
+				Protected: ProtectedHeader{HeaderLabelSDAlg: "SHA-256"},
+			},
+			wantErr: "protected header: header parameter: sd_alg: require int type",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := tt.h.marshal(nil)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Headers.marshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProtectedHeader_SDAlg(t *testing.T) {
+	tests := []struct {
+		name    string
+		h       ProtectedHeader
+		want    Algorithm
+		wantErr error
+	}{
+		{
+			name: "default",
+			h:    ProtectedHeader{},
+			want: AlgorithmSHA256,
+		},
+		{
+			name: "algorithm",
+			h:    ProtectedHeader{HeaderLabelSDAlg: AlgorithmSHA384},
+			want: AlgorithmSHA384,
+		},
+		{
+			name: "int",
+			h:    ProtectedHeader{HeaderLabelSDAlg: int64(-44)},
+			want: AlgorithmSHA512,
+		},
+		{
+			name: "uint",
+			h:    ProtectedHeader{HeaderLabelSDAlg: uint8(5)},
+			want: Algorithm(5),
+		},
+		{
+			name:    "uint overflow",
+			h:       ProtectedHeader{HeaderLabelSDAlg: uint64(math.MaxUint64)},
+			want:    AlgorithmReserved,
+			wantErr: ErrInvalidAlgorithm,
+		},
+		{
+			name:    "string",
+			h:       ProtectedHeader{HeaderLabelSDAlg: "SHA-256"},
+			want:    AlgorithmReserved,
+			wantErr: ErrInvalidAlgorithm,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.h.SDAlg()
+			if err != tt.wantErr {
+				t.Errorf("ProtectedHeader.SDAlg() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ProtectedHeader.SDAlg() = %v, want %v", got, tt.want)
+			}
+			_, err = tt.h.MarshalCBOR()
+			if valid := err == nil; valid != (tt.wantErr == nil) {
+				t.Errorf("ProtectedHeader.MarshalCBOR() error = %v, want valid %v", err, tt.wantErr == nil)
+			}
+		})
+	}
+}
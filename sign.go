@@ -64,13 +64,19 @@ func NewSignature() *Signature {
 // Notice: The COSE Sign API is EXPERIMENTAL and may be changed or removed in a
 // later release.
 func (s *Signature) MarshalCBOR() ([]byte, error) {
+	return s.marshal(nil)
+}
+
+// marshal encodes Signature into a COSE_Signature object, encoding header
+// values with the modes specified by opts.
+func (s *Signature) marshal(opts *CBOROptions) ([]byte, error) {
 	if s == nil {
 		return nil, errors.New("cbor: MarshalCBOR on nil Signature pointer")
 	}
 	if len(s.Signature) == 0 {
 		return nil, ErrEmptySignature
 	}
-	protected, unprotected, err := s.Headers.marshal()
+	protected, unprotected, err := s.Headers.marshal(opts)
 	if err != nil {
 		return nil, err
 	}
@@ -89,6 +95,12 @@ func (s *Signature) MarshalCBOR() ([]byte, error) {
 // Notice: The COSE Sign API is EXPERIMENTAL and may be changed or removed in a
 // later release.
 func (s *Signature) UnmarshalCBOR(data []byte) error {
+	return s.unmarshal(data, nil)
+}
+
+// unmarshal decodes a COSE_Signature object into Signature, decoding header
+// values with the modes specified by opts.
+func (s *Signature) unmarshal(data []byte, opts *CBOROptions) error {
 	if s == nil {
 		return errors.New("cbor: UnmarshalCBOR on nil Signature pointer")
 	}
@@ -100,7 +112,7 @@ func (s *Signature) UnmarshalCBOR(data []byte) error {
 
 	// decode to signature and parse
 	var raw signature
-	if err := decModeWithTagsForbidden.Unmarshal(data, &raw); err != nil {
+	if err := opts.messageDecMode().Unmarshal(data, &raw); err != nil {
 		return err
 	}
 	if len(raw.Signature) == 0 {
@@ -113,7 +125,7 @@ func (s *Signature) UnmarshalCBOR(data []byte) error {
 		},
 		Signature: raw.Signature,
 	}
-	if err := sig.Headers.UnmarshalFromRaw(); err != nil {
+	if err := sig.Headers.UnmarshalFromRawWithOptions(opts); err != nil {
 		return err
 	}
 
@@ -171,6 +183,10 @@ func (s *Signature) Sign(rand io.Reader, signer Signer, protected cbor.RawMessag
 // Verifying a COSE_Signature requires the encoded protected header and the
 // payload of its parent message.
 //
+// Signatures with critical header parameters not defined by this library are
+// rejected. Use [Signature.VerifyWithOptions] to verify signatures with
+// critical header parameters understood by the application.
+//
 // Reference: https://datatracker.ietf.org/doc/html/rfc8152#section-4.4
 //
 // # Experimental
@@ -178,6 +194,17 @@ func (s *Signature) Sign(rand io.Reader, signer Signer, protected cbor.RawMessag
 // Notice: The COSE Sign API is EXPERIMENTAL and may be changed or removed in a
 // later release.
 func (s *Signature) Verify(verifier Verifier, protected cbor.RawMessage, payload, external []byte) error {
+	return s.VerifyWithOptions(verifier, protected, payload, external, nil)
+}
+
+// VerifyWithOptions verifies the signature as [Signature.Verify] does, with
+// the options specified by opts.
+//
+// # Experimental
+//
+// Notice: The COSE Sign API is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func (s *Signature) VerifyWithOptions(verifier Verifier, protected cbor.RawMessage, payload, external []byte, opts *VerifyOptions) error {
 	if s == nil {
 		return errors.New("verifying nil Signature")
 	}
@@ -198,13 +225,19 @@ func (s *Signature) Verify(verifier Verifier, protected cbor.RawMessage, payload
 	if err != nil {
 		return err
 	}
+	if err := ensureCriticalUnderstood(s.Headers.Protected, opts, true); err != nil {
+		return err
+	}
 
 	// verify the message
 	toBeSigned, err := s.toBeSigned(protected, payload, external)
 	if err != nil {
 		return err
 	}
-	return verifier.Verify(toBeSigned, s.Signature)
+	if err := verifier.Verify(toBeSigned, s.Signature); err != nil {
+		return err
+	}
+	return validateCWTClaims(s.Headers.Protected, opts, false)
 }
 
 // toBeSigned constructs Sig_structure, computes and returns ToBeSigned.
@@ -308,19 +341,31 @@ func NewSignMessage() *SignMessage {
 // Notice: The COSE Sign API is EXPERIMENTAL and may be changed or removed in a
 // later release.
 func (m *SignMessage) MarshalCBOR() ([]byte, error) {
+	return m.MarshalCBORWithOptions(nil)
+}
+
+// MarshalCBORWithOptions encodes SignMessage into a COSE_Sign_Tagged object,
+// encoding header values of the message and its signatures with the modes
+// specified by opts.
+//
+// # Experimental
+//
+// Notice: The COSE Sign API is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func (m *SignMessage) MarshalCBORWithOptions(opts *CBOROptions) ([]byte, error) {
 	if m == nil {
 		return nil, errors.New("cbor: MarshalCBOR on nil SignMessage pointer")
 	}
 	if len(m.Signatures) == 0 {
 		return nil, ErrNoSignatures
 	}
-	protected, unprotected, err := m.Headers.marshal()
+	protected, unprotected, err := m.Headers.marshal(opts)
 	if err != nil {
 		return nil, err
 	}
 	signatures := make([]cbor.RawMessage, 0, len(m.Signatures))
 	for _, sig := range m.Signatures {
-		sigCBOR, err := sig.MarshalCBOR()
+		sigCBOR, err := sig.marshal(opts)
 		if err != nil {
 			return nil, err
 		}
@@ -345,9 +390,25 @@ func (m *SignMessage) MarshalCBOR() ([]byte, error) {
 // Notice: The COSE Sign API is EXPERIMENTAL and may be changed or removed in a
 // later release.
 func (m *SignMessage) UnmarshalCBOR(data []byte) error {
+	return m.UnmarshalCBORWithOptions(data, nil)
+}
+
+// UnmarshalCBORWithOptions decodes a COSE_Sign_Tagged object into SignMessage,
+// decoding header values of the message and its signatures with the modes
+// specified by opts.
+//
+// # Experimental
+//
+// Notice: The COSE Sign API is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func (m *SignMessage) UnmarshalCBORWithOptions(data []byte, opts *CBOROptions) error {
 	if m == nil {
 		return errors.New("cbor: UnmarshalCBOR on nil SignMessage pointer")
 	}
+	data, err := opts.toDefiniteLength(data, true)
+	if err != nil {
+		return err
+	}
 
 	// fast message check
 	if !bytes.HasPrefix(data, signMessagePrefix) {
@@ -356,16 +417,27 @@ func (m *SignMessage) UnmarshalCBOR(data []byte) error {
 
 	// decode to signMessage and parse
 	var raw signMessage
-	if err := decModeWithTagsForbidden.Unmarshal(data[2:], &raw); err != nil {
+	if err := opts.messageDecMode().Unmarshal(data[2:], &raw); err != nil {
 		return err
 	}
+	if opts.deterministic() {
+		if err := checkDeterministicMessage(data[2:], true); err != nil {
+			return err
+		}
+	}
+	if opts != nil && opts.DecMode != nil {
+		// the signatures array is not type checked by its items
+		if err := ensureUntaggedItems(data[2:]); err != nil {
+			return err
+		}
+	}
 	if len(raw.Signatures) == 0 {
 		return ErrNoSignatures
 	}
 	signatures := make([]*Signature, 0, len(raw.Signatures))
 	for _, sigCBOR := range raw.Signatures {
 		sig := &Signature{}
-		if err := sig.UnmarshalCBOR(sigCBOR); err != nil {
+		if err := sig.unmarshal(sigCBOR, opts); err != nil {
 			return err
 		}
 		signatures = append(signatures, sig)
@@ -378,7 +450,7 @@ func (m *SignMessage) UnmarshalCBOR(data []byte) error {
 		Payload:    raw.Payload,
 		Signatures: signatures,
 	}
-	if err := msg.Headers.UnmarshalFromRaw(); err != nil {
+	if err := msg.Headers.UnmarshalFromRawWithOptions(opts); err != nil {
 		return err
 	}
 
@@ -436,6 +508,10 @@ func (m *SignMessage) Sign(rand io.Reader, external []byte, signers ...Signer) e
 // See [Signature.Verify] for advanced verification scenarios like threshold
 // policies.
 //
+// Messages and signatures with critical header parameters not defined by this
+// library are rejected. Use [SignMessage.VerifyWithOptions] to verify messages
+// with critical header parameters understood by the application.
+//
 // Reference: https://datatracker.ietf.org/doc/html/rfc8152#section-4.4
 //
 // # Experimental
@@ -443,6 +519,17 @@ func (m *SignMessage) Sign(rand io.Reader, external []byte, signers ...Signer) e
 // Notice: The COSE Sign API is EXPERIMENTAL and may be changed or removed in a
 // later release.
 func (m *SignMessage) Verify(external []byte, verifiers ...Verifier) error {
+	return m.VerifyWithOptions(external, nil, verifiers...)
+}
+
+// VerifyWithOptions verifies the signatures on the SignMessage as
+// [SignMessage.Verify] does, with the options specified by opts.
+//
+// # Experimental
+//
+// Notice: The COSE Sign API is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func (m *SignMessage) VerifyWithOptions(external []byte, opts *VerifyOptions, verifiers ...Verifier) error {
 	if m == nil {
 		return errors.New("verifying nil SignMessage")
 	}
@@ -458,6 +545,10 @@ func (m *SignMessage) Verify(external []byte, verifiers ...Verifier) error {
 		return fmt.Errorf("%d verifiers for %d signatures", len(verifiers), len(m.Signatures))
 	}
 
+	if err := ensureCriticalUnderstood(m.Headers.Protected, opts, true); err != nil {
+		return err
+	}
+
 	// populate common parameters
 	var protected cbor.RawMessage
 	protected, err := m.Headers.MarshalProtected()
@@ -467,9 +558,9 @@ func (m *SignMessage) Verify(external []byte, verifiers ...Verifier) error {
 
 	// verify message accordingly
 	for i, signature := range m.Signatures {
-		if err := signature.Verify(verifiers[i], protected, m.Payload, external); err != nil {
+		if err := signature.VerifyWithOptions(verifiers[i], protected, m.Payload, external, opts); err != nil {
 			return err
 		}
 	}
-	return nil
+	return validateCWTClaims(m.Headers.Protected, opts, true)
 }
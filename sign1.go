@@ -52,7 +52,13 @@ func NewSign1Message() *Sign1Message {
 
 // MarshalCBOR encodes Sign1Message into a COSE_Sign1_Tagged object.
 func (m *Sign1Message) MarshalCBOR() ([]byte, error) {
-	content, err := m.getContent()
+	return m.MarshalCBORWithOptions(nil)
+}
+
+// MarshalCBORWithOptions encodes Sign1Message into a COSE_Sign1_Tagged object,
+// encoding header values with the modes specified by opts.
+func (m *Sign1Message) MarshalCBORWithOptions(opts *CBOROptions) ([]byte, error) {
+	content, err := m.getContent(opts)
 	if err != nil {
 		return nil, err
 	}
@@ -65,16 +71,26 @@ func (m *Sign1Message) MarshalCBOR() ([]byte, error) {
 
 // UnmarshalCBOR decodes a COSE_Sign1_Tagged object into Sign1Message.
 func (m *Sign1Message) UnmarshalCBOR(data []byte) error {
+	return m.UnmarshalCBORWithOptions(data, nil)
+}
+
+// UnmarshalCBORWithOptions decodes a COSE_Sign1_Tagged object into
+// Sign1Message, decoding header values with the modes specified by opts.
+func (m *Sign1Message) UnmarshalCBORWithOptions(data []byte, opts *CBOROptions) error {
 	if m == nil {
 		return errors.New("cbor: UnmarshalCBOR on nil Sign1Message pointer")
 	}
+	data, err := opts.toDefiniteLength(data, false)
+	if err != nil {
+		return err
+	}
 
 	// fast message check
 	if !bytes.HasPrefix(data, sign1MessagePrefix) {
 		return errors.New("cbor: invalid COSE_Sign1_Tagged object")
 	}
 
-	return m.doUnmarshal(data[1:])
+	return m.doUnmarshal(data[1:], opts)
 }
 
 // Sign signs a Sign1Message using the provided Signer.
@@ -122,8 +138,18 @@ func (m *Sign1Message) Sign(rand io.Reader, external []byte, signer Signer) erro
 // Verify verifies the signature on the Sign1Message returning nil on success or
 // a suitable error if verification fails.
 //
+// Messages with critical header parameters not defined by this library are
+// rejected. Use [Sign1Message.VerifyWithOptions] to verify messages with
+// critical header parameters understood by the application.
+//
 // Reference: https://datatracker.ietf.org/doc/html/rfc8152#section-4.4
 func (m *Sign1Message) Verify(external []byte, verifier Verifier) error {
+	return m.VerifyWithOptions(external, verifier, nil)
+}
+
+// VerifyWithOptions verifies the signature on the Sign1Message as
+// [Sign1Message.Verify] does, with the options specified by opts.
+func (m *Sign1Message) VerifyWithOptions(external []byte, verifier Verifier, opts *VerifyOptions) error {
 	if m == nil {
 		return errors.New("verifying nil Sign1Message")
 	}
@@ -141,13 +167,19 @@ func (m *Sign1Message) Verify(external []byte, verifier Verifier) error {
 	if err != nil {
 		return err
 	}
+	if err := ensureCriticalUnderstood(m.Headers.Protected, opts, true); err != nil {
+		return err
+	}
 
 	// verify the message
 	toBeSigned, err := m.toBeSigned(external)
 	if err != nil {
 		return err
 	}
-	return verifier.Verify(toBeSigned, m.Signature)
+	if err := verifier.Verify(toBeSigned, m.Signature); err != nil {
+		return err
+	}
+	return validateCWTClaims(m.Headers.Protected, opts, true)
 }
 
 // toBeSigned constructs Sig_structure, computes and returns ToBeSigned.
@@ -186,14 +218,14 @@ func (m *Sign1Message) toBeSigned(external []byte) ([]byte, error) {
 	return encMode.Marshal(sigStructure)
 }
 
-func (m *Sign1Message) getContent() (sign1Message, error) {
+func (m *Sign1Message) getContent(opts *CBOROptions) (sign1Message, error) {
 	if m == nil {
 		return sign1Message{}, errors.New("cbor: MarshalCBOR on nil Sign1Message pointer")
 	}
 	if len(m.Signature) == 0 {
 		return sign1Message{}, ErrEmptySignature
 	}
-	protected, unprotected, err := m.Headers.marshal()
+	protected, unprotected, err := m.Headers.marshal(opts)
 	if err != nil {
 		return sign1Message{}, err
 	}
@@ -208,12 +240,17 @@ func (m *Sign1Message) getContent() (sign1Message, error) {
 	return content, nil
 }
 
-func (m *Sign1Message) doUnmarshal(data []byte) error {
+func (m *Sign1Message) doUnmarshal(data []byte, opts *CBOROptions) error {
 	// decode to sign1Message and parse
 	var raw sign1Message
-	if err := decModeWithTagsForbidden.Unmarshal(data, &raw); err != nil {
+	if err := opts.messageDecMode().Unmarshal(data, &raw); err != nil {
 		return err
 	}
+	if opts.deterministic() {
+		if err := checkDeterministicMessage(data, false); err != nil {
+			return err
+		}
+	}
 	if len(raw.Signature) == 0 {
 		return ErrEmptySignature
 	}
@@ -225,7 +262,7 @@ func (m *Sign1Message) doUnmarshal(data []byte) error {
 		Payload:   raw.Payload,
 		Signature: raw.Signature,
 	}
-	if err := msg.Headers.UnmarshalFromRaw(); err != nil {
+	if err := msg.Headers.UnmarshalFromRawWithOptions(opts); err != nil {
 		return err
 	}
 
@@ -254,7 +291,13 @@ type UntaggedSign1Message Sign1Message
 
 // MarshalCBOR encodes UntaggedSign1Message into a COSE_Sign1 object.
 func (m *UntaggedSign1Message) MarshalCBOR() ([]byte, error) {
-	content, err := (*Sign1Message)(m).getContent()
+	return m.MarshalCBORWithOptions(nil)
+}
+
+// MarshalCBORWithOptions encodes UntaggedSign1Message into a COSE_Sign1
+// object, encoding header values with the modes specified by opts.
+func (m *UntaggedSign1Message) MarshalCBORWithOptions(opts *CBOROptions) ([]byte, error) {
+	content, err := (*Sign1Message)(m).getContent(opts)
 	if err != nil {
 		return nil, err
 	}
@@ -264,6 +307,13 @@ func (m *UntaggedSign1Message) MarshalCBOR() ([]byte, error) {
 
 // UnmarshalCBOR decodes a COSE_Sign1 object into an UntaggedSign1Message.
 func (m *UntaggedSign1Message) UnmarshalCBOR(data []byte) error {
+	return m.UnmarshalCBORWithOptions(data, nil)
+}
+
+// UnmarshalCBORWithOptions decodes a COSE_Sign1 object into an
+// UntaggedSign1Message, decoding header values with the modes specified by
+// opts.
+func (m *UntaggedSign1Message) UnmarshalCBORWithOptions(data []byte, opts *CBOROptions) error {
 	if m == nil {
 		return errors.New("cbor: UnmarshalCBOR on nil UntaggedSign1Message pointer")
 	}
@@ -271,13 +321,17 @@ func (m *UntaggedSign1Message) UnmarshalCBOR(data []byte) error {
 	if len(data) == 0 {
 		return errors.New("cbor: zero length data")
 	}
+	data, err := opts.toDefiniteLength(data, false)
+	if err != nil {
+		return err
+	}
 
 	// fast message check - ensure the frist byte indicates a four-element array
 	if data[0] != sign1MessagePrefix[1] {
 		return errors.New("cbor: invalid COSE_Sign1 object")
 	}
 
-	return (*Sign1Message)(m).doUnmarshal(data)
+	return (*Sign1Message)(m).doUnmarshal(data, opts)
 }
 
 // Sign signs an UntaggedSign1Message using the provided [Signer].
@@ -301,6 +355,12 @@ func (m *UntaggedSign1Message) Verify(external []byte, verifier Verifier) error
 	return (*Sign1Message)(m).Verify(external, verifier)
 }
 
+// VerifyWithOptions verifies the signature on the UntaggedSign1Message as
+// [Sign1Message.VerifyWithOptions] does.
+func (m *UntaggedSign1Message) VerifyWithOptions(external []byte, verifier Verifier, opts *VerifyOptions) error {
+	return (*Sign1Message)(m).VerifyWithOptions(external, verifier, opts)
+}
+
 // Sign1Untagged signs an UntaggedSign1Message using the provided [Signer].
 //
 // This method is a wrapper of [UntaggedSign1Message.Sign].
@@ -0,0 +1,695 @@
+package cose
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// X5Bag returns the bag of certificates in the protected header, or nil if
+// the x5bag header parameter is not present.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9360.html#section-2
+func (h ProtectedHeader) X5Bag() ([]*x509.Certificate, error) {
+	return headerCertificates(h, true, HeaderLabelX5Bag)
+}
+
+// SetX5Bag sets the bag of certificates in the protected header.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9360.html#section-2
+func (h ProtectedHeader) SetX5Bag(certs []*x509.Certificate) error {
+	return setHeaderCertificates(h, true, HeaderLabelX5Bag, certs)
+}
+
+// X5Chain returns the chain of certificates in the protected header, or nil
+// if the x5chain header parameter is not present.
+// The first certificate is the end-entity certificate, each following
+// certificate certifies the previous one.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9360.html#section-2
+func (h ProtectedHeader) X5Chain() ([]*x509.Certificate, error) {
+	return headerCertificates(h, true, HeaderLabelX5Chain)
+}
+
+// SetX5Chain sets the chain of certificates in the protected header.
+// The first certificate must be the end-entity certificate, each following
+// certificate must certify the previous one.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9360.html#section-2
+func (h ProtectedHeader) SetX5Chain(certs []*x509.Certificate) error {
+	return setHeaderCertificates(h, true, HeaderLabelX5Chain, certs)
+}
+
+// X5Bag returns the bag of certificates in the unprotected header, or nil if
+// the x5bag header parameter is not present.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9360.html#section-2
+func (h UnprotectedHeader) X5Bag() ([]*x509.Certificate, error) {
+	return headerCertificates(h, false, HeaderLabelX5Bag)
+}
+
+// SetX5Bag sets the bag of certificates in the unprotected header.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9360.html#section-2
+func (h UnprotectedHeader) SetX5Bag(certs []*x509.Certificate) error {
+	return setHeaderCertificates(h, false, HeaderLabelX5Bag, certs)
+}
+
+// X5Chain returns the chain of certificates in the unprotected header, or nil
+// if the x5chain header parameter is not present.
+// The first certificate is the end-entity certificate, each following
+// certificate certifies the previous one.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9360.html#section-2
+func (h UnprotectedHeader) X5Chain() ([]*x509.Certificate, error) {
+	return headerCertificates(h, false, HeaderLabelX5Chain)
+}
+
+// SetX5Chain sets the chain of certificates in the unprotected header.
+// The first certificate must be the end-entity certificate, each following
+// certificate must certify the previous one.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9360.html#section-2
+func (h UnprotectedHeader) SetX5Chain(certs []*x509.Certificate) error {
+	return setHeaderCertificates(h, false, HeaderLabelX5Chain, certs)
+}
+
+// X5Bag returns the bag of certificates from the protected or the unprotected
+// header, or nil if the x5bag header parameter is not present.
+// It fails with ErrDuplicateHeaderParameter if both headers contain a bag of
+// certificates.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9360.html#section-2
+func (h *Headers) X5Bag() ([]*x509.Certificate, error) {
+	if err := h.ensureSingleBucket(HeaderLabelX5Bag); err != nil {
+		return nil, err
+	}
+	if hasLabel(h.Protected, HeaderLabelX5Bag) {
+		return h.Protected.X5Bag()
+	}
+	return h.Unprotected.X5Bag()
+}
+
+// SetX5Bag sets the bag of certificates in the unprotected header, as the
+// certificates are only hints to build a chain.
+// It fails with ErrDuplicateHeaderParameter if the protected header contains
+// a bag of certificates.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9360.html#section-2
+func (h *Headers) SetX5Bag(certs []*x509.Certificate) error {
+	if hasLabel(h.Protected, HeaderLabelX5Bag) {
+		return fmt.Errorf("header parameter: x5bag: %w", ErrDuplicateHeaderParameter)
+	}
+	if h.Unprotected == nil {
+		h.Unprotected = make(UnprotectedHeader)
+	}
+	return h.Unprotected.SetX5Bag(certs)
+}
+
+// X5Chain returns the chain of certificates from the protected or the
+// unprotected header, or nil if the x5chain header parameter is not present.
+// It fails with ErrDuplicateHeaderParameter if both headers contain a chain
+// of certificates.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9360.html#section-2
+func (h *Headers) X5Chain() ([]*x509.Certificate, error) {
+	if err := h.ensureSingleBucket(HeaderLabelX5Chain); err != nil {
+		return nil, err
+	}
+	if hasLabel(h.Protected, HeaderLabelX5Chain) {
+		return h.Protected.X5Chain()
+	}
+	return h.Unprotected.X5Chain()
+}
+
+// SetX5Chain sets the chain of certificates in the protected header, so that
+// the signature binds the signer to its certificate.
+// It fails with ErrDuplicateHeaderParameter if the unprotected header contains
+// a chain of certificates.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9360.html#section-2
+func (h *Headers) SetX5Chain(certs []*x509.Certificate) error {
+	if hasLabel(h.Unprotected, HeaderLabelX5Chain) {
+		return fmt.Errorf("header parameter: x5chain: %w", ErrDuplicateHeaderParameter)
+	}
+	if h.Protected == nil {
+		h.Protected = make(ProtectedHeader)
+	}
+	return h.Protected.SetX5Chain(certs)
+}
+
+// headerCertificates returns the parsed certificates of a x5bag or a x5chain
+// header parameter.
+func headerCertificates(h map[any]any, protected bool, label int64) ([]*x509.Certificate, error) {
+	value, ok, err := headerParameter(h, protected, label)
+	if !ok {
+		return nil, err
+	}
+	raw, _ := coseX509(value)
+	certs := make([]*x509.Certificate, 0, len(raw))
+	for _, der := range raw {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("header parameter: %s: %w", headerLabelName(label), err)
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
+
+// setHeaderCertificates sets a x5bag or a x5chain header parameter.
+// A single certificate is encoded as a bstr, multiple certificates as an array
+// of bstr.
+func setHeaderCertificates(h map[any]any, protected bool, label int64, certs []*x509.Certificate) error {
+	var value any
+	switch len(certs) {
+	case 0:
+		return fmt.Errorf("header parameter: %s: require at least one certificate", headerLabelName(label))
+	case 1:
+		value = certs[0].Raw
+	default:
+		raw := make([]any, 0, len(certs))
+		for _, cert := range certs {
+			raw = append(raw, cert.Raw)
+		}
+		value = raw
+	}
+	return setHeaderParameter(h, protected, label, value)
+}
+
+// coseX509 returns the DER encoded certificates of a COSE_X509 value.
+//
+//	COSE_X509 = bstr / [ 2*certs: bstr ]
+//
+// Arrays of a single certificate are accepted for interoperability.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9360.html#section-2
+func coseX509(value any) ([][]byte, error) {
+	switch v := value.(type) {
+	case []byte:
+		return [][]byte{v}, nil
+	case [][]byte:
+		if len(v) == 0 {
+			return nil, errors.New("require non-empty array")
+		}
+		return v, nil
+	case []any:
+		if len(v) == 0 {
+			return nil, errors.New("require non-empty array")
+		}
+		raw := make([][]byte, 0, len(v))
+		for _, cert := range v {
+			der, ok := cert.([]byte)
+			if !ok {
+				return nil, errors.New("require bstr / array of bstr type")
+			}
+			raw = append(raw, der)
+		}
+		return raw, nil
+	}
+	return nil, errors.New("require bstr / array of bstr type")
+}
+
+// CertHash is a certificate thumbprint, used in the x5t header parameter to
+// identify the certificate of the signer.
+//
+//	COSE_CertHash = [ hashAlg: (int / tstr), hashValue: bstr ]
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9360.html#section-2
+type CertHash struct {
+	// HashAlgorithm is the hash algorithm of the thumbprint, e.g.
+	// AlgorithmSHA256, AlgorithmSHA256_64 or AlgorithmSHA512_256.
+	HashAlgorithm Algorithm
+
+	// HashValue is the hash of the DER encoded certificate.
+	HashValue []byte
+}
+
+// NewCertHash computes the thumbprint of a certificate with the hash
+// algorithm.
+func NewCertHash(alg Algorithm, cert *x509.Certificate) (*CertHash, error) {
+	value, err := certHashValue(alg, cert.Raw)
+	if err != nil {
+		return nil, err
+	}
+	return &CertHash{
+		HashAlgorithm: alg,
+		HashValue:     value,
+	}, nil
+}
+
+// Match reports whether the thumbprint identifies the certificate.
+func (h *CertHash) Match(cert *x509.Certificate) (bool, error) {
+	return h.match(cert.Raw)
+}
+
+// match reports whether the thumbprint identifies the encoded certificate.
+func (h *CertHash) match(raw []byte) (bool, error) {
+	value, err := certHashValue(h.HashAlgorithm, raw)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(value, h.HashValue), nil
+}
+
+// Find returns the first certificate identified by the thumbprint, or nil if
+// no certificate matches.
+func (h *CertHash) Find(certs []*x509.Certificate) (*x509.Certificate, error) {
+	for _, cert := range certs {
+		ok, err := h.Match(cert)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return cert, nil
+		}
+	}
+	return nil, nil
+}
+
+// certHashValue computes the hash of a DER encoded certificate.
+func certHashValue(alg Algorithm, der []byte) ([]byte, error) {
+	switch alg {
+	case AlgorithmSHA256_64:
+		value, err := computeHash(crypto.SHA256, der)
+		if err != nil {
+			return nil, err
+		}
+		return value[:8], nil
+	case AlgorithmSHA256, AlgorithmSHA384, AlgorithmSHA512, AlgorithmSHA512_256:
+		return alg.computeHash(der)
+	}
+	return nil, fmt.Errorf("x5t: %v: %w", alg, ErrAlgorithmNotSupported)
+}
+
+// coseCertHash returns the thumbprint of a COSE_CertHash value.
+func coseCertHash(value any) (*CertHash, error) {
+	v, ok := value.([]any)
+	if !ok || len(v) != 2 {
+		return nil, errors.New("require [int / tstr, bstr] type")
+	}
+	alg, ok := v[0].(Algorithm)
+	if !ok {
+		if !canInt(v[0]) {
+			if canTstr(v[0]) {
+				return nil, fmt.Errorf("hash algorithm %q: %w", v[0], ErrAlgorithmNotSupported)
+			}
+			return nil, errors.New("require [int / tstr, bstr] type")
+		}
+		alg = Algorithm(reflect.ValueOf(v[0]).Convert(reflect.TypeOf(int64(0))).Int())
+	}
+	hash, ok := v[1].([]byte)
+	if !ok {
+		return nil, errors.New("require [int / tstr, bstr] type")
+	}
+	return &CertHash{
+		HashAlgorithm: alg,
+		HashValue:     hash,
+	}, nil
+}
+
+// X5T returns the thumbprint of the certificate of the signer in the
+// protected header, or nil if the x5t header parameter is not present.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9360.html#section-2
+func (h ProtectedHeader) X5T() (*CertHash, error) {
+	return headerCertHash(h, true, HeaderLabelX5T)
+}
+
+// SetX5T sets the thumbprint of the certificate of the signer in the
+// protected header.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9360.html#section-2
+func (h ProtectedHeader) SetX5T(hash *CertHash) error {
+	return setHeaderCertHash(h, true, HeaderLabelX5T, hash)
+}
+
+// X5T returns the thumbprint of the certificate of the signer in the
+// unprotected header, or nil if the x5t header parameter is not present.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9360.html#section-2
+func (h UnprotectedHeader) X5T() (*CertHash, error) {
+	return headerCertHash(h, false, HeaderLabelX5T)
+}
+
+// SetX5T sets the thumbprint of the certificate of the signer in the
+// unprotected header.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9360.html#section-2
+func (h UnprotectedHeader) SetX5T(hash *CertHash) error {
+	return setHeaderCertHash(h, false, HeaderLabelX5T, hash)
+}
+
+// X5T returns the thumbprint of the certificate of the signer from the
+// protected or the unprotected header, or nil if the x5t header parameter is
+// not present.
+// It fails with ErrDuplicateHeaderParameter if both headers contain a
+// thumbprint.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9360.html#section-2
+func (h *Headers) X5T() (*CertHash, error) {
+	if err := h.ensureSingleBucket(HeaderLabelX5T); err != nil {
+		return nil, err
+	}
+	if hasLabel(h.Protected, HeaderLabelX5T) {
+		return h.Protected.X5T()
+	}
+	return h.Unprotected.X5T()
+}
+
+// SetX5T sets the thumbprint of the certificate of the signer in the
+// protected header, so that the signature binds the signer to its
+// certificate.
+// It fails with ErrDuplicateHeaderParameter if the unprotected header contains
+// a thumbprint.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9360.html#section-2
+func (h *Headers) SetX5T(hash *CertHash) error {
+	if hasLabel(h.Unprotected, HeaderLabelX5T) {
+		return fmt.Errorf("header parameter: x5t: %w", ErrDuplicateHeaderParameter)
+	}
+	if h.Protected == nil {
+		h.Protected = make(ProtectedHeader)
+	}
+	return h.Protected.SetX5T(hash)
+}
+
+// X5TCertificate returns the certificate of the signer identified by the x5t
+// header parameter, looked up in certs and in the x5bag header parameter.
+// It fails if the x5t header parameter is not present, or if no certificate
+// matches.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9360.html#section-2
+func (h *Headers) X5TCertificate(certs []*x509.Certificate) (*x509.Certificate, error) {
+	hash, err := h.X5T()
+	if err != nil {
+		return nil, err
+	}
+	if hash == nil {
+		return nil, errors.New("header parameter: x5t: missing thumbprint")
+	}
+	bag, err := h.X5Bag()
+	if err != nil {
+		return nil, err
+	}
+	cert, err := hash.Find(append(certs[:len(certs):len(certs)], bag...))
+	if err != nil {
+		return nil, err
+	}
+	if cert == nil {
+		return nil, errors.New("header parameter: x5t: no matching certificate")
+	}
+	return cert, nil
+}
+
+// headerCertHash returns the thumbprint of a x5t or a c5t header parameter.
+func headerCertHash(h map[any]any, protected bool, label int64) (*CertHash, error) {
+	value, ok, err := headerParameter(h, protected, label)
+	if !ok {
+		return nil, err
+	}
+	hash, err := coseCertHash(value)
+	if err != nil {
+		return nil, fmt.Errorf("header parameter: %s: %w", headerLabelName(label), err)
+	}
+	return hash, nil
+}
+
+// setHeaderCertHash sets a x5t or a c5t header parameter.
+func setHeaderCertHash(h map[any]any, protected bool, label int64, hash *CertHash) error {
+	if hash == nil {
+		return fmt.Errorf("header parameter: %s: require thumbprint", headerLabelName(label))
+	}
+	return setHeaderParameter(h, protected, label, []any{int64(hash.HashAlgorithm), hash.HashValue})
+}
+
+// X509Verifier verifies messages signed with the private key of the
+// end-entity certificate carried in their x5chain header parameter, or
+// referenced by their x5u header parameter.
+//
+// The certificate chain is verified against the trust roots, with the
+// certificates of the x5chain and x5bag header parameters as intermediates,
+// and the signature is verified with the public key of the end-entity
+// certificate. If present, the x5t header parameter must identify the
+// end-entity certificate.
+//
+// Messages without x5chain or x5u header parameter are verified with the C509
+// certificate chain of their c5c header parameter, see [C509Certificate].
+//
+// # Experimental
+//
+// Notice: The X.509 verification API is EXPERIMENTAL and may be changed or
+// removed in a later release.
+type X509Verifier struct {
+	// Roots is the set of trusted root certificates.
+	// If nil, Options.Roots is used, or the system roots if both are nil.
+	Roots *x509.CertPool
+
+	// Options are the options to verify certificate chains.
+	// The intermediates of the message are added to Options.Intermediates.
+	// If Options.KeyUsages is empty, any extended key usage is accepted,
+	// unlike x509.Certificate.Verify which defaults to server authentication.
+	Options x509.VerifyOptions
+
+	// Fetcher, if not nil, fetches the certificates referenced by the x5u
+	// header parameter of messages without x5chain header parameter.
+	Fetcher X5UFetcher
+
+	// C509Roots is the set of trusted root C509 certificates, in addition to
+	// Roots.
+	// Chains of natively signed C509 certificates are only verified against
+	// C509Roots.
+	C509Roots []*C509Certificate
+}
+
+// NewX509Verifier returns a verifier of messages signed by a certificate
+// chaining up to one of the roots.
+//
+// # Experimental
+//
+// Notice: The X.509 verification API is EXPERIMENTAL and may be changed or
+// removed in a later release.
+func NewX509Verifier(roots *x509.CertPool, opts x509.VerifyOptions) *X509Verifier {
+	return &X509Verifier{
+		Roots:   roots,
+		Options: opts,
+	}
+}
+
+// VerifySign1 verifies the certificate chain and the signature of a
+// Sign1Message, and returns the verified chain, starting with the end-entity
+// certificate and ending with the root certificate.
+//
+// # Experimental
+//
+// Notice: The X.509 verification API is EXPERIMENTAL and may be changed or
+// removed in a later release.
+func (v *X509Verifier) VerifySign1(msg *Sign1Message, external []byte) ([]*x509.Certificate, error) {
+	return v.VerifySign1WithOptions(msg, external, nil)
+}
+
+// VerifySign1WithOptions verifies a Sign1Message as [X509Verifier.VerifySign1]
+// does, with the options specified by opts.
+//
+// # Experimental
+//
+// Notice: The X.509 verification API is EXPERIMENTAL and may be changed or
+// removed in a later release.
+func (v *X509Verifier) VerifySign1WithOptions(msg *Sign1Message, external []byte, opts *VerifyOptions) ([]*x509.Certificate, error) {
+	if msg == nil {
+		return nil, errors.New("verifying nil Sign1Message")
+	}
+	chain, verifier, err := v.verifyChain(&msg.Headers)
+	if err != nil {
+		return nil, err
+	}
+	if err := msg.VerifyWithOptions(external, verifier, opts); err != nil {
+		return nil, err
+	}
+	return chain, nil
+}
+
+// VerifySign verifies the certificate chains and the signatures of a
+// SignMessage, and returns the verified chain of each signature, in the order
+// of the signatures.
+//
+// # Experimental
+//
+// Notice: The X.509 verification API is EXPERIMENTAL and may be changed or
+// removed in a later release.
+func (v *X509Verifier) VerifySign(msg *SignMessage, external []byte) ([][]*x509.Certificate, error) {
+	return v.VerifySignWithOptions(msg, external, nil)
+}
+
+// VerifySignWithOptions verifies a SignMessage as [X509Verifier.VerifySign]
+// does, with the options specified by opts.
+//
+// # Experimental
+//
+// Notice: The X.509 verification API is EXPERIMENTAL and may be changed or
+// removed in a later release.
+func (v *X509Verifier) VerifySignWithOptions(msg *SignMessage, external []byte, opts *VerifyOptions) ([][]*x509.Certificate, error) {
+	if msg == nil {
+		return nil, errors.New("verifying nil SignMessage")
+	}
+	chains := make([][]*x509.Certificate, 0, len(msg.Signatures))
+	verifiers := make([]Verifier, 0, len(msg.Signatures))
+	for i, sig := range msg.Signatures {
+		if sig == nil {
+			return nil, fmt.Errorf("signature %d: verifying nil Signature", i)
+		}
+		chain, verifier, err := v.verifyChain(&sig.Headers)
+		if err != nil {
+			return nil, fmt.Errorf("signature %d: %w", i, err)
+		}
+		chains = append(chains, chain)
+		verifiers = append(verifiers, verifier)
+	}
+	if err := msg.VerifyWithOptions(external, opts, verifiers...); err != nil {
+		return nil, err
+	}
+	return chains, nil
+}
+
+// VerifySignature verifies the certificate chain and a signature of a
+// SignMessage, and returns the verified chain.
+// Verifying a COSE_Signature requires the encoded protected header and the
+// payload of its parent message.
+//
+// # Experimental
+//
+// Notice: The X.509 verification API is EXPERIMENTAL and may be changed or
+// removed in a later release.
+func (v *X509Verifier) VerifySignature(sig *Signature, protected cbor.RawMessage, payload, external []byte) ([]*x509.Certificate, error) {
+	return v.VerifySignatureWithOptions(sig, protected, payload, external, nil)
+}
+
+// VerifySignatureWithOptions verifies a signature of a SignMessage as
+// [X509Verifier.VerifySignature] does, with the options specified by opts.
+//
+// # Experimental
+//
+// Notice: The X.509 verification API is EXPERIMENTAL and may be changed or
+// removed in a later release.
+func (v *X509Verifier) VerifySignatureWithOptions(sig *Signature, protected cbor.RawMessage, payload, external []byte, opts *VerifyOptions) ([]*x509.Certificate, error) {
+	if sig == nil {
+		return nil, errors.New("verifying nil Signature")
+	}
+	chain, verifier, err := v.verifyChain(&sig.Headers)
+	if err != nil {
+		return nil, err
+	}
+	if err := sig.VerifyWithOptions(verifier, protected, payload, external, opts); err != nil {
+		return nil, err
+	}
+	return chain, nil
+}
+
+// verifyChain verifies the certificate chain of the headers, and returns the
+// verified chain with a verifier for the public key of the end-entity
+// certificate.
+func (v *X509Verifier) verifyChain(h *Headers) ([]*x509.Certificate, Verifier, error) {
+	certs, err := h.X5Chain()
+	if err != nil {
+		return nil, nil, err
+	}
+	label := HeaderLabelX5Chain
+	if len(certs) == 0 && v.Fetcher != nil {
+		if certs, err = ResolveX5U(v.Fetcher, h); err != nil {
+			return nil, nil, err
+		}
+		label = HeaderLabelX5U
+	} else if len(certs) > 0 {
+		if err := h.ensureX5TMatch(certs[0]); err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(certs) == 0 {
+		c509Certs, err := h.C5C()
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(c509Certs) > 0 {
+			return v.verifyC509Chain(h, c509Certs)
+		}
+		return nil, nil, errors.New("header parameter: x5chain: missing certificate chain")
+	}
+	bag, err := h.X5Bag()
+	if err != nil {
+		return nil, nil, err
+	}
+	chain, err := v.verifyX509Chain(certs, bag, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", headerLabelName(label), err)
+	}
+	verifier, err := newLeafVerifier(h, label, chain[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	return chain, verifier, nil
+}
+
+// verifyX509Chain verifies the chain of X.509 certificates against the trust
+// roots and the additional roots, using the tail of the chain and the bag as
+// intermediates.
+func (v *X509Verifier) verifyX509Chain(certs, bag, roots []*x509.Certificate) ([]*x509.Certificate, error) {
+	opts := v.Options
+	if v.Roots != nil {
+		opts.Roots = v.Roots
+	}
+	if len(roots) > 0 {
+		if opts.Roots != nil {
+			opts.Roots = opts.Roots.Clone()
+		} else if pool, err := x509.SystemCertPool(); err == nil {
+			opts.Roots = pool
+		} else {
+			opts.Roots = x509.NewCertPool()
+		}
+		for _, cert := range roots {
+			opts.Roots.AddCert(cert)
+		}
+	}
+	if opts.Intermediates != nil {
+		opts.Intermediates = opts.Intermediates.Clone()
+	} else {
+		opts.Intermediates = x509.NewCertPool()
+	}
+	for _, cert := range certs[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	for _, cert := range bag {
+		opts.Intermediates.AddCert(cert)
+	}
+	if len(opts.KeyUsages) == 0 {
+		opts.KeyUsages = []x509.ExtKeyUsage{x509.ExtKeyUsageAny}
+	}
+
+	chains, err := certs[0].Verify(opts)
+	if err != nil {
+		return nil, err
+	}
+	return chains[0], nil
+}
+
+// newLeafVerifier returns a verifier for the public key of the end-entity
+// certificate carried in the header parameter, with the algorithm of the
+// protected header.
+func newLeafVerifier(h *Headers, label int64, leaf *x509.Certificate) (Verifier, error) {
+	if leaf.KeyUsage != 0 && leaf.KeyUsage&x509.KeyUsageDigitalSignature == 0 {
+		return nil, fmt.Errorf("%s: certificate key usage does not permit digital signatures", headerLabelName(label))
+	}
+	alg, err := h.Protected.Algorithm()
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := NewVerifier(alg, leaf.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", headerLabelName(label), err)
+	}
+	return verifier, nil
+}
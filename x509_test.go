@@ -0,0 +1,565 @@
+package cose
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// generateTestCertificateChain generates a certificate chain made of a leaf
+// certificate and its root CA certificate, and returns the chain with the
+// private key of the leaf certificate.
+func generateTestCertificateChain(t *testing.T) ([]*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	now := time.Now()
+	rootKey := generateTestECDSAKey(t)
+	root := createTestCertificate(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Root CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, nil, rootKey.Public(), rootKey)
+
+	leafKey := generateTestECDSAKey(t)
+	leaf := createTestCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "Test Leaf"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}, root, leafKey.Public(), rootKey)
+
+	return []*x509.Certificate{leaf, root}, leafKey
+}
+
+// createTestCertificate creates a certificate from template signed by the
+// parent certificate, or self-signed if parent is nil.
+func createTestCertificate(t *testing.T, template, parent *x509.Certificate, pub crypto.PublicKey, priv crypto.Signer) *x509.Certificate {
+	t.Helper()
+	if parent == nil {
+		parent = template
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, pub, priv)
+	if err != nil {
+		t.Fatalf("x509.CreateCertificate() error = %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("x509.ParseCertificate() error = %v", err)
+	}
+	return cert
+}
+
+func TestHeaders_X5Chain(t *testing.T) {
+	chain, _ := generateTestCertificateChain(t)
+	leaf, root := chain[0], chain[1]
+
+	tests := []struct {
+		name    string
+		h       Headers
+		want    []*x509.Certificate
+		wantErr string
+	}{
+		{
+			name: "absent",
+		},
+		{
+			name: "single certificate",
+			h: Headers{
+				Protected: ProtectedHeader{HeaderLabelX5Chain: leaf.Raw},
+			},
+			want: []*x509.Certificate{leaf},
+		},
+		{
+			name: "array of certificates",
+			h: Headers{
+				Unprotected: UnprotectedHeader{HeaderLabelX5Chain: []any{leaf.Raw, root.Raw}},
+			},
+			want: []*x509.Certificate{leaf, root},
+		},
+		{
+			name: "slice of certificates",
+			h: Headers{
+				Unprotected: UnprotectedHeader{HeaderLabelX5Chain: [][]byte{leaf.Raw, root.Raw}},
+			},
+			want: []*x509.Certificate{leaf, root},
+		},
+		{
+			name: "both buckets",
+			h: Headers{
+				Protected:   ProtectedHeader{HeaderLabelX5Chain: leaf.Raw},
+				Unprotected: UnprotectedHeader{HeaderLabelX5Chain: leaf.Raw},
+			},
+			wantErr: "header parameter: x5chain: header parameter present in both protected and unprotected headers",
+		},
+		{
+			name: "empty array",
+			h: Headers{
+				Protected: ProtectedHeader{HeaderLabelX5Chain: []any{}},
+			},
+			wantErr: "header parameter: x5chain: require non-empty array",
+		},
+		{
+			name: "invalid type",
+			h: Headers{
+				Protected: ProtectedHeader{HeaderLabelX5Chain: []any{leaf.Raw, "root"}},
+			},
+			wantErr: "header parameter: x5chain: require bstr / array of bstr type",
+		},
+		{
+			name: "invalid certificate",
+			h: Headers{
+				Protected: ProtectedHeader{HeaderLabelX5Chain: []byte{0x30}},
+			},
+			wantErr: "header parameter: x5chain: x509: malformed certificate",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.h.X5Chain()
+			if err != nil && (err.Error() != tt.wantErr) {
+				t.Errorf("Headers.X5Chain() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			} else if err == nil && tt.wantErr != "" {
+				t.Errorf("Headers.X5Chain() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Headers.X5Chain() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeaders_SetX5Chain(t *testing.T) {
+	chain, _ := generateTestCertificateChain(t)
+	leaf, root := chain[0], chain[1]
+
+	var h Headers
+	if err := h.SetX5Chain(chain); err != nil {
+		t.Fatalf("Headers.SetX5Chain() error = %v", err)
+	}
+	if err := h.SetX5Bag([]*x509.Certificate{root}); err != nil {
+		t.Fatalf("Headers.SetX5Bag() error = %v", err)
+	}
+	want := Headers{
+		Protected:   ProtectedHeader{HeaderLabelX5Chain: []any{leaf.Raw, root.Raw}},
+		Unprotected: UnprotectedHeader{HeaderLabelX5Bag: root.Raw},
+	}
+	if !reflect.DeepEqual(h, want) {
+		t.Errorf("Headers = %v, want %v", h, want)
+	}
+
+	// round trip
+	protected, err := h.MarshalProtected()
+	if err != nil {
+		t.Fatalf("Headers.MarshalProtected() error = %v", err)
+	}
+	unprotected, err := h.MarshalUnprotected()
+	if err != nil {
+		t.Fatalf("Headers.MarshalUnprotected() error = %v", err)
+	}
+	got := Headers{RawProtected: protected, RawUnprotected: unprotected}
+	if err := got.UnmarshalFromRaw(); err != nil {
+		t.Fatalf("Headers.UnmarshalFromRaw() error = %v", err)
+	}
+	gotChain, err := got.X5Chain()
+	if err != nil {
+		t.Fatalf("Headers.X5Chain() error = %v", err)
+	}
+	if !reflect.DeepEqual(gotChain, chain) {
+		t.Errorf("Headers.X5Chain() = %v, want %v", gotChain, chain)
+	}
+	gotBag, err := got.X5Bag()
+	if err != nil {
+		t.Fatalf("Headers.X5Bag() error = %v", err)
+	}
+	if !reflect.DeepEqual(gotBag, []*x509.Certificate{root}) {
+		t.Errorf("Headers.X5Bag() = %v, want %v", gotBag, []*x509.Certificate{root})
+	}
+
+	// errors
+	wantErr := "header parameter: x5chain: require at least one certificate"
+	if err := h.Protected.SetX5Chain(nil); err == nil || err.Error() != wantErr {
+		t.Errorf("ProtectedHeader.SetX5Chain() error = %v, wantErr %v", err, wantErr)
+	}
+	h.Unprotected[HeaderLabelX5Chain] = leaf.Raw
+	delete(h.Protected, HeaderLabelX5Chain)
+	if err := h.SetX5Chain(chain); !errors.Is(err, ErrDuplicateHeaderParameter) {
+		t.Errorf("Headers.SetX5Chain() error = %v, want %v", err, ErrDuplicateHeaderParameter)
+	}
+	h.Protected[HeaderLabelX5Bag] = root.Raw
+	if err := h.SetX5Bag(chain); !errors.Is(err, ErrDuplicateHeaderParameter) {
+		t.Errorf("Headers.SetX5Bag() error = %v, want %v", err, ErrDuplicateHeaderParameter)
+	}
+}
+
+func TestX509Verifier_VerifySign1(t *testing.T) {
+	chain, leafKey := generateTestCertificateChain(t)
+	leaf, root := chain[0], chain[1]
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	signer, err := NewSigner(AlgorithmES256, leafKey)
+	if err != nil {
+		t.Fatalf("NewSigner() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		headers func(h *Headers) error
+		alg     Algorithm
+		roots   *x509.CertPool
+		want    []*x509.Certificate
+		wantErr string
+	}{
+		{
+			name: "leaf certificate",
+			headers: func(h *Headers) error {
+				return h.SetX5Chain([]*x509.Certificate{leaf})
+			},
+			want: []*x509.Certificate{leaf, root},
+		},
+		{
+			name: "full chain",
+			headers: func(h *Headers) error {
+				return h.SetX5Chain(chain)
+			},
+			want: []*x509.Certificate{leaf, root},
+		},
+		{
+			name: "unprotected chain",
+			headers: func(h *Headers) error {
+				return h.Unprotected.SetX5Chain([]*x509.Certificate{leaf})
+			},
+			want: []*x509.Certificate{leaf, root},
+		},
+		{
+			name:    "missing x5chain",
+			headers: func(h *Headers) error { return nil },
+			wantErr: "header parameter: x5chain: missing certificate chain",
+		},
+		{
+			name: "untrusted root",
+			headers: func(h *Headers) error {
+				return h.SetX5Chain(chain)
+			},
+			roots:   x509.NewCertPool(),
+			wantErr: "x5chain: x509: certificate signed by unknown authority",
+		},
+		{
+			name: "algorithm mismatch",
+			headers: func(h *Headers) error {
+				return h.SetX5Chain(chain)
+			},
+			alg:     AlgorithmEdDSA,
+			wantErr: "x5chain: EdDSA: invalid public key",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := NewSign1Message()
+			msg.Payload = []byte("hello world")
+			if err := tt.headers(&msg.Headers); err != nil {
+				t.Fatalf("headers() error = %v", err)
+			}
+			msg.Headers.Protected.SetAlgorithm(AlgorithmES256)
+			if err := msg.Sign(rand.Reader, nil, signer); err != nil {
+				t.Fatalf("Sign1Message.Sign() error = %v", err)
+			}
+			if tt.alg != 0 {
+				msg.Headers.Protected.SetAlgorithm(tt.alg)
+			}
+
+			verifierRoots := roots
+			if tt.roots != nil {
+				verifierRoots = tt.roots
+			}
+			v := NewX509Verifier(verifierRoots, x509.VerifyOptions{})
+			got, err := v.VerifySign1(msg, nil)
+			if err != nil && (err.Error() != tt.wantErr) {
+				t.Errorf("X509Verifier.VerifySign1() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			} else if err == nil && tt.wantErr != "" {
+				t.Errorf("X509Verifier.VerifySign1() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr != "" {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("X509Verifier.VerifySign1() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestX509Verifier_VerifySign(t *testing.T) {
+	now := time.Now()
+	rootKey := generateTestECDSAKey(t)
+	root := createTestCertificate(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Root CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, nil, rootKey.Public(), rootKey)
+	interKey := generateTestECDSAKey(t)
+	inter := createTestCertificate(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "Test Intermediate CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, root, interKey.Public(), rootKey)
+	leafKey := generateTestECDSAKey(t)
+	leaf := createTestCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "Test Leaf"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}, inter, leafKey.Public(), interKey)
+	encipherKey := generateTestECDSAKey(t)
+	encipher := createTestCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(4),
+		Subject:      pkix.Name{CommonName: "Test Key Agreement"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+		KeyUsage:     x509.KeyUsageKeyAgreement,
+	}, root, encipherKey.Public(), rootKey)
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+
+	newSignature := func(cert *x509.Certificate, bag *x509.Certificate) *Signature {
+		sig := NewSignature()
+		sig.Headers.Protected.SetAlgorithm(AlgorithmES256)
+		if err := sig.Headers.SetX5Chain([]*x509.Certificate{cert}); err != nil {
+			t.Fatalf("Headers.SetX5Chain() error = %v", err)
+		}
+		if bag != nil {
+			if err := sig.Headers.SetX5Bag([]*x509.Certificate{bag}); err != nil {
+				t.Fatalf("Headers.SetX5Bag() error = %v", err)
+			}
+		}
+		return sig
+	}
+	newSigner := func(key *ecdsa.PrivateKey) Signer {
+		signer, err := NewSigner(AlgorithmES256, key)
+		if err != nil {
+			t.Fatalf("NewSigner() error = %v", err)
+		}
+		return signer
+	}
+
+	// intermediate certificate in x5bag
+	msg := NewSignMessage()
+	msg.Payload = []byte("hello world")
+	msg.Signatures = []*Signature{
+		newSignature(leaf, inter),
+		newSignature(inter, nil),
+	}
+	if err := msg.Sign(rand.Reader, nil, newSigner(leafKey), newSigner(interKey)); err != nil {
+		t.Fatalf("SignMessage.Sign() error = %v", err)
+	}
+	v := NewX509Verifier(roots, x509.VerifyOptions{})
+	got, err := v.VerifySign(msg, nil)
+	if err != nil {
+		t.Fatalf("X509Verifier.VerifySign() error = %v", err)
+	}
+	want := [][]*x509.Certificate{{leaf, inter, root}, {inter, root}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("X509Verifier.VerifySign() = %v, want %v", got, want)
+	}
+
+	// missing intermediate certificate
+	msg.Signatures = []*Signature{
+		newSignature(leaf, nil),
+		newSignature(inter, nil),
+	}
+	if err := msg.Sign(rand.Reader, nil, newSigner(leafKey), newSigner(interKey)); err != nil {
+		t.Fatalf("SignMessage.Sign() error = %v", err)
+	}
+	wantErr := "signature 0: x5chain: x509: certificate signed by unknown authority"
+	if _, err := v.VerifySign(msg, nil); err == nil || err.Error() != wantErr {
+		t.Errorf("X509Verifier.VerifySign() error = %v, wantErr %v", err, wantErr)
+	}
+
+	// certificate not allowed to sign
+	msg.Signatures = []*Signature{newSignature(encipher, nil)}
+	if err := msg.Sign(rand.Reader, nil, newSigner(encipherKey)); err != nil {
+		t.Fatalf("SignMessage.Sign() error = %v", err)
+	}
+	wantErr = "signature 0: x5chain: certificate key usage does not permit digital signatures"
+	if _, err := v.VerifySign(msg, nil); err == nil || err.Error() != wantErr {
+		t.Errorf("X509Verifier.VerifySign() error = %v, wantErr %v", err, wantErr)
+	}
+
+	// tampered payload
+	msg.Signatures = []*Signature{newSignature(inter, nil)}
+	if err := msg.Sign(rand.Reader, nil, newSigner(interKey)); err != nil {
+		t.Fatalf("SignMessage.Sign() error = %v", err)
+	}
+	msg.Payload = []byte("tampered")
+	if _, err := v.VerifySign(msg, nil); !errors.Is(err, ErrVerification) {
+		t.Errorf("X509Verifier.VerifySign() error = %v, want %v", err, ErrVerification)
+	}
+}
+
+func TestNewCertHash(t *testing.T) {
+	chain, _ := generateTestCertificateChain(t)
+	leaf := chain[0]
+	sha256Sum := sha256.Sum256(leaf.Raw)
+	sha512Sum := sha512.Sum512_256(leaf.Raw)
+
+	tests := []struct {
+		alg     Algorithm
+		want    []byte
+		wantErr string
+	}{
+		{
+			alg:  AlgorithmSHA256,
+			want: sha256Sum[:],
+		},
+		{
+			alg:  AlgorithmSHA256_64,
+			want: sha256Sum[:8],
+		},
+		{
+			alg:  AlgorithmSHA512_256,
+			want: sha512Sum[:],
+		},
+		{
+			alg:     AlgorithmES256,
+			wantErr: "x5t: ES256: algorithm not supported",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.alg.String(), func(t *testing.T) {
+			got, err := NewCertHash(tt.alg, leaf)
+			if err != nil && (err.Error() != tt.wantErr) {
+				t.Errorf("NewCertHash() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			} else if err == nil && tt.wantErr != "" {
+				t.Errorf("NewCertHash() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr != "" {
+				return
+			}
+			want := &CertHash{HashAlgorithm: tt.alg, HashValue: tt.want}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("NewCertHash() = %v, want %v", got, want)
+			}
+			if ok, err := got.Match(leaf); err != nil || !ok {
+				t.Errorf("CertHash.Match() = %v, %v, want true", ok, err)
+			}
+			if ok, err := got.Match(chain[1]); err != nil || ok {
+				t.Errorf("CertHash.Match() = %v, %v, want false", ok, err)
+			}
+		})
+	}
+}
+
+func TestHeaders_X5T(t *testing.T) {
+	chain, _ := generateTestCertificateChain(t)
+	leaf, root := chain[0], chain[1]
+	hash, err := NewCertHash(AlgorithmSHA256_64, leaf)
+	if err != nil {
+		t.Fatalf("NewCertHash() error = %v", err)
+	}
+
+	// round trip
+	var h Headers
+	if err := h.SetX5T(hash); err != nil {
+		t.Fatalf("Headers.SetX5T() error = %v", err)
+	}
+	if err := h.SetX5Bag(chain); err != nil {
+		t.Fatalf("Headers.SetX5Bag() error = %v", err)
+	}
+	protected, err := h.MarshalProtected()
+	if err != nil {
+		t.Fatalf("Headers.MarshalProtected() error = %v", err)
+	}
+	unprotected, err := h.MarshalUnprotected()
+	if err != nil {
+		t.Fatalf("Headers.MarshalUnprotected() error = %v", err)
+	}
+	got := Headers{RawProtected: protected, RawUnprotected: unprotected}
+	if err := got.UnmarshalFromRaw(); err != nil {
+		t.Fatalf("Headers.UnmarshalFromRaw() error = %v", err)
+	}
+	gotHash, err := got.X5T()
+	if err != nil {
+		t.Fatalf("Headers.X5T() error = %v", err)
+	}
+	if !reflect.DeepEqual(gotHash, hash) {
+		t.Errorf("Headers.X5T() = %v, want %v", gotHash, hash)
+	}
+
+	// certificate lookup
+	cert, err := got.X5TCertificate(nil)
+	if err != nil {
+		t.Fatalf("Headers.X5TCertificate() error = %v", err)
+	}
+	if !cert.Equal(leaf) {
+		t.Errorf("Headers.X5TCertificate() = %v, want %v", cert, leaf)
+	}
+	delete(got.Unprotected, HeaderLabelX5Bag)
+	if cert, err := got.X5TCertificate(chain); err != nil || !cert.Equal(leaf) {
+		t.Errorf("Headers.X5TCertificate() = %v, %v, want %v", cert, err, leaf)
+	}
+	wantErr := "header parameter: x5t: no matching certificate"
+	if _, err := got.X5TCertificate([]*x509.Certificate{root}); err == nil || err.Error() != wantErr {
+		t.Errorf("Headers.X5TCertificate() error = %v, wantErr %v", err, wantErr)
+	}
+
+	// invalid values
+	tests := []struct {
+		name    string
+		value   any
+		wantErr string
+	}{
+		{
+			name:    "not an array",
+			value:   []byte{0x01},
+			wantErr: "header parameter: x5t: require [int / tstr, bstr] type",
+		},
+		{
+			name:    "invalid hash value",
+			value:   []any{int64(-16), "hash"},
+			wantErr: "header parameter: x5t: require [int / tstr, bstr] type",
+		},
+		{
+			name:    "text hash algorithm",
+			value:   []any{"SHA-256", []byte{0x01}},
+			wantErr: `header parameter: x5t: hash algorithm "SHA-256": algorithm not supported`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := Headers{
+				Protected: ProtectedHeader{HeaderLabelX5T: tt.value},
+			}
+			_, err := h.X5T()
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Headers.X5T() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package cose
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"strings"
+)
+
+// X5U returns the URI of the certificates of the signer in the protected
+// header, or an empty string if the x5u header parameter is not present.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9360.html#section-2
+func (h ProtectedHeader) X5U() (string, error) {
+	return headerTstr(h, true, HeaderLabelX5U)
+}
+
+// SetX5U sets the URI of the certificates of the signer in the protected
+// header.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9360.html#section-2
+func (h ProtectedHeader) SetX5U(uri string) error {
+	return setHeaderParameter(h, true, HeaderLabelX5U, uri)
+}
+
+// X5U returns the URI of the certificates of the signer in the unprotected
+// header, or an empty string if the x5u header parameter is not present.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9360.html#section-2
+func (h UnprotectedHeader) X5U() (string, error) {
+	return headerTstr(h, false, HeaderLabelX5U)
+}
+
+// SetX5U sets the URI of the certificates of the signer in the unprotected
+// header.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9360.html#section-2
+func (h UnprotectedHeader) SetX5U(uri string) error {
+	return setHeaderParameter(h, false, HeaderLabelX5U, uri)
+}
+
+// X5U returns the URI of the certificates of the signer from the protected or
+// the unprotected header, or an empty string if the x5u header parameter is
+// not present.
+// It fails with ErrDuplicateHeaderParameter if both headers contain a URI.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9360.html#section-2
+func (h *Headers) X5U() (string, error) {
+	if err := h.ensureSingleBucket(HeaderLabelX5U); err != nil {
+		return "", err
+	}
+	if hasLabel(h.Protected, HeaderLabelX5U) {
+		return h.Protected.X5U()
+	}
+	return h.Unprotected.X5U()
+}
+
+// SetX5U sets the URI of the certificates of the signer in the protected
+// header, so that the signature binds the signer to its certificates.
+// It fails with ErrDuplicateHeaderParameter if the unprotected header contains
+// a URI.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9360.html#section-2
+func (h *Headers) SetX5U(uri string) error {
+	if hasLabel(h.Unprotected, HeaderLabelX5U) {
+		return fmt.Errorf("header parameter: x5u: %w", ErrDuplicateHeaderParameter)
+	}
+	if h.Protected == nil {
+		h.Protected = make(ProtectedHeader)
+	}
+	return h.Protected.SetX5U(uri)
+}
+
+// headerTstr returns the value of a tstr header parameter.
+func headerTstr(h map[any]any, protected bool, label int64) (string, error) {
+	value, ok, err := headerParameter(h, protected, label)
+	if !ok {
+		return "", err
+	}
+	return value.(string), nil
+}
+
+// X5UFetcher fetches the certificates referenced by the URI of a x5u header
+// parameter.
+//
+// Implementations fetching certificates over the network should bound the
+// time and the size of the fetch.
+//
+// # Experimental
+//
+// Notice: The X.509 verification API is EXPERIMENTAL and may be changed or
+// removed in a later release.
+type X5UFetcher interface {
+	// FetchX5U returns the content referenced by the URI, which is either
+	// DER encoded certificates, PEM encoded certificates, or a CBOR encoded
+	// COSE_X509 value.
+	FetchX5U(uri string) ([]byte, error)
+}
+
+// MapX5UFetcher is an in-memory X5UFetcher, mapping URIs to their content.
+//
+// # Experimental
+//
+// Notice: The X.509 verification API is EXPERIMENTAL and may be changed or
+// removed in a later release.
+type MapX5UFetcher map[string][]byte
+
+// FetchX5U returns the content of the URI.
+func (f MapX5UFetcher) FetchX5U(uri string) ([]byte, error) {
+	data, ok := f[uri]
+	if !ok {
+		return nil, fmt.Errorf("x5u: %s: %w", uri, fs.ErrNotExist)
+	}
+	return data, nil
+}
+
+// FSX5UFetcher is an X5UFetcher reading file URIs from a file system.
+// The path of the URI, without its leading slash, is opened in FS, e.g.
+// "file:///certs/chain.pem" is read from "certs/chain.pem".
+//
+// # Experimental
+//
+// Notice: The X.509 verification API is EXPERIMENTAL and may be changed or
+// removed in a later release.
+type FSX5UFetcher struct {
+	FS fs.FS
+}
+
+// FetchX5U reads the file of the URI.
+func (f FSX5UFetcher) FetchX5U(uri string) ([]byte, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("x5u: %w", err)
+	}
+	if u.Scheme != "file" || (u.Host != "" && u.Host != "localhost") {
+		return nil, fmt.Errorf("x5u: %s: require file URI", uri)
+	}
+	data, err := fs.ReadFile(f.FS, strings.TrimPrefix(u.Path, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("x5u: %w", err)
+	}
+	return data, nil
+}
+
+// ResolveX5U fetches and parses the certificates referenced by the x5u header
+// parameter, or returns nil if the parameter is not present.
+// The first certificate is the end-entity certificate.
+// If present, the x5t header parameter must identify the end-entity
+// certificate.
+//
+// The certificates are not verified, see [X509Verifier] to verify the
+// certificate chain.
+//
+// # Experimental
+//
+// Notice: The X.509 verification API is EXPERIMENTAL and may be changed or
+// removed in a later release.
+func ResolveX5U(fetcher X5UFetcher, h *Headers) ([]*x509.Certificate, error) {
+	uri, err := h.X5U()
+	if err != nil || uri == "" {
+		return nil, err
+	}
+	data, err := fetcher.FetchX5U(uri)
+	if err != nil {
+		return nil, err
+	}
+	certs, err := parseCertificateBag(data)
+	if err != nil {
+		return nil, fmt.Errorf("x5u: %w", err)
+	}
+	if err := h.ensureX5TMatch(certs[0]); err != nil {
+		return nil, err
+	}
+	return certs, nil
+}
+
+// ensureX5TMatch ensures the x5t header parameter, if present, identifies the
+// end-entity certificate.
+func (h *Headers) ensureX5TMatch(cert *x509.Certificate) error {
+	hash, err := h.X5T()
+	if err != nil || hash == nil {
+		return err
+	}
+	ok, err := hash.Match(cert)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("header parameter: x5t: certificate mismatch")
+	}
+	return nil
+}
+
+// parseCertificateBag parses DER encoded certificates, PEM encoded
+// certificates, or a CBOR encoded COSE_X509 value.
+func parseCertificateBag(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	switch {
+	case len(data) == 0:
+		return nil, errors.New("no certificate")
+	case bytes.HasPrefix(bytes.TrimSpace(data), []byte("-----BEGIN")):
+		for rest := data; ; {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			if block.Type != "CERTIFICATE" {
+				continue
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			certs = append(certs, cert)
+		}
+	case data[0]>>5 == 2 || data[0]>>5 == 4: // COSE_X509: bstr / [ 2*certs: bstr ]
+		var value any
+		if err := decMode.Unmarshal(data, &value); err != nil {
+			return nil, err
+		}
+		raw, err := coseX509(value)
+		if err != nil {
+			return nil, err
+		}
+		for _, der := range raw {
+			cert, err := x509.ParseCertificate(der)
+			if err != nil {
+				return nil, err
+			}
+			certs = append(certs, cert)
+		}
+	default:
+		var err error
+		if certs, err = x509.ParseCertificates(data); err != nil {
+			return nil, err
+		}
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no certificate")
+	}
+	return certs, nil
+}
@@ -0,0 +1,289 @@
+package cose
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/fs"
+	"math/big"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestParseCertificateBag(t *testing.T) {
+	chain, _ := generateTestCertificateChain(t)
+	leaf, root := chain[0], chain[1]
+	pemChain := append(
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf.Raw}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: root.Raw})...,
+	)
+	coseBstr, err := encMode.Marshal(leaf.Raw)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	coseArray, err := encMode.Marshal([][]byte{leaf.Raw, root.Raw})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    []*x509.Certificate
+		wantErr string
+	}{
+		{
+			name: "DER",
+			data: leaf.Raw,
+			want: []*x509.Certificate{leaf},
+		},
+		{
+			name: "concatenated DER",
+			data: append(append([]byte{}, leaf.Raw...), root.Raw...),
+			want: []*x509.Certificate{leaf, root},
+		},
+		{
+			name: "PEM",
+			data: pemChain,
+			want: []*x509.Certificate{leaf, root},
+		},
+		{
+			name: "COSE_X509 bstr",
+			data: coseBstr,
+			want: []*x509.Certificate{leaf},
+		},
+		{
+			name: "COSE_X509 array",
+			data: coseArray,
+			want: []*x509.Certificate{leaf, root},
+		},
+		{
+			name:    "empty",
+			data:    []byte{},
+			wantErr: "no certificate",
+		},
+		{
+			name:    "PEM without certificate",
+			data:    pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte{0x01}}),
+			wantErr: "no certificate",
+		},
+		{
+			name:    "empty COSE_X509 array",
+			data:    []byte{0x80},
+			wantErr: "require non-empty array",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCertificateBag(tt.data)
+			if err != nil && (err.Error() != tt.wantErr) {
+				t.Errorf("parseCertificateBag() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			} else if err == nil && tt.wantErr != "" {
+				t.Errorf("parseCertificateBag() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseCertificateBag() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) {
+					t.Errorf("parseCertificateBag()[%d] = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFSX5UFetcher(t *testing.T) {
+	f := FSX5UFetcher{
+		FS: fstest.MapFS{
+			"certs/chain.der": &fstest.MapFile{Data: []byte{0x30}},
+		},
+	}
+	tests := []struct {
+		uri     string
+		want    []byte
+		wantErr string
+	}{
+		{
+			uri:  "file:///certs/chain.der",
+			want: []byte{0x30},
+		},
+		{
+			uri:  "file://localhost/certs/chain.der",
+			want: []byte{0x30},
+		},
+		{
+			uri:     "https://example.com/certs/chain.der",
+			wantErr: "x5u: https://example.com/certs/chain.der: require file URI",
+		},
+		{
+			uri:     "file:///certs/missing.der",
+			wantErr: "x5u: open certs/missing.der: file does not exist",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			got, err := f.FetchX5U(tt.uri)
+			if err != nil && (err.Error() != tt.wantErr) {
+				t.Errorf("FSX5UFetcher.FetchX5U() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			} else if err == nil && tt.wantErr != "" {
+				t.Errorf("FSX5UFetcher.FetchX5U() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FSX5UFetcher.FetchX5U() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestX509Verifier_x5u(t *testing.T) {
+	chain, leafKey := generateTestCertificateChain(t)
+	leaf, root := chain[0], chain[1]
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	signer, err := NewSigner(AlgorithmES256, leafKey)
+	if err != nil {
+		t.Fatalf("NewSigner() error = %v", err)
+	}
+	leafHash, err := NewCertHash(AlgorithmSHA256, leaf)
+	if err != nil {
+		t.Fatalf("NewCertHash() error = %v", err)
+	}
+	rootHash, err := NewCertHash(AlgorithmSHA256, root)
+	if err != nil {
+		t.Fatalf("NewCertHash() error = %v", err)
+	}
+	otherKey := generateTestECDSAKey(t)
+	other := createTestCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "Test Other"},
+		NotBefore:    leaf.NotBefore,
+		NotAfter:     leaf.NotAfter,
+	}, nil, otherKey.Public(), otherKey)
+	fetcher := MapX5UFetcher{
+		"https://example.com/leaf.der":  leaf.Raw,
+		"https://example.com/root.der":  root.Raw,
+		"https://example.com/other.der": other.Raw,
+	}
+
+	tests := []struct {
+		name    string
+		headers func(h *Headers) error
+		want    []*x509.Certificate
+		wantErr string
+	}{
+		{
+			name: "x5u",
+			headers: func(h *Headers) error {
+				return h.SetX5U("https://example.com/leaf.der")
+			},
+			want: []*x509.Certificate{leaf, root},
+		},
+		{
+			name: "x5u with matching x5t",
+			headers: func(h *Headers) error {
+				if err := h.SetX5T(leafHash); err != nil {
+					return err
+				}
+				return h.SetX5U("https://example.com/leaf.der")
+			},
+			want: []*x509.Certificate{leaf, root},
+		},
+		{
+			name: "x5u with mismatching x5t",
+			headers: func(h *Headers) error {
+				if err := h.SetX5T(rootHash); err != nil {
+					return err
+				}
+				return h.SetX5U("https://example.com/leaf.der")
+			},
+			wantErr: "header parameter: x5t: certificate mismatch",
+		},
+		{
+			name: "x5chain with mismatching x5t",
+			headers: func(h *Headers) error {
+				if err := h.SetX5T(rootHash); err != nil {
+					return err
+				}
+				return h.SetX5Chain(chain)
+			},
+			wantErr: "header parameter: x5t: certificate mismatch",
+		},
+		{
+			name: "unknown x5u",
+			headers: func(h *Headers) error {
+				return h.SetX5U("https://example.com/unknown.der")
+			},
+			wantErr: "x5u: https://example.com/unknown.der: file does not exist",
+		},
+		{
+			name: "untrusted x5u",
+			headers: func(h *Headers) error {
+				return h.SetX5U("https://example.com/other.der")
+			},
+			wantErr: "x5u: x509: certificate signed by unknown authority",
+		},
+		{
+			name: "x5u without digital signature key usage",
+			headers: func(h *Headers) error {
+				return h.SetX5U("https://example.com/root.der")
+			},
+			wantErr: "x5u: certificate key usage does not permit digital signatures",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := NewSign1Message()
+			msg.Payload = []byte("hello world")
+			msg.Headers.Protected.SetAlgorithm(AlgorithmES256)
+			if err := tt.headers(&msg.Headers); err != nil {
+				t.Fatalf("headers() error = %v", err)
+			}
+			if err := msg.Sign(rand.Reader, nil, signer); err != nil {
+				t.Fatalf("Sign1Message.Sign() error = %v", err)
+			}
+
+			v := NewX509Verifier(roots, x509.VerifyOptions{})
+			v.Fetcher = fetcher
+			got, err := v.VerifySign1(msg, nil)
+			if err != nil && (err.Error() != tt.wantErr) {
+				t.Errorf("X509Verifier.VerifySign1() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			} else if err == nil && tt.wantErr != "" {
+				t.Errorf("X509Verifier.VerifySign1() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr != "" {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("X509Verifier.VerifySign1() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// without fetcher
+	msg := NewSign1Message()
+	msg.Payload = []byte("hello world")
+	msg.Headers.Protected.SetAlgorithm(AlgorithmES256)
+	if err := msg.Headers.SetX5U("https://example.com/leaf.der"); err != nil {
+		t.Fatalf("Headers.SetX5U() error = %v", err)
+	}
+	if err := msg.Sign(rand.Reader, nil, signer); err != nil {
+		t.Fatalf("Sign1Message.Sign() error = %v", err)
+	}
+	wantErr := "header parameter: x5chain: missing certificate chain"
+	if _, err := NewX509Verifier(roots, x509.VerifyOptions{}).VerifySign1(msg, nil); err == nil || err.Error() != wantErr {
+		t.Errorf("X509Verifier.VerifySign1() error = %v, wantErr %v", err, wantErr)
+	}
+	if _, err := fetcher.FetchX5U("https://example.com/unknown.der"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("MapX5UFetcher.FetchX5U() error = %v, want %v", err, fs.ErrNotExist)
+	}
+}